@@ -45,6 +45,12 @@ import (
 )
 
 var (
+	importHistoryWorkersFlag = &cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of Era1 files verified in parallel ahead of their import",
+		Value: runtime.NumCPU(),
+	}
+
 	initCommand = &cli.Command{
 		Action:    initGenesis,
 		Name:      "init",
@@ -132,13 +138,17 @@ be gzipped.`,
 		ArgsUsage: "<dir>",
 		Flags: flags.Merge([]cli.Flag{
 			utils.TxLookupLimitFlag,
+			importHistoryWorkersFlag,
 		},
 			utils.DatabaseFlags,
 			utils.NetworkFlags,
 		),
 		Description: `
 The import-history command will import blocks and their corresponding receipts
-from Era archives.
+from Era archives. Each archive is verified against the checksums.txt file of
+the directory and its accumulator root before being imported, several at a time
+as set by --workers. An interrupted import is resumed from the last block
+imported.
 `,
 	}
 	exportHistoryCommand = &cli.Command{
@@ -149,7 +159,10 @@ from Era archives.
 		Flags:     flags.Merge(utils.DatabaseFlags),
 		Description: `
 The export-history command will export blocks and their corresponding receipts
-into Era archives. Eras are typically packaged in steps of 8192 blocks.
+into Era archives. Eras are typically packaged in steps of 8192 blocks. Any
+range can be exported, each of its epochs to its own archive, replacing the
+previous archive of the epoch in the directory. The checksums.txt file of the
+directory is updated to list all of its archives.
 `,
 	}
 	importPreimagesCommand = &cli.Command{
@@ -449,7 +462,7 @@ func importHistory(ctx *cli.Context) error {
 		network = networks[0]
 	}
 
-	if err := utils.ImportHistory(chain, db, dir, network); err != nil {
+	if err := utils.ImportHistory(chain, db, dir, network, ctx.Int(importHistoryWorkersFlag.Name)); err != nil {
 		return err
 	}
 	fmt.Printf("Import done in %v\n", time.Since(start))
@@ -147,6 +147,11 @@ func loadBaseConfig(ctx *cli.Context) gethConfig {
 
 // makeConfigNode loads geth configuration and creates a blank node instance.
 func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
+	// PluGeth injection: every command, not only the node itself, needs the
+	// database engines, ancient stores and chain specs of plugins.
+	if err := initializePlugins(ctx); err != nil {
+		utils.Fatalf("Failed to load plugins: %v", err)
+	}
 	cfg := loadBaseConfig(ctx)
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
@@ -20,7 +20,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -86,6 +85,8 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolHistoryFlag,
+		utils.TxPoolHistoryRetentionFlag,
 		utils.BlobPoolDataDirFlag,
 		utils.BlobPoolDataCapFlag,
 		utils.BlobPoolPriceBumpFlag,
@@ -96,6 +97,12 @@ var (
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag, // deprecated
 		utils.TransactionHistoryFlag,
+		utils.AddressIndexFlag,
+		utils.AddressIndexLimitFlag,
+		utils.SenderNonceIndexFlag,
+		utils.LogIndexFlag,
+		utils.HistoryEra1Flag,
+		utils.LiveTraceRetentionFlag,
 		utils.StateHistoryFlag,
 		utils.LightServeFlag,    // deprecated
 		utils.LightIngressFlag,  // deprecated
@@ -240,6 +247,10 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See mempoolcmd.go
+		mempoolCommand,
+		// See stateexportcmd.go
+		exportStateCommand,
 		// See verkle.go
 		verkleCommand,
 	}
@@ -340,14 +351,7 @@ func prepare(ctx *cli.Context) {
 // blocking mode, waiting for it to be shut down.
 func geth(ctx *cli.Context) error {
 	//begin PluGeth code injection
-	var pluginsDir string
-	if ctx.IsSet(utils.PluginsDirFlag.Name) {
-		pluginsDir = ctx.String(utils.PluginsDirFlag.Name)
-	} else {
-		pluginsDir = filepath.Join(ctx.String(utils.DataDirFlag.Name), "plugins")
-	}
-
-	if err := plugins.Initialize(pluginsDir, ctx); err != nil {
+	if err := initializePlugins(ctx); err != nil {
 		return err
 	}
 	prepare(ctx)
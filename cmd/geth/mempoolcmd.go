@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	nodeEndpointFlag = &cli.StringFlag{
+		Name:  "endpoint",
+		Usage: "RPC endpoint of the running node (default: the IPC endpoint of the data directory)",
+	}
+	mempoolLocalFlag = &cli.BoolFlag{
+		Name:  "local",
+		Usage: "Add the imported transactions as local ones",
+	}
+
+	mempoolCommand = &cli.Command{
+		Name:  "mempool",
+		Usage: "Export and import the transaction pool of a running node",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export all pooled transactions, legacy and blob, into a file",
+				ArgsUsage: "<filename>",
+				Action:    exportMempool,
+				Flags:     []cli.Flag{utils.DataDirFlag, nodeEndpointFlag},
+				Description: `
+Writes every pending and queued transaction of the running node, blob
+transactions with their sidecars, to the file along with the time each was
+first seen. If the file ends with .gz, the output is gzipped.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a mempool snapshot into the transaction pool",
+				ArgsUsage: "<filename>",
+				Action:    importMempool,
+				Flags:     []cli.Flag{utils.DataDirFlag, nodeEndpointFlag, mempoolLocalFlag},
+				Description: `
+Adds the transactions of a snapshot written by 'geth mempool export' to the
+pool of the running node, keeping the time each was first seen.`,
+			},
+		},
+	}
+)
+
+// dialNode connects to the running node, through the endpoint flag if set,
+// the IPC endpoint of the data directory otherwise.
+func dialNode(ctx *cli.Context) *rpc.Client {
+	endpoint := ctx.String(nodeEndpointFlag.Name)
+	if endpoint == "" {
+		cfg := defaultNodeConfig()
+		utils.SetDataDir(ctx, &cfg)
+		endpoint = cfg.IPCEndpoint()
+	}
+	client, err := utils.DialRPCWithHeaders(endpoint, nil)
+	if err != nil {
+		utils.Fatalf("Unable to attach to geth: %v", err)
+	}
+	return client
+}
+
+// dialMempoolNode connects to the node whose pool is exported or imported,
+// returning the absolute path of the snapshot file for it.
+func dialMempoolNode(ctx *cli.Context) (*rpc.Client, string) {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	file, err := filepath.Abs(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Invalid file name: %v", err)
+	}
+	return dialNode(ctx), file
+}
+
+func exportMempool(ctx *cli.Context) error {
+	client, file := dialMempoolNode(ctx)
+	defer client.Close()
+
+	var count int
+	if err := client.CallContext(context.Background(), &count, "admin_exportMempool", file); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d transactions to %s\n", count, file)
+	return nil
+}
+
+func importMempool(ctx *cli.Context) error {
+	client, file := dialMempoolNode(ctx)
+	defer client.Close()
+
+	var result eth.MempoolImportResult
+	if err := client.CallContext(context.Background(), &result, "admin_importMempool", file, ctx.Bool(mempoolLocalFlag.Name)); err != nil {
+		return err
+	}
+	for hash, reason := range result.Rejected {
+		fmt.Printf("Rejected %x: %s\n", hash, reason)
+	}
+	fmt.Printf("Imported %d transactions, rejected %d\n", result.Imported, len(result.Rejected))
+	return nil
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -10,6 +13,7 @@ import (
 	
 	"github.com/openrelayxyz/plugeth-utils/core"
 	"github.com/openrelayxyz/plugeth-utils/restricted"
+	"github.com/urfave/cli/v2"
 )
 
 func apiTranslate(apis []core.API) []rpc.API {
@@ -122,3 +126,17 @@ func pluginBlockChain() {
 	}
 	BlockChain(plugins.DefaultPluginLoader)
 }
+
+// initializePlugins loads the plugins from the plugin directory, the plugins
+// subdirectory of the data directory by default, unless they are loaded
+// already.
+func initializePlugins(ctx *cli.Context) error {
+	if plugins.DefaultPluginLoader != nil {
+		return nil
+	}
+	pluginsDir := filepath.Join(ctx.String(utils.DataDirFlag.Name), "plugins")
+	if ctx.IsSet(utils.PluginsDirFlag.Name) {
+		pluginsDir = ctx.String(utils.PluginsDirFlag.Name)
+	}
+	return plugins.Initialize(pluginsDir, ctx)
+}
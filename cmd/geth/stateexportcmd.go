@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	stateExportFormatFlag = &cli.StringFlag{
+		Name:  "format",
+		Usage: "Output format of the exported state (ndjson or rlp)",
+		Value: "ndjson",
+	}
+	stateExportLimitFlag = &cli.Uint64Flag{
+		Name:  "limit",
+		Usage: "Maximum number of accounts and storage slots fetched per request",
+		Value: 10000,
+	}
+	stateExportResumeFlag = &cli.BoolFlag{
+		Name:  "resume",
+		Usage: "Resume an interrupted export from its cursor file",
+	}
+
+	exportStateCommand = &cli.Command{
+		Name:      "export-state",
+		Usage:     "Export the full state of a block of a running node",
+		ArgsUsage: "<filename> [<blockNum>|<blockHash>]",
+		Action:    exportState,
+		Flags:     []cli.Flag{utils.DataDirFlag, nodeEndpointFlag, stateExportFormatFlag, stateExportLimitFlag, stateExportResumeFlag},
+		Description: `
+Writes every account of the state of the given block, the latest one by
+default, with its code and storage to the file, in the order of the account
+hashes. Addresses and storage keys are included if their preimages are known.
+
+The file starts with the hash, number and state root of the block, followed by
+the accounts, one JSON object per line or one RLP item each. The storage of a
+large account may be split over several consecutive items, the later ones
+being marked as continued.
+
+The progress is recorded in <filename>.cursor, from which an interrupted export
+can be resumed with --resume, exporting the same block. The cursor file is
+removed once the export is complete.
+
+The node keeps the state of the block while it is exported, unless it uses the
+path based state scheme. Such a node prunes the states older than the most
+recent 128 blocks, ending the export with an error once its block is pruned.
+The cursor file is then removed and the export has to be restarted from a more
+recent block, or from an archive node.`,
+	}
+)
+
+// stateExportHeader is the first item of a state export file.
+type stateExportHeader struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Root        common.Hash    `json:"root"`
+}
+
+// stateExportProgress is the content of the cursor file of a state export,
+// recording where to resume it from.
+type stateExportProgress struct {
+	BlockHash common.Hash   `json:"blockHash"`
+	Format    string        `json:"format"`
+	Cursor    hexutil.Bytes `json:"cursor"`
+	Offset    int64         `json:"offset"`
+}
+
+func exportState(ctx *cli.Context) error {
+	if ctx.Args().Len() < 1 || ctx.Args().Len() > 2 {
+		utils.Fatalf("This command requires one or two arguments.")
+	}
+	var (
+		file       = ctx.Args().First()
+		cursorFile = file + ".cursor"
+		format     = ctx.String(stateExportFormatFlag.Name)
+		limit      = hexutil.Uint64(ctx.Uint64(stateExportLimitFlag.Name))
+		progress   stateExportProgress
+		block      = "latest"
+		out        *os.File
+		err        error
+	)
+	if format != "ndjson" && format != "rlp" {
+		utils.Fatalf("Unknown export format %q", format)
+	}
+	if ctx.Bool(stateExportResumeFlag.Name) {
+		if ctx.Args().Len() == 2 {
+			utils.Fatalf("The block of a resumed export can not be changed.")
+		}
+		blob, err := os.ReadFile(cursorFile)
+		if err != nil {
+			utils.Fatalf("No export to resume: %v", err)
+		}
+		if err := json.Unmarshal(blob, &progress); err != nil {
+			utils.Fatalf("Invalid cursor file: %v", err)
+		}
+		if progress.Format != format {
+			utils.Fatalf("Export was started in %s format", progress.Format)
+		}
+		if out, err = os.OpenFile(file, os.O_WRONLY, 0644); err != nil {
+			return err
+		}
+		// Drop anything written after the last recorded page
+		if err := out.Truncate(progress.Offset); err != nil {
+			return err
+		}
+		if _, err := out.Seek(progress.Offset, io.SeekStart); err != nil {
+			return err
+		}
+		block = progress.BlockHash.Hex()
+	} else {
+		if ctx.Args().Len() == 2 {
+			block = ctx.Args().Get(1)
+			if number, err := strconv.ParseUint(block, 10, 64); err == nil {
+				block = hexutil.EncodeUint64(number)
+			}
+		}
+		if out, err = os.Create(file); err != nil {
+			return err
+		}
+		if err := os.Remove(cursorFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		progress.Format = format
+	}
+	defer out.Close()
+
+	client := dialNode(ctx)
+	defer client.Close()
+
+	var (
+		w        = bufio.NewWriter(out)
+		write    = stateExportWriter(w, format)
+		accounts int
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	for {
+		var page eth.StateExportPage
+		if err := client.CallContext(context.Background(), &page, "debug_exportState", block, progress.Cursor, limit); err != nil {
+			var rpcErr rpc.Error
+			if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == eth.StateExportPrunedCode {
+				// The export can't be resumed, drop its cursor
+				os.Remove(cursorFile)
+				utils.Fatalf("Export interrupted: %v", err)
+			}
+			return err
+		}
+		if progress.Offset == 0 {
+			header := &stateExportHeader{BlockHash: page.BlockHash, BlockNumber: page.BlockNumber, Root: page.Root}
+			if err := write(header); err != nil {
+				return err
+			}
+			log.Info("Exporting state", "number", uint64(page.BlockNumber), "hash", page.BlockHash, "root", page.Root)
+		}
+		// Pin the block, the first page possibly being requested by number
+		block, progress.BlockHash = page.BlockHash.Hex(), page.BlockHash
+
+		for _, account := range page.Accounts {
+			if err := write(account); err != nil {
+				return err
+			}
+			if !account.Continued {
+				accounts++
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if err := out.Sync(); err != nil {
+			return err
+		}
+		if progress.Offset, err = out.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+		if len(page.Cursor) == 0 {
+			break
+		}
+		progress.Cursor = page.Cursor
+		if err := writeStateExportProgress(cursorFile, &progress); err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state in progress", "accounts", accounts, "cursor", progress.Cursor, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := os.Remove(cursorFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Printf("Exported %d accounts to %s\n", accounts, file)
+	return nil
+}
+
+// stateExportWriter returns the function writing the items of a state export
+// in the given format.
+func stateExportWriter(w io.Writer, format string) func(interface{}) error {
+	if format == "rlp" {
+		return func(item interface{}) error {
+			return rlp.Encode(w, item)
+		}
+	}
+	enc := json.NewEncoder(w)
+	return enc.Encode
+}
+
+// writeStateExportProgress atomically replaces the cursor file of a state
+// export.
+func writeStateExportProgress(file string, progress *stateExportProgress) error {
+	blob, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
@@ -19,12 +19,11 @@ package utils
 
 import (
 	"bufio"
-	"bytes"
 	"compress/gzip"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/signal"
 	"path"
@@ -242,11 +241,10 @@ func readList(filename string) ([]string, error) {
 }
 
 // ImportHistory imports Era1 files containing historical block information,
-// starting from genesis.
-func ImportHistory(chain *core.BlockChain, db ethdb.Database, dir string, network string) error {
-	if chain.CurrentSnapBlock().Number.BitLen() != 0 {
-		return fmt.Errorf("history import only supported when starting from genesis")
-	}
+// starting from genesis or, to resume an interrupted import, from the current
+// snap block. The files are verified against their checksums and accumulator
+// roots by the given number of workers, ahead of their import.
+func ImportHistory(chain *core.BlockChain, db ethdb.Database, dir string, network string, workers int) error {
 	entries, err := era.ReadDir(dir, network)
 	if err != nil {
 		return fmt.Errorf("error reading %s: %w", dir, err)
@@ -258,77 +256,143 @@ func ImportHistory(chain *core.BlockChain, db ethdb.Database, dir string, networ
 	if len(checksums) != len(entries) {
 		return fmt.Errorf("expected equal number of checksums and entries, have: %d checksums, %d entries", len(checksums), len(entries))
 	}
+	if workers < 1 {
+		workers = 1
+	}
+	// Skip the eras imported already.
+	head := chain.CurrentSnapBlock().Number.Uint64()
+	for len(entries) > 0 {
+		e, err := era.Open(path.Join(dir, entries[0]))
+		if err != nil {
+			return fmt.Errorf("error opening era %s: %w", entries[0], err)
+		}
+		last := e.Start() + e.Count() - 1
+		e.Close()
+		if last > head {
+			break
+		}
+		entries, checksums = entries[1:], checksums[1:]
+	}
+	if head > 0 {
+		log.Info("Resuming history import", "head", head, "eras", len(entries))
+	}
+	// Verify the eras in parallel, at most a few ahead of the import.
+	var (
+		results = make([]chan error, len(entries))
+		tasks   = make(chan int)
+		slots   = make(chan struct{}, 2*workers)
+		quit    = make(chan struct{})
+	)
+	defer close(quit)
+
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+	go func() {
+		defer close(tasks)
+		for i := range entries {
+			select {
+			case slots <- struct{}{}:
+			case <-quit:
+				return
+			}
+			select {
+			case tasks <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for n := 0; n < workers; n++ {
+		go func() {
+			for i := range tasks {
+				results[i] <- verifyEra1(path.Join(dir, entries[i]), checksums[i])
+			}
+		}()
+	}
 	var (
 		start    = time.Now()
 		reported = time.Now()
 		imported = 0
 		forker   = core.NewForkChoice(chain, nil)
-		h        = sha256.New()
-		buf      = bytes.NewBuffer(nil)
+		blocks   []*types.Block
+		receipts []types.Receipts
 	)
+	// flush inserts the pending blocks, with their receipts.
+	flush := func() error {
+		if len(blocks) == 0 {
+			return nil
+		}
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		first := blocks[0].NumberU64()
+		if status, err := chain.HeaderChain().InsertHeaderChain(headers, start, forker); err != nil {
+			return fmt.Errorf("error inserting headers from %d: %w", first, err)
+		} else if status != core.CanonStatTy {
+			return fmt.Errorf("error inserting headers from %d, not canon: %v", first, status)
+		}
+		// Freeze the blocks if the freezer holds all the previous ones.
+		var ancientLimit uint64
+		if frozen, err := db.Ancients(); err == nil && (frozen == first || (frozen == 0 && first == 1)) {
+			ancientLimit = math.MaxUint64
+		}
+		if _, err := chain.InsertReceiptChain(blocks, receipts, ancientLimit); err != nil {
+			return fmt.Errorf("error inserting bodies from %d: %w", first, err)
+		}
+		imported += len(blocks)
+		blocks, receipts = blocks[:0], receipts[:0]
+
+		// Give the user some feedback that something is happening.
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Importing Era files", "head", first+uint64(len(headers))-1, "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+			imported = 0
+			reported = time.Now()
+		}
+		return nil
+	}
 	for i, filename := range entries {
+		if err := <-results[i]; err != nil {
+			return fmt.Errorf("invalid era %s: %w", filename, err)
+		}
 		err := func() error {
-			f, err := os.Open(path.Join(dir, filename))
-			if err != nil {
-				return fmt.Errorf("unable to open era: %w", err)
-			}
-			defer f.Close()
-
-			// Validate checksum.
-			if _, err := io.Copy(h, f); err != nil {
-				return fmt.Errorf("unable to recalculate checksum: %w", err)
-			}
-			if have, want := common.BytesToHash(h.Sum(buf.Bytes()[:])).Hex(), checksums[i]; have != want {
-				return fmt.Errorf("checksum mismatch: have %s, want %s", have, want)
-			}
-			h.Reset()
-			buf.Reset()
-
-			// Import all block data from Era1.
-			e, err := era.From(f)
+			e, err := era.Open(path.Join(dir, filename))
 			if err != nil {
 				return fmt.Errorf("error opening era: %w", err)
 			}
+			defer e.Close()
+
 			it, err := era.NewIterator(e)
 			if err != nil {
 				return fmt.Errorf("error making era reader: %w", err)
 			}
 			for it.Next() {
-				block, err := it.Block()
+				block, blockReceipts, err := it.BlockAndReceipts()
 				if err != nil {
 					return fmt.Errorf("error reading block %d: %w", it.Number(), err)
 				}
-				if block.Number().BitLen() == 0 {
-					continue // skip genesis
-				}
-				receipts, err := it.Receipts()
-				if err != nil {
-					return fmt.Errorf("error reading receipts %d: %w", it.Number(), err)
-				}
-				if status, err := chain.HeaderChain().InsertHeaderChain([]*types.Header{block.Header()}, start, forker); err != nil {
-					return fmt.Errorf("error inserting header %d: %w", it.Number(), err)
-				} else if status != core.CanonStatTy {
-					return fmt.Errorf("error inserting header %d, not canon: %v", it.Number(), status)
-				}
-				if _, err := chain.InsertReceiptChain([]*types.Block{block}, []types.Receipts{receipts}, 2^64-1); err != nil {
-					return fmt.Errorf("error inserting body %d: %w", it.Number(), err)
+				if number := block.NumberU64(); number <= head {
+					// Imported already, or the genesis
+					if hash := chain.GetCanonicalHash(number); hash != block.Hash() {
+						return fmt.Errorf("block %d conflicts with local chain: have %s, want %s", number, hash, block.Hash())
+					}
+					continue
 				}
-				imported += 1
-
-				// Give the user some feedback that something is happening.
-				if time.Since(reported) >= 8*time.Second {
-					log.Info("Importing Era files", "head", it.Number(), "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
-					imported = 0
-					reported = time.Now()
+				blocks, receipts = append(blocks, block), append(receipts, blockReceipts)
+				if len(blocks) >= importBatchSize {
+					if err := flush(); err != nil {
+						return err
+					}
 				}
 			}
-			return nil
+			return flush()
 		}()
 		if err != nil {
 			return err
 		}
+		<-slots
 	}
-
 	return nil
 }
 
@@ -402,7 +466,9 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 }
 
 // ExportHistory exports blockchain history into the specified directory,
-// following the Era format.
+// following the Era format. Each epoch of the range is exported to its own
+// Era1 file, replacing any previous export of it, and the checksums.txt file
+// is updated to list all the Era1 files of the directory.
 func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) error {
 	log.Info("Exporting blockchain history", "dir", dir)
 	if head := bc.CurrentBlock().Number.Uint64(); head < last {
@@ -419,13 +485,21 @@ func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) er
 	var (
 		start     = time.Now()
 		reported  = time.Now()
-		h         = sha256.New()
-		buf       = bytes.NewBuffer(nil)
-		checksums []string
+		checksums = readChecksums(dir, network)
 	)
-	for i := first; i <= last; i += step {
+	for epoch := first / step; epoch <= last/step; epoch++ {
+		from, to := epoch*step, (epoch+1)*step-1
+		if from < first {
+			from = first
+		}
+		if to > last {
+			to = last
+		}
 		err := func() error {
-			filename := path.Join(dir, era.Filename(network, int(i/step), common.Hash{}))
+			if err := removeEra1(dir, network, epoch); err != nil {
+				return err
+			}
+			filename := path.Join(dir, era.Filename(network, int(epoch), common.Hash{}))
 			f, err := os.Create(filename)
 			if err != nil {
 				return fmt.Errorf("could not create era file: %w", err)
@@ -433,11 +507,8 @@ func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) er
 			defer f.Close()
 
 			w := era.NewBuilder(f)
-			for j := uint64(0); j < step && j <= last-i; j++ {
-				var (
-					n     = i + j
-					block = bc.GetBlockByNumber(n)
-				)
+			for n := from; n <= to; n++ {
+				block := bc.GetBlockByNumber(n)
 				if block == nil {
 					return fmt.Errorf("export failed on #%d: not found", n)
 				}
@@ -455,34 +526,30 @@ func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) er
 			}
 			root, err := w.Finalize()
 			if err != nil {
-				return fmt.Errorf("export failed to finalize %d: %w", step/i, err)
+				return fmt.Errorf("export failed to finalize %d: %w", epoch, err)
 			}
 			// Set correct filename with root.
-			os.Rename(filename, path.Join(dir, era.Filename(network, int(i/step), root)))
-
-			// Compute checksum of entire Era1.
-			if _, err := f.Seek(0, io.SeekStart); err != nil {
-				return err
+			name := era.Filename(network, int(epoch), root)
+			if err := os.Rename(filename, path.Join(dir, name)); err != nil {
+				return fmt.Errorf("unable to rename era: %w", err)
 			}
-			if _, err := io.Copy(h, f); err != nil {
+			// Compute checksum of entire Era1.
+			if checksums[name], err = era1Checksum(f); err != nil {
 				return fmt.Errorf("unable to calculate checksum: %w", err)
 			}
-			checksums = append(checksums, common.BytesToHash(h.Sum(buf.Bytes()[:])).Hex())
-			h.Reset()
-			buf.Reset()
 			return nil
 		}()
 		if err != nil {
 			return err
 		}
 		if time.Since(reported) >= 8*time.Second {
-			log.Info("Exporting blocks", "exported", i, "elapsed", common.PrettyDuration(time.Since(start)))
+			log.Info("Exporting blocks", "exported", to, "elapsed", common.PrettyDuration(time.Since(start)))
 			reported = time.Now()
 		}
 	}
-
-	os.WriteFile(path.Join(dir, "checksums.txt"), []byte(strings.Join(checksums, "\n")), os.ModePerm)
-
+	if err := writeChecksums(dir, network, checksums); err != nil {
+		return fmt.Errorf("unable to write checksums: %w", err)
+	}
 	log.Info("Exported blockchain to", "dir", dir)
 
 	return nil
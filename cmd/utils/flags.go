@@ -107,10 +107,17 @@ var (
 	}
 	DBEngineFlag = &cli.StringFlag{
 		Name:     "db.engine",
-		Usage:    "Backing database implementation to use ('pebble' or 'leveldb')",
+		Usage:    "Backing database implementation to use ('pebble', 'leveldb' or an engine provided by a plugin)",
 		Value:    node.DefaultConfig.DBEngine,
 		Category: flags.EthCategory,
 	}
+	// begin PluGeth code injection
+	DBAncientEngineFlag = &cli.StringFlag{
+		Name:     "db.ancient.engine",
+		Usage:    "Plugin provided engine storing the ancient chain segments (default: flat file freezer)",
+		Category: flags.EthCategory,
+	}
+	// end PluGeth code injection
 	AncientFlag = &flags.DirectoryFlag{
 		Name:     "datadir.ancient",
 		Usage:    "Root directory for ancient data (default = inside chaindata)",
@@ -289,6 +296,37 @@ var (
 		Value:    ethconfig.Defaults.TransactionHistory,
 		Category: flags.StateCategory,
 	}
+	AddressIndexFlag = &cli.BoolFlag{
+		Name:     "history.addresses",
+		Usage:    "Index the transactions and internal calls involving each address, queryable with eth_getAddressActivity (slows down block processing)",
+		Category: flags.StateCategory,
+	}
+	AddressIndexLimitFlag = &cli.Uint64Flag{
+		Name:     "history.addresses.limit",
+		Usage:    "Number of recent blocks to maintain the address activity index for (0 = entire chain)",
+		Category: flags.StateCategory,
+	}
+	SenderNonceIndexFlag = &cli.BoolFlag{
+		Name:     "history.sendernonces",
+		Usage:    "Index the transactions by sender and nonce over the transaction history, queryable with eth_getTransactionBySenderAndNonce",
+		Category: flags.StateCategory,
+	}
+	LogIndexFlag = &cli.BoolFlag{
+		Name:     "history.logindex",
+		Usage:    "Index the blocks holding each log address and topic, speeding up eth_getLogs over wide ranges",
+		Category: flags.StateCategory,
+	}
+	HistoryEra1Flag = &flags.DirectoryFlag{
+		Name:     "history.era1",
+		Usage:    "Directory of Era1 files to serve the pre-merge block bodies and receipts from, pruning them from the ancient store",
+		Category: flags.StateCategory,
+	}
+	LiveTraceRetentionFlag = &cli.Uint64Flag{
+		Name:     "history.livetraces",
+		Usage:    "Number of recent blocks to keep the stored live tracer results for (default = 90,000 blocks, 0 = entire chain)",
+		Value:    ethconfig.Defaults.LiveTraceRetention,
+		Category: flags.StateCategory,
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = &cli.StringFlag{
 		Name:     "txpool.locals",
@@ -354,6 +392,17 @@ var (
 		Value:    ethconfig.Defaults.TxPool.Lifetime,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolHistoryFlag = &cli.BoolFlag{
+		Name:     "txpool.history",
+		Usage:    "Record the history of the pool's transactions, queryable with the txpool_history* RPC methods",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolHistoryRetentionFlag = &cli.DurationFlag{
+		Name:     "txpool.history.retention",
+		Usage:    "Amount of time the pool's transaction history is kept for (0 = forever)",
+		Value:    ethconfig.Defaults.TxPoolHistoryRetention,
+		Category: flags.TxPoolCategory,
+	}
 	// Blob transaction pool settings
 	BlobPoolDataDirFlag = &cli.StringFlag{
 		Name:     "blobpool.datadir",
@@ -934,6 +983,7 @@ var (
 		AncientFlag,
 		RemoteDBFlag,
 		DBEngineFlag,
+		DBAncientEngineFlag, // PluGeth injection
 		StateSchemeFlag,
 		HttpHeaderFlag,
 	}
@@ -1400,12 +1450,22 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	}
 	if ctx.IsSet(DBEngineFlag.Name) {
 		dbEngine := ctx.String(DBEngineFlag.Name)
-		if dbEngine != "leveldb" && dbEngine != "pebble" {
-			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", dbEngine)
+		if dbEngine != "leveldb" && dbEngine != "pebble" && !rawdb.HasPluginDatabaseEngine(dbEngine) { // PluGeth injection
+			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb', 'pebble' or an engine provided by a plugin", dbEngine)
 		}
 		log.Info(fmt.Sprintf("Using %s as db engine", dbEngine))
 		cfg.DBEngine = dbEngine
 	}
+	// begin PluGeth code injection
+	if ctx.IsSet(DBAncientEngineFlag.Name) {
+		engine := ctx.String(DBAncientEngineFlag.Name)
+		if !rawdb.HasPluginAncientEngine(engine) {
+			Fatalf("Invalid choice for db.ancient.engine '%s', no plugin provides it", engine)
+		}
+		log.Info(fmt.Sprintf("Using %s as ancient engine", engine))
+		cfg.DBAncientEngine = engine
+	}
+	// end PluGeth code injection
 	// deprecation notice for log debug flags (TODO: find a more appropriate place to put these?)
 	if ctx.IsSet(LogBacktraceAtFlag.Name) {
 		log.Warn("log.backtrace flag is deprecated")
@@ -1513,6 +1573,15 @@ func setTxPool(ctx *cli.Context, cfg *legacypool.Config) {
 	}
 }
 
+func setTxPoolHistory(ctx *cli.Context, cfg *ethconfig.Config) {
+	if ctx.IsSet(TxPoolHistoryFlag.Name) {
+		cfg.TxPoolHistory = ctx.Bool(TxPoolHistoryFlag.Name)
+	}
+	if ctx.IsSet(TxPoolHistoryRetentionFlag.Name) {
+		cfg.TxPoolHistoryRetention = ctx.Duration(TxPoolHistoryRetentionFlag.Name)
+	}
+}
+
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.IsSet(MinerExtraDataFlag.Name) {
 		cfg.ExtraData = []byte(ctx.String(MinerExtraDataFlag.Name))
@@ -1610,6 +1679,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, cfg)
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
+	setTxPoolHistory(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
 	setLes(ctx, cfg)
@@ -1701,6 +1771,24 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		cfg.TransactionHistory = 0
 		log.Warn("Disabled transaction unindexing for archive node")
 	}
+	if ctx.IsSet(AddressIndexFlag.Name) {
+		cfg.AddressIndex = ctx.Bool(AddressIndexFlag.Name)
+	}
+	if ctx.IsSet(AddressIndexLimitFlag.Name) {
+		cfg.AddressIndexLimit = ctx.Uint64(AddressIndexLimitFlag.Name)
+	}
+	if ctx.IsSet(SenderNonceIndexFlag.Name) {
+		cfg.SenderNonceIndex = ctx.Bool(SenderNonceIndexFlag.Name)
+	}
+	if ctx.IsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.Bool(LogIndexFlag.Name)
+	}
+	if ctx.IsSet(HistoryEra1Flag.Name) {
+		cfg.HistoryEra1Dir = ctx.String(HistoryEra1Flag.Name)
+	}
+	if ctx.IsSet(LiveTraceRetentionFlag.Name) {
+		cfg.LiveTraceRetention = ctx.Uint64(LiveTraceRetentionFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -1906,7 +1994,7 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 	if err != nil {
 		Fatalf("Failed to register the Ethereum service: %v", err)
 	}
-	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	tracers.Register(stack, backend.APIBackend)
 	return backend.APIBackend, backend
 }
 
@@ -2147,6 +2235,12 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
 		StateScheme:         scheme,
 		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
+		AddressIndex:        ctx.Bool(AddressIndexFlag.Name),
+		AddressIndexLimit:   ctx.Uint64(AddressIndexLimitFlag.Name),
+		SenderNonceIndex:    ctx.Bool(SenderNonceIndexFlag.Name),
+		LogIndex:            ctx.Bool(LogIndexFlag.Name),
+		HistoryEra1Dir:      ctx.String(HistoryEra1Flag.Name),
+		LiveTraceRetention:  ctx.Uint64(LiveTraceRetentionFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
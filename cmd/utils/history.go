@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// listEra1 returns the names of the Era1 files of a network held in a
+// directory, in epoch order. Unlike era.ReadDir, the epochs need not be
+// contiguous nor start at genesis.
+func listEra1(dir, network string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %w", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if path.Ext(entry.Name()) != ".era1" {
+			continue
+		}
+		if parts := strings.Split(entry.Name(), "-"); len(parts) == 3 && parts[0] == network {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// removeEra1 deletes the Era1 files of a network held in a directory for the
+// given epoch, so that it can be exported anew.
+func removeEra1(dir, network string, epoch uint64) error {
+	names, err := listEra1(dir, network)
+	if err != nil {
+		return err
+	}
+	prefix := fmt.Sprintf("%s-%05d-", network, epoch)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			if err := os.Remove(path.Join(dir, name)); err != nil {
+				return fmt.Errorf("unable to remove era %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readChecksums returns the checksums listed in the checksums.txt file of a
+// directory by Era1 file name, if they are consistent with its files.
+func readChecksums(dir, network string) map[string]string {
+	checksums := make(map[string]string)
+	names, err := listEra1(dir, network)
+	if err != nil {
+		return checksums
+	}
+	list, err := readList(path.Join(dir, "checksums.txt"))
+	if err != nil || len(list) != len(names) {
+		return checksums
+	}
+	for i, name := range names {
+		checksums[name] = list[i]
+	}
+	return checksums
+}
+
+// writeChecksums writes the checksums.txt file of a directory, listing the
+// checksums of its Era1 files in epoch order. The known checksums are reused,
+// the others computed.
+func writeChecksums(dir, network string, known map[string]string) error {
+	names, err := listEra1(dir, network)
+	if err != nil {
+		return err
+	}
+	checksums := make([]string, len(names))
+	for i, name := range names {
+		if checksum, ok := known[name]; ok {
+			checksums[i] = checksum
+			continue
+		}
+		f, err := os.Open(path.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("unable to open era: %w", err)
+		}
+		checksums[i], err = era1Checksum(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to calculate checksum of %s: %w", name, err)
+		}
+	}
+	return os.WriteFile(path.Join(dir, "checksums.txt"), []byte(strings.Join(checksums, "\n")), os.ModePerm)
+}
+
+// era1Checksum returns the sha256 checksum of an Era1 file, read from the
+// start.
+func era1Checksum(f io.ReadSeeker) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return common.BytesToHash(h.Sum(nil)).Hex(), nil
+}
+
+// verifyEra1 checks an Era1 file against its checksum and the accumulator
+// root abbreviated in its name, recomputing the root from its blocks, whose
+// transactions and receipts are checked against their headers.
+func verifyEra1(filename, checksum string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("unable to open era: %w", err)
+	}
+	defer f.Close()
+
+	if have, err := era1Checksum(f); err != nil {
+		return fmt.Errorf("unable to recalculate checksum: %w", err)
+	} else if have != checksum {
+		return fmt.Errorf("checksum mismatch: have %s, want %s", have, checksum)
+	}
+	e, err := era.From(f)
+	if err != nil {
+		return fmt.Errorf("error opening era: %w", err)
+	}
+	want, err := e.Accumulator()
+	if err != nil {
+		return fmt.Errorf("error reading accumulator: %w", err)
+	}
+	parts := strings.Split(strings.TrimSuffix(path.Base(filename), ".era1"), "-")
+	if len(parts) != 3 || parts[2] != want.Hex()[2:10] {
+		return fmt.Errorf("accumulator root %s does not match file name", want)
+	}
+	td, err := e.InitialTD()
+	if err != nil {
+		return fmt.Errorf("error reading total difficulty: %w", err)
+	}
+	it, err := era.NewIterator(e)
+	if err != nil {
+		return fmt.Errorf("error making era reader: %w", err)
+	}
+	var (
+		hashes = make([]common.Hash, 0, e.Count())
+		tds    = make([]*big.Int, 0, e.Count())
+	)
+	for it.Next() {
+		block, receipts, err := it.BlockAndReceipts()
+		if err != nil {
+			return fmt.Errorf("error reading block %d: %w", it.Number(), err)
+		}
+		if root := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); root != block.TxHash() {
+			return fmt.Errorf("tx root in block %d mismatch: have %s, want %s", block.NumberU64(), root, block.TxHash())
+		}
+		if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+			return fmt.Errorf("receipt root in block %d mismatch: have %s, want %s", block.NumberU64(), root, block.ReceiptHash())
+		}
+		hashes = append(hashes, block.Hash())
+		td.Add(td, block.Difficulty())
+		tds = append(tds, new(big.Int).Set(td))
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("error reading era: %w", err)
+	}
+	have, err := era.ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return fmt.Errorf("error computing accumulator: %w", err)
+	}
+	if have != want {
+		return fmt.Errorf("accumulator root mismatch: have %s, want %s", have, want)
+	}
+	return nil
+}
@@ -176,10 +176,119 @@ func TestHistoryImportAndExport(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unable to initialize chain: %v", err)
 	}
-	if err := ImportHistory(imported, db2, dir, "mainnet"); err != nil {
+	if err := ImportHistory(imported, db2, dir, "mainnet", 4); err != nil {
 		t.Fatalf("failed to import chain: %v", err)
 	}
 	if have, want := imported.CurrentHeader(), chain.CurrentHeader(); have.Hash() != want.Hash() {
 		t.Fatalf("imported chain does not match expected, have (%d, %s) want (%d, %s)", have.Number, have.Hash(), want.Number, want.Hash())
 	}
 }
+
+func TestHistoryExportRangeAndResume(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	db, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), int(count), func(i int, g *core.BlockGen) {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   genesis.Config.ChainID,
+			Nonce:     uint64(i),
+			GasTipCap: common.Big0,
+			GasFeeCap: g.PrevBlock(-1).BaseFee(),
+			Gas:       50000,
+			To:        &common.Address{0xaa},
+			Value:     big.NewInt(int64(i)),
+		})
+		if err != nil {
+			t.Fatalf("error creating tx: %v", err)
+		}
+		g.AddTx(tx)
+	})
+	chain, err := core.NewBlockChain(db, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to initialize chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("error insterting chain: %v", err)
+	}
+	// Export the history in two ranges, the second replacing the
+	// partial epoch of the first.
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, 0, 40, step); err != nil {
+		t.Fatalf("error exporting history: %v", err)
+	}
+	if err := ExportHistory(chain, dir, 2*step, count, step); err != nil {
+		t.Fatalf("error exporting history: %v", err)
+	}
+	entries, err := era.ReadDir(dir, "mainnet")
+	if err != nil {
+		t.Fatalf("error reading era dir: %v", err)
+	}
+	checksums, err := readList(path.Join(dir, "checksums.txt"))
+	if err != nil {
+		t.Fatalf("failed to read checksums: %v", err)
+	}
+	if have, want := len(entries), int(count/step)+1; have != want {
+		t.Fatalf("era count mismatch: have %d, want %d", have, want)
+	}
+	if len(checksums) != len(entries) {
+		t.Fatalf("checksum count mismatch: have %d, want %d", len(checksums), len(entries))
+	}
+	for i, name := range entries {
+		if err := verifyEra1(path.Join(dir, name), checksums[i]); err != nil {
+			t.Fatalf("era %s invalid: %v", name, err)
+		}
+	}
+	// A file whose name does not match its accumulator root is rejected.
+	bad := path.Join(t.TempDir(), era.Filename("mainnet", 0, common.Hash{}))
+	data, _ := os.ReadFile(path.Join(dir, entries[0]))
+	if err := os.WriteFile(bad, data, 0644); err != nil {
+		t.Fatalf("failed to copy era: %v", err)
+	}
+	if err := verifyEra1(bad, checksums[0]); err == nil {
+		t.Fatalf("era with mismatched name accepted")
+	}
+	// Import the first eras, then resume from them with the full directory.
+	partial := t.TempDir()
+	for _, name := range entries[:3] {
+		data, _ := os.ReadFile(path.Join(dir, name))
+		if err := os.WriteFile(path.Join(partial, name), data, 0644); err != nil {
+			t.Fatalf("failed to copy era: %v", err)
+		}
+	}
+	if err := os.WriteFile(path.Join(partial, "checksums.txt"), []byte(strings.Join(checksums[:3], "\n")), 0644); err != nil {
+		t.Fatalf("failed to write checksums: %v", err)
+	}
+	db2, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		db2.Close()
+	})
+	genesis.MustCommit(db2, triedb.NewDatabase(db2, triedb.HashDefaults))
+	imported, err := core.NewBlockChain(db2, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to initialize chain: %v", err)
+	}
+	if err := ImportHistory(imported, db2, partial, "mainnet", 2); err != nil {
+		t.Fatalf("failed to import partial history: %v", err)
+	}
+	if have, want := imported.CurrentSnapBlock().Number.Uint64(), 3*step-1; have != want {
+		t.Fatalf("partial import head mismatch: have %d, want %d", have, want)
+	}
+	if err := ImportHistory(imported, db2, dir, "mainnet", 2); err != nil {
+		t.Fatalf("failed to resume history import: %v", err)
+	}
+	if have, want := imported.CurrentHeader(), chain.CurrentHeader(); have.Hash() != want.Hash() {
+		t.Fatalf("imported chain does not match expected, have (%d, %s) want (%d, %s)", have.Number, have.Hash(), want.Number, want.Hash())
+	}
+	if receipts := imported.GetReceiptsByHash(chain.CurrentHeader().Hash()); len(receipts) != 1 {
+		t.Fatalf("receipts of head not imported: have %d", len(receipts))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/plugins"
 )
 
@@ -44,6 +45,9 @@ func PluginSetBootStrapNodes(pl *plugins.PluginLoader) []string {
 }
 
 func pluginSetBootstrapNodes() []string {
+	if spec := pluginChainSpec(); spec != nil && spec.Bootnodes != nil {
+		return spec.Bootnodes
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting pluginSetBootStrapNodes, but default PluginLoader has not been initialized")
 		return nil
@@ -66,6 +70,9 @@ func PluginNetworkId(pl *plugins.PluginLoader) *uint64 {
 }
 
 func pluginNetworkId() *uint64 {
+	if spec := pluginChainSpec(); spec != nil && spec.NetworkID != nil {
+		return spec.NetworkID
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting pluginNetworkID, but default PluginLoader has not been initialized")
 		return nil
@@ -88,6 +95,9 @@ func PluginETHDiscoveryURLs(pl *plugins.PluginLoader, mode bool) []string {
 }
 
 func pluginETHDiscoveryURLs(mode bool) []string {
+	if spec := pluginChainSpec(); spec != nil && spec.EthDiscoveryURLs != nil {
+		return spec.EthDiscoveryURLs
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting pluginETHDiscoveryURLs, but default PluginLoader has not been initialized")
 		return nil
@@ -110,6 +120,9 @@ func PluginSnapDiscoveryURLs(pl *plugins.PluginLoader) []string {
 }
 
 func pluginSnapDiscoveryURLs() []string {
+	if spec := pluginChainSpec(); spec != nil && spec.SnapDiscoveryURLs != nil {
+		return spec.SnapDiscoveryURLs
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting PluginSnapDiscoveryURLs, but default PluginLoader has not been initialized")
 		return nil
@@ -131,9 +144,33 @@ func PluginGenesisBlock(pl *plugins.PluginLoader) *core.Genesis {
 }
 
 func pluginGenesisBlock() *core.Genesis {
+	if spec := pluginChainSpec(); spec != nil && len(spec.Genesis) > 0 {
+		var genesis core.Genesis
+		if err := json.Unmarshal(spec.Genesis, &genesis); err != nil {
+			Fatalf("Invalid plugin chain spec genesis: %v", err)
+		}
+		if genesis.Config != nil {
+			genesis.Config = spec.Apply(genesis.Config)
+		}
+		return &genesis
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting PluginGenesisBlock, but default PluginLoader has not been initialized")
 		return nil
 	}
 	return PluginGenesisBlock(plugins.DefaultPluginLoader)
 }
+
+// pluginChainSpec returns the chain spec provided through the ChainSpec hook,
+// refusing to start with an invalid one.
+func pluginChainSpec() *params.ChainSpec {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting pluginChainSpec, but default PluginLoader has not been initialized")
+		return nil
+	}
+	spec, err := params.PluginChainSpec(plugins.DefaultPluginLoader)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	return spec
+}
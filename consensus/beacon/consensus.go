@@ -347,12 +347,19 @@ func (beacon *Beacon) Prepare(chain consensus.ChainHeaderReader, header *types.H
 	return nil
 }
 
+// balanceChangeWithdrawal labels withdrawal credits for live tracers, it is
+// declared here as the state package is shadowed inside Finalize.
+const balanceChangeWithdrawal = state.BalanceChangeWithdrawal
+
 // Finalize implements consensus.Engine and processes withdrawals on top.
 func (beacon *Beacon) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, withdrawals []*types.Withdrawal) {
 	if !beacon.IsPoSHeader(header) {
 		beacon.ethone.Finalize(chain, header, state, txs, uncles, nil)
 		return
 	}
+	// begin PluGeth code injection
+	defer state.SetBalanceChangeReason(balanceChangeWithdrawal)()
+	// end PluGeth code injection
 	// Withdrawals processing.
 	for _, w := range withdrawals {
 		// Convert amount from gwei to wei.
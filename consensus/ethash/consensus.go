@@ -570,7 +570,7 @@ var (
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward. The total reward consists of the static block reward and rewards for
 // included uncles. The coinbase of each uncle block is also rewarded.
-func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
+func accumulateRewards(config *params.ChainConfig, statedb *state.StateDB, header *types.Header, uncles []*types.Header) {
 	// Select the correct block reward based on chain progression
 	blockReward := FrontierBlockReward
 	if config.IsByzantium(header.Number) {
@@ -589,10 +589,17 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 		r.Sub(r, hNum)
 		r.Mul(r, blockReward)
 		r.Div(r, u256_8)
-		state.AddBalance(uncle.Coinbase, r)
+		// begin PluGeth code injection
+		restore := statedb.SetBalanceChangeReason(state.BalanceChangeUncleReward)
+		statedb.AddBalance(uncle.Coinbase, r)
+		restore()
+		// end PluGeth code injection
 
 		r.Div(blockReward, u256_32)
 		reward.Add(reward, r)
 	}
-	state.AddBalance(header.Coinbase, reward)
+	// begin PluGeth code injection
+	defer statedb.SetBalanceChangeReason(state.BalanceChangeBlockReward)()
+	// end PluGeth code injection
+	statedb.AddBalance(header.Coinbase, reward)
 }
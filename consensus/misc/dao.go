@@ -79,6 +79,9 @@ func ApplyDAOHardFork(statedb *state.StateDB) {
 		statedb.CreateAccount(params.DAORefundContract)
 	}
 
+	// begin PluGeth code injection
+	defer statedb.SetBalanceChangeReason(state.BalanceChangeDAORefund)()
+	// end PluGeth code injection
 	// Move every DAO account and extra-balance account funds into the refund contract
 	for _, addr := range params.DAODrainList() {
 		statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr))
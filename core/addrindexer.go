@@ -0,0 +1,426 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// AddressIndexProgress is the progress of the address activity indexing.
+type AddressIndexProgress struct {
+	Tail         uint64 // First block whose activity is indexed
+	Head         uint64 // Last block whose activity is indexed
+	Remaining    uint64 // Number of blocks up to the chain head not indexed yet
+	InternalTail uint64 // First block whose internal calls are indexed, past Head if none are
+}
+
+// addrIndexer maintains the index of the transactions involving an address,
+// as sender, recipient, created contract or internal call target, in the
+// background. The internal calls of a block are only known if it was
+// processed while the index was enabled, the blocks backfilled or synced
+// before are indexed without them.
+type addrIndexer struct {
+	// limit is the maximum number of blocks from head whose activity is
+	// indexed, 0 meaning the entire chain.
+	limit    uint64
+	db       ethdb.Database
+	config   *params.ChainConfig
+	progress chan chan AddressIndexProgress
+	term     chan chan struct{}
+	closed   chan struct{}
+}
+
+// newAddrIndexer initializes the address activity indexer.
+func newAddrIndexer(limit uint64, chain *BlockChain) *addrIndexer {
+	indexer := &addrIndexer{
+		limit:    limit,
+		db:       chain.db,
+		config:   chain.chainConfig,
+		progress: make(chan chan AddressIndexProgress),
+		term:     make(chan chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go indexer.loop(chain)
+
+	var msg string
+	if limit == 0 {
+		msg = "entire chain"
+	} else {
+		msg = fmt.Sprintf("last %d blocks", limit)
+	}
+	log.Info("Initialized address activity indexer", "range", msg)
+
+	return indexer
+}
+
+// loop schedules the indexing of new chain heads.
+func (indexer *addrIndexer) loop(chain *BlockChain) {
+	defer close(indexer.closed)
+
+	var (
+		stop     chan struct{} // Non-nil if background routine is active.
+		done     chan struct{} // Non-nil if background routine is active.
+		lastHead uint64        // The latest announced chain head
+		pending  bool          // Whether a head arrived while indexing
+
+		headCh = make(chan ChainHeadEvent)
+		sub    = chain.SubscribeChainHeadEvent(headCh)
+	)
+	defer sub.Unsubscribe()
+
+	start := func() {
+		stop = make(chan struct{})
+		done = make(chan struct{})
+		go indexer.run(lastHead, stop, done)
+	}
+	if head := rawdb.ReadHeadBlock(indexer.db); head != nil && head.NumberU64() != 0 {
+		lastHead = head.NumberU64()
+		start()
+	}
+	for {
+		select {
+		case head := <-headCh:
+			lastHead = head.Block.NumberU64()
+			if done == nil {
+				start()
+			} else {
+				pending = true
+			}
+		case <-done:
+			stop, done = nil, nil
+			if pending {
+				pending = false
+				start()
+			}
+		case ch := <-indexer.progress:
+			ch <- indexer.report(lastHead)
+		case ch := <-indexer.term:
+			if stop != nil {
+				close(stop)
+			}
+			if done != nil {
+				log.Info("Waiting background address indexer to exit")
+				<-done
+			}
+			close(ch)
+			return
+		}
+	}
+}
+
+// first returns the first block whose activity should be indexed.
+func (indexer *addrIndexer) first(head uint64) uint64 {
+	if indexer.limit == 0 || head < indexer.limit {
+		return 0
+	}
+	return head - indexer.limit + 1
+}
+
+// run brings the index in line with the canonical chain up to head: the
+// blocks reorged out are unindexed, the ones left of the configured range
+// unindexed or backfilled, and the new ones indexed. If the stop channel is
+// closed, the task is terminated as soon as possible, the done channel being
+// closed once it is finished.
+func (indexer *addrIndexer) run(head uint64, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	var (
+		batch = indexer.db.NewBatch()
+		first = indexer.first(head)
+		tail  uint64      // First indexed block
+		next  uint64      // First block not indexed
+		hash  common.Hash // Hash of the block before next
+	)
+	if t := rawdb.ReadAddressIndexTail(indexer.db); t != nil {
+		tail, next = *t, *t
+		if number, last, ok := rawdb.ReadAddressIndexHead(indexer.db); ok {
+			next, hash = number+1, last
+		}
+	} else {
+		tail, next = first, first
+	}
+	if next == tail && tail > 0 {
+		hash = rawdb.ReadCanonicalHash(indexer.db, tail-1)
+	}
+	// flush writes out the batch along with the matching markers.
+	flush := func() {
+		if next > 0 {
+			rawdb.WriteAddressIndexHead(batch, next-1, hash)
+		} else {
+			rawdb.DeleteAddressIndexHead(batch)
+		}
+		rawdb.WriteAddressIndexTail(batch, tail)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write address index", "err", err)
+		}
+		batch.Reset()
+	}
+	defer flush()
+
+	// checkpoint flushes large batches, reporting whether to go on.
+	checkpoint := func() bool {
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			flush()
+		}
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+	// Unindex the blocks that are no longer canonical
+	for next > tail && next > 1 && rawdb.ReadCanonicalHash(indexer.db, next-1) != hash {
+		if block := rawdb.ReadBlock(indexer.db, hash, next-1); block != nil {
+			rawdb.DeleteAddressActivity(batch, indexer.blockActivity(block))
+			hash = block.ParentHash()
+		} else {
+			// Stale entries are left behind, past the head if the chain
+			// was rewound
+			log.Warn("Missing block to unindex address activity", "number", next-1, "hash", hash)
+			hash = rawdb.ReadCanonicalHash(indexer.db, next-2)
+		}
+		next--
+		if !checkpoint() {
+			return
+		}
+	}
+	if tail > head+1 {
+		// The chain was rewound below the indexed range
+		tail, next, hash = head+1, head+1, rawdb.ReadCanonicalHash(indexer.db, head)
+	}
+	// Move the tail to the first block of the configured range
+	for tail > first {
+		block := indexer.canonicalBlock(tail - 1)
+		if block == nil {
+			return
+		}
+		rawdb.WriteAddressActivity(batch, indexer.blockActivity(block))
+		tail--
+		if !checkpoint() {
+			return
+		}
+	}
+	for tail < first && tail < next {
+		if block := indexer.canonicalBlock(tail); block != nil {
+			rawdb.DeleteAddressActivity(batch, indexer.blockActivity(block))
+		}
+		tail++
+		if !checkpoint() {
+			return
+		}
+	}
+	if next < first {
+		tail, next, hash = first, first, rawdb.ReadCanonicalHash(indexer.db, first-1)
+	}
+	if first > 0 {
+		rawdb.PruneAddressCalls(indexer.db, batch, first)
+	}
+	// Index the new blocks
+	for next <= head {
+		block := indexer.canonicalBlock(next)
+		if block == nil || (next > 0 && block.ParentHash() != hash) {
+			// Not available yet or reorged meanwhile, retried with the next head
+			return
+		}
+		rawdb.WriteAddressActivity(batch, indexer.blockActivity(block))
+		next, hash = next+1, block.Hash()
+		if !checkpoint() {
+			return
+		}
+	}
+}
+
+// canonicalBlock returns the canonical block of the given number, nil if it
+// is not available.
+func (indexer *addrIndexer) canonicalBlock(number uint64) *types.Block {
+	hash := rawdb.ReadCanonicalHash(indexer.db, number)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return rawdb.ReadBlock(indexer.db, hash, number)
+}
+
+// blockActivity returns the index entries of a block.
+func (indexer *addrIndexer) blockActivity(block *types.Block) []rawdb.AddressActivity {
+	var (
+		signer  = types.MakeSigner(indexer.config, block.Number(), block.Time())
+		number  = block.NumberU64()
+		hash    = block.Hash()
+		entries []rawdb.AddressActivity
+		seen    = make(map[rawdb.AddressActivity]struct{})
+	)
+	add := func(addr common.Address, i uint32, role rawdb.AddressRole) {
+		entry := rawdb.AddressActivity{Address: addr, Number: number, Hash: hash, TxIndex: i, Role: role}
+		if _, ok := seen[entry]; !ok {
+			seen[entry] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	for i, tx := range block.Transactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			log.Warn("Failed to derive sender for address index", "tx", tx.Hash(), "err", err)
+		} else {
+			add(from, uint32(i), rawdb.AddressRoleSender)
+		}
+		if to := tx.To(); to != nil {
+			add(*to, uint32(i), rawdb.AddressRoleRecipient)
+		} else if err == nil {
+			add(crypto.CreateAddress(from, tx.Nonce()), uint32(i), rawdb.AddressRoleCreation)
+		}
+	}
+	for _, call := range rawdb.ReadAddressCalls(indexer.db, number, hash) {
+		add(call.Address, call.TxIndex, rawdb.AddressRoleInternal)
+	}
+	return entries
+}
+
+// report returns the address indexing progress.
+func (indexer *addrIndexer) report(head uint64) AddressIndexProgress {
+	var progress AddressIndexProgress
+	tail := rawdb.ReadAddressIndexTail(indexer.db)
+	number, _, ok := rawdb.ReadAddressIndexHead(indexer.db)
+	if tail == nil || !ok {
+		progress.Remaining = head + 1 - indexer.first(head)
+		return progress
+	}
+	progress.Tail, progress.Head = *tail, number
+	progress.InternalTail = number + 1
+	if calls := rawdb.ReadAddressCallsTail(indexer.db); calls != nil && *calls <= number {
+		progress.InternalTail = *calls
+		if progress.InternalTail < progress.Tail {
+			progress.InternalTail = progress.Tail
+		}
+	}
+	if head > number {
+		progress.Remaining = head - number
+	}
+	if first := indexer.first(head); first < *tail {
+		progress.Remaining += *tail - first
+	}
+	return progress
+}
+
+// addressIndexProgress retrieves the address indexing progress, or an error
+// if the indexer is already stopped.
+func (indexer *addrIndexer) addressIndexProgress() (AddressIndexProgress, error) {
+	ch := make(chan AddressIndexProgress, 1)
+	select {
+	case indexer.progress <- ch:
+		return <-ch, nil
+	case <-indexer.closed:
+		return AddressIndexProgress{}, errors.New("indexer is closed")
+	}
+}
+
+// close shuts down the indexer. Safe to be called multiple times.
+func (indexer *addrIndexer) close() {
+	ch := make(chan struct{})
+	select {
+	case indexer.term <- ch:
+		<-ch
+	case <-indexer.closed:
+	}
+}
+
+// addressCallTracer records the internal call targets of the transactions of
+// a block being imported, passing all events on to the tracer it wraps, if
+// any. Without one, the interpreter is not put in debug mode.
+type addressCallTracer struct {
+	inner vm.EVMLogger
+	hash  common.Hash // Hash of the block being processed
+	txs   int         // Number of transactions started
+	inTx  bool        // Whether a transaction is executing, as opposed to a system call
+	calls []rawdb.AddressCall
+}
+
+func newAddressCallTracer(hash common.Hash, inner vm.EVMLogger) *addressCallTracer {
+	return &addressCallTracer{inner: inner, hash: hash}
+}
+
+// write adds the recorded call targets to the batch writing the block, if
+// they were recorded for it. The block starts the segment of blocks with
+// recorded calls unless its parent was recorded too.
+func (t *addressCallTracer) write(db ethdb.KeyValueReader, batch ethdb.KeyValueWriter, block *types.Block) {
+	if t == nil || t.hash != block.Hash() {
+		return
+	}
+	number := block.NumberU64()
+	rawdb.WriteAddressCalls(batch, number, t.hash, t.calls)
+	if rawdb.ReadAddressCallsTail(db) == nil || number == 0 || !rawdb.HasAddressCalls(db, number-1, block.ParentHash()) {
+		rawdb.WriteAddressCallsTail(batch, number)
+	}
+}
+
+// SkipSteps implements vm.StepSkipper, the opcodes only being traced for the
+// wrapped tracer.
+func (t *addressCallTracer) SkipSteps() bool {
+	if skipper, ok := t.inner.(vm.StepSkipper); ok {
+		return skipper.SkipSteps()
+	}
+	return t.inner == nil
+}
+
+func (t *addressCallTracer) CaptureTxStart(gasLimit uint64) {
+	t.txs++
+	t.inTx = true
+	if t.inner != nil {
+		t.inner.CaptureTxStart(gasLimit)
+	}
+}
+
+func (t *addressCallTracer) CaptureTxEnd(restGas uint64) {
+	t.inTx = false
+	if t.inner != nil {
+		t.inner.CaptureTxEnd(restGas)
+	}
+}
+
+func (t *addressCallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if t.inner != nil {
+		t.inner.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (t *addressCallTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if t.inner != nil {
+		t.inner.CaptureEnd(output, gasUsed, err)
+	}
+}
+
+func (t *addressCallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.inTx {
+		t.calls = append(t.calls, rawdb.AddressCall{TxIndex: uint32(t.txs - 1), Address: to})
+	}
+	if t.inner != nil {
+		t.inner.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (t *addressCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.inner != nil {
+		t.inner.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (t *addressCallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.inner != nil {
+		t.inner.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (t *addressCallTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if t.inner != nil {
+		t.inner.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// TestAddressIndexer tests the maintenance of the address activity index on
+// import, reorg and pruning.
+func TestAddressIndexer(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		caller = common.HexToAddress("0xaa")
+		callee = common.HexToAddress("0xbb")
+
+		// Code calling the callee
+		code = []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0xbb, 0x5a, 0xf1, 0x00}
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}, caller: {Code: code}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine  = ethash.NewFaker()
+		signer  = types.LatestSigner(gspec.Config)
+		created = crypto.CreateAddress(sender, 1)
+	)
+	// Every block calls the contract, except the second one creating another
+	// with the same code, calling the callee from its constructor.
+	genDb, blocks, _ := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {
+		tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &caller, Gas: 100000, GasPrice: gen.BaseFee()}
+		if i == 1 {
+			tx.To, tx.Data = nil, code
+		}
+		gen.AddTx(types.MustSignNewTx(key, signer, tx))
+	})
+	newChain := func(limit uint64) (ethdb.Database, *BlockChain) {
+		cacheConfig := *defaultCacheConfig
+		cacheConfig.AddressIndex, cacheConfig.AddressIndexLimit = true, limit
+		db := rawdb.NewMemoryDatabase()
+		chain, err := NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		return db, chain
+	}
+	wait := func(chain *BlockChain, head uint64) {
+		for i := 0; i < 100; i++ {
+			if progress, err := chain.AddressIndexProgress(); err == nil && progress.Head == head && progress.Remaining == 0 {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("address index did not reach block %d", head)
+	}
+	activity := func(db ethdb.Database, addr common.Address) map[rawdb.AddressRole][]uint64 {
+		res := make(map[rawdb.AddressRole][]uint64)
+		rawdb.ReadAddressActivity(db, addr, nil, func(entry rawdb.AddressActivity) bool {
+			if hash := rawdb.ReadCanonicalHash(db, entry.Number); entry.Hash != hash {
+				t.Errorf("address %x: entry of block %d has hash %x, want %x", addr, entry.Number, entry.Hash, hash)
+			}
+			res[entry.Role] = append(res[entry.Role], entry.Number)
+			return true
+		})
+		return res
+	}
+	check := func(db ethdb.Database, addr common.Address, want map[rawdb.AddressRole][]uint64) {
+		t.Helper()
+		if have := activity(db, addr); !reflect.DeepEqual(have, want) {
+			t.Errorf("address %x: have activity %v, want %v", addr, have, want)
+		}
+	}
+	db, chain := newChain(0)
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	wait(chain, 4)
+	check(db, sender, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleSender: {1, 2, 3, 4}})
+	check(db, caller, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleRecipient: {1, 3, 4}})
+	check(db, callee, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleInternal: {1, 2, 3, 4}})
+	check(db, created, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleCreation: {2}})
+	if progress, _ := chain.AddressIndexProgress(); progress.InternalTail != 1 {
+		t.Errorf("have internal tail %d, want 1", progress.InternalTail)
+	}
+
+	// A longer fork without transactions unindexes the reorged blocks.
+	_, fork, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, gen *BlockGen) {})
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	wait(chain, 5)
+	for _, addr := range []common.Address{sender, caller, callee, created} {
+		check(db, addr, map[rawdb.AddressRole][]uint64{})
+	}
+	// A limited index prunes the old blocks along with their calls.
+	db, chain = newChain(2)
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	wait(chain, 4)
+	if progress, _ := chain.AddressIndexProgress(); progress.Tail != 3 {
+		t.Errorf("have tail %d, want 3", progress.Tail)
+	}
+	check(db, sender, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleSender: {3, 4}})
+	check(db, callee, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleInternal: {3, 4}})
+	if calls := rawdb.ReadAddressCalls(db, 1, blocks[0].Hash()); calls != nil {
+		t.Errorf("calls of pruned block left: %v", calls)
+	}
+	// The calls of a block failing validation are not stored.
+	bad, _ := GenerateChain(gspec.Config, blocks[3], engine, genDb, 1, func(i int, gen *BlockGen) {
+		gen.AddTx(types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &caller, Gas: 100000, GasPrice: gen.BaseFee()}))
+	})
+	header := bad[0].Header()
+	header.Root = common.Hash{0x01}
+	badBlock := bad[0].WithSeal(header)
+	if _, err := chain.InsertChain([]*types.Block{badBlock}); err == nil || !strings.Contains(err.Error(), "invalid merkle root") {
+		t.Fatalf("invalid block import error mismatch: have %v", err)
+	}
+	if calls := rawdb.ReadAddressCalls(db, 5, badBlock.Hash()); calls != nil {
+		t.Errorf("calls of invalid block stored: %v", calls)
+	}
+	// The blocks imported before the index was enabled are backfilled
+	// without their internal calls, which is reported.
+	db = rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[:2]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.AddressIndex = true
+	chain, err = NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks[2:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	wait(chain, 4)
+	check(db, sender, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleSender: {1, 2, 3, 4}})
+	check(db, callee, map[rawdb.AddressRole][]uint64{rawdb.AddressRoleInternal: {3, 4}})
+	if progress, _ := chain.AddressIndexProgress(); progress.Tail != 0 || progress.InternalTail != 3 {
+		t.Errorf("have tail %d and internal tail %d, want 0 and 3", progress.Tail, progress.InternalTail)
+	}
+}
+
+// stepCounter is a tracer counting the calls and opcodes it sees.
+type stepCounter struct {
+	skip          bool
+	enters, steps int
+}
+
+func (c *stepCounter) SkipSteps() bool                { return c.skip }
+func (c *stepCounter) CaptureTxStart(gasLimit uint64) {}
+func (c *stepCounter) CaptureTxEnd(restGas uint64)    {}
+func (c *stepCounter) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+func (c *stepCounter) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+func (c *stepCounter) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	c.enters++
+}
+func (c *stepCounter) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (c *stepCounter) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	c.steps++
+}
+func (c *stepCounter) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// TestAddressCallTracerSkipsSteps tests that recording the call targets only
+// puts the interpreter in debug mode for a wrapped tracer needing opcodes.
+func TestAddressCallTracerSkipsSteps(t *testing.T) {
+	var (
+		caller = common.HexToAddress("0xaa")
+		callee = common.HexToAddress("0xbb")
+		code   = []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0xbb, 0x5a, 0xf1, 0x00}
+	)
+	for _, skip := range []bool{true, false} {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(caller, code)
+
+		inner := &stepCounter{skip: skip}
+		tracer := newAddressCallTracer(common.Hash{}, inner)
+		if tracer.SkipSteps() != skip {
+			t.Fatalf("skip %v: tracer skips steps: %v", skip, tracer.SkipSteps())
+		}
+		context := vm.BlockContext{CanTransfer: CanTransfer, Transfer: Transfer, BlockNumber: big.NewInt(0)}
+		evm := vm.NewEVM(context, vm.TxContext{}, statedb, params.TestChainConfig, vm.Config{Tracer: tracer})
+
+		tracer.CaptureTxStart(100000)
+		if _, _, err := evm.Call(vm.AccountRef(common.Address{}), caller, nil, 100000, new(uint256.Int)); err != nil {
+			t.Fatalf("skip %v: call failed: %v", skip, err)
+		}
+		tracer.CaptureTxEnd(0)
+
+		if want := []rawdb.AddressCall{{TxIndex: 0, Address: callee}}; !reflect.DeepEqual(tracer.calls, want) {
+			t.Errorf("skip %v: have calls %v, want %v", skip, tracer.calls, want)
+		}
+		if inner.enters != 1 {
+			t.Errorf("skip %v: inner tracer saw %d calls, want 1", skip, inner.enters)
+		}
+		if skip && inner.steps != 0 {
+			t.Errorf("interpreter traced %d steps of a tracer skipping them", inner.steps)
+		}
+		if !skip && inner.steps == 0 {
+			t.Error("interpreter did not trace steps")
+		}
+	}
+	if !newAddressCallTracer(common.Hash{}, nil).SkipSteps() {
+		t.Error("tracer without inner tracer does not skip steps")
+	}
+}
@@ -146,6 +146,15 @@ type CacheConfig struct {
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
+
+	// begin PluGeth code injection
+	AddressIndex       bool   // Whether to maintain the address activity index
+	AddressIndexLimit  uint64 // Number of blocks from head whose address activity is indexed, 0 for all
+	SenderNonceIndex   bool   // Whether to index the transactions by sender and nonce, over the transaction index range
+	LogIndex           bool   // Whether to maintain the index of the blocks holding each log address and topic
+	HistoryEra1Dir     string // Directory of Era1 files to serve the pre-merge bodies and receipts from, pruning them from the freezer
+	LiveTraceRetention uint64 // Number of blocks from head whose stored live tracer results are kept, 0 for all
+	// end PluGeth code injection
 }
 
 // triedbConfig derives the configures for trie database.
@@ -219,6 +228,10 @@ type BlockChain struct {
 	triedb        *triedb.Database                 // The database handler for maintaining trie nodes.
 	stateCache    state.Database                   // State database to reuse between imports (contains state cache)
 	txIndexer     *txIndexer                       // Transaction indexer, might be nil if not enabled
+	addrIndexer   *addrIndexer                     // Address activity indexer, might be nil if not enabled (PluGeth injection)
+	addrCalls     *addressCallTracer               // Internal calls of the block being inserted, if indexed (PluGeth injection)
+	logIndexer    *logIndexer                      // Log address and topic indexer, might be nil if not enabled (PluGeth injection)
+	expirer       *historyExpirer                  // Pre-merge history expirer, might be nil if not enabled (PluGeth injection)
 
 	hc            *HeaderChain
 	rmLogsFeed    event.Feed
@@ -468,6 +481,32 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	if txLookupLimit != nil {
 		bc.txIndexer = newTxIndexer(*txLookupLimit, bc)
 	}
+	// begin PluGeth code injection
+	if cacheConfig.AddressIndex {
+		bc.addrIndexer = newAddrIndexer(cacheConfig.AddressIndexLimit, bc)
+	}
+	if !cacheConfig.SenderNonceIndex && rawdb.ReadSenderNonceIndexTail(db) != nil {
+		// Blocks are not indexed while disabled, rebuild the index from
+		// scratch if enabled again.
+		log.Warn("Sender nonce index disabled, dropping its range")
+		rawdb.DeleteSenderNonceIndexRange(db)
+	}
+	if cacheConfig.LogIndex {
+		bc.logIndexer = newLogIndexer(bc)
+	} else if _, _, ok := rawdb.ReadLogIndexHead(db); ok {
+		// The index is not kept up to date while disabled, stop log filters
+		// from using it.
+		log.Warn("Log index disabled, dropping its range")
+		rawdb.DeleteLogIndexHead(db)
+	}
+	if cacheConfig.HistoryEra1Dir != "" {
+		if bc.expirer, err = newHistoryExpirer(cacheConfig.HistoryEra1Dir, bc); err != nil {
+			return nil, fmt.Errorf("failed to enable history expiry: %w", err)
+		}
+	} else if tail, err := rawdb.ReadHistoryTail(db); err == nil && tail > 0 {
+		log.Warn("Block history pruned, bodies and receipts not available without Era1 files", "tail", tail)
+	}
+	// end PluGeth code injection
 	return bc, nil
 }
 
@@ -757,9 +796,15 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 		if num+1 <= frozen {
 			// Truncate all relative data(header, total difficulty, body, receipt
 			// and canonical hash) from ancient store.
+			// begin PluGeth injection
+			restore := rawdb.SetTruncateReason(rawdb.ChainFreezerName, rawdb.TruncateReasonSetHead)
+			// end PluGeth injection
 			if _, err := bc.db.TruncateHead(num); err != nil {
 				log.Crit("Failed to truncate ancient data", "number", num, "err", err)
 			}
+			// begin PluGeth injection
+			restore()
+			// end PluGeth injection
 			// Remove the hash <-> number mapping from the active store.
 			rawdb.DeleteHeaderNumber(db, hash)
 		} else {
@@ -930,6 +975,15 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	rawdb.WriteHeadFastBlockHash(batch, block.Hash())
 	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
 	rawdb.WriteTxLookupEntriesByBlock(batch, block)
+	// begin PluGeth code injection
+	if bc.cacheConfig.SenderNonceIndex {
+		writeSenderNonceLookups(batch, block, types.MakeSigner(bc.chainConfig, block.Number(), block.Time()))
+		// Extend the indexed range if it reaches the parent
+		if head := rawdb.ReadSenderNonceIndexHead(bc.db); head != nil && *head+1 == block.NumberU64() {
+			rawdb.WriteSenderNonceIndexHead(batch, block.NumberU64())
+		}
+	}
+	// end PluGeth code injection
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
 
 	// Flush the whole batch into the disk, exit the node if failed
@@ -960,6 +1014,17 @@ func (bc *BlockChain) stopWithoutSaving() {
 	if bc.txIndexer != nil {
 		bc.txIndexer.close()
 	}
+	// begin PluGeth code injection
+	if bc.logIndexer != nil {
+		bc.logIndexer.close()
+	}
+	if bc.addrIndexer != nil {
+		bc.addrIndexer.close()
+	}
+	if bc.expirer != nil {
+		bc.expirer.close()
+	}
+	// end PluGeth code injection
 	// Unsubscribe all subscriptions registered from blockchain.
 	bc.scope.Close()
 
@@ -1191,9 +1256,15 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		if !updateHead(blockChain[len(blockChain)-1]) {
 			// We end up here if the header chain has reorg'ed, and the blocks/receipts
 			// don't match the canonical chain.
+			// begin PluGeth injection
+			restore := rawdb.SetTruncateReason(rawdb.ChainFreezerName, rawdb.TruncateReasonRollback)
+			// end PluGeth injection
 			if _, err := bc.db.TruncateHead(previousSnapBlock + 1); err != nil {
 				log.Error("Can't truncate ancient store after failed insert", "err", err)
 			}
+			// begin PluGeth injection
+			restore()
+			// end PluGeth injection
 			return 0, errSideChainReceipts
 		}
 
@@ -1366,6 +1437,10 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
 	rawdb.WritePreimages(blockBatch, state.Preimages())
+	// begin PluGeth code injection
+	bc.addrCalls.write(bc.db, blockBatch, block)
+	bc.addrCalls = nil
+	// end PluGeth code injection
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
@@ -1787,7 +1862,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks, setHead bool) (int, error)
 
 		// Process block using the parent state as reference point
 		pstart := time.Now()
-		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		// begin PluGeth code injection
+		// The internal call targets of the block feed the address activity index
+		vmConfig := bc.vmConfig
+		if bc.addrIndexer != nil {
+			bc.addrCalls = newAddressCallTracer(block.Hash(), vmConfig.Tracer)
+			vmConfig.Tracer = bc.addrCalls
+		}
+		// end PluGeth code injection
+		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, vmConfig)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			followupInterrupt.Store(true)
@@ -2240,6 +2323,20 @@ func (bc *BlockChain) reorg(oldHead *types.Header, newHead *types.Block) error {
 	for _, tx := range diffs {
 		rawdb.DeleteTxLookupEntry(indexesBatch, tx)
 	}
+	// begin PluGeth code injection
+	if bc.cacheConfig.SenderNonceIndex {
+		dropped := make(map[common.Hash]struct{}, len(diffs))
+		for _, tx := range diffs {
+			dropped[tx] = struct{}{}
+		}
+		for _, block := range oldChain {
+			deleteSenderNonceLookups(bc.db, indexesBatch, block, types.MakeSigner(bc.chainConfig, block.Number(), block.Time()), dropped)
+		}
+	}
+	for _, block := range oldChain {
+		rawdb.DeleteLiveTraces(bc.db, indexesBatch, block.NumberU64(), block.Hash())
+	}
+	// end PluGeth code injection
 	// Delete all hash markers that are not part of the new canonical chain.
 	// Because the reorg function does not handle new chain head, all hash
 	// markers greater than or equal to new chain head should be deleted.
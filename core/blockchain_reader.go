@@ -405,6 +405,24 @@ func (bc *BlockChain) TxIndexProgress() (TxIndexProgress, error) {
 	return bc.txIndexer.txIndexProgress()
 }
 
+// SenderNonceIndexProgress returns the sender nonce indexing progress.
+// PluGeth injection
+func (bc *BlockChain) SenderNonceIndexProgress() (TxIndexProgress, error) {
+	if bc.txIndexer == nil || bc.txIndexer.senders == nil {
+		return TxIndexProgress{}, errors.New("sender nonce indexer is not enabled")
+	}
+	return bc.txIndexer.senderIndexProgress(bc.CurrentBlock().Number.Uint64()), nil
+}
+
+// AddressIndexProgress returns the address activity indexing progress.
+// PluGeth injection
+func (bc *BlockChain) AddressIndexProgress() (AddressIndexProgress, error) {
+	if bc.addrIndexer == nil {
+		return AddressIndexProgress{}, errors.New("address indexer is not enabled")
+	}
+	return bc.addrIndexer.addressIndexProgress()
+}
+
 // TrieDB retrieves the low level trie database used for data storage.
 func (bc *BlockChain) TrieDB() *triedb.Database {
 	return bc.triedb
@@ -240,7 +240,10 @@ func checksumToBytes(hash uint32) [4]byte {
 // gatherForks gathers all the known forks and creates two sorted lists out of
 // them, one for the block number based forks and the second for the timestamps.
 func gatherForks(config *params.ChainConfig, genesis uint64) ([]uint64, []uint64) {
-	
+	// begin PluGeth injection
+	config, extraForks := pluginForkSchedule(config)
+	// end PluGeth injection
+
 	// Gather all the fork block numbers via reflection
 	kind := reflect.TypeOf(params.ChainConfig{})
 	conf := reflect.ValueOf(config).Elem()
@@ -270,6 +273,9 @@ func gatherForks(config *params.ChainConfig, genesis uint64) ([]uint64, []uint64
 			}
 		}
 	}
+	// begin PluGeth injection
+	forksByBlock = append(forksByBlock, extraForks...)
+	// end PluGeth injection
 	slices.Sort(forksByBlock)
 	slices.Sort(forksByTime)
 
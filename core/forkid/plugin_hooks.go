@@ -2,6 +2,7 @@ package forkid
 
 import (
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/plugins"
 )
 
@@ -23,3 +24,18 @@ func pluginForkIDs(byBlock, byTime []uint64) ([]uint64, []uint64, bool) {
 	}
 	return PluginForkIDs(plugins.DefaultPluginLoader, byBlock, byTime)
 }
+
+// pluginForkSchedule applies the fork rules overridden by plugins, through
+// the chain spec or the ForkRule hook, to config, returning the block
+// activations which have no ChainConfig field.
+func pluginForkSchedule(config *params.ChainConfig) (*params.ChainConfig, []uint64) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting pluginForkSchedule, but default PluginLoader has not been initialized")
+		return config, nil
+	}
+	schedule := params.PluginForkSchedule(plugins.DefaultPluginLoader)
+	if schedule == nil {
+		return config, nil
+	}
+	return schedule.Apply(config), schedule.ExtraForks()
+}
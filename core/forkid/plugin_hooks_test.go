@@ -0,0 +1,32 @@
+package forkid
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/plugins"
+)
+
+func TestForkRuleForkID(t *testing.T) {
+	config := &params.ChainConfig{
+		ChainID:        big.NewInt(1),
+		HomesteadBlock: big.NewInt(0),
+		BerlinBlock:    big.NewInt(100),
+	}
+	done := plugins.HookTester("ForkRule", func(name string, num *big.Int, time uint64) (bool, bool) {
+		switch name {
+		case "berlin":
+			return num.Uint64() >= 200, true
+		case "eip160":
+			return num.Uint64() >= 150, true
+		}
+		return false, false
+	})
+	defer done()
+
+	byBlock, byTime := gatherForks(config, 0)
+	if len(byBlock) != 2 || byBlock[0] != 150 || byBlock[1] != 200 || len(byTime) != 0 {
+		t.Errorf("have forks %v %v, want [150 200] []", byBlock, byTime)
+	}
+}
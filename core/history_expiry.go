@@ -0,0 +1,372 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// maxOpenEras is the number of Era1 files kept open to serve the history,
+// ranges of blocks being read from the same few files.
+const maxOpenEras = 8
+
+// eraHistory serves the block bodies and receipts pruned from the chain
+// freezer from a directory of Era1 files, checking them against the canonical
+// hashes kept by the freezer.
+type eraHistory struct {
+	dir   string
+	files []string // Era1 file names, in epoch order
+	first []uint64 // Number of the first block of each file
+	next  uint64   // Number of the first block not covered
+
+	lock   sync.Mutex
+	eras   lru.BasicLRU[int, *eraFile] // Recently used files, by index
+	closed bool
+}
+
+// eraFile is an open Era1 file, closed once evicted and no longer read.
+type eraFile struct {
+	*era.Era
+	refs    int  // Number of reads in progress
+	evicted bool // Whether the file was dropped from the open ones
+}
+
+// newEraHistory opens the Era1 files of a network in a directory, which must
+// cover a contiguous range of blocks from the genesis.
+func newEraHistory(dir, network string) (*eraHistory, error) {
+	names, err := era.ReadDir(dir, network)
+	if err != nil {
+		return nil, err
+	}
+	h := &eraHistory{dir: dir, eras: lru.NewBasicLRU[int, *eraFile](maxOpenEras)}
+	for _, name := range names {
+		e, err := era.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("error opening era %s: %w", name, err)
+		}
+		start, count := e.Start(), e.Count()
+		e.Close()
+
+		if start != h.next {
+			return nil, fmt.Errorf("era %s starts at block %d, want %d", name, start, h.next)
+		}
+		h.files = append(h.files, name)
+		h.first = append(h.first, start)
+		h.next = start + count
+	}
+	return h, nil
+}
+
+// end returns the number of the first block after the ones of a file.
+func (h *eraHistory) end(i int) uint64 {
+	if i+1 < len(h.first) {
+		return h.first[i+1]
+	}
+	return h.next
+}
+
+// open returns the i-th Era1 file, along with the function to call once done
+// reading it.
+func (h *eraHistory) open(i int) (*era.Era, func(), error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	f, ok := h.eras.Get(i)
+	if !ok {
+		e, err := era.Open(filepath.Join(h.dir, h.files[i]))
+		if err != nil {
+			return nil, nil, err
+		}
+		f = &eraFile{Era: e, evicted: h.closed}
+		if !h.closed {
+			if h.eras.Len() == maxOpenEras {
+				_, old, _ := h.eras.RemoveOldest()
+				h.evict(old)
+			}
+			h.eras.Add(i, f)
+		}
+	}
+	f.refs++
+	release := func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		if f.refs--; f.refs == 0 && f.evicted {
+			f.Close()
+		}
+	}
+	return f.Era, release, nil
+}
+
+// evict drops an open file, closing it unless it is being read.
+func (h *eraHistory) evict(f *eraFile) {
+	f.evicted = true
+	if f.refs == 0 {
+		f.Close()
+	}
+}
+
+// close closes the open Era1 files, the ones being read once done. Files
+// read afterwards are closed right away.
+func (h *eraHistory) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, i := range h.eras.Keys() {
+		f, _ := h.eras.Peek(i)
+		h.evict(f)
+	}
+	h.eras.Purge()
+	h.closed = true
+}
+
+// Ancient implements rawdb.HistorySource, retrieving the body or receipts of
+// a block from the Era1 file covering it.
+func (h *eraHistory) Ancient(kind string, number uint64, hash common.Hash) ([]byte, error) {
+	if number >= h.next {
+		return nil, errors.New("block not covered by era history")
+	}
+	i := sort.Search(len(h.first), func(i int) bool { return h.first[i] > number }) - 1
+	e, release, err := h.open(i)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	block, err := e.GetBlockByNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	if block.Hash() != hash {
+		return nil, fmt.Errorf("era block %d hash mismatch: have %s, want %s", number, block.Hash(), hash)
+	}
+	switch kind {
+	case rawdb.ChainFreezerBodiesTable:
+		if root := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); root != block.TxHash() {
+			return nil, fmt.Errorf("era block %d tx root mismatch: have %s, want %s", number, root, block.TxHash())
+		}
+		if uncles := types.CalcUncleHash(block.Uncles()); uncles != block.UncleHash() {
+			return nil, fmt.Errorf("era block %d uncle hash mismatch: have %s, want %s", number, uncles, block.UncleHash())
+		}
+		return rlp.EncodeToBytes(block.Body())
+
+	case rawdb.ChainFreezerReceiptTable:
+		receipts, err := e.GetReceiptsByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+			return nil, fmt.Errorf("era block %d receipt root mismatch: have %s, want %s", number, root, block.ReceiptHash())
+		}
+		stored := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			stored[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		return rlp.EncodeToBytes(stored)
+	}
+	return nil, fmt.Errorf("unknown table %s", kind)
+}
+
+// historyExpirer prunes the bodies and receipts of the pre-merge blocks from
+// the chain freezer in the background, as they get frozen, serving them from
+// a directory of Era1 files instead. Each file is checked against the local
+// chain before its blocks are pruned.
+type historyExpirer struct {
+	db      ethdb.Database
+	history *eraHistory
+	term    chan chan struct{}
+	closed  chan struct{}
+}
+
+// newHistoryExpirer initializes the history expirer, the pruned history being
+// served from the Era1 files of the given directory right away.
+func newHistoryExpirer(dir string, chain *BlockChain) (*historyExpirer, error) {
+	network := "unknown"
+	if name, ok := params.NetworkNames[chain.chainConfig.ChainID.String()]; ok {
+		network = name
+	}
+	history, err := newEraHistory(dir, network)
+	if err != nil {
+		return nil, err
+	}
+	if err := rawdb.SetHistorySource(chain.db, history); err != nil {
+		return nil, err
+	}
+	tail, err := rawdb.ReadHistoryTail(chain.db)
+	if err != nil {
+		return nil, err
+	}
+	if tail > history.next {
+		log.Warn("Era1 files do not cover the pruned history", "dir", dir, "pruned", tail, "covered", history.next)
+	}
+	expirer := &historyExpirer{
+		db:      chain.db,
+		history: history,
+		term:    make(chan chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go expirer.loop(chain)
+
+	log.Info("Initialized history expiry", "dir", dir, "eras", len(history.files), "pruned", tail)
+	return expirer, nil
+}
+
+// loop schedules the pruning of the history as new blocks get frozen.
+func (expirer *historyExpirer) loop(chain *BlockChain) {
+	defer close(expirer.closed)
+
+	var (
+		stop     chan struct{} // Non-nil if background routine is active.
+		done     chan struct{} // Non-nil if background routine is active.
+		finished bool          // Whether no more history can be pruned
+
+		headCh = make(chan ChainHeadEvent)
+		sub    = chain.SubscribeChainHeadEvent(headCh)
+	)
+	defer sub.Unsubscribe()
+
+	start := func() {
+		stop = make(chan struct{})
+		done = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			finished = expirer.run(stop)
+		}(done)
+	}
+	start()
+	for {
+		select {
+		case <-headCh:
+			if done == nil && !finished {
+				start()
+			}
+		case <-done:
+			stop, done = nil, nil
+		case ch := <-expirer.term:
+			if stop != nil {
+				close(stop)
+			}
+			if done != nil {
+				log.Info("Waiting background history expirer to exit")
+				<-done
+			}
+			close(ch)
+			return
+		}
+	}
+}
+
+// run prunes the blocks of the Era1 files which are frozen, reporting whether
+// no more blocks can be pruned later on. If the stop channel is closed, the
+// task is terminated as soon as possible.
+func (expirer *historyExpirer) run(stop chan struct{}) bool {
+	tail, err := rawdb.ReadHistoryTail(expirer.db)
+	if err != nil {
+		log.Error("Failed to read history tail", "err", err)
+		return true
+	}
+	frozen, err := expirer.db.Ancients()
+	if err != nil {
+		log.Error("Failed to read frozen blocks", "err", err)
+		return true
+	}
+	for i, name := range expirer.history.files {
+		next := expirer.history.end(i)
+		if next <= tail {
+			continue
+		}
+		if next > frozen {
+			return false // Pruned once frozen
+		}
+		if err := expirer.verify(i); err != nil {
+			log.Error("Era1 file does not match the chain, stopping history expiry", "file", name, "err", err)
+			return true
+		}
+		if err := rawdb.PruneHistory(expirer.db, next); err != nil {
+			log.Error("Failed to prune history", "file", name, "err", err)
+			return true
+		}
+		tail = next
+		log.Info("Pruned block history", "file", name, "tail", tail)
+
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+	}
+	return true
+}
+
+// verify checks that an Era1 file holds pre-merge blocks of the local chain,
+// recomputing its accumulator root from the canonical hashes and total
+// difficulties.
+func (expirer *historyExpirer) verify(i int) error {
+	var (
+		first = expirer.history.first[i]
+		next  = expirer.history.end(i)
+	)
+	e, release, err := expirer.history.open(i)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	want, err := e.Accumulator()
+	if err != nil {
+		return fmt.Errorf("error reading accumulator: %w", err)
+	}
+	var (
+		hashes = make([]common.Hash, 0, next-first)
+		tds    = make([]*big.Int, 0, next-first)
+	)
+	for number := first; number < next; number++ {
+		hash := rawdb.ReadCanonicalHash(expirer.db, number)
+		td := rawdb.ReadTd(expirer.db, hash, number)
+		if td == nil {
+			return fmt.Errorf("missing total difficulty of block %d", number)
+		}
+		hashes = append(hashes, hash)
+		tds = append(tds, td)
+	}
+	last := rawdb.ReadHeader(expirer.db, hashes[len(hashes)-1], next-1)
+	if last == nil {
+		return fmt.Errorf("missing header of block %d", next-1)
+	}
+	if last.Difficulty.Sign() == 0 {
+		return errors.New("post-merge blocks are not expired")
+	}
+	have, err := era.ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return fmt.Errorf("error computing accumulator: %w", err)
+	}
+	if have != want {
+		return fmt.Errorf("accumulator root mismatch: have %s, want %s", have, want)
+	}
+	return nil
+}
+
+// close shuts down the expirer, closing the Era1 files it keeps open. Safe to
+// be called multiple times.
+func (expirer *historyExpirer) close() {
+	ch := make(chan struct{})
+	select {
+	case expirer.term <- ch:
+		<-ch
+	case <-expirer.closed:
+	}
+	expirer.history.close()
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestHistoryExpiry tests the pruning of the frozen history covered by Era1
+// files, and its retrieval from them.
+func TestHistoryExpiry(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		emitter = common.HexToAddress("0xaa")
+
+		// Code emitting a log with a single topic
+		code = []byte{0x60, 0x2a, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}, emitter: {Code: code}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+		step   = 16
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, 4*step-1, func(i int, gen *BlockGen) {
+		tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &emitter, Gas: 100000, GasPrice: gen.BaseFee()}
+		gen.AddTx(types.MustSignNewTx(key, signer, tx))
+	})
+	source, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer source.Stop()
+	if _, err := source.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Export the chain to Era1 files of 16 blocks
+	dir := t.TempDir()
+	for epoch := 0; epoch < 4; epoch++ {
+		f, err := os.CreateTemp(dir, "export")
+		if err != nil {
+			t.Fatalf("failed to create era: %v", err)
+		}
+		builder := era.NewBuilder(f)
+		for n := epoch * step; n < (epoch+1)*step; n++ {
+			block := source.GetBlockByNumber(uint64(n))
+			if err := builder.Add(block, source.GetReceiptsByHash(block.Hash()), source.GetTd(block.Hash(), block.NumberU64())); err != nil {
+				t.Fatalf("failed to add block %d: %v", n, err)
+			}
+		}
+		root, err := builder.Finalize()
+		if err != nil {
+			t.Fatalf("failed to finalize era: %v", err)
+		}
+		f.Close()
+		if err := os.Rename(f.Name(), filepath.Join(dir, era.Filename("mainnet", epoch, root))); err != nil {
+			t.Fatalf("failed to rename era: %v", err)
+		}
+	}
+	// Import the chain with the blocks up to 40 frozen
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err := NewBlockChain(db, nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, 40); err != nil {
+		t.Fatalf("failed to insert receipts: %v", err)
+	}
+	chain.Stop()
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.HistoryEra1Dir = dir
+	chain, err = NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Only the files fully frozen are pruned
+	for i := 0; ; i++ {
+		if tail, _ := rawdb.ReadHistoryTail(db); tail == uint64(2*step) {
+			break
+		} else if i == 100 {
+			t.Fatalf("history tail mismatch: have %d, want %d", tail, 2*step)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if has, _ := db.HasAncient(rawdb.ChainFreezerBodiesTable, 10); has {
+		t.Fatal("body of block 10 not pruned")
+	}
+	for _, n := range []uint64{0, 10, uint64(2*step - 1), uint64(2 * step), 50} {
+		want := source.GetBlockByNumber(n)
+		block := chain.GetBlockByNumber(n)
+		if block == nil || block.Hash() != want.Hash() || len(block.Transactions()) != len(want.Transactions()) {
+			t.Fatalf("block %d mismatch: have %v", n, block)
+		}
+		have := chain.GetReceiptsByHash(want.Hash())
+		wantReceipts := source.GetReceiptsByHash(want.Hash())
+		if len(have) != len(wantReceipts) {
+			t.Fatalf("block %d receipts mismatch: have %d, want %d", n, len(have), len(wantReceipts))
+		}
+		for i := range have {
+			if have[i].TxHash != wantReceipts[i].TxHash || have[i].GasUsed != wantReceipts[i].GasUsed || len(have[i].Logs) != 1 {
+				t.Fatalf("block %d receipt %d mismatch", n, i)
+			}
+		}
+	}
+	// Era1 files must match the chain
+	history, err := newEraHistory(dir, "mainnet")
+	if err != nil {
+		t.Fatalf("failed to open era history: %v", err)
+	}
+	if _, err := history.Ancient(rawdb.ChainFreezerBodiesTable, 10, common.Hash{0x01}); err == nil {
+		t.Fatal("served body of mismatching block")
+	}
+	// Files are kept open across reads until closed
+	e, release, err := history.open(0)
+	if err != nil {
+		t.Fatalf("failed to open era: %v", err)
+	}
+	again, release2, _ := history.open(0)
+	release()
+	release2()
+	if again != e || history.eras.Len() != 1 {
+		t.Fatalf("era reopened, %d open", history.eras.Len())
+	}
+	history.close()
+	if history.eras.Len() != 0 {
+		t.Fatalf("%d eras left open", history.eras.Len())
+	}
+	if _, err := e.Accumulator(); err == nil {
+		t.Fatal("era not closed")
+	}
+	hash := rawdb.ReadCanonicalHash(db, 10)
+	if _, err := history.Ancient(rawdb.ChainFreezerBodiesTable, 10, hash); err != nil || history.eras.Len() != 0 {
+		t.Fatalf("failed to read closed history: %v, %d open", err, history.eras.Len())
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// logIndexFlushSize is the number of postings added or removed after which
+// the modified posting lists are written out.
+const logIndexFlushSize = 100000
+
+// logIndexer maintains the index of the blocks holding each log address and
+// topic in the background, from the genesis up to the chain head.
+type logIndexer struct {
+	db     ethdb.Database
+	term   chan chan struct{}
+	closed chan struct{}
+}
+
+// newLogIndexer initializes the log indexer.
+func newLogIndexer(chain *BlockChain) *logIndexer {
+	indexer := &logIndexer{
+		db:     chain.db,
+		term:   make(chan chan struct{}),
+		closed: make(chan struct{}),
+	}
+	go indexer.loop(chain)
+
+	log.Info("Initialized log indexer")
+	return indexer
+}
+
+// loop schedules the indexing of new chain heads.
+func (indexer *logIndexer) loop(chain *BlockChain) {
+	defer close(indexer.closed)
+
+	var (
+		stop     chan struct{} // Non-nil if background routine is active.
+		done     chan struct{} // Non-nil if background routine is active.
+		lastHead uint64        // The latest announced chain head
+		pending  bool          // Whether a head arrived while indexing
+
+		headCh = make(chan ChainHeadEvent)
+		sub    = chain.SubscribeChainHeadEvent(headCh)
+	)
+	defer sub.Unsubscribe()
+
+	start := func() {
+		stop = make(chan struct{})
+		done = make(chan struct{})
+		go indexer.run(lastHead, stop, done)
+	}
+	if head := rawdb.ReadHeadBlock(indexer.db); head != nil {
+		lastHead = head.NumberU64()
+		start()
+	}
+	for {
+		select {
+		case head := <-headCh:
+			lastHead = head.Block.NumberU64()
+			if done == nil {
+				start()
+			} else {
+				pending = true
+			}
+		case <-done:
+			stop, done = nil, nil
+			if pending {
+				pending = false
+				start()
+			}
+		case ch := <-indexer.term:
+			if stop != nil {
+				close(stop)
+			}
+			if done != nil {
+				log.Info("Waiting background log indexer to exit")
+				<-done
+			}
+			close(ch)
+			return
+		}
+	}
+}
+
+// run brings the index in line with the canonical chain up to head: the
+// blocks reorged out are unindexed and the new ones indexed. If the stop
+// channel is closed, the task is terminated as soon as possible, the done
+// channel being closed once it is finished.
+func (indexer *logIndexer) run(head uint64, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	var (
+		writer = newLogIndexWriter(indexer.db)
+		next   uint64      // First block not indexed
+		hash   common.Hash // Hash of the block before next
+	)
+	if number, last, ok := rawdb.ReadLogIndexHead(indexer.db); ok {
+		next, hash = number+1, last
+	}
+	// flush writes out the modified posting lists along with the head.
+	flush := func() {
+		batch := indexer.db.NewBatch()
+		writer.flush(batch)
+		if next > 0 {
+			rawdb.WriteLogIndexHead(batch, next-1, hash)
+		} else {
+			rawdb.DeleteLogIndexHead(batch)
+		}
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write log index", "err", err)
+		}
+	}
+	defer flush()
+
+	// checkpoint flushes large changes, reporting whether to go on.
+	checkpoint := func() bool {
+		if writer.size > logIndexFlushSize {
+			flush()
+		}
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+	// Unindex the blocks that are no longer canonical
+	for next > 0 && rawdb.ReadCanonicalHash(indexer.db, next-1) != hash {
+		if header := rawdb.ReadHeader(indexer.db, hash, next-1); header != nil {
+			writer.remove(next-1, rawdb.ReadLogs(indexer.db, hash, next-1))
+			hash = header.ParentHash
+		} else {
+			// Stale postings are left behind, matching no logs
+			log.Warn("Missing block to unindex logs", "number", next-1, "hash", hash)
+			if next > 1 {
+				hash = rawdb.ReadCanonicalHash(indexer.db, next-2)
+			}
+		}
+		next--
+		if !checkpoint() {
+			return
+		}
+	}
+	// Index the new blocks
+	for next <= head {
+		number := next
+		header := rawdb.ReadHeader(indexer.db, rawdb.ReadCanonicalHash(indexer.db, number), number)
+		if header == nil || (number > 0 && header.ParentHash != hash) {
+			// Not available yet or reorged meanwhile, retried with the next head
+			return
+		}
+		logs := rawdb.ReadLogs(indexer.db, header.Hash(), number)
+		if logs == nil && header.Bloom != (types.Bloom{}) {
+			log.Warn("Missing receipts to index logs", "number", number, "hash", header.Hash())
+			return
+		}
+		writer.add(number, logs)
+		next, hash = number+1, header.Hash()
+		if !checkpoint() {
+			return
+		}
+	}
+}
+
+// close shuts down the indexer. Safe to be called multiple times.
+func (indexer *logIndexer) close() {
+	ch := make(chan struct{})
+	select {
+	case indexer.term <- ch:
+		<-ch
+	case <-indexer.closed:
+	}
+}
+
+// logIndexList identifies a posting list of the log index.
+type logIndexList struct {
+	term    string
+	section uint64
+}
+
+// logIndexWriter accumulates the changes to the posting lists of the log
+// index until flushed.
+type logIndexWriter struct {
+	db    ethdb.KeyValueReader
+	lists map[logIndexList][]uint64
+	size  int // Number of postings added or removed
+}
+
+func newLogIndexWriter(db ethdb.KeyValueReader) *logIndexWriter {
+	return &logIndexWriter{db: db, lists: make(map[logIndexList][]uint64)}
+}
+
+// list returns a posting list, with the pending changes.
+func (w *logIndexWriter) list(id logIndexList) []uint64 {
+	if numbers, ok := w.lists[id]; ok {
+		return numbers
+	}
+	numbers := rawdb.ReadLogIndexSection(w.db, id.term, id.section)
+	w.lists[id] = numbers
+	return numbers
+}
+
+// add lists a block under the terms of its logs. Blocks are added in order,
+// so the postings from the block on, left behind by a reorg or a rebuild of
+// the index, are stale and dropped.
+func (w *logIndexWriter) add(number uint64, logs [][]*types.Log) {
+	for term := range logIndexTerms(logs) {
+		id := logIndexList{term: term, section: number / rawdb.LogIndexSectionSize}
+		numbers := w.list(id)
+		for len(numbers) > 0 && numbers[len(numbers)-1] >= number {
+			numbers = numbers[:len(numbers)-1]
+		}
+		w.lists[id] = append(numbers, number)
+		w.size++
+	}
+}
+
+// remove unlists a block from the terms of its logs.
+func (w *logIndexWriter) remove(number uint64, logs [][]*types.Log) {
+	for term := range logIndexTerms(logs) {
+		id := logIndexList{term: term, section: number / rawdb.LogIndexSectionSize}
+		numbers := w.list(id)
+		for i, n := range numbers {
+			if n == number {
+				w.lists[id] = append(numbers[:i:i], numbers[i+1:]...)
+				break
+			}
+		}
+		w.size++
+	}
+}
+
+// flush writes out the modified posting lists.
+func (w *logIndexWriter) flush(batch ethdb.KeyValueWriter) {
+	for id, numbers := range w.lists {
+		rawdb.WriteLogIndexSection(batch, id.term, id.section, numbers)
+	}
+	w.lists = make(map[logIndexList][]uint64)
+	w.size = 0
+}
+
+// logIndexTerms returns the terms of the log index a block is listed under.
+func logIndexTerms(logs [][]*types.Log) map[string]struct{} {
+	terms := make(map[string]struct{})
+	for _, txLogs := range logs {
+		for _, l := range txLogs {
+			terms[rawdb.LogIndexAddressTerm(l.Address)] = struct{}{}
+			for _, topic := range l.Topics {
+				terms[rawdb.LogIndexTopicTerm(topic)] = struct{}{}
+			}
+		}
+	}
+	return terms
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestLogIndexer tests the maintenance of the log index on import, reorg and
+// disabling.
+func TestLogIndexer(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		emitter  = common.HexToAddress("0xaa")
+		topic    = common.HexToHash("0x2a")
+		addrTerm = rawdb.LogIndexAddressTerm(emitter)
+
+		// Code emitting a log with a single topic
+		code = []byte{0x60, 0x2a, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}, emitter: {Code: code}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+	)
+	// Every block but the third calls the emitter.
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {
+		if i != 2 {
+			tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &emitter, Gas: 100000, GasPrice: gen.BaseFee()}
+			gen.AddTx(types.MustSignNewTx(key, signer, tx))
+		}
+	})
+	newChain := func(db ethdb.Database, enabled bool) *BlockChain {
+		cacheConfig := *defaultCacheConfig
+		cacheConfig.LogIndex = enabled
+		chain, err := NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		return chain
+	}
+	wait := func(db ethdb.Database, head uint64) {
+		for i := 0; i < 100; i++ {
+			if number, _, ok := rawdb.ReadLogIndexHead(db); ok && number == head {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("log index did not reach block %d", head)
+	}
+	check := func(db ethdb.Database, want []uint64) {
+		t.Helper()
+		for _, term := range []string{addrTerm, rawdb.LogIndexTopicTerm(topic)} {
+			if have := rawdb.ReadLogIndexSection(db, term, 0); !reflect.DeepEqual(have, want) {
+				t.Errorf("term %x: have blocks %v, want %v", term, have, want)
+			}
+		}
+	}
+	db := rawdb.NewMemoryDatabase()
+	chain := newChain(db, true)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	wait(db, 4)
+	check(db, []uint64{1, 2, 4})
+
+	// A longer fork only calling the emitter in its third block unindexes
+	// the reorged blocks.
+	_, fork, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, gen *BlockGen) {
+		if i == 2 {
+			tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &emitter, Gas: 100000, GasPrice: gen.BaseFee()}
+			gen.AddTx(types.MustSignNewTx(key, signer, tx))
+		}
+	})
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	wait(db, 5)
+	check(db, []uint64{3})
+	chain.Stop()
+
+	// Disabling the index stops it from being used.
+	newChain(db, false).Stop()
+	if _, _, ok := rawdb.ReadLogIndexHead(db); ok {
+		t.Error("log index head left after disabling the index")
+	}
+}
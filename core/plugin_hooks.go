@@ -8,13 +8,16 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/plugins"
 	"github.com/ethereum/go-ethereum/plugins/wrappers"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
 	"github.com/openrelayxyz/plugeth-utils/core"
 )
 
@@ -207,8 +210,98 @@ type PreTracer interface {
 
 }
 
+// SystemCallTracer may be implemented by live tracers to learn which EVM
+// executions are consensus system calls, such as the EIP-4788 beacon root
+// update, rather than transactions.
+type SystemCallTracer interface {
+	CaptureSystemCallStart(from core.Address, to core.Address, input []byte)
+	CaptureSystemCallEnd()
+}
+
+// WithdrawalTracer may be implemented by live tracers to receive the beacon
+// chain withdrawals of a block, just before they are credited. The amount is
+// denominated in Gwei.
+type WithdrawalTracer interface {
+	CaptureWithdrawal(index uint64, validator uint64, address core.Address, amount uint64)
+}
+
+// BalanceChangeTracer may be implemented by live tracers to receive every
+// balance change of a block, including the ones made outside of transactions
+// like block rewards and withdrawals. The reason is one of the names returned
+// by state.BalanceChangeReason.String.
+type BalanceChangeTracer interface {
+	CaptureBalanceChange(addr core.Address, prev *big.Int, new *big.Int, reason string)
+}
+
+// pluginBalanceChangeReason labels the balance changes that follow, if db
+// supports reporting them.
+func pluginBalanceChangeReason(db vm.StateDB, reason state.BalanceChangeReason) func() {
+	if statedb, ok := db.(*state.StateDB); ok {
+		return statedb.SetBalanceChangeReason(reason)
+	}
+	return func() {}
+}
+
+// StoredTracer may be implemented by live tracers whose output should be
+// persisted. Results are stored under the namespace of the tracer after each
+// transaction and block, and served by the debug_liveTrace* RPC methods.
+// Returning a nil result skips storing it.
+type StoredTracer interface {
+	TraceNamespace() string
+	StoredTransactionResult(tx core.Hash, i int) ([]byte, error)
+	StoredBlockResult(block core.Hash) ([]byte, error)
+}
+
 type metaTracer struct {
-	tracers []core.BlockTracer
+	tracers   []core.BlockTracer
+	db        ethdb.Database
+	store     ethdb.Batch
+	retention uint64        // Number of blocks from head whose results are kept, 0 for all
+	storedTxs []common.Hash // Transactions of the current block with a stored result
+}
+
+// setStore makes the tracer persist the results of stored tracers into db,
+// all results of a block being written at once when it has been processed.
+// The results of the blocks older than retention are pruned as new ones are
+// stored.
+func (mt *metaTracer) setStore(db ethdb.Database, retention uint64) {
+	for _, tracer := range mt.tracers {
+		if _, ok := tracer.(StoredTracer); ok {
+			mt.db, mt.store, mt.retention = db, db.NewBatch(), retention
+			return
+		}
+	}
+}
+
+func (mt *metaTracer) CaptureSystemCallStart(from common.Address, to common.Address, input []byte) {
+	for _, tracer := range mt.tracers {
+		if v, ok := tracer.(SystemCallTracer); ok {
+			v.CaptureSystemCallStart(core.Address(from), core.Address(to), input)
+		}
+	}
+}
+func (mt *metaTracer) CaptureSystemCallEnd() {
+	for _, tracer := range mt.tracers {
+		if v, ok := tracer.(SystemCallTracer); ok {
+			v.CaptureSystemCallEnd()
+		}
+	}
+}
+func (mt *metaTracer) CaptureWithdrawals(withdrawals []*types.Withdrawal) {
+	for _, tracer := range mt.tracers {
+		if v, ok := tracer.(WithdrawalTracer); ok {
+			for _, w := range withdrawals {
+				v.CaptureWithdrawal(w.Index, w.Validator, core.Address(w.Address), w.Amount)
+			}
+		}
+	}
+}
+func (mt *metaTracer) CaptureBalanceChange(addr common.Address, prev, new *uint256.Int, reason state.BalanceChangeReason) {
+	for _, tracer := range mt.tracers {
+		if v, ok := tracer.(BalanceChangeTracer); ok {
+			v.CaptureBalanceChange(core.Address(addr), prev.ToBig(), new.ToBig(), reason.String())
+		}
+	}
 }
 
 func (mt *metaTracer) PreProcessBlock(block *types.Block) {
@@ -249,6 +342,17 @@ func (mt *metaTracer) PostProcessTransaction(tx *types.Transaction, block *types
 	receiptBytes, _ := json.Marshal(receipt)
 	for _, tracer := range mt.tracers {
 		tracer.PostProcessTransaction(transactionHash, blockHash, i, receiptBytes)
+		if v, ok := tracer.(StoredTracer); ok && mt.store != nil {
+			result, err := v.StoredTransactionResult(transactionHash, i)
+			if err != nil {
+				log.Warn("Failed to retrieve live trace", "namespace", v.TraceNamespace(), "tx", tx.Hash(), "err", err)
+			} else if result != nil {
+				rawdb.WriteLiveTraceTransaction(mt.store, v.TraceNamespace(), tx.Hash(), block.Hash(), result)
+				if n := len(mt.storedTxs); n == 0 || mt.storedTxs[n-1] != tx.Hash() {
+					mt.storedTxs = append(mt.storedTxs, tx.Hash())
+				}
+			}
+		}
 	}
 }
 func (mt *metaTracer) PostProcessBlock(block *types.Block) {
@@ -256,6 +360,27 @@ func (mt *metaTracer) PostProcessBlock(block *types.Block) {
 	blockHash := core.Hash(block.Hash())
 	for _, tracer := range mt.tracers {
 		tracer.PostProcessBlock(blockHash)
+		if v, ok := tracer.(StoredTracer); ok && mt.store != nil {
+			result, err := v.StoredBlockResult(blockHash)
+			if err != nil {
+				log.Warn("Failed to retrieve live trace", "namespace", v.TraceNamespace(), "block", block.Hash(), "err", err)
+			} else if result != nil {
+				rawdb.WriteLiveTraceBlock(mt.store, v.TraceNamespace(), block.Hash(), result)
+			}
+		}
+	}
+	if mt.store != nil {
+		if mt.store.ValueSize() > 0 {
+			rawdb.WriteLiveTraceIndex(mt.store, block.NumberU64(), block.Hash(), mt.storedTxs)
+		}
+		if number := block.NumberU64(); mt.retention > 0 && number >= mt.retention {
+			rawdb.PruneLiveTraces(mt.db, mt.store, number-mt.retention+1)
+		}
+		if err := mt.store.Write(); err != nil {
+			log.Error("Failed to store live traces", "block", block.Hash(), "err", err)
+		}
+		mt.store.Reset()
+		mt.storedTxs = mt.storedTxs[:0]
 	}
 }
 func (mt *metaTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
@@ -0,0 +1,254 @@
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// AddressRole is the way a transaction involves an address.
+type AddressRole uint8
+
+const (
+	AddressRoleSender    AddressRole = iota // Address sending the transaction
+	AddressRoleRecipient                    // Address the transaction is sent to
+	AddressRoleCreation                     // Contract created by the transaction
+	AddressRoleInternal                     // Target of an internal call, creation or self-destruct
+)
+
+// String returns the name of the role.
+func (r AddressRole) String() string {
+	switch r {
+	case AddressRoleSender:
+		return "sender"
+	case AddressRoleRecipient:
+		return "recipient"
+	case AddressRoleCreation:
+		return "creation"
+	case AddressRoleInternal:
+		return "internal"
+	}
+	return "unknown"
+}
+
+// AddressActivity is an entry of the address activity index. The hash of the
+// block is stored along with the entry, telling apart the entries of blocks
+// being reorged.
+type AddressActivity struct {
+	Address common.Address
+	Number  uint64
+	Hash    common.Hash
+	TxIndex uint32
+	Role    AddressRole
+}
+
+// AddressCall is an internal call target of a transaction, recorded when its
+// block is processed.
+type AddressCall struct {
+	TxIndex uint32
+	Address common.Address
+}
+
+var (
+	// addressIndexPrefix + address + block number + tx index + role -> block hash
+	addressIndexPrefix = []byte("plugeth-ai-")
+
+	// addressCallsPrefix + block hash -> RLP encoded internal call targets
+	addressCallsPrefix = []byte("plugeth-ac-")
+
+	// Head (number + hash) and tail (number) of the indexed block range
+	addressIndexHeadKey = []byte("plugeth-am-head")
+	addressIndexTailKey = []byte("plugeth-am-tail")
+
+	// First block of the chain segment up to the head whose internal call
+	// targets were recorded
+	addressCallsTailKey = []byte("plugeth-am-calls")
+)
+
+// AddressActivityCursorLength is the length of the position of an entry in
+// the activity of an address.
+const AddressActivityCursorLength = 8 + 4 + 1
+
+func addressIndexKey(a AddressActivity) []byte {
+	key := make([]byte, 0, len(addressIndexPrefix)+common.AddressLength+AddressActivityCursorLength)
+	key = append(append(key, addressIndexPrefix...), a.Address.Bytes()...)
+	return append(key, a.Cursor()...)
+}
+
+// Cursor returns the position of the entry in the activity of its address,
+// in the order the entries are iterated.
+func (a AddressActivity) Cursor() []byte {
+	cursor := make([]byte, AddressActivityCursorLength)
+	binary.BigEndian.PutUint64(cursor, a.Number)
+	binary.BigEndian.PutUint32(cursor[8:], a.TxIndex)
+	cursor[12] = byte(a.Role)
+	return cursor
+}
+
+// WriteAddressActivity stores entries of the address activity index.
+func WriteAddressActivity(db ethdb.KeyValueWriter, entries []AddressActivity) {
+	for _, entry := range entries {
+		if err := db.Put(addressIndexKey(entry), entry.Hash.Bytes()); err != nil {
+			log.Crit("Failed to store address activity", "err", err)
+		}
+	}
+}
+
+// DeleteAddressActivity removes entries of the address activity index.
+func DeleteAddressActivity(db ethdb.KeyValueWriter, entries []AddressActivity) {
+	for _, entry := range entries {
+		if err := db.Delete(addressIndexKey(entry)); err != nil {
+			log.Crit("Failed to delete address activity", "err", err)
+		}
+	}
+}
+
+// ReadAddressActivity iterates the activity of an address in the order of
+// blocks and transactions, starting at the given cursor, or the first block
+// if nil. Iteration stops once fn returns false.
+func ReadAddressActivity(db ethdb.Iteratee, addr common.Address, cursor []byte, fn func(AddressActivity) bool) {
+	prefix := append(append([]byte{}, addressIndexPrefix...), addr.Bytes()...)
+	it := db.NewIterator(prefix, cursor)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+AddressActivityCursorLength {
+			continue
+		}
+		pos := key[len(prefix):]
+		entry := AddressActivity{
+			Address: addr,
+			Number:  binary.BigEndian.Uint64(pos),
+			Hash:    common.BytesToHash(it.Value()),
+			TxIndex: binary.BigEndian.Uint32(pos[8:]),
+			Role:    AddressRole(pos[12]),
+		}
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+func addressCallsKey(number uint64, hash common.Hash) []byte {
+	key := append(append([]byte{}, addressCallsPrefix...), encodeBlockNumber(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+// ReadAddressCalls retrieves the internal call targets recorded for a block.
+func ReadAddressCalls(db ethdb.KeyValueReader, number uint64, hash common.Hash) []AddressCall {
+	data, _ := db.Get(addressCallsKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var calls []AddressCall
+	if err := rlp.DecodeBytes(data, &calls); err != nil {
+		log.Error("Invalid address calls RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return calls
+}
+
+// HasAddressCalls reports whether the internal call targets of a block were
+// recorded, even if there are none.
+func HasAddressCalls(db ethdb.KeyValueReader, number uint64, hash common.Hash) bool {
+	has, _ := db.Has(addressCallsKey(number, hash))
+	return has
+}
+
+// WriteAddressCalls stores the internal call targets of a block.
+func WriteAddressCalls(db ethdb.KeyValueWriter, number uint64, hash common.Hash, calls []AddressCall) {
+	data, err := rlp.EncodeToBytes(calls)
+	if err != nil {
+		log.Crit("Failed to encode address calls", "err", err)
+	}
+	if err := db.Put(addressCallsKey(number, hash), data); err != nil {
+		log.Crit("Failed to store address calls", "hash", hash, "err", err)
+	}
+}
+
+// PruneAddressCalls removes the internal call targets of all blocks below the
+// given number, whether canonical or not.
+func PruneAddressCalls(db ethdb.Iteratee, batch ethdb.KeyValueWriter, number uint64) {
+	it := db.NewIterator(addressCallsPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(addressCallsPrefix)+8+common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(addressCallsPrefix):]) >= number {
+			return
+		}
+		if err := batch.Delete(key); err != nil {
+			log.Crit("Failed to delete address calls", "err", err)
+		}
+	}
+}
+
+// ReadAddressIndexHead retrieves the number and hash of the last block whose
+// activity is indexed.
+func ReadAddressIndexHead(db ethdb.KeyValueReader) (uint64, common.Hash, bool) {
+	data, _ := db.Get(addressIndexHeadKey)
+	if len(data) != 8+common.HashLength {
+		return 0, common.Hash{}, false
+	}
+	return binary.BigEndian.Uint64(data), common.BytesToHash(data[8:]), true
+}
+
+// WriteAddressIndexHead stores the last block whose activity is indexed.
+func WriteAddressIndexHead(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	data := binary.BigEndian.AppendUint64(nil, number)
+	if err := db.Put(addressIndexHeadKey, append(data, hash.Bytes()...)); err != nil {
+		log.Crit("Failed to store address index head", "err", err)
+	}
+}
+
+// ReadAddressIndexTail retrieves the number of the first block whose activity
+// is indexed.
+func ReadAddressIndexTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(addressIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteAddressIndexTail stores the first block whose activity is indexed.
+func WriteAddressIndexTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(addressIndexTailKey, binary.BigEndian.AppendUint64(nil, number)); err != nil {
+		log.Crit("Failed to store address index tail", "err", err)
+	}
+}
+
+// ReadAddressCallsTail retrieves the number of the first block from which the
+// internal call targets of the blocks up to the head were recorded.
+func ReadAddressCallsTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(addressCallsTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteAddressCallsTail stores the first block from which the internal call
+// targets of the blocks up to the head were recorded.
+func WriteAddressCallsTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(addressCallsTailKey, binary.BigEndian.AppendUint64(nil, number)); err != nil {
+		log.Crit("Failed to store address calls tail", "err", err)
+	}
+}
+
+// DeleteAddressIndexHead removes the last indexed block, once no block is
+// indexed.
+func DeleteAddressIndexHead(db ethdb.KeyValueWriter) {
+	if err := db.Delete(addressIndexHeadKey); err != nil {
+		log.Crit("Failed to delete address index head", "err", err)
+	}
+}
@@ -0,0 +1,148 @@
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// liveTraceBlockPrefix + block hash + namespace -> block result
+	liveTraceBlockPrefix = []byte("plugeth-lt-b")
+
+	// liveTraceTxPrefix + tx hash + block hash + namespace -> transaction result
+	liveTraceTxPrefix = []byte("plugeth-lt-t")
+
+	// liveTraceNumberPrefix + num (uint64 big endian) + block hash -> hashes of
+	// the transactions with a stored result
+	liveTraceNumberPrefix = []byte("plugeth-lt-n")
+)
+
+func liveTraceBlockKey(namespace string, hash common.Hash) []byte {
+	key := append(append([]byte{}, liveTraceBlockPrefix...), hash.Bytes()...)
+	return append(key, namespace...)
+}
+
+func liveTraceNumberKey(number uint64, hash common.Hash) []byte {
+	key := append(append([]byte{}, liveTraceNumberPrefix...), encodeBlockNumber(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+func liveTraceTxKey(namespace string, txHash, blockHash common.Hash) []byte {
+	key := append(append([]byte{}, liveTraceTxPrefix...), txHash.Bytes()...)
+	key = append(key, blockHash.Bytes()...)
+	return append(key, namespace...)
+}
+
+// ReadLiveTraceBlock retrieves the stored live tracer result of a block.
+func ReadLiveTraceBlock(db ethdb.KeyValueReader, namespace string, hash common.Hash) []byte {
+	data, _ := db.Get(liveTraceBlockKey(namespace, hash))
+	return data
+}
+
+// WriteLiveTraceBlock stores the live tracer result of a block.
+func WriteLiveTraceBlock(db ethdb.KeyValueWriter, namespace string, hash common.Hash, result []byte) {
+	if err := db.Put(liveTraceBlockKey(namespace, hash), result); err != nil {
+		log.Crit("Failed to store live trace", "namespace", namespace, "hash", hash, "err", err)
+	}
+}
+
+// ReadLiveTraceTransactions retrieves the stored live tracer results of a
+// transaction, keyed by the hash of the block it was executed in. A
+// transaction has more than one result if it was included in several forks.
+func ReadLiveTraceTransactions(db ethdb.Iteratee, namespace string, txHash common.Hash) map[common.Hash][]byte {
+	prefix := append(append([]byte{}, liveTraceTxPrefix...), txHash.Bytes()...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	results := make(map[common.Hash][]byte)
+	for it.Next() {
+		key := it.Key()
+		if len(key) < len(prefix)+common.HashLength || !bytes.Equal(key[len(prefix)+common.HashLength:], []byte(namespace)) {
+			continue
+		}
+		results[common.BytesToHash(key[len(prefix):len(prefix)+common.HashLength])] = common.CopyBytes(it.Value())
+	}
+	return results
+}
+
+// WriteLiveTraceTransaction stores the live tracer result of a transaction
+// executed in the given block.
+func WriteLiveTraceTransaction(db ethdb.KeyValueWriter, namespace string, txHash, blockHash common.Hash, result []byte) {
+	if err := db.Put(liveTraceTxKey(namespace, txHash, blockHash), result); err != nil {
+		log.Crit("Failed to store live trace", "namespace", namespace, "hash", txHash, "err", err)
+	}
+}
+
+// WriteLiveTraceIndex records that live tracer results were stored for a
+// block and the given transactions of it, so they can be deleted later on.
+func WriteLiveTraceIndex(db ethdb.KeyValueWriter, number uint64, hash common.Hash, txs []common.Hash) {
+	blob := make([]byte, 0, len(txs)*common.HashLength)
+	for _, tx := range txs {
+		blob = append(blob, tx.Bytes()...)
+	}
+	if err := db.Put(liveTraceNumberKey(number, hash), blob); err != nil {
+		log.Crit("Failed to store live trace index", "number", number, "hash", hash, "err", err)
+	}
+}
+
+// DeleteLiveTraces deletes the live tracer results stored for a block and its
+// transactions, under every namespace.
+func DeleteLiveTraces(db ethdb.KeyValueStore, batch ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	key := liveTraceNumberKey(number, hash)
+	blob, err := db.Get(key)
+	if err != nil {
+		return // No results stored
+	}
+	deleteLiveTraceEntries(db, batch, number, hash, blob)
+	if err := batch.Delete(key); err != nil {
+		log.Crit("Failed to delete live trace index", "err", err)
+	}
+}
+
+// PruneLiveTraces deletes the live tracer results stored for the blocks below
+// the given number, canonical or not, returning the number of blocks pruned.
+func PruneLiveTraces(db ethdb.KeyValueStore, batch ethdb.KeyValueWriter, limit uint64) int {
+	it := db.NewIterator(liveTraceNumberPrefix, nil)
+	defer it.Release()
+
+	var pruned int
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(liveTraceNumberPrefix)+8+common.HashLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(liveTraceNumberPrefix):])
+		if number >= limit {
+			break
+		}
+		deleteLiveTraceEntries(db, batch, number, common.BytesToHash(key[len(liveTraceNumberPrefix)+8:]), it.Value())
+		if err := batch.Delete(key); err != nil {
+			log.Crit("Failed to delete live trace index", "err", err)
+		}
+		pruned++
+	}
+	return pruned
+}
+
+// deleteLiveTraceEntries deletes the results of a block and of the listed
+// transactions of it, under every namespace.
+func deleteLiveTraceEntries(db ethdb.Iteratee, batch ethdb.KeyValueWriter, number uint64, hash common.Hash, txs []byte) {
+	prefixes := [][]byte{append(append([]byte{}, liveTraceBlockPrefix...), hash.Bytes()...)}
+	for i := 0; i+common.HashLength <= len(txs); i += common.HashLength {
+		prefix := append(append([]byte{}, liveTraceTxPrefix...), txs[i:i+common.HashLength]...)
+		prefixes = append(prefixes, append(prefix, hash.Bytes()...))
+	}
+	for _, prefix := range prefixes {
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			if err := batch.Delete(it.Key()); err != nil {
+				log.Crit("Failed to delete live trace", "number", number, "hash", hash, "err", err)
+			}
+		}
+		it.Release()
+	}
+}
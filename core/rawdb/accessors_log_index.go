@@ -0,0 +1,100 @@
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// LogIndexSectionSize is the number of blocks covered by a posting list of the
+// log index.
+const LogIndexSectionSize = 4096
+
+var (
+	// logIndexPrefix + term + section -> offsets of the blocks holding the term
+	logIndexPrefix = []byte("plugeth-li-")
+
+	// Head (number + hash) of the indexed block range
+	logIndexHeadKey = []byte("plugeth-lm-head")
+)
+
+// Kinds of the terms of the log index.
+const (
+	logIndexAddress = 'a'
+	logIndexTopic   = 't'
+)
+
+// LogIndexAddressTerm returns the term of the log index under which the blocks
+// with logs emitted by an address are listed.
+func LogIndexAddressTerm(addr common.Address) string {
+	return string(append([]byte{logIndexAddress}, addr.Bytes()...))
+}
+
+// LogIndexTopicTerm returns the term of the log index under which the blocks
+// with logs holding a topic, at any position, are listed.
+func LogIndexTopicTerm(topic common.Hash) string {
+	return string(append([]byte{logIndexTopic}, topic.Bytes()...))
+}
+
+func logIndexKey(term string, section uint64) []byte {
+	key := append(append([]byte{}, logIndexPrefix...), term...)
+	return binary.BigEndian.AppendUint64(key, section)
+}
+
+// ReadLogIndexSection retrieves the ascending numbers of the blocks of a
+// section holding the given term.
+func ReadLogIndexSection(db ethdb.KeyValueReader, term string, section uint64) []uint64 {
+	data, _ := db.Get(logIndexKey(term, section))
+	numbers := make([]uint64, 0, len(data)/2)
+	for i := 0; i+2 <= len(data); i += 2 {
+		numbers = append(numbers, section*LogIndexSectionSize+uint64(binary.BigEndian.Uint16(data[i:])))
+	}
+	return numbers
+}
+
+// WriteLogIndexSection stores the ascending numbers of the blocks of a section
+// holding the given term, removing the posting list if there are none.
+func WriteLogIndexSection(db ethdb.KeyValueWriter, term string, section uint64, numbers []uint64) {
+	key := logIndexKey(term, section)
+	if len(numbers) == 0 {
+		if err := db.Delete(key); err != nil {
+			log.Crit("Failed to delete log index section", "err", err)
+		}
+		return
+	}
+	data := make([]byte, 0, 2*len(numbers))
+	for _, number := range numbers {
+		data = binary.BigEndian.AppendUint16(data, uint16(number-section*LogIndexSectionSize))
+	}
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store log index section", "err", err)
+	}
+}
+
+// ReadLogIndexHead retrieves the number and hash of the last block whose logs
+// are indexed.
+func ReadLogIndexHead(db ethdb.KeyValueReader) (uint64, common.Hash, bool) {
+	data, _ := db.Get(logIndexHeadKey)
+	if len(data) != 8+common.HashLength {
+		return 0, common.Hash{}, false
+	}
+	return binary.BigEndian.Uint64(data), common.BytesToHash(data[8:]), true
+}
+
+// WriteLogIndexHead stores the last block whose logs are indexed.
+func WriteLogIndexHead(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	data := binary.BigEndian.AppendUint64(nil, number)
+	if err := db.Put(logIndexHeadKey, append(data, hash.Bytes()...)); err != nil {
+		log.Crit("Failed to store log index head", "err", err)
+	}
+}
+
+// DeleteLogIndexHead removes the last indexed block, the index not being
+// usable until rebuilt.
+func DeleteLogIndexHead(db ethdb.KeyValueWriter) {
+	if err := db.Delete(logIndexHeadKey); err != nil {
+		log.Crit("Failed to delete log index head", "err", err)
+	}
+}
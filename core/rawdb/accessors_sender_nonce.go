@@ -0,0 +1,98 @@
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// senderNoncePrefix + sender + nonce -> transaction hash
+	senderNoncePrefix = []byte("plugeth-sn-")
+
+	// Head and tail (numbers) of the indexed block range
+	senderNonceHeadKey = []byte("plugeth-sm-head")
+	senderNonceTailKey = []byte("plugeth-sm-tail")
+)
+
+func senderNonceKey(sender common.Address, nonce uint64) []byte {
+	key := append(append([]byte{}, senderNoncePrefix...), sender.Bytes()...)
+	return binary.BigEndian.AppendUint64(key, nonce)
+}
+
+// ReadSenderNonceLookup retrieves the hash of the canonical transaction sent
+// by an address with the given nonce, the zero hash if it is not indexed.
+func ReadSenderNonceLookup(db ethdb.KeyValueReader, sender common.Address, nonce uint64) common.Hash {
+	data, _ := db.Get(senderNonceKey(sender, nonce))
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSenderNonceLookup stores the hash of the transaction sent by an address
+// with the given nonce.
+func WriteSenderNonceLookup(db ethdb.KeyValueWriter, sender common.Address, nonce uint64, hash common.Hash) {
+	if err := db.Put(senderNonceKey(sender, nonce), hash.Bytes()); err != nil {
+		log.Crit("Failed to store sender nonce lookup", "err", err)
+	}
+}
+
+// DeleteSenderNonceLookup removes the transaction sent by an address with the
+// given nonce from the index.
+func DeleteSenderNonceLookup(db ethdb.KeyValueWriter, sender common.Address, nonce uint64) {
+	if err := db.Delete(senderNonceKey(sender, nonce)); err != nil {
+		log.Crit("Failed to delete sender nonce lookup", "err", err)
+	}
+}
+
+// ReadSenderNonceIndexHead retrieves the number of the last block whose
+// transactions are indexed by sender and nonce.
+func ReadSenderNonceIndexHead(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(senderNonceHeadKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteSenderNonceIndexHead stores the number of the last block whose
+// transactions are indexed by sender and nonce.
+func WriteSenderNonceIndexHead(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(senderNonceHeadKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store sender nonce index head", "err", err)
+	}
+}
+
+// ReadSenderNonceIndexTail retrieves the number of the first block whose
+// transactions are indexed by sender and nonce.
+func ReadSenderNonceIndexTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(senderNonceTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteSenderNonceIndexTail stores the number of the first block whose
+// transactions are indexed by sender and nonce.
+func WriteSenderNonceIndexTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(senderNonceTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store sender nonce index tail", "err", err)
+	}
+}
+
+// DeleteSenderNonceIndexRange removes the head and tail markers, the index
+// then being rebuilt once enabled again.
+func DeleteSenderNonceIndexRange(db ethdb.KeyValueWriter) {
+	if err := db.Delete(senderNonceHeadKey); err != nil {
+		log.Crit("Failed to delete sender nonce index head", "err", err)
+	}
+	if err := db.Delete(senderNonceTailKey); err != nil {
+		log.Crit("Failed to delete sender nonce index tail", "err", err)
+	}
+}
@@ -77,5 +77,5 @@ var freezers = []string{ChainFreezerName, StateFreezerName}
 
 // NewStateFreezer initializes the freezer for state history.
 func NewStateFreezer(ancientDir string, readOnly bool) (*ResettableFreezer, error) {
-	return NewResettableFreezer(filepath.Join(ancientDir, StateFreezerName), "eth/db/state", readOnly, stateHistoryTableSize, stateFreezerNoSnappy)
+	return newResettableFreezer(filepath.Join(ancientDir, StateFreezerName), StateFreezerName, "eth/db/state", readOnly, stateHistoryTableSize, stateFreezerNoSnappy) // PluGeth injection
 }
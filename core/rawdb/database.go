@@ -199,8 +199,14 @@ func resolveChainFreezerDir(ancient string) string {
 // storage. The passed ancient indicates the path of root ancient directory
 // where the chain freezer can be opened.
 func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+	return newDatabaseWithFreezer(db, ancient, "", namespace, readonly)
+}
+
+// newDatabaseWithFreezer is NewDatabaseWithFreezer storing the chain
+// segments with the named plugin provided ancient engine, if set.
+func newDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, engine string, namespace string, readonly bool) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly)
+	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), engine, namespace, readonly)
 	if err != nil {
 		printChainMetadata(db)
 		return nil, err
@@ -341,6 +347,11 @@ const (
 // instantiated at that location, and if so, returns the type of database (or the
 // empty string).
 func PreexistingDatabase(path string) string {
+	// begin PluGeth code injection
+	if engine := pluginEngine(path); len(engine) != 0 {
+		return engine
+	}
+	// end PluGeth code injection
 	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
 		return "" // No pre-existing db
 	}
@@ -356,9 +367,10 @@ func PreexistingDatabase(path string) string {
 // OpenOptions contains the options to apply when opening a database.
 // OBS: If AncientsDirectory is empty, it indicates that no freezer is to be used.
 type OpenOptions struct {
-	Type              string // "leveldb" | "pebble"
+	Type              string // "leveldb" | "pebble" | a plugin provided engine
 	Directory         string // the datadir
 	AncientsDirectory string // the ancients-dir
+	AncientEngine     string // plugin provided engine storing the chain segments, the flat file freezer if empty
 	Namespace         string // the namespace for database relevant metrics
 	Cache             int    // the capacity(in megabytes) of the data caching
 	Handles           int    // number of files to be open simultaneously
@@ -375,6 +387,11 @@ type OpenOptions struct {
 //	db is non-existent |  pebble default  |  specified type
 //	db is existent     |  from db         |  specified type (if compatible)
 func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
+	// begin PluGeth code injection
+	if db, ok, err := openPluginDatabase(o); ok {
+		return db, err
+	}
+	// end PluGeth code injection
 	// Reject any unsupported database type
 	if len(o.Type) != 0 && o.Type != dbLeveldb && o.Type != dbPebble {
 		return nil, fmt.Errorf("unknown db.engine %v", o.Type)
@@ -411,7 +428,7 @@ func Open(o OpenOptions) (ethdb.Database, error) {
 	if len(o.AncientsDirectory) == 0 {
 		return kvdb, nil
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly)
+	frdb, err := newDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.AncientEngine, o.Namespace, o.ReadOnly)
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -74,12 +74,17 @@ type Freezer struct {
 	tables       map[string]*freezerTable // Data tables for storing everything
 	instanceLock *flock.Flock             // File-system lock to prevent double opens
 	closeOnce    sync.Once
+
+	// begin PluGeth injection
+	name     string          // Name of the freezer ("chain" or "state"), identifying it to plugins
+	prunable map[string]bool // Tables whose tail can be pruned beyond the others
+	// end PluGeth injection
 }
 
 // NewChainFreezer is a small utility method around NewFreezer that sets the
 // default parameters for the chain storage.
 func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer, error) {
-	return NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	return newFreezer(datadir, ChainFreezerName, namespace, readonly, freezerTableSize, chainFreezerNoSnappy, chainFreezerPrunable) // PluGeth injection
 }
 
 // NewFreezer creates a freezer instance for maintaining immutable ordered
@@ -88,6 +93,14 @@ func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer,
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, "", namespace, readonly, maxTableSize, tables, nil) // PluGeth injection
+}
+
+// newFreezer creates a freezer instance identified to plugins by the given
+// name, which is independent of the folder as the chain freezer may be in the
+// legacy location at the ancient root. The tail of the prunable tables is
+// allowed to go beyond the others. (PluGeth injection)
+func newFreezer(datadir string, name string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, prunable map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -122,6 +135,10 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 		readonly:     readonly,
 		tables:       make(map[string]*freezerTable),
 		instanceLock: lock,
+		// begin PluGeth injection
+		name:     name,
+		prunable: prunable,
+		// end PluGeth injection
 	}
 
 	// Create the tables.
@@ -263,6 +280,9 @@ func (f *Freezer) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (writeSize
 					log.Error("Freezer table roll-back failed", "table", name, "index", prevItem, "err", err)
 				}
 			}
+			// begin PluGeth injection
+			pluginDiscardUpdates(f.name)
+			// end PluGeth injection
 		}
 	}()
 
@@ -275,7 +295,7 @@ func (f *Freezer) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (writeSize
 		return 0, err
 	}
 	//begin PluGeth code injection
-	pluginCommitUpdate(item)
+	pluginCommitUpdate(f.name, item)
 	//end PluGeth code injection
 	// atomic.StoreUint64(*f.frozen, item)
 	f.frozen.Store(item)
@@ -301,6 +321,9 @@ func (f *Freezer) TruncateHead(items uint64) (uint64, error) {
 		}
 	}
 	f.frozen.Store(items)
+	// begin PluGeth injection
+	pluginTruncateAncientHead(f.name, f.tableNames(), oitems, items)
+	// end PluGeth injection
 	return oitems, nil
 }
 
@@ -322,6 +345,9 @@ func (f *Freezer) TruncateTail(tail uint64) (uint64, error) {
 		}
 	}
 	f.tail.Store(tail)
+	// begin PluGeth injection
+	pluginTruncateAncientTail(f.name, f.tableNames(), old, tail)
+	// end PluGeth injection
 	return old, nil
 }
 
@@ -352,6 +378,10 @@ func (f *Freezer) validate() error {
 	)
 	// Hack to get boundary of any table
 	for kind, table := range f.tables {
+		// PluGeth injection: the tail of the prunable tables may be beyond
+		if f.prunable[kind] {
+			continue
+		}
 		head = table.items.Load()
 		tail = table.itemHidden.Load()
 		name = kind
@@ -362,6 +392,12 @@ func (f *Freezer) validate() error {
 		if head != table.items.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing head: %d != %d", kind, name, table.items.Load(), head)
 		}
+		if f.prunable[kind] { // PluGeth injection
+			if tail > table.itemHidden.Load() {
+				return fmt.Errorf("freezer table %s has tail %d below %d", kind, table.itemHidden.Load(), tail)
+			}
+			continue
+		}
 		if tail != table.itemHidden.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing tail: %d != %d", kind, name, table.itemHidden.Load(), tail)
 		}
@@ -377,11 +413,15 @@ func (f *Freezer) repair() error {
 		head = uint64(math.MaxUint64)
 		tail = uint64(0)
 	)
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		items := table.items.Load()
 		if head > items {
 			head = items
 		}
+		// PluGeth injection: the tail of the prunable tables may be beyond
+		if f.prunable[kind] {
+			continue
+		}
 		hidden := table.itemHidden.Load()
 		if hidden > tail {
 			tail = hidden
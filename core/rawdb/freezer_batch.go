@@ -31,10 +31,14 @@ const freezerBatchBufferLimit = 2 * 1024 * 1024
 // freezerBatch is a write operation of multiple items on a freezer.
 type freezerBatch struct {
 	tables map[string]*freezerTableBatch
+
+	// begin PluGeth injection
+	freezer string
+	// end PluGeth injection
 }
 
 func newFreezerBatch(f *Freezer) *freezerBatch {
-	batch := &freezerBatch{tables: make(map[string]*freezerTableBatch, len(f.tables))}
+	batch := &freezerBatch{tables: make(map[string]*freezerTableBatch, len(f.tables)), freezer: f.name}
 	for kind, table := range f.tables {
 		batch.tables[kind] = table.newBatch()
 	}
@@ -44,7 +48,7 @@ func newFreezerBatch(f *Freezer) *freezerBatch {
 // Append adds an RLP-encoded item of the given kind.
 func (batch *freezerBatch) Append(kind string, num uint64, item interface{}) error {
 	// begin PluGeth injection
-	PluginTrackUpdate(num, kind, item)
+	PluginTrackUpdate(batch.freezer, num, kind, item)
 	// end PluGeth injection
 	return batch.tables[kind].Append(num, item)
 }
@@ -52,7 +56,7 @@ func (batch *freezerBatch) Append(kind string, num uint64, item interface{}) err
 // AppendRaw adds an item of the given kind.
 func (batch *freezerBatch) AppendRaw(kind string, num uint64, item []byte) error {
 	// being PluGeth injection
-	PluginTrackUpdate(num, kind, item)
+	PluginTrackUpdate(batch.freezer, num, kind, item)
 	// end PluGeth injection
 	return batch.tables[kind].AppendRaw(num, item)
 }
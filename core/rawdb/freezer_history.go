@@ -0,0 +1,210 @@
+package rawdb
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// chainFreezerPrunable are the tables of the chain freezer whose items can be
+// pruned below the tail of the others, the block history then being served
+// by a HistorySource.
+var chainFreezerPrunable = map[string]bool{
+	ChainFreezerBodiesTable:  true,
+	ChainFreezerReceiptTable: true,
+}
+
+// errHistoryNotSupported is returned if the history is pruned from a database
+// whose chain freezer does not support it.
+var errHistoryNotSupported = errors.New("ancient store does not support history pruning")
+
+// HistorySource serves the items pruned from the bodies and receipts tables
+// of the chain freezer, in the encoding of the tables. Items are requested
+// with the canonical hash of their block, which the source must check.
+type HistorySource interface {
+	Ancient(kind string, number uint64, hash common.Hash) ([]byte, error)
+}
+
+// historySource boxes a HistorySource for atomic access.
+type historySource struct {
+	HistorySource
+}
+
+// SetHistorySource sets the source serving the bodies and receipts pruned
+// from the chain freezer of the database.
+func SetHistorySource(db ethdb.Database, source HistorySource) error {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return errHistoryNotSupported
+	}
+	chain, ok := frdb.AncientStore.(*chainFreezer)
+	if !ok {
+		return errHistoryNotSupported
+	}
+	chain.history.Store(&historySource{source})
+	return nil
+}
+
+// ReadHistoryTail retrieves the number of the first block whose body and
+// receipts are held by the chain freezer of the database, the ones before
+// having been pruned.
+func ReadHistoryTail(db ethdb.Database) (uint64, error) {
+	f, err := prunableFreezer(db)
+	if err != nil {
+		return 0, err
+	}
+	return f.prunedTail(), nil
+}
+
+// PruneHistory discards the bodies and receipts of the blocks below tail from
+// the chain freezer of the database, keeping their headers. The tail must not
+// be beyond the frozen blocks.
+func PruneHistory(db ethdb.Database, tail uint64) error {
+	f, err := prunableFreezer(db)
+	if err != nil {
+		return err
+	}
+	// begin PluGeth injection
+	restore := SetTruncateReason(f.name, TruncateReasonHistoryExpiry)
+	defer restore()
+	// end PluGeth injection
+	return f.truncatePrunableTail(tail)
+}
+
+// prunableFreezer returns the flat file freezer backing the chain freezer of
+// the database, the only one whose history can be pruned.
+func prunableFreezer(db ethdb.Database) (*Freezer, error) {
+	frdb, ok := db.(*freezerdb)
+	if !ok {
+		return nil, errHistoryNotSupported
+	}
+	chain, ok := frdb.AncientStore.(*chainFreezer)
+	if !ok {
+		return nil, errHistoryNotSupported
+	}
+	f, ok := chain.AncientStore.(*Freezer)
+	if !ok {
+		return nil, errHistoryNotSupported
+	}
+	return f, nil
+}
+
+// prunedTail returns the number of the first item held by all the prunable
+// tables.
+func (f *Freezer) prunedTail() uint64 {
+	tail := f.tail.Load()
+	for kind, table := range f.tables {
+		if f.prunable[kind] {
+			if hidden := table.itemHidden.Load(); hidden > tail {
+				tail = hidden
+			}
+		}
+	}
+	return tail
+}
+
+// truncatePrunableTail discards the items below tail from the prunable tables.
+func (f *Freezer) truncatePrunableTail(tail uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.writeLock.Lock()
+	defer f.writeLock.Unlock()
+
+	if tail > f.frozen.Load() {
+		return errors.New("truncation above head")
+	}
+	var (
+		old   = f.prunedTail()
+		names []string
+	)
+	for _, kind := range f.tableNames() {
+		if f.prunable[kind] {
+			if err := f.tables[kind].truncateTail(tail); err != nil {
+				return err
+			}
+			names = append(names, kind)
+		}
+	}
+	// begin PluGeth injection
+	if tail > old {
+		pluginTruncateAncientTail(f.name, names, old, tail)
+	}
+	// end PluGeth injection
+	return nil
+}
+
+// Ancient retrieves an ancient binary blob, the pruned bodies and receipts
+// being served by the history source.
+func (f *chainFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	return f.historyReader(f.AncientStore).Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, the pruned bodies and
+// receipts being served by the history source.
+func (f *chainFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return f.historyReader(f.AncientStore).AncientRange(kind, start, count, maxBytes)
+}
+
+// ReadAncients runs the given read operation while ensuring that no writes
+// take place, the pruned bodies and receipts being served by the history
+// source.
+func (f *chainFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	return f.AncientStore.ReadAncients(func(op ethdb.AncientReaderOp) error {
+		return fn(f.historyReader(op))
+	})
+}
+
+// historyReader wraps a reader of the chain freezer to fall back to the
+// history source.
+func (f *chainFreezer) historyReader(op ethdb.AncientReaderOp) ethdb.AncientReaderOp {
+	source := f.history.Load()
+	if source == nil {
+		return op
+	}
+	return &historyReader{AncientReaderOp: op, source: source.HistorySource}
+}
+
+// historyReader is a reader of the chain freezer retrieving the bodies and
+// receipts missing from it from a history source.
+type historyReader struct {
+	ethdb.AncientReaderOp
+	source HistorySource
+}
+
+func (r *historyReader) Ancient(kind string, number uint64) ([]byte, error) {
+	data, err := r.AncientReaderOp.Ancient(kind, number)
+	if err == nil || !chainFreezerPrunable[kind] {
+		return data, err
+	}
+	hash, herr := r.AncientReaderOp.Ancient(ChainFreezerHashTable, number)
+	if herr != nil {
+		return nil, err
+	}
+	return r.source.Ancient(kind, number, common.BytesToHash(hash))
+}
+
+func (r *historyReader) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	items, err := r.AncientReaderOp.AncientRange(kind, start, count, maxBytes)
+	if err == nil || !chainFreezerPrunable[kind] {
+		return items, err
+	}
+	var size uint64
+	items = nil
+	for number := start; number < start+count; number++ {
+		item, ierr := r.Ancient(kind, number)
+		if ierr != nil {
+			break
+		}
+		if len(items) > 0 && maxBytes > 0 && size+uint64(len(item)) > maxBytes {
+			break
+		}
+		items = append(items, item)
+		size += uint64(len(item))
+	}
+	if len(items) == 0 {
+		return nil, err
+	}
+	return items, nil
+}
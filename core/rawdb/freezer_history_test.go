@@ -0,0 +1,122 @@
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/plugins"
+)
+
+// testHistorySource serves items identifying their table and block.
+type testHistorySource struct{}
+
+func (testHistorySource) Ancient(kind string, number uint64, hash common.Hash) ([]byte, error) {
+	if hash != (common.Hash{byte(number)}) {
+		return nil, fmt.Errorf("hash mismatch for block %d", number)
+	}
+	return []byte(fmt.Sprintf("%s-%d", kind, number)), nil
+}
+
+func TestPruneHistory(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), dir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	_, err = db.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for number := uint64(0); number < 10; number++ {
+			for kind := range chainFreezerNoSnappy {
+				data := []byte(fmt.Sprintf("%s-%d", kind, number))
+				if kind == ChainFreezerHashTable {
+					data = common.Hash{byte(number)}.Bytes()
+				}
+				if err := op.AppendRaw(kind, number, data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to write ancients: %v", err)
+	}
+	if err := PruneHistory(db, 11); err == nil {
+		t.Fatal("pruned history beyond the frozen blocks")
+	}
+	var have []truncation
+	done := plugins.HookTester("TruncateAncientTail", func(freezer string, tables []string, old, new uint64, reason string) {
+		have = append(have, truncation{freezer, tables, old, new, reason})
+	})
+	if err := PruneHistory(db, 5); err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if err := PruneHistory(db, 5); err != nil { // noop, not reported
+		t.Fatalf("failed to prune history again: %v", err)
+	}
+	done()
+	want := truncation{ChainFreezerName, []string{ChainFreezerBodiesTable, ChainFreezerReceiptTable}, 0, 5, TruncateReasonHistoryExpiry}
+	if len(have) != 1 || fmt.Sprint(have[0]) != fmt.Sprint(want) {
+		t.Fatalf("truncations mismatch: have %v, want [%v]", have, want)
+	}
+	check := func(db ethdb.Database, served bool) {
+		t.Helper()
+		if tail, err := ReadHistoryTail(db); err != nil || tail != 5 {
+			t.Fatalf("history tail mismatch: have %d (%v), want 5", tail, err)
+		}
+		if tail, _ := db.Tail(); tail != 0 {
+			t.Fatalf("freezer tail mismatch: have %d, want 0", tail)
+		}
+		for _, kind := range []string{ChainFreezerHeaderTable, ChainFreezerHashTable, ChainFreezerDifficultyTable} {
+			if _, err := db.Ancient(kind, 2); err != nil {
+				t.Fatalf("%s of pruned block not kept: %v", kind, err)
+			}
+		}
+		for _, kind := range []string{ChainFreezerBodiesTable, ChainFreezerReceiptTable} {
+			want := []byte(fmt.Sprintf("%s-%d", kind, 2))
+			data, err := db.Ancient(kind, 2)
+			if served != (err == nil) || (served && !bytes.Equal(data, want)) {
+				t.Fatalf("%s of pruned block mismatch: have %q (%v), served %v", kind, data, err, served)
+			}
+			if data, err := db.Ancient(kind, 5); err != nil || string(data) != fmt.Sprintf("%s-%d", kind, 5) {
+				t.Fatalf("%s of kept block mismatch: have %q (%v)", kind, data, err)
+			}
+			items, err := db.AncientRange(kind, 3, 4, 0)
+			if served != (err == nil) || (served && len(items) != 4) {
+				t.Fatalf("%s range mismatch: have %d items (%v), served %v", kind, len(items), err, served)
+			}
+			db.ReadAncients(func(op ethdb.AncientReaderOp) error {
+				if _, err := op.Ancient(kind, 2); served != (err == nil) {
+					t.Fatalf("%s of pruned block mismatch in read operation: %v, served %v", kind, err, served)
+				}
+				return nil
+			})
+		}
+	}
+	check(db, false)
+	if err := SetHistorySource(db, testHistorySource{}); err != nil {
+		t.Fatalf("failed to set history source: %v", err)
+	}
+	check(db, true)
+
+	// The pruned tail is kept when reopening the freezer
+	db.Close()
+	db, err = NewDatabaseWithFreezer(NewMemoryDatabase(), dir, "", false)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	check(db, false)
+}
+
+func TestPruneHistoryUnsupported(t *testing.T) {
+	db := NewMemoryDatabase()
+	if err := PruneHistory(db, 1); err != errHistoryNotSupported {
+		t.Fatalf("pruning without a freezer: have %v, want %v", err, errHistoryNotSupported)
+	}
+	if err := SetHistorySource(db, testHistorySource{}); err != errHistoryNotSupported {
+		t.Fatalf("history source without a freezer: have %v, want %v", err, errHistoryNotSupported)
+	}
+}
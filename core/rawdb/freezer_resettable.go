@@ -49,11 +49,17 @@ type ResettableFreezer struct {
 // The reset function will delete directory atomically and re-create the
 // freezer from scratch.
 func NewResettableFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*ResettableFreezer, error) {
+	return newResettableFreezer(datadir, "", namespace, readonly, maxTableSize, tables) // PluGeth injection
+}
+
+// newResettableFreezer creates a resettable freezer identified to plugins by
+// the given name. (PluGeth injection)
+func newResettableFreezer(datadir string, name string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*ResettableFreezer, error) {
 	if err := cleanup(datadir); err != nil {
 		return nil, err
 	}
 	opener := func() (*Freezer, error) {
-		return NewFreezer(datadir, namespace, readonly, maxTableSize, tables)
+		return newFreezer(datadir, name, namespace, readonly, maxTableSize, tables, nil)
 	}
 	freezer, err := opener()
 	if err != nil {
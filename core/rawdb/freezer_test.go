@@ -42,6 +42,37 @@ var freezerTestTableDef = map[string]bool{"test": true}
 
 func TestFreezerModify(t *testing.T) {
 	t.Parallel()
+	testAncientModify(t, openAncientFreezerForTesting)
+}
+
+// begin PluGeth injection
+// ancientStoreFactory opens the ancient store with the given tables in a
+// directory, creating it if it doesn't exist yet, so the tests of the freezer
+// semantics also run against plugin stores.
+type ancientStoreFactory func(t *testing.T, dir string, tables map[string]bool, readonly bool) (ethdb.AncientStore, error)
+
+func openAncientFreezerForTesting(t *testing.T, dir string, tables map[string]bool, readonly bool) (ethdb.AncientStore, error) {
+	// note: using low max table size here to ensure the tests actually
+	// switch between multiple files.
+	return NewFreezer(dir, "", readonly, 2049, tables)
+}
+
+// newAncientStore creates an empty ancient store and returns it with its
+// directory.
+func newAncientStore(t *testing.T, open ancientStoreFactory, tables map[string]bool) (ethdb.AncientStore, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	f, err := open(t, dir, tables, false)
+	if err != nil {
+		t.Fatal("can't open ancient store", err)
+	}
+	return f, dir
+}
+
+// end PluGeth injection
+
+func testAncientModify(t *testing.T, open ancientStoreFactory) {
 
 	// Create test data.
 	var valuesRaw [][]byte
@@ -55,7 +86,7 @@ func TestFreezerModify(t *testing.T) {
 	}
 
 	tables := map[string]bool{"raw": true, "rlp": false}
-	f, _ := newFreezerForTesting(t, tables)
+	f, _ := newAncientStore(t, open, tables)
 	defer f.Close()
 
 	// Commit test data.
@@ -75,8 +106,10 @@ func TestFreezerModify(t *testing.T) {
 	}
 
 	// Dump indexes.
-	for _, table := range f.tables {
-		t.Log(table.name, "index:", table.dumpIndexString(0, int64(len(valuesRaw))))
+	if f, ok := f.(*Freezer); ok {
+		for _, table := range f.tables {
+			t.Log(table.name, "index:", table.dumpIndexString(0, int64(len(valuesRaw))))
+		}
 	}
 
 	// Read back test data.
@@ -99,8 +132,11 @@ func TestFreezerModify(t *testing.T) {
 // when the function passed to it returns an error.
 func TestFreezerModifyRollback(t *testing.T) {
 	t.Parallel()
+	testAncientModifyRollback(t, openAncientFreezerForTesting)
+}
 
-	f, dir := newFreezerForTesting(t, freezerTestTableDef)
+func testAncientModifyRollback(t *testing.T, open ancientStoreFactory) {
+	f, dir := newAncientStore(t, open, freezerTestTableDef)
 
 	theError := errors.New("oops")
 	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
@@ -119,7 +155,7 @@ func TestFreezerModifyRollback(t *testing.T) {
 
 	// Reopen and check that the rolled-back data doesn't reappear.
 	tables := map[string]bool{"test": true}
-	f2, err := NewFreezer(dir, "", false, 2049, tables)
+	f2, err := open(t, dir, tables, false)
 	if err != nil {
 		t.Fatalf("can't reopen freezer after failed ModifyAncients: %v", err)
 	}
@@ -130,8 +166,11 @@ func TestFreezerModifyRollback(t *testing.T) {
 // This test runs ModifyAncients and Ancient concurrently with each other.
 func TestFreezerConcurrentModifyRetrieve(t *testing.T) {
 	t.Parallel()
+	testAncientConcurrentModifyRetrieve(t, openAncientFreezerForTesting)
+}
 
-	f, _ := newFreezerForTesting(t, freezerTestTableDef)
+func testAncientConcurrentModifyRetrieve(t *testing.T, open ancientStoreFactory) {
+	f, _ := newAncientStore(t, open, freezerTestTableDef)
 	defer f.Close()
 
 	var (
@@ -191,7 +230,11 @@ func TestFreezerConcurrentModifyRetrieve(t *testing.T) {
 
 // This test runs ModifyAncients and TruncateHead concurrently with each other.
 func TestFreezerConcurrentModifyTruncate(t *testing.T) {
-	f, _ := newFreezerForTesting(t, freezerTestTableDef)
+	testAncientConcurrentModifyTruncate(t, openAncientFreezerForTesting)
+}
+
+func testAncientConcurrentModifyTruncate(t *testing.T, open ancientStoreFactory) {
+	f, _ := newAncientStore(t, open, freezerTestTableDef)
 	defer f.Close()
 
 	var item = make([]byte, 256)
@@ -255,6 +298,147 @@ func TestFreezerConcurrentModifyTruncate(t *testing.T) {
 	}
 }
 
+// begin PluGeth injection
+// This checks that the items below a truncated tail can't be read anymore,
+// while the others stay readable and the store can still be appended to.
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	testAncientTruncateTail(t, openAncientFreezerForTesting)
+}
+
+func testAncientTruncateTail(t *testing.T, open ancientStoreFactory) {
+	tables := map[string]bool{"a": true, "b": false}
+	f, _ := newAncientStore(t, open, tables)
+	defer f.Close()
+
+	appendAncientItems(t, f, tables, 0, 10)
+	if old, err := f.TruncateTail(4); err != nil || old != 0 {
+		t.Fatalf("TruncateTail(4) returned %d, %v, want 0, nil", old, err)
+	}
+	// Truncating below the current tail is a noop.
+	if old, err := f.TruncateTail(2); err != nil || old != 4 {
+		t.Fatalf("TruncateTail(2) returned %d, %v, want 4, nil", old, err)
+	}
+	if tail, _ := f.Tail(); tail != 4 {
+		t.Fatalf("Tail() returned %d, want 4", tail)
+	}
+	checkAncientCount(t, f, "a", 10)
+	for kind := range tables {
+		if _, err := f.Ancient(kind, 3); err != errOutOfBounds {
+			t.Fatalf("Ancient(%q, 3) returned %v, want %v", kind, err, errOutOfBounds)
+		}
+		if v, err := f.Ancient(kind, 4); err != nil || !bytes.Equal(v, getChunk(16, 4)) {
+			t.Fatalf("Ancient(%q, 4) returned %x, %v", kind, v, err)
+		}
+	}
+	appendAncientItems(t, f, tables, 10, 12)
+	checkAncientCount(t, f, "b", 12)
+}
+
+// This checks that the head, tail and items of a store survive reopening it.
+func TestFreezerReopen(t *testing.T) {
+	t.Parallel()
+	testAncientReopen(t, openAncientFreezerForTesting)
+}
+
+func testAncientReopen(t *testing.T, open ancientStoreFactory) {
+	tables := map[string]bool{"a": true, "b": false}
+	f, dir := newAncientStore(t, open, tables)
+
+	appendAncientItems(t, f, tables, 0, 20)
+	if _, err := f.TruncateHead(15); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.TruncateTail(5); err != nil {
+		t.Fatal(err)
+	}
+	require.NoError(t, f.Close())
+
+	f, err := open(t, dir, tables, false)
+	if err != nil {
+		t.Fatal("can't reopen ancient store", err)
+	}
+	defer f.Close()
+	if tail, _ := f.Tail(); tail != 5 {
+		t.Fatalf("Tail() returned %d, want 5", tail)
+	}
+	checkAncientCount(t, f, "a", 15)
+	for number := uint64(5); number < 15; number++ {
+		for kind := range tables {
+			if v, err := f.Ancient(kind, number); err != nil || !bytes.Equal(v, getChunk(16, int(number))) {
+				t.Fatalf("Ancient(%q, %d) returned %x, %v", kind, number, v, err)
+			}
+		}
+	}
+	appendAncientItems(t, f, tables, 15, 16)
+}
+
+// This checks that a store opened read only serves its items, also to
+// several concurrent instances, but can't be modified.
+func TestFreezerReadonlyStore(t *testing.T) {
+	t.Parallel()
+	testAncientReadonly(t, openAncientFreezerForTesting)
+}
+
+func testAncientReadonly(t *testing.T, open ancientStoreFactory) {
+	tables := map[string]bool{"a": true, "b": false}
+	f, dir := newAncientStore(t, open, tables)
+	appendAncientItems(t, f, tables, 0, 10)
+	require.NoError(t, f.Close())
+
+	var (
+		wg   sync.WaitGroup
+		fs   = make([]ethdb.AncientStore, 5)
+		errs = make([]error, 5)
+	)
+	for i := range fs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fs[i], errs[i] = open(t, dir, tables, true)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, f := range fs {
+		if errs[i] != nil {
+			t.Fatal("can't open ancient store read only", errs[i])
+		}
+		checkAncientCount(t, f, "a", 10)
+		if _, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err != errReadOnly {
+			t.Fatalf("ModifyAncients returned %v, want %v", err, errReadOnly)
+		}
+		if _, err := f.TruncateHead(5); err != errReadOnly {
+			t.Fatalf("TruncateHead returned %v, want %v", err, errReadOnly)
+		}
+		if _, err := f.TruncateTail(5); err != errReadOnly {
+			t.Fatalf("TruncateTail returned %v, want %v", err, errReadOnly)
+		}
+		require.NoError(t, f.Close())
+	}
+}
+
+// appendAncientItems appends the items from first up to limit to every table.
+func appendAncientItems(t *testing.T, f ethdb.AncientStore, tables map[string]bool, first, limit uint64) {
+	t.Helper()
+
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for number := first; number < limit; number++ {
+			for kind := range tables {
+				if err := op.AppendRaw(kind, number, getChunk(16, int(number))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+}
+
+// end PluGeth injection
+
 func TestFreezerReadonlyValidate(t *testing.T) {
 	tables := map[string]bool{"a": true, "b": true}
 	dir := t.TempDir()
@@ -354,7 +538,7 @@ func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, strin
 }
 
 // checkAncientCount verifies that the freezer contains n items.
-func checkAncientCount(t *testing.T, f *Freezer, kind string, n uint64) {
+func checkAncientCount(t *testing.T, f ethdb.AncientReaderOp, kind string, n uint64) {
 	t.Helper()
 
 	if frozen, _ := f.Ancients(); frozen != n {
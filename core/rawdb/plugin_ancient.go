@@ -0,0 +1,327 @@
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// PluginAncientStore is the ancient store returned by the openers of a
+// plugin's AncientEngines map. Plugins can't import this package, they
+// implement the methods and return the store as an interface{}.
+//
+// Reads are only issued for known tables and for items within the stored
+// range, writes are validated to be contiguous and to cover every table
+// before they reach the store. Reads may run concurrently with each other
+// and with a write, writes and truncations never run concurrently.
+type PluginAncientStore interface {
+	HasAncient(kind string, number uint64) (bool, error)
+	Ancient(kind string, number uint64) ([]byte, error)
+	AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error)
+	Ancients() (uint64, error)
+	Tail() (uint64, error)
+	AncientSize(kind string) (uint64, error)
+
+	// AppendAncients atomically appends the items first, first+1 and so on,
+	// each item holding the bytes to store for every table.
+	AppendAncients(first uint64, items []map[string][]byte) error
+
+	// TruncateHead discards the items from n on, TruncateTail the items
+	// below n.
+	TruncateHead(n uint64) error
+	TruncateTail(n uint64) error
+
+	Sync() error
+	Close() error
+}
+
+var errPluginMigrateTable = errors.New("ancient engine does not support table migrations")
+
+// openChainAncients opens the chain freezer with the given engine, the
+// built-in flat file freezer if it's empty and no plugin engine was used
+// for the directory before.
+func openChainAncients(datadir string, engine string, namespace string, readonly bool) (ethdb.AncientStore, error) {
+	existing := pluginEngine(datadir)
+	if len(engine) == 0 {
+		engine = existing
+	}
+	if len(engine) == 0 {
+		return NewChainFreezer(datadir, namespace, readonly)
+	}
+	if len(existing) == 0 {
+		if matches, _ := filepath.Glob(filepath.Join(datadir, "*.meta")); len(matches) > 0 {
+			return nil, fmt.Errorf("ancient engine choice was %v but found pre-existing flat file freezer in %v", engine, datadir)
+		}
+	} else if existing != engine {
+		return nil, fmt.Errorf("ancient engine choice was %v but found pre-existing %v ancient store in %v", engine, existing, datadir)
+	}
+	open, ok := pluginAncientEngine(engine)
+	if !ok {
+		return nil, fmt.Errorf("unknown ancient engine %v", engine)
+	}
+	if !readonly {
+		if err := os.MkdirAll(datadir, 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(datadir, pluginEngineFile), []byte(engine), 0644); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("Using plugin provided ancient engine", "engine", engine, "datadir", datadir)
+	return openPluginAncientStore(open, ChainFreezerName, datadir, chainFreezerNoSnappy, readonly)
+}
+
+func openPluginAncientStore(open func(string, map[string]bool, bool) (interface{}, error), name string, datadir string, tables map[string]bool, readonly bool) (*pluginAncientStore, error) {
+	item, err := open(datadir, tables, readonly)
+	if err != nil {
+		return nil, err
+	}
+	store, ok := item.(PluginAncientStore)
+	if !ok {
+		if closer, ok := item.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("ancient engine returned %T, which is not an ancient store", item)
+	}
+	return &pluginAncientStore{
+		store:    store,
+		name:     name,
+		tables:   tables,
+		readonly: readonly,
+	}, nil
+}
+
+// pluginAncientStore turns the ancient store of a plugin into an
+// ethdb.AncientStore with the semantics of the Freezer.
+type pluginAncientStore struct {
+	store    PluginAncientStore
+	name     string // Name of the store ("chain"), identifying it to plugins
+	tables   map[string]bool
+	readonly bool
+
+	// This lock synchronizes writers and the truncate operation, as well as
+	// the "atomic" (batched) read operations.
+	writeLock sync.RWMutex
+}
+
+func (s *pluginAncientStore) tableNames() []string {
+	names := make([]string, 0, len(s.tables))
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inRange checks that the item of the table is stored.
+func (s *pluginAncientStore) inRange(kind string, number uint64) error {
+	if _, ok := s.tables[kind]; !ok {
+		return errUnknownTable
+	}
+	tail, err := s.store.Tail()
+	if err != nil {
+		return err
+	}
+	head, err := s.store.Ancients()
+	if err != nil {
+		return err
+	}
+	if number < tail || number >= head {
+		return errOutOfBounds
+	}
+	return nil
+}
+
+func (s *pluginAncientStore) HasAncient(kind string, number uint64) (bool, error) {
+	if _, ok := s.tables[kind]; !ok {
+		return false, nil
+	}
+	return s.store.HasAncient(kind, number)
+}
+
+func (s *pluginAncientStore) Ancient(kind string, number uint64) ([]byte, error) {
+	if err := s.inRange(kind, number); err != nil {
+		return nil, err
+	}
+	return s.store.Ancient(kind, number)
+}
+
+func (s *pluginAncientStore) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if err := s.inRange(kind, start); err != nil {
+		return nil, err
+	}
+	return s.store.AncientRange(kind, start, count, maxBytes)
+}
+
+func (s *pluginAncientStore) Ancients() (uint64, error) {
+	return s.store.Ancients()
+}
+
+func (s *pluginAncientStore) Tail() (uint64, error) {
+	return s.store.Tail()
+}
+
+func (s *pluginAncientStore) AncientSize(kind string) (uint64, error) {
+	if _, ok := s.tables[kind]; !ok {
+		return 0, errUnknownTable
+	}
+	return s.store.AncientSize(kind)
+}
+
+func (s *pluginAncientStore) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	s.writeLock.RLock()
+	defer s.writeLock.RUnlock()
+
+	return fn(s)
+}
+
+func (s *pluginAncientStore) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (writeSize int64, err error) {
+	if s.readonly {
+		return 0, errReadOnly
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	defer func() {
+		if err != nil {
+			pluginDiscardUpdates(s.name)
+		}
+	}()
+	head, err := s.store.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	batch := &pluginAncientBatch{store: s, first: head, tables: make(map[string][][]byte, len(s.tables))}
+	if err := fn(batch); err != nil {
+		return 0, err
+	}
+	items, err := batch.items()
+	if err != nil {
+		return 0, err
+	}
+	if len(items) > 0 {
+		if err := s.store.AppendAncients(head, items); err != nil {
+			return 0, err
+		}
+	}
+	pluginCommitUpdate(s.name, head+uint64(len(items)))
+	return batch.size, nil
+}
+
+func (s *pluginAncientStore) TruncateHead(items uint64) (uint64, error) {
+	if s.readonly {
+		return 0, errReadOnly
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	oitems, err := s.store.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if oitems <= items {
+		return oitems, nil
+	}
+	if err := s.store.TruncateHead(items); err != nil {
+		return 0, err
+	}
+	pluginTruncateAncientHead(s.name, s.tableNames(), oitems, items)
+	return oitems, nil
+}
+
+func (s *pluginAncientStore) TruncateTail(tail uint64) (uint64, error) {
+	if s.readonly {
+		return 0, errReadOnly
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	old, err := s.store.Tail()
+	if err != nil {
+		return 0, err
+	}
+	if old >= tail {
+		return old, nil
+	}
+	if err := s.store.TruncateTail(tail); err != nil {
+		return 0, err
+	}
+	pluginTruncateAncientTail(s.name, s.tableNames(), old, tail)
+	return old, nil
+}
+
+func (s *pluginAncientStore) Sync() error {
+	return s.store.Sync()
+}
+
+func (s *pluginAncientStore) MigrateTable(kind string, convert convertLegacyFn) error {
+	return errPluginMigrateTable
+}
+
+func (s *pluginAncientStore) Close() error {
+	return s.store.Close()
+}
+
+// pluginAncientBatch collects the items of a write operation.
+type pluginAncientBatch struct {
+	store  *pluginAncientStore
+	first  uint64
+	tables map[string][][]byte
+	size   int64
+}
+
+func (batch *pluginAncientBatch) Append(kind string, num uint64, item interface{}) error {
+	enc, err := rlp.EncodeToBytes(item)
+	if err != nil {
+		return err
+	}
+	return batch.append(kind, num, item, enc)
+}
+
+func (batch *pluginAncientBatch) AppendRaw(kind string, num uint64, item []byte) error {
+	return batch.append(kind, num, item, append([]byte{}, item...))
+}
+
+func (batch *pluginAncientBatch) append(kind string, num uint64, item interface{}, enc []byte) error {
+	if _, ok := batch.store.tables[kind]; !ok {
+		return errUnknownTable
+	}
+	if num != batch.first+uint64(len(batch.tables[kind])) {
+		return errOutOrderInsertion
+	}
+	PluginTrackUpdate(batch.store.name, num, kind, item)
+	batch.tables[kind] = append(batch.tables[kind], enc)
+	batch.size += int64(len(enc))
+	return nil
+}
+
+// items checks that every table was written up to the same item and returns
+// the items by number.
+func (batch *pluginAncientBatch) items() ([]map[string][]byte, error) {
+	count := -1
+	for name := range batch.store.tables {
+		n := len(batch.tables[name])
+		if count >= 0 && n != count {
+			return nil, fmt.Errorf("table %s is at item %d, want %d", name, batch.first+uint64(n), batch.first+uint64(count))
+		}
+		count = n
+	}
+	if count < 0 {
+		count = 0
+	}
+	items := make([]map[string][]byte, count)
+	for i := range items {
+		items[i] = make(map[string][]byte, len(batch.tables))
+		for name, values := range batch.tables {
+			items[i][name] = values[i]
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,296 @@
+package rawdb
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/openrelayxyz/plugeth-utils/core"
+)
+
+// testAncientStore is an in-memory plugin ancient store.
+type testAncientStore struct {
+	lock    sync.RWMutex
+	tail    uint64
+	items   []map[string][]byte // items from the tail on
+	appends int
+}
+
+func (s *testAncientStore) item(number uint64) (map[string][]byte, bool) {
+	if number < s.tail || number >= s.tail+uint64(len(s.items)) {
+		return nil, false
+	}
+	return s.items[number-s.tail], true
+}
+
+func (s *testAncientStore) HasAncient(kind string, number uint64) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, ok := s.item(number)
+	return ok, nil
+}
+
+func (s *testAncientStore) Ancient(kind string, number uint64) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	item, ok := s.item(number)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return item[kind], nil
+}
+
+func (s *testAncientStore) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var (
+		values [][]byte
+		size   uint64
+	)
+	for number := start; number < start+count; number++ {
+		item, ok := s.item(number)
+		if !ok || (maxBytes > 0 && len(values) > 0 && size+uint64(len(item[kind])) > maxBytes) {
+			break
+		}
+		values = append(values, item[kind])
+		size += uint64(len(item[kind]))
+	}
+	return values, nil
+}
+
+func (s *testAncientStore) Ancients() (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.tail + uint64(len(s.items)), nil
+}
+
+func (s *testAncientStore) Tail() (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.tail, nil
+}
+
+func (s *testAncientStore) AncientSize(kind string) (uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var size uint64
+	for _, item := range s.items {
+		size += uint64(len(item[kind]))
+	}
+	return size, nil
+}
+
+func (s *testAncientStore) AppendAncients(first uint64, items []map[string][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if first != s.tail+uint64(len(s.items)) {
+		return errors.New("gapped append")
+	}
+	s.items = append(s.items, items...)
+	s.appends++
+	return nil
+}
+
+func (s *testAncientStore) TruncateHead(n uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if n < s.tail {
+		n = s.tail
+	}
+	s.items = s.items[:n-s.tail]
+	return nil
+}
+
+func (s *testAncientStore) TruncateTail(n uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if head := s.tail + uint64(len(s.items)); n > head {
+		n = head
+	}
+	s.items = s.items[n-s.tail:]
+	s.tail = n
+	return nil
+}
+
+func (s *testAncientStore) Sync() error  { return nil }
+func (s *testAncientStore) Close() error { return nil }
+
+// testAncientStores holds the test stores by directory, so they keep their
+// items when reopened.
+var testAncientStores sync.Map
+
+func openPluginAncientStoreForTesting(t *testing.T, dir string, tables map[string]bool, readonly bool) (ethdb.AncientStore, error) {
+	open := func(datadir string, tables map[string]bool, readonly bool) (interface{}, error) {
+		store, _ := testAncientStores.LoadOrStore(datadir, new(testAncientStore))
+		return store, nil
+	}
+	return openPluginAncientStore(open, "", dir, tables, readonly)
+}
+
+func TestPluginAncientStoreModify(t *testing.T) {
+	testAncientModify(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreConcurrentModifyRetrieve(t *testing.T) {
+	testAncientConcurrentModifyRetrieve(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreConcurrentModifyTruncate(t *testing.T) {
+	testAncientConcurrentModifyTruncate(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreTruncateTail(t *testing.T) {
+	testAncientTruncateTail(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreReopen(t *testing.T) {
+	testAncientReopen(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreReadonly(t *testing.T) {
+	testAncientReadonly(t, openPluginAncientStoreForTesting)
+}
+
+func TestPluginAncientStoreModifyRollback(t *testing.T) {
+	testAncientModifyRollback(t, openPluginAncientStoreForTesting)
+
+	f, _ := newAncientStore(t, openPluginAncientStoreForTesting, map[string]bool{"a": true, "b": false})
+	theError := errors.New("oops")
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		op.AppendRaw("a", 0, []byte{1})
+		op.AppendRaw("b", 0, []byte{1})
+		return theError
+	})
+	if err != theError {
+		t.Fatalf("ModifyAncients returned wrong error %q", err)
+	}
+	// Tables written up to different items are rejected as a whole.
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		op.AppendRaw("a", 0, []byte{1})
+		op.AppendRaw("a", 1, []byte{1})
+		return op.AppendRaw("b", 0, []byte{1})
+	})
+	if err == nil {
+		t.Fatal("committed tables of different lengths")
+	}
+	if _, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error { return op.AppendRaw("a", 1, nil) }); err != errOutOrderInsertion {
+		t.Fatalf("have %v, want %v", err, errOutOrderInsertion)
+	}
+	checkAncientCount(t, f, "a", 0)
+	if appends := f.(*pluginAncientStore).store.(*testAncientStore).appends; appends != 0 {
+		t.Fatalf("store received %d failed writes", appends)
+	}
+}
+
+func TestPluginAncientEngine(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		ancient = filepath.Join(dir, "ancient")
+		store   = new(testAncientStore)
+	)
+	engines := map[string]func(string, map[string]bool, bool) (interface{}, error){
+		"memory": func(datadir string, tables map[string]bool, readonly bool) (interface{}, error) {
+			if datadir != filepath.Join(ancient, ChainFreezerName) || len(tables) != len(chainFreezerNoSnappy) {
+				t.Errorf("unexpected store %s with tables %v", datadir, tables)
+			}
+			return store, nil
+		},
+	}
+	done := plugins.HookTester("AncientEngines", &engines)
+	defer done()
+
+	db, err := newDatabaseWithFreezer(memorydb.New(), ancient, "memory", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := makeTestBlocks(4, 1)
+	receipts := make([]types.Receipts, len(blocks))
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		if have := ReadCanonicalHash(db, block.NumberU64()); have != block.Hash() {
+			t.Fatalf("block %d: have hash %x, want %x", block.NumberU64(), have, block.Hash())
+		}
+		if body := ReadBody(db, block.Hash(), block.NumberU64()); body == nil || len(body.Transactions) != 1 {
+			t.Fatalf("block %d: missing body", block.NumberU64())
+		}
+	}
+	if _, err := db.TruncateTail(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Ancient(ChainFreezerHashTable, 1); err != errOutOfBounds {
+		t.Fatalf("have %v, want %v", err, errOutOfBounds)
+	}
+	db.Close()
+
+	// The store is reopened with its engine, but not with the flat files.
+	db, err = newDatabaseWithFreezer(memorydb.New(), ancient, "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frozen, _ := db.Ancients(); frozen != 4 {
+		t.Fatalf("have %d ancients, want 4", frozen)
+	}
+	db.Close()
+	if _, err := newDatabaseWithFreezer(memorydb.New(), ancient, "rocks", "", false); err == nil {
+		t.Fatal("opened the store with another engine")
+	}
+	// Existing flat file freezers aren't replaced by a plugin store.
+	flat := filepath.Join(dir, "flat")
+	db, err = NewDatabaseWithFreezer(memorydb.New(), flat, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := newDatabaseWithFreezer(memorydb.New(), flat, "memory", "", false); err == nil {
+		t.Fatal("opened a flat file freezer with a plugin engine")
+	}
+}
+
+func TestPluginAncientEngineLegacyLayout(t *testing.T) {
+	// The chain store of an existing ancient root without a chain folder is
+	// opened at the root itself, it is still the chain store to plugins.
+	ancient := filepath.Join(t.TempDir(), "ancient")
+	if err := os.MkdirAll(ancient, 0755); err != nil {
+		t.Fatal(err)
+	}
+	engines := map[string]func(string, map[string]bool, bool) (interface{}, error){
+		"memory": func(datadir string, tables map[string]bool, readonly bool) (interface{}, error) {
+			if datadir != ancient {
+				t.Errorf("store not in the legacy location: %s", datadir)
+			}
+			return new(testAncientStore), nil
+		},
+	}
+	done := plugins.HookTester("AncientEngines", &engines)
+	db, err := newDatabaseWithFreezer(memorydb.New(), ancient, "memory", "", false)
+	done()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var (
+		blocks   = makeTestBlocks(2, 1)
+		receipts = make([]types.Receipts, len(blocks))
+		have     []uint64
+	)
+	done = plugins.HookTester("ChainAncientCommit", func(number uint64, hash core.Hash, header, body, receipts, td []byte) {
+		have = append(have, number)
+	})
+	defer done()
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 2 {
+		t.Fatalf("have commits %v, want blocks 0 and 1", have)
+	}
+}
@@ -0,0 +1,207 @@
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/openrelayxyz/plugeth-utils/restricted"
+)
+
+// pluginEngineFile marks a directory as holding a database of a plugin
+// provided engine, so it is reopened with the same engine even when
+// --db.engine is not set. Engines like RocksDB leave files behind which would
+// otherwise be mistaken for a leveldb or pebble database.
+const pluginEngineFile = "PLUGETH_DB_ENGINE"
+
+// PluginBatchWriter must be implemented by plugin databases, applying a batch
+// atomically. Operations are given in order, deletes[i] telling whether
+// keys[i] is deleted rather than set to values[i]. Engines without it are
+// refused, as a batch applied one operation at a time leaves the database
+// inconsistent if the node crashes halfway through it.
+type PluginBatchWriter interface {
+	WriteBatch(keys, values [][]byte, deletes []bool) error
+}
+
+// PluginSnapshotter is implemented by plugin databases supporting
+// snapshots. If the returned reader has a Release method, it is called when
+// the snapshot is released.
+type PluginSnapshotter interface {
+	NewSnapshot() (restricted.KeyValueReader, error)
+}
+
+var (
+	errPluginIteration = errors.New("database engine does not support iteration")
+	errPluginBatch     = errors.New("database engine does not support atomic batches")
+	errPluginSnapshot  = errors.New("database engine does not support snapshots")
+)
+
+// pluginEngine returns the plugin engine a database was created with.
+func pluginEngine(path string) string {
+	blob, err := os.ReadFile(filepath.Join(path, pluginEngineFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(blob))
+}
+
+// openPluginDatabase opens the database if it is, or is requested to be, of a
+// plugin provided engine. It returns false for the built-in engines.
+func openPluginDatabase(o OpenOptions) (ethdb.Database, bool, error) {
+	existingDb := PreexistingDatabase(o.Directory)
+	name := o.Type
+	if len(name) == 0 {
+		name = existingDb
+	}
+	if len(name) == 0 || name == dbLeveldb || name == dbPebble {
+		return nil, false, nil
+	}
+	if len(existingDb) != 0 && existingDb != name {
+		return nil, true, fmt.Errorf("db.engine choice was %v but found pre-existing %v database in specified data directory", name, existingDb)
+	}
+	open, ok := pluginDatabaseEngine(name)
+	if !ok {
+		if len(o.Type) == 0 {
+			return nil, true, fmt.Errorf("found pre-existing %v database, but no plugin provides the engine", name)
+		}
+		return nil, true, fmt.Errorf("unknown db.engine %v", name)
+	}
+	log.Info("Using plugin provided engine as the backing database", "engine", name)
+	db, err := openPluginKeyValueDatabase(name, open, o)
+	return db, true, err
+}
+
+func openPluginKeyValueDatabase(name string, open func(string, int, int, bool) (restricted.KeyValueStore, error), o OpenOptions) (ethdb.Database, error) {
+	if !o.ReadOnly {
+		if err := os.MkdirAll(o.Directory, 0755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := open(o.Directory, o.Cache, o.Handles, o.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	kvdb, err := newPluginKeyValueStore(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	if !o.ReadOnly {
+		if err := os.WriteFile(filepath.Join(o.Directory, pluginEngineFile), []byte(name), 0644); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return NewDatabase(kvdb), nil
+}
+
+// pluginKeyValueStore turns the key-value store of a plugin into an
+// ethdb.KeyValueStore.
+type pluginKeyValueStore struct {
+	restricted.KeyValueStore
+	iteratee restricted.Iteratee
+	batcher  PluginBatchWriter
+}
+
+func newPluginKeyValueStore(db restricted.KeyValueStore) (*pluginKeyValueStore, error) {
+	iteratee, ok := db.(restricted.Iteratee)
+	if !ok {
+		return nil, errPluginIteration
+	}
+	batcher, ok := db.(PluginBatchWriter)
+	if !ok {
+		return nil, errPluginBatch
+	}
+	return &pluginKeyValueStore{db, iteratee, batcher}, nil
+}
+
+func (db *pluginKeyValueStore) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return db.iteratee.NewIterator(prefix, start)
+}
+
+func (db *pluginKeyValueStore) NewBatch() ethdb.Batch {
+	return &pluginBatch{db: db}
+}
+
+func (db *pluginKeyValueStore) NewBatchWithSize(size int) ethdb.Batch {
+	return &pluginBatch{db: db}
+}
+
+func (db *pluginKeyValueStore) NewSnapshot() (ethdb.Snapshot, error) {
+	snapshotter, ok := db.KeyValueStore.(PluginSnapshotter)
+	if !ok {
+		return nil, errPluginSnapshot
+	}
+	reader, err := snapshotter.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &pluginSnapshot{reader}, nil
+}
+
+type pluginSnapshot struct {
+	restricted.KeyValueReader
+}
+
+func (snap *pluginSnapshot) Release() {
+	if r, ok := snap.KeyValueReader.(interface{ Release() }); ok {
+		r.Release()
+	}
+}
+
+// pluginBatch buffers the operations of a batch until it is written.
+type pluginBatch struct {
+	db      *pluginKeyValueStore
+	keys    [][]byte
+	values  [][]byte
+	deletes []bool
+	size    int
+}
+
+func (b *pluginBatch) Put(key, value []byte) error {
+	b.keys = append(b.keys, common.CopyBytes(key))
+	b.values = append(b.values, common.CopyBytes(value))
+	b.deletes = append(b.deletes, false)
+	b.size += len(key) + len(value)
+	return nil
+}
+
+func (b *pluginBatch) Delete(key []byte) error {
+	b.keys = append(b.keys, common.CopyBytes(key))
+	b.values = append(b.values, nil)
+	b.deletes = append(b.deletes, true)
+	b.size += len(key)
+	return nil
+}
+
+func (b *pluginBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *pluginBatch) Write() error {
+	return b.db.batcher.WriteBatch(b.keys, b.values, b.deletes)
+}
+
+func (b *pluginBatch) Reset() {
+	b.keys, b.values, b.deletes, b.size = b.keys[:0], b.values[:0], b.deletes[:0], 0
+}
+
+func (b *pluginBatch) Replay(w ethdb.KeyValueWriter) error {
+	for i, key := range b.keys {
+		var err error
+		if b.deletes[i] {
+			err = w.Delete(key)
+		} else {
+			err = w.Put(key, b.values[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
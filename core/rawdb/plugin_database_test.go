@@ -0,0 +1,137 @@
+package rawdb
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/openrelayxyz/plugeth-utils/restricted"
+)
+
+// testPluginStore is a plugin database only using plugin facing types.
+type testPluginStore struct {
+	db *memorydb.Database
+}
+
+func (s *testPluginStore) Has(key []byte) (bool, error)         { return s.db.Has(key) }
+func (s *testPluginStore) Get(key []byte) ([]byte, error)       { return s.db.Get(key) }
+func (s *testPluginStore) Put(key []byte, value []byte) error   { return s.db.Put(key, value) }
+func (s *testPluginStore) Delete(key []byte) error              { return s.db.Delete(key) }
+func (s *testPluginStore) Stat(property string) (string, error) { return s.db.Stat(property) }
+func (s *testPluginStore) Compact(start []byte, limit []byte) error {
+	return s.db.Compact(start, limit)
+}
+func (s *testPluginStore) Close() error { return s.db.Close() }
+
+func (s *testPluginStore) NewIterator(prefix []byte, start []byte) restricted.Iterator {
+	return s.db.NewIterator(prefix, start)
+}
+
+func (s *testPluginStore) NewSnapshot() (restricted.KeyValueReader, error) {
+	return s.db.NewSnapshot()
+}
+
+type testAtomicPluginStore struct {
+	*testPluginStore
+	batches int
+}
+
+func (s *testAtomicPluginStore) WriteBatch(keys, values [][]byte, deletes []bool) error {
+	s.batches++
+	batch := s.db.NewBatch()
+	for i, key := range keys {
+		if deletes[i] {
+			batch.Delete(key)
+		} else {
+			batch.Put(key, values[i])
+		}
+	}
+	return batch.Write()
+}
+
+func TestPluginKeyValueStore(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() ethdb.KeyValueStore {
+		db, err := newPluginKeyValueStore(&testAtomicPluginStore{testPluginStore: &testPluginStore{memorydb.New()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestPluginKeyValueStoreNonAtomic(t *testing.T) {
+	if _, err := newPluginKeyValueStore(&testPluginStore{memorydb.New()}); err != errPluginBatch {
+		t.Fatalf("store without atomic batches error mismatch: have %v, want %v", err, errPluginBatch)
+	}
+}
+
+func TestPluginDatabaseEngine(t *testing.T) {
+	var (
+		dir    = filepath.Join(t.TempDir(), "chaindata")
+		store  = &testAtomicPluginStore{testPluginStore: &testPluginStore{memorydb.New()}}
+		opened string
+	)
+	engines := map[string]func(string, int, int, bool) (restricted.KeyValueStore, error){
+		"memory": func(path string, cache, handles int, readonly bool) (restricted.KeyValueStore, error) {
+			opened = path
+			return store, nil
+		},
+	}
+	done := plugins.HookTester("DatabaseEngines", &engines)
+	defer done()
+
+	if !HasPluginDatabaseEngine("memory") || HasPluginDatabaseEngine("rocksdb") {
+		t.Fatal("unexpected plugin database engines")
+	}
+	db, err := Open(OpenOptions{Type: "memory", Directory: dir, AncientsDirectory: filepath.Join(dir, "ancient")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != dir {
+		t.Fatalf("opened %q, want %q", opened, dir)
+	}
+	batch := db.NewBatch()
+	batch.Put([]byte("key"), []byte("value"))
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if store.batches != 1 {
+		t.Fatalf("have %d atomic batches, want 1", store.batches)
+	}
+	if value, _ := store.db.Get([]byte("key")); string(value) != "value" {
+		t.Fatalf("unexpected value %q", value)
+	}
+	db.Close()
+
+	// The database is reopened with its engine, and can't be opened with another.
+	if engine := PreexistingDatabase(dir); engine != "memory" {
+		t.Fatalf("have engine %q, want memory", engine)
+	}
+	store.db = memorydb.New()
+	if db, err = Open(OpenOptions{Directory: dir}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := Open(OpenOptions{Type: dbPebble, Directory: dir}); err == nil {
+		t.Fatal("opened a plugin database with pebble")
+	}
+	if _, err := Open(OpenOptions{Type: "rocksdb", Directory: t.TempDir()}); err == nil {
+		t.Fatal("opened a database of an unknown engine")
+	}
+
+	// Engines without atomic batches are refused, without marking the directory.
+	engines["nonatomic"] = func(path string, cache, handles int, readonly bool) (restricted.KeyValueStore, error) {
+		return &testPluginStore{memorydb.New()}, nil
+	}
+	dir = t.TempDir()
+	if _, err := Open(OpenOptions{Type: "nonatomic", Directory: dir}); !errors.Is(err, errPluginBatch) {
+		t.Fatalf("engine without atomic batches error mismatch: have %v, want %v", err, errPluginBatch)
+	}
+	if engine := PreexistingDatabase(dir); engine != "" {
+		t.Fatalf("refused engine %q recorded", engine)
+	}
+}
@@ -2,22 +2,28 @@ package rawdb
 
 
 import (
+	"sort"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/plugins"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/openrelayxyz/plugeth-utils/core"
+	"github.com/openrelayxyz/plugeth-utils/restricted"
 )
 
 var (
-	freezerUpdates map[uint64]map[string]interface{}
+	// freezerUpdates holds the items written to each freezer, by freezer
+	// name and item number, until the write is committed.
+	freezerUpdates map[string]map[uint64]map[string]interface{}
 	lock sync.Mutex
 	modifyAncientsInjection *bool
 	appendRawInjection *bool
 	appendInjection *bool
 )
 
-func PluginTrackUpdate(num uint64, kind string, value interface{}) {
+func PluginTrackUpdate(freezer string, num uint64, kind string, value interface{}) {
 
 	if appendRawInjection != nil {
 		called := true
@@ -31,16 +37,28 @@ func PluginTrackUpdate(num uint64, kind string, value interface{}) {
 
 	lock.Lock()
 	defer lock.Unlock()
-	if freezerUpdates == nil { freezerUpdates = make(map[uint64]map[string]interface{}) }
-	update, ok := freezerUpdates[num]
+	if freezerUpdates == nil { freezerUpdates = make(map[string]map[uint64]map[string]interface{}) }
+	updates, ok := freezerUpdates[freezer]
+	if !ok {
+		updates = make(map[uint64]map[string]interface{})
+		freezerUpdates[freezer] = updates
+	}
+	update, ok := updates[num]
 	if !ok {
 		update = make(map[string]interface{})
-		freezerUpdates[num] = update
+		updates[num] = update
 	}
 	update[kind] = value
 }
 
-func pluginCommitUpdate(num uint64) {
+// pluginDiscardUpdates drops the tracked items of a failed freezer write.
+func pluginDiscardUpdates(freezer string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(freezerUpdates, freezer)
+}
+
+func pluginCommitUpdate(freezer string, num uint64) {
 
 	if modifyAncientsInjection != nil {
 		called := true
@@ -49,94 +67,280 @@ func pluginCommitUpdate(num uint64) {
 
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting CommitUpdate, but default PluginLoader has not been initialized")
+		pluginDiscardUpdates(freezer)
 		return
 	}
-	PluginCommitUpdate(plugins.DefaultPluginLoader, num)
+	PluginCommitUpdate(plugins.DefaultPluginLoader, freezer, num)
+}
+
+// encodeAncient returns the item as stored in the freezer: raw items as they
+// are, the others in their canonical RLP encoding.
+func encodeAncient(item interface{}) []byte {
+	if v, ok := item.([]byte); ok {
+		return v
+	}
+	enc, err := rlp.EncodeToBytes(item)
+	if err != nil {
+		log.Warn("Failed to encode ancient item for plugins", "err", err)
+	}
+	return enc
 }
 
-func PluginCommitUpdate(pl *plugins.PluginLoader, num uint64) {
+// PluginCommitUpdate hands the items committed to a freezer ("chain" or
+// "state") below num to plugins. The typed hooks are:
+//
+//   - AncientCommit(freezer string, number uint64, items map[string][]byte),
+//     for every freezer, keyed by table name with the bytes as stored.
+//   - ChainAncientCommit(number uint64, hash core.Hash, header, body,
+//     receipts, td []byte) for the chain freezer, all in canonical RLP.
+//   - StateHistoryCommit(id uint64, meta, accountIndex, storageIndex,
+//     accountData, storageData []byte) for the state history freezer of the
+//     path based state scheme.
+//
+// ModifyAncients and AppendAncient are deprecated, the former receiving the
+// items as Go values which plugins can't decode reliably.
+func PluginCommitUpdate(pl *plugins.PluginLoader, freezer string, num uint64) {
 	
 	lock.Lock()
 	defer lock.Unlock()
-	if freezerUpdates == nil { freezerUpdates = make(map[uint64]map[string]interface{}) }
+	updates := freezerUpdates[freezer]
+	delete(freezerUpdates, freezer)
 	min := ^uint64(0)
-	for i := range freezerUpdates{
+	for i := range updates {
 		if min > i { min = i }
 	}
+	modifyFnList := pl.Lookup("ModifyAncients", func(item interface{}) bool {
+		_, ok := item.(func(uint64, map[string]interface{}))
+		if ok { log.Warn("PluGeth's ModifyAncients is deprecated. Please update to AncientCommit or ChainAncientCommit.") }
+		return ok
+	})
+	commitFnList := pl.Lookup("AncientCommit", func(item interface{}) bool {
+		_, ok := item.(func(string, uint64, map[string][]byte))
+		return ok
+	})
+	chainFnList := pl.Lookup("ChainAncientCommit", func(item interface{}) bool {
+		_, ok := item.(func(uint64, core.Hash, []byte, []byte, []byte, []byte))
+		return ok
+	})
+	appendAncientFnList := pl.Lookup("AppendAncient", func(item interface{}) bool {
+		_, ok := item.(func(number uint64, hash, header, body, receipts, td []byte))
+		if ok { log.Warn("PluGeth's AppendAncient is deprecated. Please update to ChainAncientCommit.") }
+		return ok
+	})
+	stateFnList := pl.Lookup("StateHistoryCommit", func(item interface{}) bool {
+		_, ok := item.(func(uint64, []byte, []byte, []byte, []byte, []byte))
+		return ok
+	})
 	for i := min ; i < num; i++ {
-		update, ok := freezerUpdates[i]
-		defer func(i uint64) { delete(freezerUpdates, i) }(i)
+		update, ok := updates[i]
 		if !ok {
-			log.Warn("Attempting to commit untracked block", "num", i)
+			log.Warn("Attempting to commit untracked ancient item", "freezer", freezer, "num", i)
 			continue
 		}
-		fnList := pl.Lookup("ModifyAncients", func(item interface{}) bool {
-			_, ok := item.(func(uint64, map[string]interface{}))
-			return ok
-		})
-		for _, fni := range fnList {
-			if fn, ok := fni.(func(uint64, map[string]interface{})); ok {
-				fn(i, update)
+		if freezer == ChainFreezerName {
+			for _, fni := range modifyFnList {
+				if fn, ok := fni.(func(uint64, map[string]interface{})); ok {
+					fn(i, update)
+				}
 			}
 		}
-		appendAncientFnList := pl.Lookup("AppendAncient", func(item interface{}) bool {
-			_, ok := item.(func(number uint64, hash, header, body, receipts, td []byte))
-			if ok { log.Warn("PluGeth's AppendAncient is deprecated. Please update to ModifyAncients.") }
-			return ok
-		})
-		if len(appendAncientFnList) > 0 {
-			var (
-				hash []byte
-				header []byte
-				body []byte
-				receipts []byte
-				td []byte
-			)
-			if hashi, ok := update[ChainFreezerHashTable]; ok {
-				switch v := hashi.(type) {
-				case []byte:
-					hash = v
-				default:
-					hash, _ = rlp.EncodeToBytes(v)
-				}
+		if len(commitFnList) + len(chainFnList) + len(appendAncientFnList) + len(stateFnList) == 0 {
+			continue
+		}
+		items := make(map[string][]byte, len(update))
+		for table, item := range update {
+			items[table] = encodeAncient(item)
+		}
+		for _, fni := range commitFnList {
+			if fn, ok := fni.(func(string, uint64, map[string][]byte)); ok {
+				fn(freezer, i, items)
 			}
-			if headeri, ok := update[ChainFreezerHeaderTable]; ok {
-				switch v := headeri.(type) {
-				case []byte:
-					header = v
-				default:
-					header, _ = rlp.EncodeToBytes(v)
+		}
+		switch freezer {
+		case ChainFreezerName:
+			for _, fni := range chainFnList {
+				if fn, ok := fni.(func(uint64, core.Hash, []byte, []byte, []byte, []byte)); ok {
+					fn(i, core.Hash(common.BytesToHash(items[ChainFreezerHashTable])), items[ChainFreezerHeaderTable], items[ChainFreezerBodiesTable], items[ChainFreezerReceiptTable], items[ChainFreezerDifficultyTable])
 				}
 			}
-			if bodyi, ok := update[ChainFreezerBodiesTable]; ok {
-				switch v := bodyi.(type) {
-				case []byte:
-					body = v
-				default:
-					body, _ = rlp.EncodeToBytes(v)
+			for _, fni := range appendAncientFnList {
+				if fn, ok := fni.(func(number uint64, hash, header, body, receipts, td []byte)); ok {
+					fn(i, items[ChainFreezerHashTable], items[ChainFreezerHeaderTable], items[ChainFreezerBodiesTable], items[ChainFreezerReceiptTable], items[ChainFreezerDifficultyTable])
 				}
 			}
-			if receiptsi, ok := update[ChainFreezerReceiptTable]; ok {
-				switch v := receiptsi.(type) {
-				case []byte:
-					receipts = v
-				default:
-					receipts, _ = rlp.EncodeToBytes(v)
+		case StateFreezerName:
+			for _, fni := range stateFnList {
+				if fn, ok := fni.(func(uint64, []byte, []byte, []byte, []byte, []byte)); ok {
+					fn(i, items[stateHistoryMeta], items[stateHistoryAccountIndex], items[stateHistoryStorageIndex], items[stateHistoryAccountData], items[stateHistoryStorageData])
 				}
 			}
-			if tdi, ok := update[ChainFreezerDifficultyTable]; ok {
-				switch v := tdi.(type) {
-				case []byte:
-					td = v
-				default:
-					td, _ = rlp.EncodeToBytes(v)
-				}
+		}
+	}
+}
+
+// Reasons reported to plugins for ancient store truncations.
+const (
+	TruncateReasonUnknown       = "unknown"
+	TruncateReasonSetHead       = "setHead"       // the chain was rewound, e.g. by debug_setHead
+	TruncateReasonRollback      = "rollback"      // a failed import was rolled back
+	TruncateReasonStateRollback = "stateRollback" // state histories were discarded by a state rewind
+	TruncateReasonStatePruning  = "statePruning"  // old state histories were pruned
+	TruncateReasonHistoryExpiry = "historyExpiry" // old bodies and receipts were pruned, served by era files since
+)
+
+var truncateReasons = struct {
+	sync.Mutex
+	reasons map[string]string
+}{reasons: make(map[string]string)}
+
+// SetTruncateReason labels the truncations of the named freezer, as reported
+// to plugins, with the given reason until the returned function is called.
+func SetTruncateReason(freezer string, reason string) func() {
+	truncateReasons.Lock()
+	defer truncateReasons.Unlock()
+	prev, ok := truncateReasons.reasons[freezer]
+	truncateReasons.reasons[freezer] = reason
+	return func() {
+		truncateReasons.Lock()
+		defer truncateReasons.Unlock()
+		if ok {
+			truncateReasons.reasons[freezer] = prev
+		} else {
+			delete(truncateReasons.reasons, freezer)
+		}
+	}
+}
+
+func truncateReason(freezer string) string {
+	truncateReasons.Lock()
+	defer truncateReasons.Unlock()
+	if reason, ok := truncateReasons.reasons[freezer]; ok {
+		return reason
+	}
+	return TruncateReasonUnknown
+}
+
+func (f *Freezer) tableNames() []string {
+	names := make([]string, 0, len(f.tables))
+	for name := range f.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PluginTruncateAncientHead tells plugins that the items [newHead, oldHead) of
+// the given tables of a freezer ("chain" or "state") were discarded.
+func PluginTruncateAncientHead(pl *plugins.PluginLoader, freezer string, tables []string, oldHead, newHead uint64, reason string) {
+	fnList := pl.Lookup("TruncateAncientHead", func(item interface{}) bool {
+		_, ok := item.(func(string, []string, uint64, uint64, string))
+		return ok
+	})
+	for _, fni := range fnList {
+		if fn, ok := fni.(func(string, []string, uint64, uint64, string)); ok {
+			fn(freezer, tables, oldHead, newHead, reason)
+		}
+	}
+}
+
+func pluginTruncateAncientHead(freezer string, tables []string, oldHead, newHead uint64) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting TruncateAncientHead, but default PluginLoader has not been initialized")
+		return
+	}
+	PluginTruncateAncientHead(plugins.DefaultPluginLoader, freezer, tables, oldHead, newHead, truncateReason(freezer))
+}
+
+// PluginTruncateAncientTail tells plugins that the items [oldTail, newTail) of
+// the given tables of a freezer ("chain" or "state") were discarded.
+func PluginTruncateAncientTail(pl *plugins.PluginLoader, freezer string, tables []string, oldTail, newTail uint64, reason string) {
+	fnList := pl.Lookup("TruncateAncientTail", func(item interface{}) bool {
+		_, ok := item.(func(string, []string, uint64, uint64, string))
+		return ok
+	})
+	for _, fni := range fnList {
+		if fn, ok := fni.(func(string, []string, uint64, uint64, string)); ok {
+			fn(freezer, tables, oldTail, newTail, reason)
+		}
+	}
+}
+
+func pluginTruncateAncientTail(freezer string, tables []string, oldTail, newTail uint64) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting TruncateAncientTail, but default PluginLoader has not been initialized")
+		return
+	}
+	PluginTruncateAncientTail(plugins.DefaultPluginLoader, freezer, tables, oldTail, newTail, truncateReason(freezer))
+}
+
+// PluginDatabaseEngine returns the opener of a key-value database engine
+// provided by a plugin through its DatabaseEngines map, selected with
+// --db.engine=<name>. The opener is given the database directory, cache size
+// in megabytes, number of file handles and whether the database is opened
+// read only. The returned store must also implement restricted.Iteratee and
+// PluginBatchWriter, and may implement PluginSnapshotter.
+func PluginDatabaseEngine(pl *plugins.PluginLoader, name string) (func(string, int, int, bool) (restricted.KeyValueStore, error), bool) {
+	engines := pl.Lookup("DatabaseEngines", func(item interface{}) bool {
+		_, ok := item.(*map[string]func(string, int, int, bool) (restricted.KeyValueStore, error))
+		return ok
+	})
+	for _, emap := range engines {
+		if engineMap, ok := emap.(*map[string]func(string, int, int, bool) (restricted.KeyValueStore, error)); ok {
+			if engine, ok := (*engineMap)[name]; ok {
+				return engine, true
 			}
-			for _, fni := range appendAncientFnList {
-				if fn, ok := fni.(func(number uint64, hash, header, body, receipts, td []byte)); ok {
-					fn(i, hash, header, body, receipts, td)
-				}
+		}
+	}
+	return nil, false
+}
+
+func pluginDatabaseEngine(name string) (func(string, int, int, bool) (restricted.KeyValueStore, error), bool) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting PluginDatabaseEngine, but default PluginLoader has not been initialized")
+		return nil, false
+	}
+	return PluginDatabaseEngine(plugins.DefaultPluginLoader, name)
+}
+
+// HasPluginDatabaseEngine reports whether a plugin provides the named
+// database engine.
+func HasPluginDatabaseEngine(name string) bool {
+	_, ok := pluginDatabaseEngine(name)
+	return ok
+}
+
+// PluginAncientEngine returns the opener of an ancient store engine provided
+// by a plugin through its AncientEngines map, selected for the chain freezer
+// with --db.ancient.engine=<name>. The opener is given the directory of the
+// store, its tables (true if the built-in freezer stores the table
+// uncompressed) and whether it is opened read only. It must return a value
+// implementing PluginAncientStore.
+func PluginAncientEngine(pl *plugins.PluginLoader, name string) (func(string, map[string]bool, bool) (interface{}, error), bool) {
+	engines := pl.Lookup("AncientEngines", func(item interface{}) bool {
+		_, ok := item.(*map[string]func(string, map[string]bool, bool) (interface{}, error))
+		return ok
+	})
+	for _, emap := range engines {
+		if engineMap, ok := emap.(*map[string]func(string, map[string]bool, bool) (interface{}, error)); ok {
+			if engine, ok := (*engineMap)[name]; ok {
+				return engine, true
 			}
 		}
 	}
+	return nil, false
+}
+
+func pluginAncientEngine(name string) (func(string, map[string]bool, bool) (interface{}, error), bool) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting PluginAncientEngine, but default PluginLoader has not been initialized")
+		return nil, false
+	}
+	return PluginAncientEngine(plugins.DefaultPluginLoader, name)
+}
+
+// HasPluginAncientEngine reports whether a plugin provides the named ancient
+// store engine.
+func HasPluginAncientEngine(name string) bool {
+	_, ok := pluginAncientEngine(name)
+	return ok
 }
@@ -0,0 +1,172 @@
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/openrelayxyz/plugeth-utils/core"
+)
+
+type truncation struct {
+	freezer  string
+	tables   []string
+	old, new uint64
+	reason   string
+}
+
+func TestPluginTruncateAncients(t *testing.T) {
+	f, err := newFreezer(t.TempDir(), ChainFreezerName, "", false, 2049, map[string]bool{"a": true, "b": false}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("a", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("b", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have []truncation
+	record := func(freezer string, tables []string, old, new uint64, reason string) {
+		have = append(have, truncation{freezer, tables, old, new, reason})
+	}
+	done := plugins.HookTester("TruncateAncientHead", record)
+	restore := SetTruncateReason(ChainFreezerName, TruncateReasonSetHead)
+	f.TruncateHead(7)
+	restore()
+	f.TruncateHead(7) // noop, not reported
+	f.TruncateHead(6)
+	done()
+
+	done = plugins.HookTester("TruncateAncientTail", record)
+	f.TruncateTail(2)
+	done()
+
+	want := []truncation{
+		{ChainFreezerName, []string{"a", "b"}, 10, 7, TruncateReasonSetHead},
+		{ChainFreezerName, []string{"a", "b"}, 7, 6, TruncateReasonUnknown},
+		{ChainFreezerName, []string{"a", "b"}, 0, 2, TruncateReasonUnknown},
+	}
+	if len(have) != len(want) {
+		t.Fatalf("have %d truncations %v, want %d", len(have), have, len(want))
+	}
+	for i := range want {
+		if have[i].freezer != want[i].freezer || len(have[i].tables) != 2 || have[i].tables[0] != "a" ||
+			have[i].old != want[i].old || have[i].new != want[i].new || have[i].reason != want[i].reason {
+			t.Errorf("truncation %d: have %v, want %v", i, have[i], want[i])
+		}
+	}
+}
+
+func TestPluginAncientCommit(t *testing.T) {
+	f, err := newFreezer(t.TempDir(), StateFreezerName, "", false, 2049, map[string]bool{"a": true, "b": false}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	have := make(map[uint64]map[string][]byte)
+	done := plugins.HookTester("AncientCommit", func(freezer string, number uint64, items map[string][]byte) {
+		if freezer != StateFreezerName {
+			t.Errorf("unexpected freezer %q", freezer)
+		}
+		have[number] = items
+	})
+	defer done()
+
+	// A failed write must not leak its items into the next commit.
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		op.AppendRaw("a", 0, []byte{0xff})
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("write did not fail")
+	}
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 3; i++ {
+			if err := op.AppendRaw("a", i, []byte{byte(i)}); err != nil {
+				return err
+			}
+			if err := op.Append("b", i, []uint64{i, i + 1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 3 {
+		t.Fatalf("have %d commits, want 3", len(have))
+	}
+	for i := uint64(0); i < 3; i++ {
+		enc, _ := rlp.EncodeToBytes([]uint64{i, i + 1})
+		if !bytes.Equal(have[i]["a"], []byte{byte(i)}) || !bytes.Equal(have[i]["b"], enc) {
+			t.Errorf("item %d: unexpected commit %x", i, have[i])
+		}
+		stored, _ := f.Ancient("b", i)
+		if !bytes.Equal(have[i]["b"], stored) {
+			t.Errorf("item %d: have %x, stored %x", i, have[i]["b"], stored)
+		}
+	}
+}
+
+func TestPluginChainAncientCommitLegacyLayout(t *testing.T) {
+	// The chain freezer of an existing ancient root without a chain folder is
+	// opened at the root itself.
+	ancient := filepath.Join(t.TempDir(), "ancient")
+	if err := os.MkdirAll(ancient, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), ancient, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if resolveChainFreezerDir(ancient) != ancient {
+		t.Fatal("chain freezer not in the legacy location")
+	}
+	var (
+		blocks   = makeTestBlocks(4, 1)
+		receipts = make([]types.Receipts, len(blocks))
+		have     []uint64
+	)
+	done := plugins.HookTester("ChainAncientCommit", func(number uint64, hash core.Hash, header, body, receipts, td []byte) {
+		if hash != core.Hash(blocks[number].Hash()) {
+			t.Errorf("block %d: have hash %x, want %x", number, hash, blocks[number].Hash())
+		}
+		have = append(have, number)
+	})
+	if _, err := WriteAncientBlocks(db, blocks[:2], receipts[:2], big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	done = plugins.HookTester("ModifyAncients", func(number uint64, items map[string]interface{}) {
+		have = append(have, number)
+	})
+	if _, err := WriteAncientBlocks(db, blocks[2:], receipts[2:], big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	if len(have) != 4 || have[0] != 0 || have[3] != 3 {
+		t.Fatalf("have commits %v, want blocks 0 to 3", have)
+	}
+}
@@ -0,0 +1,258 @@
+package state
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+//go:generate go run github.com/fjl/gencodec -type ExportedAccount -field-override exportedAccountMarshaling -out gen_exported_account.go
+
+// ExportedAccount is an account of a state export, along with its code and a
+// range of its storage. The storage of an account too large for a single page
+// of the export is split over several, the later parts being continued ones,
+// without code.
+type ExportedAccount struct {
+	Hash      common.Hash     `json:"hash" gencodec:"required"`
+	Address   *common.Address `json:"address,omitempty" rlp:"nil"` // Nil if the preimage is not known
+	Nonce     uint64          `json:"nonce" gencodec:"required"`
+	Balance   *big.Int        `json:"balance" gencodec:"required"`
+	Root      common.Hash     `json:"root" gencodec:"required"`
+	CodeHash  common.Hash     `json:"codeHash" gencodec:"required"`
+	Code      []byte          `json:"code,omitempty"`
+	Storage   []ExportedSlot  `json:"storage,omitempty"`
+	Continued bool            `json:"continued,omitempty"`
+}
+
+type exportedAccountMarshaling struct {
+	Nonce   hexutil.Uint64
+	Balance *hexutil.Big
+	Code    hexutil.Bytes
+}
+
+// ExportedSlot is a storage slot of a state export.
+type ExportedSlot struct {
+	Hash  common.Hash  `json:"hash"`
+	Key   *common.Hash `json:"key,omitempty" rlp:"nil"` // Nil if the preimage is not known
+	Value common.Hash  `json:"value"`
+}
+
+// errInvalidExportCursor is returned if a state export is resumed from a
+// malformed cursor.
+var errInvalidExportCursor = errors.New("invalid state export cursor")
+
+// ExportState exports the accounts of the state with the given root, with
+// their code and storage, in the order of their hashes. The export starts at
+// the given cursor and stops after limit items, accounts and storage slots,
+// returning the cursor to resume from, nil once done. The snapshot is walked
+// if it holds the state, the tries otherwise.
+func ExportState(db Database, snaps *snapshot.Tree, root common.Hash, cursor []byte, limit int, emit func(*ExportedAccount) error) ([]byte, error) {
+	var start, slotStart common.Hash
+	switch len(cursor) {
+	case 0:
+	case common.HashLength:
+		start = common.BytesToHash(cursor)
+	case 2 * common.HashLength:
+		start, slotStart = common.BytesToHash(cursor[:common.HashLength]), common.BytesToHash(cursor[common.HashLength:])
+	default:
+		return nil, errInvalidExportCursor
+	}
+	source := newExportSource(db, snaps, root)
+	accounts, err := source.accounts(start)
+	if err != nil {
+		return nil, err
+	}
+	defer accounts.Release()
+
+	var items int
+	for accounts.Next() {
+		hash := accounts.Hash()
+		continued := len(cursor) == 2*common.HashLength && hash == start
+		if items >= limit && !continued {
+			return hash.Bytes(), nil
+		}
+		account, err := source.account(accounts.Value())
+		if err != nil {
+			return nil, err
+		}
+		exported := &ExportedAccount{
+			Hash:      hash,
+			Nonce:     account.Nonce,
+			Balance:   account.Balance.ToBig(),
+			Root:      account.Root,
+			CodeHash:  common.BytesToHash(account.CodeHash),
+			Continued: continued,
+		}
+		var address common.Address
+		if preimage := db.TrieDB().Preimage(hash); len(preimage) == common.AddressLength {
+			address = common.BytesToAddress(preimage)
+			exported.Address = &address
+		}
+		seek := common.Hash{}
+		if continued {
+			seek = slotStart
+		} else {
+			if exported.CodeHash != types.EmptyCodeHash {
+				if exported.Code, err = db.ContractCode(address, exported.CodeHash); err != nil {
+					return nil, err
+				}
+			}
+			items++
+		}
+		next, err := exportStorage(db, source, exported, seek, limit-items)
+		if err != nil {
+			return nil, err
+		}
+		items += len(exported.Storage)
+		if err := emit(exported); err != nil {
+			return nil, err
+		}
+		if next != nil {
+			return append(hash.Bytes(), next.Bytes()...), nil
+		}
+	}
+	return nil, accounts.Error()
+}
+
+// exportStorage adds up to limit storage slots of an account to its export,
+// starting at the given slot, returning the slot to resume from if more are
+// left.
+func exportStorage(db Database, source exportSource, exported *ExportedAccount, seek common.Hash, limit int) (*common.Hash, error) {
+	if exported.Root == types.EmptyRootHash {
+		return nil, nil
+	}
+	slots, err := source.storage(exported.Hash, exported.Root, seek)
+	if err != nil {
+		return nil, err
+	}
+	defer slots.Release()
+
+	for slots.Next() {
+		hash := slots.Hash()
+		if len(exported.Storage) >= limit {
+			return &hash, nil
+		}
+		_, content, _, err := rlp.Split(slots.Value())
+		if err != nil {
+			return nil, err
+		}
+		slot := ExportedSlot{Hash: hash, Value: common.BytesToHash(content)}
+		if preimage := db.TrieDB().Preimage(hash); len(preimage) == common.HashLength {
+			key := common.BytesToHash(preimage)
+			slot.Key = &key
+		}
+		exported.Storage = append(exported.Storage, slot)
+	}
+	return nil, slots.Error()
+}
+
+// exportIterator iterates the accounts or the storage slots of a state in the
+// order of their hashes.
+type exportIterator interface {
+	Next() bool
+	Hash() common.Hash
+	Value() []byte // RLP encoded account or slot
+	Error() error
+	Release()
+}
+
+// exportSource provides the iterators over a state.
+type exportSource interface {
+	accounts(seek common.Hash) (exportIterator, error)
+	storage(account common.Hash, root common.Hash, seek common.Hash) (exportIterator, error)
+	account(value []byte) (*types.StateAccount, error)
+}
+
+// newExportSource returns the source to export a state from, its snapshot if
+// available, its tries otherwise.
+func newExportSource(db Database, snaps *snapshot.Tree, root common.Hash) exportSource {
+	if snaps != nil && snaps.Snapshot(root) != nil {
+		// The snapshot might still be generating, check it can be iterated
+		if it, err := snaps.AccountIterator(root, common.Hash{}); err == nil {
+			it.Release()
+			return &snapshotExportSource{snaps: snaps, root: root}
+		}
+	}
+	return &trieExportSource{db: db, root: root}
+}
+
+// snapshotExportSource walks the snapshot of a state.
+type snapshotExportSource struct {
+	snaps *snapshot.Tree
+	root  common.Hash
+}
+
+func (s *snapshotExportSource) accounts(seek common.Hash) (exportIterator, error) {
+	it, err := s.snaps.AccountIterator(s.root, seek)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotAccounts{it}, nil
+}
+
+func (s *snapshotExportSource) storage(account common.Hash, root common.Hash, seek common.Hash) (exportIterator, error) {
+	it, err := s.snaps.StorageIterator(s.root, account, seek)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotSlots{it}, nil
+}
+
+func (s *snapshotExportSource) account(value []byte) (*types.StateAccount, error) {
+	return types.FullAccount(value)
+}
+
+type snapshotAccounts struct{ snapshot.AccountIterator }
+
+func (it snapshotAccounts) Value() []byte { return it.Account() }
+
+type snapshotSlots struct{ snapshot.StorageIterator }
+
+func (it snapshotSlots) Value() []byte { return it.Slot() }
+
+// trieExportSource walks the tries of a state.
+type trieExportSource struct {
+	db   Database
+	root common.Hash
+}
+
+func (s *trieExportSource) accounts(seek common.Hash) (exportIterator, error) {
+	return s.iterator(trie.StateTrieID(s.root), seek)
+}
+
+func (s *trieExportSource) storage(account common.Hash, root common.Hash, seek common.Hash) (exportIterator, error) {
+	return s.iterator(trie.StorageTrieID(s.root, account, root), seek)
+}
+
+func (s *trieExportSource) iterator(id *trie.ID, seek common.Hash) (exportIterator, error) {
+	tr, err := trie.NewStateTrie(id, s.db.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	nodeIt, err := tr.NodeIterator(seek.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return trieIterator{trie.NewIterator(nodeIt)}, nil
+}
+
+func (s *trieExportSource) account(value []byte) (*types.StateAccount, error) {
+	account := new(types.StateAccount)
+	if err := rlp.DecodeBytes(value, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+type trieIterator struct{ *trie.Iterator }
+
+func (it trieIterator) Hash() common.Hash { return common.BytesToHash(it.Key) }
+func (it trieIterator) Value() []byte     { return it.Iterator.Value }
+func (it trieIterator) Error() error      { return it.Err }
+func (it trieIterator) Release()          {}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+func TestExportState(t *testing.T) {
+	var (
+		disk     = rawdb.NewMemoryDatabase()
+		tdb      = triedb.NewDatabase(disk, &triedb.Config{Preimages: true})
+		db       = NewDatabaseWithNodeDB(disk, tdb)
+		snaps, _ = snapshot.New(snapshot.Config{CacheSize: 10}, disk, tdb, types.EmptyRootHash)
+		state, _ = New(types.EmptyRootHash, db, snaps)
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	)
+	for i := byte(1); i <= 8; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.SetBalance(addr, uint256.NewInt(uint64(i)))
+		state.SetNonce(addr, uint64(i))
+		if i%2 == 0 {
+			state.SetCode(addr, code)
+			for j := 1; j <= int(i); j++ {
+				state.SetState(addr, common.BytesToHash([]byte{byte(j)}), common.BytesToHash([]byte{i, byte(j)}))
+			}
+		}
+	}
+	root, _ := state.Commit(0, true)
+
+	exportAll := func(snaps *snapshot.Tree, limit int) []*ExportedAccount {
+		var (
+			accounts []*ExportedAccount
+			cursor   []byte
+			pages    int
+		)
+		for {
+			next, err := ExportState(db, snaps, root, cursor, limit, func(account *ExportedAccount) error {
+				if account.Continued {
+					last := accounts[len(accounts)-1]
+					if last.Hash != account.Hash {
+						t.Fatalf("continued account %x does not follow its first part %x", account.Hash, last.Hash)
+					}
+					last.Storage = append(last.Storage, account.Storage...)
+					return nil
+				}
+				accounts = append(accounts, account)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to export state: %v", err)
+			}
+			if pages++; pages > 100 {
+				t.Fatal("export does not progress")
+			}
+			if next == nil {
+				return accounts
+			}
+			cursor = next
+		}
+	}
+	want := exportAll(nil, 1000)
+	if len(want) != 8 {
+		t.Fatalf("exported account count mismatch: have %d, want %d", len(want), 8)
+	}
+	for i, account := range want {
+		if i > 0 && account.Hash.Big().Cmp(want[i-1].Hash.Big()) <= 0 {
+			t.Fatalf("account %d out of order", i)
+		}
+		if account.Address == nil || crypto.Keccak256Hash(account.Address.Bytes()) != account.Hash {
+			t.Fatalf("account %x: missing or wrong address preimage", account.Hash)
+		}
+		n := account.Address[common.AddressLength-1]
+		if account.Nonce != uint64(n) || account.Balance.Uint64() != uint64(n) {
+			t.Fatalf("account %x: nonce or balance mismatch", account.Hash)
+		}
+		if n%2 == 0 {
+			if !reflect.DeepEqual(account.Code, code) {
+				t.Fatalf("account %x: code mismatch", account.Hash)
+			}
+			if len(account.Storage) != int(n) {
+				t.Fatalf("account %x: slot count mismatch: have %d, want %d", account.Hash, len(account.Storage), n)
+			}
+			for _, slot := range account.Storage {
+				if slot.Key == nil || crypto.Keccak256Hash(slot.Key.Bytes()) != slot.Hash {
+					t.Fatalf("account %x: missing or wrong slot preimage", account.Hash)
+				}
+			}
+		} else if account.Code != nil || account.Storage != nil {
+			t.Fatalf("account %x: unexpected code or storage", account.Hash)
+		}
+	}
+	for _, limit := range []int{1, 2, 3, 7} {
+		if have := exportAll(nil, limit); !reflect.DeepEqual(have, want) {
+			t.Fatalf("trie export with limit %d mismatch", limit)
+		}
+		if have := exportAll(snaps, limit); !reflect.DeepEqual(have, want) {
+			t.Fatalf("snapshot export with limit %d mismatch", limit)
+		}
+	}
+	if _, err := ExportState(db, snaps, root, []byte{1, 2, 3}, 10, func(*ExportedAccount) error { return nil }); err != errInvalidExportCursor {
+		t.Fatalf("malformed cursor error mismatch: have %v, want %v", err, errInvalidExportCursor)
+	}
+}
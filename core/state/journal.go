@@ -186,6 +186,7 @@ func (ch resetObjectChange) dirtied() *common.Address {
 func (ch selfDestructChange) revert(s *StateDB) {
 	obj := s.getStateObject(*ch.account)
 	if obj != nil {
+		defer s.pluginBalanceChangeWithReason(obj, obj.Balance(), BalanceChangeRevert) // PluGeth injection
 		obj.selfDestructed = ch.prev
 		obj.setBalance(ch.prevbalance)
 	}
@@ -205,7 +206,9 @@ func (ch touchChange) dirtied() *common.Address {
 }
 
 func (ch balanceChange) revert(s *StateDB) {
-	s.getStateObject(*ch.account).setBalance(ch.prev)
+	obj := s.getStateObject(*ch.account)
+	defer s.pluginBalanceChangeWithReason(obj, obj.Balance(), BalanceChangeRevert) // PluGeth injection
+	obj.setBalance(ch.prev)
 }
 
 func (ch balanceChange) dirtied() *common.Address {
@@ -6,6 +6,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/plugins"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
 	"github.com/openrelayxyz/plugeth-utils/core"
 )
 
@@ -68,3 +69,81 @@ func pluginStateUpdate(blockRoot, parentRoot common.Hash, destructs map[common.H
 	}
 	PluginStateUpdate(plugins.DefaultPluginLoader, blockRoot, parentRoot, destructs, accounts, storage, codeUpdates)
 }
+
+// BalanceChangeReason labels the balance changes reported to a
+// BalanceChangeHook.
+type BalanceChangeReason byte
+
+const (
+	BalanceChangeUnspecified BalanceChangeReason = iota
+	BalanceChangeTransfer                        // value transferred by a message call or create
+	BalanceChangeGasBuy                          // gas purchased up front by the sender
+	BalanceChangeGasRefund                       // unused gas returned to the sender
+	BalanceChangeTip                             // priority fee paid to the coinbase
+	BalanceChangeBlockReward                     // consensus reward for mining a block
+	BalanceChangeUncleReward                     // consensus reward for a mined uncle
+	BalanceChangeWithdrawal                      // beacon chain withdrawal
+	BalanceChangeDAORefund                       // DAO hard fork balance move
+	BalanceChangeSelfDestruct                    // balance of a self-destructed account burnt
+	BalanceChangeRevert                          // earlier change undone by a reverted call or transaction
+)
+
+func (r BalanceChangeReason) String() string {
+	switch r {
+	case BalanceChangeTransfer:
+		return "transfer"
+	case BalanceChangeGasBuy:
+		return "gas_buy"
+	case BalanceChangeGasRefund:
+		return "gas_refund"
+	case BalanceChangeTip:
+		return "tip"
+	case BalanceChangeBlockReward:
+		return "block_reward"
+	case BalanceChangeUncleReward:
+		return "uncle_reward"
+	case BalanceChangeWithdrawal:
+		return "withdrawal"
+	case BalanceChangeDAORefund:
+		return "dao_refund"
+	case BalanceChangeSelfDestruct:
+		return "self_destruct"
+	case BalanceChangeRevert:
+		return "revert"
+	default:
+		return "unspecified"
+	}
+}
+
+// BalanceChangeHook is called after every balance change of a StateDB it is
+// installed on. Reverted changes are reported as they happen, then undone by
+// a change with the BalanceChangeRevert reason, so the reported changes always
+// add up to the balances of the state.
+type BalanceChangeHook func(addr common.Address, prev, new *uint256.Int, reason BalanceChangeReason)
+
+// SetBalanceChangeHook installs a hook reporting balance changes, or removes
+// it if hook is nil. Hooks are not carried over to copies of the StateDB.
+func (s *StateDB) SetBalanceChangeHook(hook BalanceChangeHook) {
+	s.balanceHook = hook
+}
+
+// SetBalanceChangeReason labels the balance changes that follow, until the
+// returned function restores the previous reason.
+func (s *StateDB) SetBalanceChangeReason(reason BalanceChangeReason) func() {
+	prev := s.balanceReason
+	s.balanceReason = reason
+	return func() { s.balanceReason = prev }
+}
+
+func (s *StateDB) pluginBalanceChange(obj *stateObject, prev *uint256.Int) {
+	s.pluginBalanceChangeWithReason(obj, prev, s.balanceReason)
+}
+
+// pluginBalanceChangeWithReason reports a balance change under the given
+// reason rather than the current one.
+func (s *StateDB) pluginBalanceChangeWithReason(obj *stateObject, prev *uint256.Int, reason BalanceChangeReason) {
+	if s.balanceHook == nil || obj.Balance().Eq(prev) {
+		return
+	}
+	s.balanceHook(obj.address, prev, obj.Balance(), reason)
+}
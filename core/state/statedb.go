@@ -138,6 +138,11 @@ type StateDB struct {
 
 	// Testing hooks
 	onCommit func(states *triestate.Set) // Hook invoked when commit is performed
+
+	// begin PluGeth code injection
+	balanceHook   BalanceChangeHook   // Hook reporting balance changes to live tracers
+	balanceReason BalanceChangeReason // Reason reported for the current balance changes
+	// end PluGeth code injection
 }
 
 // New creates a new state from a given trie.
@@ -385,6 +390,9 @@ func (s *StateDB) HasSelfDestructed(addr common.Address) bool {
 func (s *StateDB) AddBalance(addr common.Address, amount *uint256.Int) {
 	stateObject := s.getOrNewStateObject(addr)
 	if stateObject != nil {
+		// begin PluGeth code injection
+		defer s.pluginBalanceChange(stateObject, stateObject.Balance())
+		// end PluGeth code injection
 		stateObject.AddBalance(amount)
 	}
 }
@@ -393,6 +401,9 @@ func (s *StateDB) AddBalance(addr common.Address, amount *uint256.Int) {
 func (s *StateDB) SubBalance(addr common.Address, amount *uint256.Int) {
 	stateObject := s.getOrNewStateObject(addr)
 	if stateObject != nil {
+		// begin PluGeth code injection
+		defer s.pluginBalanceChange(stateObject, stateObject.Balance())
+		// end PluGeth code injection
 		stateObject.SubBalance(amount)
 	}
 }
@@ -400,6 +411,9 @@ func (s *StateDB) SubBalance(addr common.Address, amount *uint256.Int) {
 func (s *StateDB) SetBalance(addr common.Address, amount *uint256.Int) {
 	stateObject := s.getOrNewStateObject(addr)
 	if stateObject != nil {
+		// begin PluGeth code injection
+		defer s.pluginBalanceChange(stateObject, stateObject.Balance())
+		// end PluGeth code injection
 		stateObject.SetBalance(amount)
 	}
 }
@@ -461,6 +475,7 @@ func (s *StateDB) SelfDestruct(addr common.Address) {
 		prev:        stateObject.selfDestructed,
 		prevbalance: new(uint256.Int).Set(stateObject.Balance()),
 	})
+	defer s.pluginBalanceChangeWithReason(stateObject, stateObject.Balance(), BalanceChangeSelfDestruct) // PluGeth injection
 	stateObject.markSelfdestructed()
 	stateObject.data.Balance = new(uint256.Int)
 }
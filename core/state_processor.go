@@ -67,28 +67,45 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs     []*types.Log
 		gp          = new(GasPool).AddGas(block.GasLimit())
 	)
-	// Mutate the block and state according to any hard-fork specs
-	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
-		misc.ApplyDAOHardFork(statedb)
-	}
 	//begin PluGeth code injection
 	blockTracer, ok := pluginGetBlockTracer(header.Hash(), statedb)
 	if ok {
-		cfg.Tracer = blockTracer
+		// The address call tracer of block imports passes the events on
+		if calls, isCalls := cfg.Tracer.(*addressCallTracer); isCalls {
+			calls.inner = blockTracer
+		} else {
+			cfg.Tracer = blockTracer
+		}
 		// cfg.Debug = true
+		statedb.SetBalanceChangeHook(blockTracer.CaptureBalanceChange)
+		defer statedb.SetBalanceChangeHook(nil)
+		if p.bc != nil {
+			blockTracer.setStore(p.bc.db, p.bc.cacheConfig.LiveTraceRetention)
+		}
 	}
+	// Live tracers see the block start before any of its state changes
+	blockTracer.PreProcessBlock(block)
 	// end pluGeth code injection
+	// Mutate the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
 	var (
 		context = NewEVMBlockContext(header, p.bc, nil)
 		vmenv   = vm.NewEVM(context, vm.TxContext{}, statedb, p.config, cfg)
 		signer  = types.MakeSigner(p.config, header.Number, header.Time)
 	)
 	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		// begin PluGeth code injection
+		blockTracer.CaptureSystemCallStart(params.SystemAddress, params.BeaconRootsStorageAddress, beaconRoot[:])
+		// end PluGeth code injection
 		ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+		// begin PluGeth code injection
+		blockTracer.CaptureSystemCallEnd()
+		// end PluGeth code injection
 	}
 	// begin PluGeth code injection
 	pluginPreProcessBlock(block)
-	blockTracer.PreProcessBlock(block)
 	// end PluGeth code injection
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
@@ -125,6 +142,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if len(withdrawals) > 0 && !p.config.IsShanghai(block.Number(), block.Time()) {
 		return nil, nil, 0, errors.New("withdrawals before shanghai")
 	}
+	// begin PluGeth code injection
+	blockTracer.CaptureWithdrawals(withdrawals)
+	// end PluGeth code injection
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
 	//begin PluGeth code injection
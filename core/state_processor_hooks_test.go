@@ -0,0 +1,183 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/openrelayxyz/plugeth-utils/core"
+)
+
+type balanceChange struct {
+	addr   core.Address
+	delta  *big.Int
+	reason string
+}
+
+type balanceTracer struct {
+	changes []balanceChange
+}
+
+func (t *balanceTracer) CaptureStart(from core.Address, to core.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+func (t *balanceTracer) CaptureState(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *balanceTracer) CaptureFault(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, depth int, err error) {
+}
+func (t *balanceTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+func (t *balanceTracer) CaptureEnter(typ core.OpCode, from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (t *balanceTracer) CaptureExit(output []byte, gasUsed uint64, err error)          {}
+func (t *balanceTracer) Result() (interface{}, error)                                  { return nil, nil }
+func (t *balanceTracer) PreProcessBlock(hash core.Hash, number uint64, encoded []byte) {}
+func (t *balanceTracer) PreProcessTransaction(tx core.Hash, block core.Hash, i int)    {}
+func (t *balanceTracer) BlockProcessingError(tx core.Hash, block core.Hash, err error) {}
+func (t *balanceTracer) PostProcessTransaction(tx core.Hash, block core.Hash, i int, receipt []byte) {
+}
+func (t *balanceTracer) PostProcessBlock(block core.Hash) {}
+
+func (t *balanceTracer) CaptureBalanceChange(addr core.Address, prev *big.Int, new *big.Int, reason string) {
+	t.changes = append(t.changes, balanceChange{addr, new.Sub(new, prev), reason})
+}
+
+func TestLiveTracerBalanceChanges(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		receiver = common.HexToAddress("0x1000")
+		coinbase = common.HexToAddress("0x2000")
+		config   = *params.AllEthashProtocolChanges
+		gspec    = &Genesis{
+			Config: &config,
+			Alloc:  types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(coinbase)
+		tx, _ := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+			ChainID:   config.ChainID,
+			To:        &receiver,
+			Value:     big.NewInt(1000),
+			Gas:       params.TxGas + 1000,
+			GasFeeCap: new(big.Int).Add(b.BaseFee(), big.NewInt(1)),
+			GasTipCap: big.NewInt(1),
+		}), types.LatestSigner(&config), key)
+		b.AddTx(tx)
+	})
+
+	tracer := new(balanceTracer)
+	done := plugins.HookTester("GetLiveTracer", func(core.Hash, core.StateDB) core.BlockTracer { return tracer })
+	defer done()
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		addr   common.Address
+		reason string
+	}{
+		{sender, "gas_buy"},
+		{sender, "transfer"},
+		{receiver, "transfer"},
+		{sender, "gas_refund"},
+		{coinbase, "tip"},
+		{coinbase, "block_reward"},
+	}
+	var have []balanceChange
+	for _, change := range tracer.changes {
+		if change.delta.Sign() != 0 {
+			have = append(have, change)
+		}
+	}
+	if len(have) != len(want) {
+		t.Fatalf("have %d balance changes %v, want %d", len(have), have, len(want))
+	}
+	for i, change := range have {
+		if common.Address(change.addr) != want[i].addr || change.reason != want[i].reason {
+			t.Errorf("change %d: have %x %s, want %x %s", i, change.addr, change.reason, want[i].addr, want[i].reason)
+		}
+	}
+	if have[len(have)-1].delta.Cmp(ethash.ConstantinopleBlockReward.ToBig()) != 0 {
+		t.Errorf("have block reward %v, want %v", have[len(have)-1].delta, ethash.ConstantinopleBlockReward)
+	}
+}
+
+func TestLiveTracerBalanceChangesRevert(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		reverter = common.HexToAddress("0x1000")
+		coinbase = common.HexToAddress("0x2000")
+		config   = *params.AllEthashProtocolChanges
+		gspec    = &Genesis{
+			Config: &config,
+			Alloc: types.GenesisAlloc{
+				sender:   {Balance: big.NewInt(params.Ether)},
+				reverter: {Code: []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}},
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(coinbase)
+		tx, _ := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+			ChainID:   config.ChainID,
+			To:        &reverter,
+			Value:     big.NewInt(1000),
+			Gas:       100000,
+			GasFeeCap: new(big.Int).Add(b.BaseFee(), big.NewInt(1)),
+			GasTipCap: big.NewInt(1),
+		}), types.LatestSigner(&config), key)
+		b.AddTx(tx)
+	})
+
+	tracer := new(balanceTracer)
+	done := plugins.HookTester("GetLiveTracer", func(core.Hash, core.StateDB) core.BlockTracer { return tracer })
+	defer done()
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	var reverted bool
+	sums := make(map[common.Address]*big.Int)
+	for _, change := range tracer.changes {
+		addr := common.Address(change.addr)
+		if sums[addr] == nil {
+			sums[addr] = new(big.Int)
+		}
+		sums[addr].Add(sums[addr], change.delta)
+		reverted = reverted || change.reason == "revert"
+	}
+	if !reverted {
+		t.Fatal("reverted transfer was not reported")
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, genesis := range map[common.Address]*big.Int{sender: big.NewInt(params.Ether), reverter: new(big.Int), coinbase: new(big.Int)} {
+		want := new(big.Int).Sub(statedb.GetBalance(addr).ToBig(), genesis)
+		if have := sums[addr]; (have == nil && want.Sign() != 0) || (have != nil && have.Cmp(want) != 0) {
+			t.Errorf("%x: reported changes add up to %v, want %v", addr, have, want)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	cmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto/kzg4844"
@@ -267,7 +268,11 @@ func (st *StateTransition) buyGas() error {
 
 	st.initialGas = st.msg.GasLimit
 	mgvalU256, _ := uint256.FromBig(mgval)
+	// begin PluGeth code injection
+	restore := pluginBalanceChangeReason(st.state, state.BalanceChangeGasBuy)
+	// end PluGeth code injection
 	st.state.SubBalance(st.msg.From, mgvalU256)
+	restore()
 	return nil
 }
 
@@ -470,7 +475,11 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	} else {
 		fee := new(uint256.Int).SetUint64(st.gasUsed())
 		fee.Mul(fee, effectiveTipU256)
+		// begin PluGeth code injection
+		restore := pluginBalanceChangeReason(st.state, state.BalanceChangeTip)
+		// end PluGeth code injection
 		st.state.AddBalance(st.evm.Context.Coinbase, fee)
+		restore()
 	}
 
 	return &ExecutionResult{
@@ -492,7 +501,11 @@ func (st *StateTransition) refundGas(refundQuotient uint64) uint64 {
 	// Return ETH for remaining gas, exchanged at the original rate.
 	remaining := uint256.NewInt(st.gasRemaining)
 	remaining = remaining.Mul(remaining, uint256.MustFromBig(st.msg.GasPrice))
+	// begin PluGeth code injection
+	restore := pluginBalanceChangeReason(st.state, state.BalanceChangeGasRefund)
+	// end PluGeth code injection
 	st.state.AddBalance(st.msg.From, remaining)
+	restore()
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 // TxIndexProgress is the struct describing the progress for transaction indexing.
@@ -46,6 +47,7 @@ type txIndexer struct {
 	//       and all others shouldn't.
 	limit    uint64
 	db       ethdb.Database
+	senders  *params.ChainConfig // Chain config if the sender nonce index is enabled (PluGeth injection)
 	progress chan chan TxIndexProgress
 	term     chan chan struct{}
 	closed   chan struct{}
@@ -60,6 +62,10 @@ func newTxIndexer(limit uint64, chain *BlockChain) *txIndexer {
 		term:     make(chan chan struct{}),
 		closed:   make(chan struct{}),
 	}
+	// PluGeth injection
+	if chain.cacheConfig.SenderNonceIndex {
+		indexer.senders = chain.chainConfig
+	}
 	go indexer.loop(chain)
 
 	var msg string
@@ -79,6 +85,11 @@ func newTxIndexer(limit uint64, chain *BlockChain) *txIndexer {
 func (indexer *txIndexer) run(tail *uint64, head uint64, stop chan struct{}, done chan struct{}) {
 	defer func() { close(done) }()
 
+	// PluGeth injection
+	if indexer.senders != nil {
+		defer indexer.indexSenders(head, stop)
+	}
+
 	// Short circuit if chain is empty and nothing to index.
 	if head == 0 {
 		return
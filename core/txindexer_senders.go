@@ -0,0 +1,165 @@
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// writeSenderNonceLookups indexes the transactions of a block by sender and
+// nonce.
+func writeSenderNonceLookups(db ethdb.KeyValueWriter, block *types.Block, signer types.Signer) {
+	for _, tx := range block.Transactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			log.Warn("Failed to derive sender for nonce index", "tx", tx.Hash(), "err", err)
+			continue
+		}
+		rawdb.WriteSenderNonceLookup(db, from, tx.Nonce(), tx.Hash())
+	}
+}
+
+// deleteSenderNonceLookups unindexes the transactions of a block, all of them
+// if dropped is nil, otherwise only the given ones. Entries already pointing
+// to another transaction, which replaced the block's one in a reorg, are kept.
+func deleteSenderNonceLookups(db ethdb.KeyValueReader, batch ethdb.KeyValueWriter, block *types.Block, signer types.Signer, dropped map[common.Hash]struct{}) {
+	for _, tx := range block.Transactions() {
+		if dropped != nil {
+			if _, ok := dropped[tx.Hash()]; !ok {
+				continue
+			}
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		if rawdb.ReadSenderNonceLookup(db, from, tx.Nonce()) == tx.Hash() {
+			rawdb.DeleteSenderNonceLookup(batch, from, tx.Nonce())
+		}
+	}
+}
+
+// senderIndexRange returns the indexed block range [tail, next) of the sender
+// nonce index, empty at head+1 if nothing is indexed yet. Indexes made before
+// the head was tracked are rebuilt, their head being unknown.
+func (indexer *txIndexer) senderIndexRange(head uint64) (tail uint64, next uint64) {
+	t, h := rawdb.ReadSenderNonceIndexTail(indexer.db), rawdb.ReadSenderNonceIndexHead(indexer.db)
+	if t == nil || h == nil || *t > *h+1 {
+		return head + 1, head + 1
+	}
+	return *t, *h + 1
+}
+
+// senderIndexFirst returns the first block of the sender nonce index range,
+// the same as the transaction index one.
+func (indexer *txIndexer) senderIndexFirst(head uint64) uint64 {
+	if indexer.limit != 0 && head >= indexer.limit {
+		return head - indexer.limit + 1
+	}
+	return 0
+}
+
+// indexSenders brings the sender nonce index in line with the transaction
+// index range: blocks that left it are unindexed, the ones up to the head
+// indexed and the ones below the tail backfilled. New heads are indexed as
+// they are written too, the range only recording what this task did, so that
+// it stays contiguous if interrupted.
+func (indexer *txIndexer) indexSenders(head uint64, stop chan struct{}) {
+	var (
+		batch      = indexer.db.NewBatch()
+		first      = indexer.senderIndexFirst(head)
+		tail, next = indexer.senderIndexRange(head)
+	)
+	// The chain was rewound below the indexed range
+	if next > head+1 {
+		next = head + 1
+	}
+	if tail > next {
+		tail = next
+	}
+	flush := func() {
+		rawdb.WriteSenderNonceIndexTail(batch, tail)
+		rawdb.WriteSenderNonceIndexHead(batch, next-1)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write sender nonce index", "err", err)
+		}
+		batch.Reset()
+	}
+	defer flush()
+
+	// checkpoint flushes large batches, reporting whether to go on.
+	checkpoint := func() bool {
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			flush()
+		}
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+	block := func(number uint64) *types.Block {
+		return rawdb.ReadBlock(indexer.db, rawdb.ReadCanonicalHash(indexer.db, number), number)
+	}
+	for tail < first && tail < next {
+		if b := block(tail); b != nil {
+			deleteSenderNonceLookups(indexer.db, batch, b, types.MakeSigner(indexer.senders, b.Number(), b.Time()), nil)
+		}
+		tail++
+		if !checkpoint() {
+			return
+		}
+	}
+	if next < first {
+		// Nothing indexed in the range, start at its beginning
+		tail, next = first, first
+	}
+	for next <= head {
+		b := block(next)
+		if b == nil {
+			// Body not available yet, retried with the next head
+			return
+		}
+		writeSenderNonceLookups(batch, b, types.MakeSigner(indexer.senders, b.Number(), b.Time()))
+		next++
+		if !checkpoint() {
+			return
+		}
+	}
+	for tail > first {
+		b := block(tail - 1)
+		if b == nil {
+			// Body not available, pruned or not yet synced
+			return
+		}
+		writeSenderNonceLookups(batch, b, types.MakeSigner(indexer.senders, b.Number(), b.Time()))
+		tail--
+		if !checkpoint() {
+			return
+		}
+	}
+}
+
+// senderIndexProgress returns the sender nonce indexing progress at the given
+// chain head.
+func (indexer *txIndexer) senderIndexProgress(head uint64) TxIndexProgress {
+	var (
+		first      = indexer.senderIndexFirst(head)
+		tail, next = indexer.senderIndexRange(head)
+		total      = head + 1 - first
+		indexed    uint64
+	)
+	if tail < first {
+		tail = first
+	}
+	if next > head+1 {
+		next = head + 1
+	}
+	if next > tail {
+		indexed = next - tail
+	}
+	return TxIndexProgress{Indexed: indexed, Remaining: total - indexed}
+}
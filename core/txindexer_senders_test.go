@@ -0,0 +1,173 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestSenderNonceIndex tests the maintenance of the sender nonce index on
+// import, reorg, pruning and disabling.
+func TestSenderNonceIndex(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0xdeadbeef")
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+	)
+	newTx := func(gen *BlockGen, value int64) *types.Transaction {
+		tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &to, Value: big.NewInt(value), Gas: params.TxGas, GasPrice: gen.BaseFee()}
+		return types.MustSignNewTx(key, signer, tx)
+	}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {
+		gen.AddTx(newTx(gen, 1))
+	})
+	// The fork replaces the third transaction and drops the fourth one.
+	_, fork, _ := GenerateChainWithGenesis(gspec, engine, 5, func(i int, gen *BlockGen) {
+		if i < 2 {
+			gen.AddTx(newTx(gen, 1))
+		} else if i == 2 {
+			gen.AddTx(newTx(gen, 2))
+		}
+	})
+	newChain := func(db ethdb.Database, enabled bool, limit uint64) *BlockChain {
+		cacheConfig := *defaultCacheConfig
+		cacheConfig.SenderNonceIndex = enabled
+		chain, err := NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, &limit)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		return chain
+	}
+	wait := func(chain *BlockChain, db ethdb.Database, tail uint64) {
+		for i := 0; i < 100; i++ {
+			progress, err := chain.SenderNonceIndexProgress()
+			if have := rawdb.ReadSenderNonceIndexTail(db); err == nil && progress.Done() && have != nil && *have == tail {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("sender nonce index did not reach tail %d", tail)
+	}
+	check := func(db ethdb.Database, want ...common.Hash) {
+		t.Helper()
+		for nonce, hash := range want {
+			if have := rawdb.ReadSenderNonceLookup(db, sender, uint64(nonce)); have != hash {
+				t.Errorf("nonce %d: have transaction %x, want %x", nonce, have, hash)
+			}
+		}
+	}
+	txHash := func(block *types.Block) common.Hash {
+		return block.Transactions()[0].Hash()
+	}
+	db := rawdb.NewMemoryDatabase()
+	chain := newChain(db, true, 0)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	wait(chain, db, 0)
+	check(db, txHash(blocks[0]), txHash(blocks[1]), txHash(blocks[2]), txHash(blocks[3]))
+
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	check(db, txHash(fork[0]), txHash(fork[1]), txHash(fork[2]), common.Hash{})
+	chain.Stop()
+
+	// Disabling the index drops its range, to be rebuilt if enabled again.
+	newChain(db, false, 0).Stop()
+	if tail, head := rawdb.ReadSenderNonceIndexTail(db), rawdb.ReadSenderNonceIndexHead(db); tail != nil || head != nil {
+		t.Errorf("have range %v-%v after disabling the index", tail, head)
+	}
+	// A limited index is backfilled from the head down and prunes old blocks.
+	db = rawdb.NewMemoryDatabase()
+	chain = newChain(db, false, 2)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+	chain = newChain(db, true, 3)
+	wait(chain, db, 2)
+	check(db, common.Hash{}, txHash(blocks[1]), txHash(blocks[2]), txHash(blocks[3]))
+	chain.Stop()
+
+	chain = newChain(db, true, 2)
+	defer chain.Stop()
+	wait(chain, db, 3)
+	check(db, common.Hash{}, common.Hash{}, txHash(blocks[2]), txHash(blocks[3]))
+}
+
+// TestSenderNonceIndexSnapSync tests that blocks written below the head of
+// the index, like snap synced ones, are indexed once the chain reaches them.
+func TestSenderNonceIndexSnapSync(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0xdeadbeef")
+
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+		limit  = uint64(0)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, engine, 4, func(i int, gen *BlockGen) {
+		tx := &types.LegacyTx{Nonce: gen.TxNonce(sender), To: &to, Gas: params.TxGas, GasPrice: gen.BaseFee()}
+		gen.AddTx(types.MustSignNewTx(key, signer, tx))
+	})
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.SenderNonceIndex = true
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, &cacheConfig, gspec, nil, engine, vm.Config{}, nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// The index is built at genesis before the sync starts.
+	chain.txIndexer.indexSenders(0, make(chan struct{}))
+	if progress := chain.txIndexer.senderIndexProgress(0); !progress.Done() {
+		t.Fatalf("genesis not indexed: %+v", progress)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if _, err := chain.InsertHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert header chain: %v", err)
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, 0); err != nil {
+		t.Fatalf("failed to insert receipt chain: %v", err)
+	}
+	if progress := chain.txIndexer.senderIndexProgress(4); progress.Done() || progress.Remaining != 4 {
+		t.Fatalf("synced blocks reported indexed: %+v", progress)
+	}
+	chain.txIndexer.indexSenders(4, make(chan struct{}))
+	if progress := chain.txIndexer.senderIndexProgress(4); !progress.Done() || progress.Indexed != 5 {
+		t.Fatalf("synced blocks not indexed: %+v", progress)
+	}
+	for i, block := range blocks {
+		if have, want := rawdb.ReadSenderNonceLookup(db, sender, uint64(i)), block.Transactions()[0].Hash(); have != want {
+			t.Errorf("nonce %d: have transaction %x, want %x", i, have, want)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -106,6 +107,8 @@ type blobTxMeta struct {
 	evictionExecTip      *uint256.Int // Worst gas tip across all previous nonces
 	evictionExecFeeJumps float64      // Worst base fee (converted to fee jumps) across all previous nonces
 	evictionBlobFeeJumps float64      // Worse blob fee (converted to fee jumps) across all previous nonces
+
+	seen time.Time // PluGeth: time the transaction was first seen, exported in pool snapshots
 }
 
 // newBlobTxMeta retrieves the indexed metadata fields from a blob transaction
@@ -122,6 +125,7 @@ func newBlobTxMeta(id uint64, size uint32, tx *types.Transaction) *blobTxMeta {
 		blobFeeCap: uint256.MustFromBig(tx.BlobGasFeeCap()),
 		execGas:    tx.Gas(),
 		blobGas:    tx.BlobGas(),
+		seen:       tx.Time(), // PluGeth injection
 	}
 	meta.basefeeJumps = dynamicFeeJumps(meta.execFeeCap)
 	meta.blobfeeJumps = dynamicFeeJumps(meta.blobFeeCap)
@@ -315,6 +319,8 @@ type BlobPool struct {
 	insertFeed   event.Feed // Event feed to send out new tx events on pool inclusion (reorg included)
 
 	lock sync.RWMutex // Mutex protecting the pool during reorg handling
+
+	recorder atomic.Pointer[txpool.Recorder] // PluGeth injection: history of the pool's transactions, if recorded
 }
 
 // New creates a new blob transaction pool to gather, sort and filter inbound
@@ -532,6 +538,13 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 
 			p.stored -= uint64(txs[i].size)
 			delete(p.lookup, txs[i].hash)
+			// begin PluGeth code injection
+			if gapped {
+				p.record(txpool.EventDropped, addr, txs[i], "nonce gap")
+			} else {
+				p.recordStale(addr, txs[i], inclusions)
+			}
+			// end PluGeth code injection
 
 			// Included transactions blobs need to be moved to the limbo
 			if filled && inclusions != nil {
@@ -573,6 +586,7 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 			p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], txs[0].costCap)
 			p.stored -= uint64(txs[0].size)
 			delete(p.lookup, txs[0].hash)
+			p.recordStale(addr, txs[0], inclusions) // PluGeth injection
 
 			// Included transactions blobs need to be moved to the limbo
 			if inclusions != nil {
@@ -629,6 +643,7 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 			p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], txs[i].costCap)
 			p.stored -= uint64(txs[i].size)
 			delete(p.lookup, txs[i].hash)
+			p.record(txpool.EventDropped, addr, txs[i], "repeated nonce") // PluGeth injection
 
 			if err := p.store.Delete(id); err != nil {
 				log.Error("Failed to delete blob transaction", "from", addr, "id", id, "err", err)
@@ -651,6 +666,7 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 			p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], txs[j].costCap)
 			p.stored -= uint64(txs[j].size)
 			delete(p.lookup, txs[j].hash)
+			p.record(txpool.EventDropped, addr, txs[j], "nonce gap") // PluGeth injection
 		}
 		txs = txs[:i]
 
@@ -689,6 +705,7 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 			p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], last.costCap)
 			p.stored -= uint64(last.size)
 			delete(p.lookup, last.hash)
+			p.record(txpool.EventDropped, addr, last, "insufficient funds") // PluGeth injection
 		}
 		if len(txs) == 0 {
 			delete(p.index, addr)
@@ -729,6 +746,7 @@ func (p *BlobPool) recheck(addr common.Address, inclusions map[common.Hash]uint6
 			p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], last.costCap)
 			p.stored -= uint64(last.size)
 			delete(p.lookup, last.hash)
+			p.record(txpool.EventDropped, addr, last, "account limit") // PluGeth injection
 		}
 		p.index[addr] = txs
 
@@ -1034,6 +1052,7 @@ func (p *BlobPool) SetGasTip(tip *big.Int) {
 					p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], txs[i].costCap)
 					p.stored -= uint64(tx.size)
 					delete(p.lookup, tx.hash)
+					p.record(txpool.EventDropped, addr, tx, "underpriced") // PluGeth injection
 					txs[i] = nil
 
 					// Drop everything afterwards, no gaps allowed
@@ -1044,6 +1063,7 @@ func (p *BlobPool) SetGasTip(tip *big.Int) {
 						p.spent[addr] = new(uint256.Int).Sub(p.spent[addr], tx.costCap)
 						p.stored -= uint64(tx.size)
 						delete(p.lookup, tx.hash)
+						p.record(txpool.EventDropped, addr, tx, "underpriced") // PluGeth injection
 						txs[i+1+j] = nil
 					}
 					// Clear out the dropped transactions from the index
@@ -1316,6 +1336,7 @@ func (p *BlobPool) add(tx *types.Transaction) (err error) {
 		p.spent[from] = new(uint256.Int).Add(p.spent[from], meta.costCap)
 
 		delete(p.lookup, prev.hash)
+		p.recorder.Load().RecordReplaced(prev.hash, from, prev.nonce, meta.hash) // PluGeth injection
 		p.lookup[meta.hash] = meta.id
 		p.stored += uint64(meta.size) - uint64(prev.size)
 	} else {
@@ -1382,6 +1403,7 @@ func (p *BlobPool) add(tx *types.Transaction) (err error) {
 		p.drop()
 	}
 	p.updateStorageMetrics()
+	p.record(txpool.EventAdded, from, meta, "") // PluGeth injection
 
 	addValidMeter.Mark(1)
 	return nil
@@ -1416,6 +1438,7 @@ func (p *BlobPool) drop() {
 	}
 	p.stored -= uint64(drop.size)
 	delete(p.lookup, drop.hash)
+	p.record(txpool.EventDropped, from, drop, "pool overflow") // PluGeth injection
 
 	// Remove the transaction from the pool's eviction heap:
 	//   - If the entire account was dropped, pop off the address
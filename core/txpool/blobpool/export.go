@@ -0,0 +1,38 @@
+package blobpool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var _ txpool.Exporter = (*BlobPool)(nil)
+
+// Export returns all transactions of the pool with their blob sidecars,
+// grouped by sender and sorted by nonce. Their Time is the time they were
+// first seen, or loaded from the store at startup.
+func (p *BlobPool) Export() []*types.Transaction {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	txs := make(map[common.Address][]*types.Transaction, len(p.index))
+	for addr, metas := range p.index {
+		for _, meta := range metas {
+			data, err := p.store.Get(meta.id)
+			if err != nil {
+				log.Error("Tracked blob transaction missing from store", "hash", meta.hash, "id", meta.id, "err", err)
+				continue
+			}
+			tx := new(types.Transaction)
+			if err := rlp.DecodeBytes(data, tx); err != nil {
+				log.Error("Blobs corrupted for exported transaction", "hash", meta.hash, "id", meta.id, "err", err)
+				continue
+			}
+			tx.SetTime(meta.seen)
+			txs[addr] = append(txs[addr], tx)
+		}
+	}
+	return txpool.SortExport(txs)
+}
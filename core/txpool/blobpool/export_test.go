@@ -0,0 +1,125 @@
+package blobpool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/holiman/uint256"
+)
+
+// newExportTestPool creates an empty blob pool in which the given accounts are
+// funded.
+func newExportTestPool(t *testing.T, keys ...*ecdsa.PrivateKey) *BlobPool {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewDatabase(memorydb.New())), nil)
+	for _, key := range keys {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), uint256.NewInt(1_000_000_000))
+	}
+	statedb.Commit(0, true)
+
+	chain := &testBlockChain{
+		config:  testChainConfig,
+		basefee: uint256.NewInt(1050),
+		blobfee: uint256.NewInt(105),
+		statedb: statedb,
+	}
+	pool := New(Config{Datadir: t.TempDir()}, chain)
+	if err := pool.Init(1, chain.CurrentBlock(), makeAddressReserver()); err != nil {
+		t.Fatalf("failed to create blob pool: %v", err)
+	}
+	return pool
+}
+
+func TestExportSnapshot(t *testing.T) {
+	var (
+		key1, _ = crypto.GenerateKey()
+		key2, _ = crypto.GenerateKey()
+		seen    = time.Unix(1700000000, 0)
+		txs     = []*types.Transaction{
+			makeTx(0, 1, 1000, 100, key1),
+			makeTx(1, 1, 1000, 100, key1),
+			makeTx(0, 1, 1000, 100, key2),
+		}
+	)
+	pool := newExportTestPool(t, key1, key2)
+	defer pool.Close()
+
+	for i, tx := range txs {
+		tx.SetTime(seen.Add(time.Duration(i) * time.Second))
+		if err := pool.add(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	exported := pool.Export()
+	if len(exported) != len(txs) {
+		t.Fatalf("exported %d transactions, want %d", len(exported), len(txs))
+	}
+	want := make(map[common.Hash]*types.Transaction)
+	for _, tx := range txs {
+		want[tx.Hash()] = tx
+	}
+	for i, tx := range exported {
+		if i > 0 {
+			prev, _ := types.Sender(types.LatestSigner(testChainConfig), exported[i-1])
+			from, _ := types.Sender(types.LatestSigner(testChainConfig), tx)
+			if cmp := bytes.Compare(prev[:], from[:]); cmp > 0 || (cmp == 0 && exported[i-1].Nonce() >= tx.Nonce()) {
+				t.Fatalf("transaction %d not sorted by sender and nonce", i)
+			}
+		}
+		orig, ok := want[tx.Hash()]
+		if !ok {
+			t.Fatalf("unexpected exported transaction %x", tx.Hash())
+		}
+		if !tx.Time().Equal(orig.Time()) {
+			t.Errorf("transaction %x: have first seen %v, want %v", tx.Hash(), tx.Time(), orig.Time())
+		}
+		if sidecar := tx.BlobTxSidecar(); sidecar == nil || len(sidecar.Blobs) != 1 || sidecar.Commitments[0] != emptyBlobCommit {
+			t.Errorf("transaction %x: blob sidecar missing", tx.Hash())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := txpool.WriteSnapshot(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := txpool.ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(exported) {
+		t.Fatalf("imported %d transactions, want %d", len(imported), len(exported))
+	}
+
+	// The snapshot, sidecars included, can be imported into another pool,
+	// which keeps the times the transactions were first seen.
+	pool2 := newExportTestPool(t, key1, key2)
+	defer pool2.Close()
+
+	for i, tx := range imported {
+		if err := pool2.add(tx); err != nil {
+			t.Fatalf("failed to import transaction %d: %v", i, err)
+		}
+	}
+	verifyPoolInternals(t, pool2)
+
+	reexported := pool2.Export()
+	if len(reexported) != len(exported) {
+		t.Fatalf("reexported %d transactions, want %d", len(reexported), len(exported))
+	}
+	for i, tx := range reexported {
+		if tx.Hash() != exported[i].Hash() || !tx.Time().Equal(exported[i].Time()) {
+			t.Errorf("transaction %d: have %x seen %v, want %x seen %v", i, tx.Hash(), tx.Time(), exported[i].Hash(), exported[i].Time())
+		}
+		if tx.BlobTxSidecar() == nil {
+			t.Errorf("transaction %d: blob sidecar lost", i)
+		}
+	}
+}
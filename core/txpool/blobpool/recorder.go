@@ -0,0 +1,29 @@
+package blobpool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+)
+
+// SetRecorder makes the pool record the history of its transactions. Blob
+// transactions are never queued, so they are not promoted nor demoted.
+func (p *BlobPool) SetRecorder(recorder *txpool.Recorder) {
+	p.recorder.Store(recorder)
+}
+
+// record adds an event for the transaction to the pool's history, if it is
+// recorded.
+func (p *BlobPool) record(kind txpool.EventKind, from common.Address, meta *blobTxMeta, reason string) {
+	p.recorder.Load().Record(kind, meta.hash, from, meta.nonce, reason)
+}
+
+// recordStale adds the removal of a transaction whose nonce is too low to the
+// pool's history, as an inclusion if the reorg included it and as a drop
+// otherwise.
+func (p *BlobPool) recordStale(from common.Address, meta *blobTxMeta, inclusions map[common.Hash]uint64) {
+	if number, ok := inclusions[meta.hash]; ok {
+		p.recorder.Load().RecordIncluded(meta.hash, from, meta.nonce, number)
+		return
+	}
+	p.record(txpool.EventDropped, from, meta, "nonce too low")
+}
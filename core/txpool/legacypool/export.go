@@ -0,0 +1,26 @@
+package legacypool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var _ txpool.Exporter = (*LegacyPool)(nil)
+
+// Export returns all pending and queued transactions of the pool, grouped by
+// sender and sorted by nonce. Their Time is the time they were first seen,
+// or loaded from the journal.
+func (pool *LegacyPool) Export() []*types.Transaction {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	txs := make(map[common.Address][]*types.Transaction, len(pool.pending)+len(pool.queue))
+	for addr, list := range pool.pending {
+		txs[addr] = append(txs[addr], list.Flatten()...)
+	}
+	for addr, list := range pool.queue {
+		txs[addr] = append(txs[addr], list.Flatten()...)
+	}
+	return txpool.SortExport(txs)
+}
@@ -0,0 +1,92 @@
+package legacypool
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+)
+
+func TestExportSnapshot(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Close()
+
+	from, _ := deriveSender(transaction(0, 0, key))
+	testAddBalance(pool, from, big.NewInt(1000000))
+
+	// Two pending transactions and a queued one, added out of order.
+	seen := time.Unix(1700000000, 0)
+	txs := []*types.Transaction{transaction(3, 100000, key), transaction(1, 100000, key), transaction(0, 100000, key)}
+	for i, tx := range txs {
+		tx.SetTime(seen.Add(time.Duration(i) * time.Second))
+	}
+	for _, err := range pool.addRemotesSync(txs) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("have %d pending and %d queued, want 2 and 1", pending, queued)
+	}
+	exported := pool.Export()
+	if len(exported) != 3 {
+		t.Fatalf("exported %d transactions, want 3", len(exported))
+	}
+	for i, nonce := range []uint64{0, 1, 3} {
+		if exported[i].Nonce() != nonce {
+			t.Fatalf("transaction %d: have nonce %d, want %d", i, exported[i].Nonce(), nonce)
+		}
+	}
+	// Blob transactions are written with their sidecar.
+	blobTx := types.NewTx(&types.BlobTx{
+		Nonce:      7,
+		GasTipCap:  uint256.NewInt(1),
+		GasFeeCap:  uint256.NewInt(1),
+		BlobFeeCap: uint256.NewInt(1),
+		BlobHashes: []common.Hash{{0x01}},
+		Sidecar:    &types.BlobTxSidecar{Blobs: []kzg4844.Blob{{}}, Commitments: []kzg4844.Commitment{{}}, Proofs: []kzg4844.Proof{{}}},
+	})
+	blobTx.SetTime(seen)
+	exported = append(exported, blobTx)
+
+	var buf bytes.Buffer
+	if err := txpool.WriteSnapshot(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := txpool.ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(exported) {
+		t.Fatalf("imported %d transactions, want %d", len(imported), len(exported))
+	}
+	for i, tx := range imported {
+		if tx.Hash() != exported[i].Hash() || !tx.Time().Equal(exported[i].Time()) {
+			t.Errorf("transaction %d: have %x seen %v, want %x seen %v", i, tx.Hash(), tx.Time(), exported[i].Hash(), exported[i].Time())
+		}
+	}
+	if sidecar := imported[3].BlobTxSidecar(); sidecar == nil || len(sidecar.Blobs) != 1 {
+		t.Fatal("blob sidecar lost")
+	}
+
+	// The snapshot can be imported into another pool.
+	pool2, _ := setupPool()
+	defer pool2.Close()
+	testAddBalance(pool2, from, big.NewInt(1000000))
+	for _, err := range pool2.addRemotesSync(imported[:3]) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tx := pool2.Get(txs[0].Hash()); tx == nil || !tx.Time().Equal(seen) {
+		t.Fatal("imported transaction lost its first seen time")
+	}
+}
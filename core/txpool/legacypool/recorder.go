@@ -0,0 +1,72 @@
+package legacypool
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SetRecorder makes the pool record the history of its transactions.
+func (pool *LegacyPool) SetRecorder(recorder *txpool.Recorder) {
+	pool.recorder.Store(recorder)
+}
+
+// record adds an event for the transaction to the pool's history, if it is
+// recorded.
+func (pool *LegacyPool) record(kind txpool.EventKind, tx *types.Transaction, reason string) {
+	recorder := pool.recorder.Load()
+	if recorder == nil {
+		return
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	recorder.Record(kind, tx.Hash(), from, tx.Nonce(), reason)
+}
+
+// recordAll adds an event for each of the transactions.
+func (pool *LegacyPool) recordAll(kind txpool.EventKind, txs types.Transactions, reason string) {
+	for _, tx := range txs {
+		pool.record(kind, tx, reason)
+	}
+}
+
+// recordReplaced adds the replacement of old by tx to the pool's history.
+func (pool *LegacyPool) recordReplaced(old, tx *types.Transaction) {
+	recorder := pool.recorder.Load()
+	if recorder == nil {
+		return
+	}
+	from, _ := types.Sender(pool.signer, old) // already validated
+	recorder.RecordReplaced(old.Hash(), from, old.Nonce(), tx.Hash())
+}
+
+// trackIncluded remembers the block including each of its transactions, so
+// their removal during the current reorg is recorded as an inclusion.
+func (pool *LegacyPool) trackIncluded(block *types.Block) {
+	if pool.recorder.Load() == nil {
+		return
+	}
+	if pool.included == nil {
+		pool.included = make(map[common.Hash]uint64)
+	}
+	for _, tx := range block.Transactions() {
+		pool.included[tx.Hash()] = block.NumberU64()
+	}
+}
+
+// recordStale adds the removal of transactions whose nonce is too low to the
+// pool's history, as an inclusion if they were included by the current reorg
+// and as a drop otherwise.
+func (pool *LegacyPool) recordStale(txs types.Transactions) {
+	recorder := pool.recorder.Load()
+	if recorder == nil {
+		return
+	}
+	for _, tx := range txs {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if number, ok := pool.included[tx.Hash()]; ok {
+			recorder.RecordIncluded(tx.Hash(), from, tx.Nonce(), number)
+		} else {
+			recorder.Record(txpool.EventDropped, tx.Hash(), from, tx.Nonce(), "nonce too low")
+		}
+	}
+}
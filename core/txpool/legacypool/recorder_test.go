@@ -0,0 +1,145 @@
+package legacypool
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// recorderTestChain is a test chain serving the blocks of a pool reset.
+type recorderTestChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *recorderTestChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// newBlock adds a block including the transactions on top of the parent.
+func (bc *recorderTestChain) newBlock(parent *types.Header, txs ...*types.Transaction) *types.Header {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		BaseFee:    common.Big1,
+	}
+	block := types.NewBlockWithHeader(header).WithBody(txs, nil)
+	bc.blocks[block.Hash()] = block
+	return block.Header()
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	var (
+		statedb, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		chain      = &recorderTestChain{newTestBlockChain(params.TestChainConfig, 10000000, statedb, new(event.Feed)), make(map[common.Hash]*types.Block)}
+		genesis    = chain.CurrentBlock()
+	)
+	chain.blocks[genesis.Hash()] = types.NewBlockWithHeader(genesis)
+
+	pool := New(testTxPoolConfig, chain)
+	if err := pool.Init(testTxPoolConfig.PriceLimit, genesis, makeAddressReserver()); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	db := rawdb.NewMemoryDatabase()
+	recorder := txpool.NewRecorder(db, 0)
+	pool.SetRecorder(recorder)
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+
+	start := uint64(time.Now().UnixNano())
+
+	// A queued transaction, promoted once the gap is filled, a replaced one
+	// whose replacement gets included, and one outdated by a transaction the
+	// pool never saw.
+	var (
+		tx0    = pricedTransaction(0, 100000, big.NewInt(1), key)
+		tx1    = pricedTransaction(1, 100000, big.NewInt(1), key)
+		rep    = pricedTransaction(0, 100000, big.NewInt(2), key)
+		stale  = pricedTransaction(0, 100000, big.NewInt(1), other)
+		unseen = pricedTransaction(0, 100000, big.NewInt(3), other)
+	)
+	for _, tx := range []*types.Transaction{tx1, tx0, rep, stale} {
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The head moves to a child block, then by two blocks at once.
+	statedb.SetNonce(from, 1)
+	statedb.SetNonce(crypto.PubkeyToAddress(other.PublicKey), 1)
+	head1 := chain.newBlock(genesis, rep, unseen)
+	<-pool.requestReset(genesis, head1)
+
+	statedb.SetNonce(from, 2)
+	head3 := chain.newBlock(chain.newBlock(head1, tx1))
+	<-pool.requestReset(head1, head3)
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("have %d pending and %d queued, want an empty pool", pending, queued)
+	}
+	recorder.Close()
+
+	kinds := func(events []*txpool.Event) []txpool.EventKind {
+		var res []txpool.EventKind
+		for _, ev := range events {
+			res = append(res, ev.Kind)
+		}
+		return res
+	}
+	tests := []struct {
+		tx   *types.Transaction
+		want []txpool.EventKind
+	}{
+		{tx0, []txpool.EventKind{txpool.EventAdded, txpool.EventPromoted, txpool.EventReplaced}},
+		{tx1, []txpool.EventKind{txpool.EventAdded, txpool.EventPromoted, txpool.EventIncluded}},
+		{rep, []txpool.EventKind{txpool.EventAdded, txpool.EventIncluded}},
+		{stale, []txpool.EventKind{txpool.EventAdded, txpool.EventPromoted, txpool.EventDropped}},
+		{unseen, nil},
+	}
+	for i, tt := range tests {
+		if have := kinds(recorder.ByHash(tt.tx.Hash(), 0)); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: have events %v, want %v", i, have, tt.want)
+		}
+	}
+	events := recorder.ByHash(tx0.Hash(), 0)
+	if replaced := events[2]; replaced.Replacement != rep.Hash() || replaced.Sender != from {
+		t.Errorf("replacement: have %x from %x, want %x from %x", replaced.Replacement, replaced.Sender, rep.Hash(), from)
+	}
+	if included := recorder.ByHash(rep.Hash(), 0)[1]; included.Block != 1 || included.Sender != from {
+		t.Errorf("inclusion: have block %d from %x, want block 1 from %x", included.Block, included.Sender, from)
+	}
+	if included := recorder.ByHash(tx1.Hash(), 0)[2]; included.Block != 2 {
+		t.Errorf("inclusion: have block %d, want 2", included.Block)
+	}
+	if dropped := recorder.ByHash(stale.Hash(), 0)[2]; dropped.Reason != "nonce too low" {
+		t.Errorf("drop: have reason %q, want %q", dropped.Reason, "nonce too low")
+	}
+	end := uint64(time.Now().UnixNano())
+	if have := len(recorder.BySender(from, start, end, 0)); have != 8 {
+		t.Errorf("sender history: have %d events, want 8", have)
+	}
+	if have := len(recorder.Range(start, end, 3)); have != 3 {
+		t.Errorf("limited range: have %d events, want 3", have)
+	}
+	if have := len(recorder.Range(end, end+1, 0)); have != 0 {
+		t.Errorf("empty range: have %d events, want 0", have)
+	}
+}
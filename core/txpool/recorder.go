@@ -0,0 +1,336 @@
+package txpool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EventKind is the kind of a recorded pool event.
+type EventKind uint8
+
+const (
+	EventAdded    EventKind = iota // Transaction entered the pool
+	EventReplaced                  // Transaction was replaced by another with the same nonce
+	EventPromoted                  // Transaction moved from the queue into the pending set
+	EventDemoted                   // Transaction moved from the pending set back into the queue
+	EventDropped                   // Transaction was removed from the pool without replacement
+	EventIncluded                  // Transaction was included in a block
+)
+
+// String implements fmt.Stringer.
+func (k EventKind) String() string {
+	switch k {
+	case EventAdded:
+		return "added"
+	case EventReplaced:
+		return "replaced"
+	case EventPromoted:
+		return "promoted"
+	case EventDemoted:
+		return "demoted"
+	case EventDropped:
+		return "dropped"
+	case EventIncluded:
+		return "included"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a single entry of the pool's arrival timeline.
+type Event struct {
+	Time        uint64 // Unix time of the event in nanoseconds
+	Kind        EventKind
+	Hash        common.Hash
+	Sender      common.Address
+	Nonce       uint64
+	Reason      string      // Why a transaction was dropped
+	Replacement common.Hash // Transaction replacing a replaced one
+	Block       uint64      // Block including an included transaction
+}
+
+var (
+	// Events are stored by time, and indexed by transaction and by sender.
+	recorderEventPrefix  = []byte("plugeth-txh-e") // recorderEventPrefix + time (uint64 big endian) + hash + kind -> event
+	recorderHashPrefix   = []byte("plugeth-txh-h") // recorderHashPrefix + hash + time + kind -> nil
+	recorderSenderPrefix = []byte("plugeth-txh-a") // recorderSenderPrefix + sender + time + hash + kind -> nil
+
+	recorderDropMeter = metrics.NewRegisteredMeter("txpool/recorder/dropped", nil)
+)
+
+const (
+	// recorderQueue is the number of events buffered for writing. Events are
+	// not recorded while the buffer is full rather than stalling the pool.
+	recorderQueue = 16384
+
+	// recorderPruneInterval is how often events past the retention are deleted.
+	recorderPruneInterval = 10 * time.Minute
+)
+
+// Recorder keeps the history of the transactions going through the pool in a
+// database, to be queried by transaction, by sender or by time. All methods
+// can be called on a nil Recorder, which doesn't record anything.
+type Recorder struct {
+	db        ethdb.KeyValueStore
+	retention time.Duration // How long events are kept, forever if zero
+
+	events chan *Event
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRecorder creates a recorder writing into the given database and starts
+// its writer.
+func NewRecorder(db ethdb.KeyValueStore, retention time.Duration) *Recorder {
+	r := &Recorder{
+		db:        db,
+		retention: retention,
+		events:    make(chan *Event, recorderQueue),
+		quit:      make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r
+}
+
+// Record adds an event for the transaction to the timeline.
+func (r *Recorder) Record(kind EventKind, hash common.Hash, sender common.Address, nonce uint64, reason string) {
+	if r == nil {
+		return
+	}
+	r.record(&Event{Kind: kind, Hash: hash, Sender: sender, Nonce: nonce, Reason: reason})
+}
+
+// RecordReplaced adds the replacement of a transaction by another one to the
+// timeline.
+func (r *Recorder) RecordReplaced(hash common.Hash, sender common.Address, nonce uint64, replacement common.Hash) {
+	if r == nil {
+		return
+	}
+	r.record(&Event{Kind: EventReplaced, Hash: hash, Sender: sender, Nonce: nonce, Replacement: replacement})
+}
+
+// RecordIncluded adds the inclusion of a transaction in the given block to
+// the timeline. Subpools record it when they remove an included transaction.
+func (r *Recorder) RecordIncluded(hash common.Hash, sender common.Address, nonce uint64, block uint64) {
+	if r == nil {
+		return
+	}
+	r.record(&Event{Kind: EventIncluded, Hash: hash, Sender: sender, Nonce: nonce, Block: block})
+}
+
+func (r *Recorder) record(ev *Event) {
+	ev.Time = uint64(time.Now().UnixNano())
+	select {
+	case r.events <- ev:
+	default:
+		recorderDropMeter.Mark(1)
+	}
+}
+
+// Close stops the recorder after writing the buffered events.
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// loop writes the recorded events in batches and prunes old ones.
+func (r *Recorder) loop() {
+	defer r.wg.Done()
+
+	prune := time.NewTicker(recorderPruneInterval)
+	defer prune.Stop()
+
+	batch := r.db.NewBatch()
+	flush := func() {
+		if batch.ValueSize() == 0 {
+			return
+		}
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to write pool history", "err", err)
+		}
+		batch.Reset()
+	}
+	for {
+		select {
+		case ev := <-r.events:
+			writeEvent(batch, ev)
+			// Keep batching while events are waiting
+			for drained := false; !drained && batch.ValueSize() < ethdb.IdealBatchSize; {
+				select {
+				case ev := <-r.events:
+					writeEvent(batch, ev)
+				default:
+					drained = true
+				}
+			}
+			flush()
+
+		case <-prune.C:
+			if r.retention > 0 {
+				r.prune(uint64(time.Now().Add(-r.retention).UnixNano()))
+			}
+
+		case <-r.quit:
+			for drained := false; !drained; {
+				select {
+				case ev := <-r.events:
+					writeEvent(batch, ev)
+				default:
+					drained = true
+				}
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// prune deletes the events older than the given time.
+func (r *Recorder) prune(before uint64) {
+	var (
+		it    = r.db.NewIterator(recorderEventPrefix, nil)
+		batch = r.db.NewBatch()
+		count int
+	)
+	defer it.Release()
+
+	for it.Next() {
+		ev := new(Event)
+		if err := rlp.DecodeBytes(it.Value(), ev); err != nil || ev.Time >= before {
+			break
+		}
+		batch.Delete(it.Key())
+		batch.Delete(hashIndexKey(ev))
+		batch.Delete(senderIndexKey(ev))
+		count++
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Error("Failed to prune pool history", "err", err)
+				return
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to prune pool history", "err", err)
+		return
+	}
+	if count > 0 {
+		log.Debug("Pruned pool history", "events", count)
+	}
+}
+
+// ByHash returns the events of a transaction by time, at most limit of them
+// if limit is positive.
+func (r *Recorder) ByHash(hash common.Hash, limit int) []*Event {
+	if r == nil {
+		return nil
+	}
+	prefix := append(append([]byte{}, recorderHashPrefix...), hash.Bytes()...)
+	return r.collect(prefix, nil, nil, limit, func(key []byte) []byte {
+		// hash + time + kind
+		suffix := key[len(recorderHashPrefix):]
+		return eventKey(binary.BigEndian.Uint64(suffix[common.HashLength:]), common.BytesToHash(suffix[:common.HashLength]), EventKind(suffix[common.HashLength+8]))
+	})
+}
+
+// BySender returns the events of the transactions of a sender between the
+// given unix times in nanoseconds, the end excluded.
+func (r *Recorder) BySender(sender common.Address, from, to uint64, limit int) []*Event {
+	if r == nil {
+		return nil
+	}
+	prefix := append(append([]byte{}, recorderSenderPrefix...), sender.Bytes()...)
+	return r.collect(prefix, encodeTime(from), encodeTime(to), limit, func(key []byte) []byte {
+		// sender + time + hash + kind
+		suffix := key[len(recorderSenderPrefix)+common.AddressLength:]
+		return eventKey(binary.BigEndian.Uint64(suffix), common.BytesToHash(suffix[8:8+common.HashLength]), EventKind(suffix[8+common.HashLength]))
+	})
+}
+
+// Range returns the events between the given unix times in nanoseconds, the
+// end excluded.
+func (r *Recorder) Range(from, to uint64, limit int) []*Event {
+	if r == nil {
+		return nil
+	}
+	return r.collect(recorderEventPrefix, encodeTime(from), encodeTime(to), limit, nil)
+}
+
+// collect iterates the keys with the prefix between the start and end
+// suffixes, decoding the events they point to.
+func (r *Recorder) collect(prefix, start, end []byte, limit int, resolve func([]byte) []byte) []*Event {
+	it := r.db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var events []*Event
+	for it.Next() {
+		if end != nil && bytes.Compare(it.Key()[len(prefix):], end) >= 0 {
+			break
+		}
+		blob := it.Value()
+		if resolve != nil {
+			var err error
+			if blob, err = r.db.Get(resolve(it.Key())); err != nil {
+				continue // pruned in the meantime
+			}
+		}
+		ev := new(Event)
+		if err := rlp.DecodeBytes(blob, ev); err != nil {
+			log.Error("Invalid pool history event", "err", err)
+			continue
+		}
+		events = append(events, ev)
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+	}
+	return events
+}
+
+func writeEvent(batch ethdb.KeyValueWriter, ev *Event) {
+	blob, err := rlp.EncodeToBytes(ev)
+	if err != nil {
+		log.Error("Failed to encode pool history event", "err", err)
+		return
+	}
+	batch.Put(eventKey(ev.Time, ev.Hash, ev.Kind), blob)
+	batch.Put(hashIndexKey(ev), nil)
+	batch.Put(senderIndexKey(ev), nil)
+}
+
+func encodeTime(t uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, t)
+}
+
+func eventKey(time uint64, hash common.Hash, kind EventKind) []byte {
+	key := append(append([]byte{}, recorderEventPrefix...), encodeTime(time)...)
+	key = append(key, hash.Bytes()...)
+	return append(key, byte(kind))
+}
+
+func hashIndexKey(ev *Event) []byte {
+	key := append(append([]byte{}, recorderHashPrefix...), ev.Hash.Bytes()...)
+	key = append(key, encodeTime(ev.Time)...)
+	return append(key, byte(ev.Kind))
+}
+
+func senderIndexKey(ev *Event) []byte {
+	key := append(append([]byte{}, recorderSenderPrefix...), ev.Sender.Bytes()...)
+	key = append(key, encodeTime(ev.Time)...)
+	key = append(key, ev.Hash.Bytes()...)
+	return append(key, byte(ev.Kind))
+}
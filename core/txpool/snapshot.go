@@ -0,0 +1,89 @@
+package txpool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Exporter is implemented by subpools able to export their content for a
+// pool snapshot.
+type Exporter interface {
+	// Export returns all pending and queued transactions of the subpool,
+	// grouped by sender and sorted by nonce, with blob sidecars attached. The
+	// Time of every transaction is the time it was first seen.
+	Export() []*types.Transaction
+}
+
+// snapshotEntry is a transaction of a pool snapshot, with the unix time in
+// nanoseconds it was first seen.
+type snapshotEntry struct {
+	Time uint64
+	Tx   *types.Transaction
+}
+
+// Export returns all transactions of the subpools supporting it, grouped by
+// subpool and sender and sorted by nonce, with the time they were first seen.
+func (p *TxPool) Export() []*types.Transaction {
+	var txs []*types.Transaction
+	for _, subpool := range p.subpools {
+		if exporter, ok := subpool.(Exporter); ok {
+			txs = append(txs, exporter.Export()...)
+		}
+	}
+	return txs
+}
+
+// SortExport orders the transactions of a subpool export by sender and nonce.
+func SortExport(txs map[common.Address][]*types.Transaction) []*types.Transaction {
+	addrs := make([]common.Address, 0, len(txs))
+	for addr := range txs {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	var sorted []*types.Transaction
+	for _, addr := range addrs {
+		list := txs[addr]
+		sort.Slice(list, func(i, j int) bool { return list[i].Nonce() < list[j].Nonce() })
+		sorted = append(sorted, list...)
+	}
+	return sorted
+}
+
+// WriteSnapshot writes the transactions as a pool snapshot, an RLP stream
+// of transactions in their network encoding with their first seen time.
+func WriteSnapshot(w io.Writer, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		if err := rlp.Encode(w, &snapshotEntry{Time: uint64(tx.Time().UnixNano()), Tx: tx}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshot reads the transactions of a pool snapshot, restoring the time
+// they were first seen.
+func ReadSnapshot(r io.Reader) ([]*types.Transaction, error) {
+	var (
+		stream = rlp.NewStream(r, 0)
+		txs    []*types.Transaction
+	)
+	for {
+		entry := new(snapshotEntry)
+		if err := stream.Decode(entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return txs, nil
+			}
+			return txs, err
+		}
+		entry.Tx.SetTime(time.Unix(0, int64(entry.Time)))
+		txs = append(txs, entry.Tx)
+	}
+}
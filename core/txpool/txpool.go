@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -75,6 +76,8 @@ type TxPool struct {
 	term chan struct{}           // Termination channel to detect a closed pool
 
 	sync chan chan error // Testing / simulator channel to block until internal reset is done
+
+	recorder atomic.Pointer[Recorder] // PluGeth injection: history of the pool's transactions, if recorded
 }
 
 // New creates a new transaction pool to gather, sort and filter inbound
@@ -276,6 +279,23 @@ func (p *TxPool) loop(head *types.Header, chain BlockChain) {
 	errc <- nil
 }
 
+// SetRecorder makes the pool and the subpools supporting it record the history
+// of their transactions.
+func (p *TxPool) SetRecorder(recorder *Recorder) {
+	p.recorder.Store(recorder)
+	for _, subpool := range p.subpools {
+		if s, ok := subpool.(interface{ SetRecorder(*Recorder) }); ok {
+			s.SetRecorder(recorder)
+		}
+	}
+}
+
+// Recorder returns the recorder of the pool's history, nil if it isn't
+// recorded.
+func (p *TxPool) Recorder() *Recorder {
+	return p.recorder.Load()
+}
+
 // SetGasTip updates the minimum gas tip required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (p *TxPool) SetGasTip(tip *big.Int) {
@@ -41,3 +41,26 @@ type EVMLogger interface {
 	CaptureState(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, rData []byte, depth int, err error)
 	CaptureFault(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, depth int, err error)
 }
+
+// begin PluGeth injection
+
+// StepSkipper is implemented by loggers that may not need the opcode level
+// events. If SkipSteps reports true, the interpreter does not run in debug
+// mode and CaptureState and CaptureFault are not called.
+type StepSkipper interface {
+	SkipSteps() bool
+}
+
+// tracesSteps reports whether the opcode level events are passed to the
+// logger.
+func tracesSteps(tracer EVMLogger) bool {
+	if tracer == nil {
+		return false
+	}
+	if skipper, ok := tracer.(StepSkipper); ok {
+		return !skipper.SkipSteps()
+	}
+	return true
+}
+
+// end PluGeth injection
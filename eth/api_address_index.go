@@ -0,0 +1,197 @@
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxAddressActivity is the maximum number of entries returned by an activity
+// query.
+const maxAddressActivity = 1000
+
+var errNoAddressIndex = errors.New("address activity is not indexed, enable it with --history.addresses")
+
+// AddressIndexAPI serves the index of the transactions involving an address.
+type AddressIndexAPI struct {
+	eth *Ethereum
+}
+
+// NewAddressIndexAPI creates a new API for the address activity index.
+func NewAddressIndexAPI(eth *Ethereum) *AddressIndexAPI {
+	return &AddressIndexAPI{eth: eth}
+}
+
+// AddressActivityArgs selects the entries returned by an activity query. The
+// cursor is the one returned by the previous page, if any.
+type AddressActivityArgs struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"`
+	Roles     []string         `json:"roles"`
+	Cursor    hexutil.Bytes    `json:"cursor"`
+	Limit     *hexutil.Uint64  `json:"limit"`
+}
+
+// AddressActivity is a transaction involving an address, with the role the
+// address plays in it: sender, recipient, creation or internal.
+type AddressActivity struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	Role             string         `json:"role"`
+}
+
+// AddressActivityPage is a page of the activity of an address. The cursor of
+// the next page is nil once all matching entries were returned.
+type AddressActivityPage struct {
+	Activity []*AddressActivity `json:"activity"`
+	Cursor   hexutil.Bytes      `json:"cursor"`
+}
+
+// AddressIndexStatus is the range of blocks whose activity is indexed. The
+// internal calls are only indexed from InternalTail on, past Head if none are.
+type AddressIndexStatus struct {
+	Tail         hexutil.Uint64 `json:"tail"`
+	Head         hexutil.Uint64 `json:"head"`
+	Remaining    hexutil.Uint64 `json:"remaining"`
+	InternalTail hexutil.Uint64 `json:"internalTail"`
+}
+
+// AddressIndexStatus returns the range of blocks whose activity is indexed,
+// the number of blocks left to index and the first block whose internal calls
+// are indexed, those of blocks synced or imported before the index was enabled
+// being unknown.
+func (api *AddressIndexAPI) AddressIndexStatus() (*AddressIndexStatus, error) {
+	progress, err := api.eth.blockchain.AddressIndexProgress()
+	if err != nil {
+		return nil, errNoAddressIndex
+	}
+	return &AddressIndexStatus{
+		Tail:         hexutil.Uint64(progress.Tail),
+		Head:         hexutil.Uint64(progress.Head),
+		Remaining:    hexutil.Uint64(progress.Remaining),
+		InternalTail: hexutil.Uint64(progress.InternalTail),
+	}, nil
+}
+
+// GetAddressActivity returns the transactions involving an address, in the
+// order of the chain, one page at a time.
+func (api *AddressIndexAPI) GetAddressActivity(ctx context.Context, address common.Address, args *AddressActivityArgs) (*AddressActivityPage, error) {
+	progress, err := api.eth.blockchain.AddressIndexProgress()
+	if err != nil {
+		return nil, errNoAddressIndex
+	}
+	if args == nil {
+		args = new(AddressActivityArgs)
+	}
+	var (
+		from  uint64
+		to    = progress.Head
+		limit = uint64(maxAddressActivity)
+		roles map[rawdb.AddressRole]bool
+	)
+	if args.FromBlock != nil {
+		if from, err = api.resolveNumber(ctx, *args.FromBlock); err != nil {
+			return nil, err
+		}
+	}
+	if args.ToBlock != nil {
+		number, err := api.resolveNumber(ctx, *args.ToBlock)
+		if err != nil {
+			return nil, err
+		}
+		if number < to {
+			to = number
+		}
+	}
+	if args.Limit != nil && uint64(*args.Limit) < limit {
+		limit = uint64(*args.Limit)
+	}
+	if limit == 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	if len(args.Roles) > 0 {
+		roles = make(map[rawdb.AddressRole]bool)
+		for _, name := range args.Roles {
+			role, err := parseAddressRole(name)
+			if err != nil {
+				return nil, err
+			}
+			roles[role] = true
+		}
+	}
+	cursor := binary.BigEndian.AppendUint64(nil, from)
+	if args.Cursor != nil {
+		if len(args.Cursor) != rawdb.AddressActivityCursorLength {
+			return nil, errors.New("invalid cursor")
+		}
+		cursor = args.Cursor
+	}
+	var (
+		db     = api.eth.ChainDb()
+		page   = &AddressActivityPage{Activity: []*AddressActivity{}}
+		blocks = make(map[uint64]*types.Block)
+	)
+	rawdb.ReadAddressActivity(db, address, cursor, func(entry rawdb.AddressActivity) bool {
+		if entry.Number > to {
+			return false
+		}
+		if roles != nil && !roles[entry.Role] {
+			return true
+		}
+		if uint64(len(page.Activity)) == limit {
+			page.Cursor = entry.Cursor()
+			return false
+		}
+		block, ok := blocks[entry.Number]
+		if !ok {
+			block = rawdb.ReadBlock(db, rawdb.ReadCanonicalHash(db, entry.Number), entry.Number)
+			blocks[entry.Number] = block
+		}
+		if block == nil || block.Hash() != entry.Hash || int(entry.TxIndex) >= len(block.Transactions()) {
+			// Stale entry of a block being reorged or rewound
+			return true
+		}
+		page.Activity = append(page.Activity, &AddressActivity{
+			BlockNumber:      hexutil.Uint64(entry.Number),
+			BlockHash:        block.Hash(),
+			TransactionIndex: hexutil.Uint64(entry.TxIndex),
+			TransactionHash:  block.Transactions()[entry.TxIndex].Hash(),
+			Role:             entry.Role.String(),
+		})
+		return true
+	})
+	return page, nil
+}
+
+// resolveNumber returns the number of the given block.
+func (api *AddressIndexAPI) resolveNumber(ctx context.Context, number rpc.BlockNumber) (uint64, error) {
+	if number >= 0 {
+		return uint64(number), nil
+	}
+	header, err := api.eth.APIBackend.HeaderByNumber(ctx, number)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block %d not found", number)
+	}
+	return header.Number.Uint64(), nil
+}
+
+func parseAddressRole(name string) (rawdb.AddressRole, error) {
+	for _, role := range []rawdb.AddressRole{rawdb.AddressRoleSender, rawdb.AddressRoleRecipient, rawdb.AddressRoleCreation, rawdb.AddressRoleInternal} {
+		if role.String() == name {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown address role %q", name)
+}
@@ -0,0 +1,75 @@
+package eth
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+)
+
+// MempoolImportResult is the outcome of a mempool snapshot import.
+type MempoolImportResult struct {
+	Imported int                    `json:"imported"`
+	Rejected map[common.Hash]string `json:"rejected"`
+}
+
+// ExportMempool writes all transactions of the pool, legacy and blob, into a
+// local file with the time they were first seen, returning their number.
+func (api *AdminAPI) ExportMempool(file string) (int, error) {
+	if _, err := os.Stat(file); err == nil {
+		// File already exists. Allowing overwrite could be a DoS vector,
+		// since the 'file' may point to arbitrary paths on the drive.
+		return 0, errors.New("location would overwrite an existing file")
+	}
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var writer io.Writer = out
+	if strings.HasSuffix(file, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	txs := api.eth.TxPool().Export()
+	if err := txpool.WriteSnapshot(writer, txs); err != nil {
+		return 0, err
+	}
+	return len(txs), nil
+}
+
+// ImportMempool adds the transactions of a mempool snapshot to the pool,
+// keeping the time they were first seen. Transactions are added as remote
+// ones unless local is set.
+func (api *AdminAPI) ImportMempool(file string, local *bool) (*MempoolImportResult, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	if strings.HasSuffix(file, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	txs, err := txpool.ReadSnapshot(reader)
+	if err != nil {
+		return nil, err
+	}
+	result := &MempoolImportResult{Rejected: make(map[common.Hash]string)}
+	for i, err := range api.eth.TxPool().Add(txs, local != nil && *local, true) {
+		if err != nil {
+			result.Rejected[txs[i].Hash()] = err.Error()
+		} else {
+			result.Imported++
+		}
+	}
+	return result, nil
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
 )
 
 // EthAPIBackend implements ethapi.Backend and tracers.Backend for full nodes
@@ -329,6 +330,24 @@ func (b *EthAPIBackend) GetTransaction(ctx context.Context, txHash common.Hash)
 	return true, tx, lookup.BlockHash, lookup.BlockIndex, lookup.Index, nil
 }
 
+// SenderNonceLookup returns the hash of the canonical transaction sent by an
+// address with the given nonce, the zero hash if it is not indexed.
+// PluGeth injection
+func (b *EthAPIBackend) SenderNonceLookup(sender common.Address, nonce uint64) (common.Hash, error) {
+	if !b.eth.config.SenderNonceIndex {
+		return common.Hash{}, errors.New("transactions are not indexed by sender and nonce, enable it with --history.sendernonces")
+	}
+	return rawdb.ReadSenderNonceLookup(b.eth.ChainDb(), sender, nonce), nil
+}
+
+// SenderNonceIndexDone reports whether the transactions of the whole
+// transaction index range are indexed by sender and nonce.
+// PluGeth injection
+func (b *EthAPIBackend) SenderNonceIndexDone() bool {
+	progress, err := b.eth.blockchain.SenderNonceIndexProgress()
+	return err == nil && progress.Done()
+}
+
 func (b *EthAPIBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.eth.txPool.Nonce(addr), nil
 }
@@ -433,6 +452,13 @@ func (b *EthAPIBackend) StateAtBlock(ctx context.Context, block *types.Block, re
 	return b.eth.stateAtBlock(ctx, block, reexec, base, readOnly, preferDisk)
 }
 
+// StateHistoryDiff reconstructs the change made by the block transitioning to
+// the given state root from the path-based state histories.
+// PluGeth injection
+func (b *EthAPIBackend) StateHistoryDiff(root common.Hash) (*pathdb.HistoryDiff, error) {
+	return b.eth.blockchain.TrieDB().StateHistoryDiff(root)
+}
+
 func (b *EthAPIBackend) StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (*core.Message, vm.BlockContext, *state.StateDB, tracers.StateReleaseFunc, error) {
 	return b.eth.stateAtTransaction(ctx, block, txIndex, reexec)
 }
@@ -38,7 +38,8 @@ import (
 // DebugAPI is the collection of Ethereum full node APIs for debugging the
 // protocol.
 type DebugAPI struct {
-	eth *Ethereum
+	eth     *Ethereum
+	exports stateExportPins // States kept for their exports
 }
 
 // NewDebugAPI creates a new DebugAPI instance.
@@ -0,0 +1,176 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxStateExportItems is the maximum number of accounts and storage slots
+	// returned by a page of a state export.
+	maxStateExportItems = 10000
+
+	// stateExportTimeout is how long the state of an export is kept after its
+	// last page was requested.
+	stateExportTimeout = 5 * time.Minute
+)
+
+// StateExportPrunedCode is the JSON-RPC error code returned if the state of an
+// export was pruned before its last page was requested.
+const StateExportPrunedCode = -32010
+
+// StateExportPage is a page of the export of the state of a block. The cursor
+// of the next page is nil once all accounts were returned. An account whose
+// storage spans several pages is continued on the next ones.
+type StateExportPage struct {
+	BlockHash   common.Hash              `json:"blockHash"`
+	BlockNumber hexutil.Uint64           `json:"blockNumber"`
+	Root        common.Hash              `json:"root"`
+	Accounts    []*state.ExportedAccount `json:"accounts"`
+	Cursor      hexutil.Bytes            `json:"cursor"`
+}
+
+// stateExportPrunedError is returned if the state of a block was pruned while
+// it was being exported. The export can't be resumed, it has to be restarted
+// from a more recent block.
+type stateExportPrunedError struct {
+	number uint64
+}
+
+func (e *stateExportPrunedError) Error() string {
+	return fmt.Sprintf("state of block #%d was pruned during the export, restart it from a more recent block", e.number)
+}
+
+func (e *stateExportPrunedError) ErrorCode() int { return StateExportPrunedCode }
+
+// ExportState returns a page of the accounts of the state of a block, with
+// their code and storage, in the order of their hashes, starting at the cursor
+// returned by the previous page, if any. Each page holds up to limit accounts
+// and storage slots.
+//
+// With the hash based state scheme, the state is kept by the node until no page
+// of its export was requested for stateExportTimeout, even once the block is
+// older than the states the node keeps. With the path based scheme, the state
+// can't be kept and an error with the StateExportPrunedCode is returned once it
+// was pruned.
+func (api *DebugAPI) ExportState(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, cursor hexutil.Bytes, limit *hexutil.Uint64) (*StateExportPage, error) {
+	if number, ok := blockNrOrHash.Number(); ok && number == rpc.PendingBlockNumber {
+		return nil, errors.New("pending state can not be exported")
+	}
+	header, err := api.eth.APIBackend.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	chain := api.eth.BlockChain()
+	if !api.exports.pin(chain, header.Root) {
+		if len(cursor) > 0 {
+			return nil, &stateExportPrunedError{number: header.Number.Uint64()}
+		}
+		return nil, fmt.Errorf("state of block #%d is not available", header.Number)
+	}
+	max := maxStateExportItems
+	if limit != nil && *limit > 0 && *limit < maxStateExportItems {
+		max = int(*limit)
+	}
+	page := &StateExportPage{
+		BlockHash:   header.Hash(),
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		Root:        header.Root,
+	}
+	export := func(snaps *snapshot.Tree) (err error) {
+		page.Accounts = []*state.ExportedAccount{}
+		page.Cursor, err = state.ExportState(chain.StateCache(), snaps, header.Root, cursor, max, func(account *state.ExportedAccount) error {
+			page.Accounts = append(page.Accounts, account)
+			return ctx.Err()
+		})
+		return err
+	}
+	err = export(chain.Snapshots())
+	if errors.Is(err, snapshot.ErrSnapshotStale) {
+		// The snapshot layer of the state was flattened during the page,
+		// export it again from the tries.
+		err = export(nil)
+	}
+	if err != nil {
+		if !chain.HasState(header.Root) {
+			return nil, &stateExportPrunedError{number: header.Number.Uint64()}
+		}
+		return nil, err
+	}
+	return page, nil
+}
+
+// stateExportPins keeps the states being exported referenced in the trie
+// database, so they aren't garbage collected once their blocks are older than
+// the states the node keeps.
+type stateExportPins struct {
+	pins map[common.Hash]*stateExportPin
+	lock sync.Mutex
+}
+
+// stateExportPin is the reference to the state of an export.
+type stateExportPin struct {
+	used  time.Time // Time the last page of the export was requested
+	timer *time.Timer
+}
+
+// pin keeps the state with the given root available until no page of its
+// export was requested for stateExportTimeout, returning whether the state is
+// available. States can only be kept with the hash based state scheme, with the
+// path based one the availability of the state is returned as is.
+func (p *stateExportPins) pin(chain *core.BlockChain, root common.Hash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if pin, ok := p.pins[root]; ok {
+		pin.used = time.Now()
+		return chain.HasState(root)
+	}
+	triedb := chain.TrieDB()
+	if triedb.Scheme() != rawdb.HashScheme {
+		return chain.HasState(root)
+	}
+	// Reference the state before checking it, it might otherwise be garbage
+	// collected in between. States already flushed to disk are never removed
+	// by the hash based scheme, referencing them is a noop.
+	triedb.Reference(root, common.Hash{})
+	if !chain.HasState(root) {
+		triedb.Dereference(root)
+		return false
+	}
+	if p.pins == nil {
+		p.pins = make(map[common.Hash]*stateExportPin)
+	}
+	pin := &stateExportPin{used: time.Now()}
+	pin.timer = time.AfterFunc(stateExportTimeout, func() { p.expire(chain, root, pin) })
+	p.pins[root] = pin
+	return true
+}
+
+// expire releases the state of an export if no page of it was requested for
+// stateExportTimeout, rescheduling itself otherwise.
+func (p *stateExportPins) expire(chain *core.BlockChain, root common.Hash, pin *stateExportPin) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if idle := time.Since(pin.used); idle < stateExportTimeout {
+		pin.timer.Reset(stateExportTimeout - idle)
+		return
+	}
+	delete(p.pins, root)
+	chain.TrieDB().Dereference(root)
+}
@@ -0,0 +1,122 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newStateExportTest creates a chain with the given state scheme holding the
+// first two of its blocks, returning the debug API serving it and the blocks
+// left to insert, enough for the states of the first ones to be pruned.
+func newStateExportTest(t *testing.T, scheme string) (*DebugAPI, *core.BlockChain, []*types.Block) {
+	alloc := types.GenesisAlloc{}
+	for i := byte(1); i <= 6; i++ {
+		account := types.Account{Balance: big.NewInt(int64(i)), Nonce: uint64(i)}
+		if i%2 == 0 {
+			account.Code = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+			account.Storage = make(map[common.Hash]common.Hash)
+			for j := byte(1); j <= i; j++ {
+				account.Storage[common.BytesToHash([]byte{j})] = common.BytesToHash([]byte{i, j})
+			}
+		}
+		alloc[common.BytesToAddress([]byte{i})] = account
+	}
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 2+core.TriesInMemory+10, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.BytesToAddress([]byte{byte(i), 0xff}))
+	})
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(scheme), gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+	if _, err := chain.InsertChain(blocks[:2]); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	backend := &Ethereum{blockchain: chain}
+	backend.APIBackend = &EthAPIBackend{eth: backend}
+	return NewDebugAPI(backend), chain, blocks[2:]
+}
+
+func TestExportStateKeptDuringExport(t *testing.T) {
+	api, chain, blocks := newStateExportTest(t, rawdb.HashScheme)
+
+	block := chain.GetBlockByNumber(1)
+	var want []*state.ExportedAccount
+	if _, err := state.ExportState(chain.StateCache(), nil, block.Root(), nil, maxStateExportItems, func(account *state.ExportedAccount) error {
+		want = append(want, account)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	var (
+		got    []*state.ExportedAccount
+		cursor hexutil.Bytes
+		limit  = hexutil.Uint64(3)
+	)
+	for pages := 0; ; pages++ {
+		page, err := api.ExportState(context.Background(), rpc.BlockNumberOrHashWithNumber(1), cursor, &limit)
+		if err != nil {
+			t.Fatalf("page %d: failed to export state: %v", pages, err)
+		}
+		for _, account := range page.Accounts {
+			if account.Continued {
+				last := got[len(got)-1]
+				last.Storage = append(last.Storage, account.Storage...)
+				continue
+			}
+			got = append(got, account)
+		}
+		if pages == 0 {
+			// Prune the states of the first blocks in the middle of the export
+			if _, err := chain.InsertChain(blocks); err != nil {
+				t.Fatalf("failed to insert blocks: %v", err)
+			}
+			if chain.HasState(chain.GetBlockByNumber(2).Root()) {
+				t.Fatal("state of block #2 not pruned")
+			}
+		}
+		if cursor = page.Cursor; cursor == nil {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("exported state mismatch: have %d accounts, want %d", len(got), len(want))
+	}
+}
+
+func TestExportStatePruned(t *testing.T) {
+	api, chain, blocks := newStateExportTest(t, rawdb.PathScheme)
+
+	limit := hexutil.Uint64(3)
+	page, err := api.ExportState(context.Background(), rpc.BlockNumberOrHashWithNumber(1), nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	_, err = api.ExportState(context.Background(), rpc.BlockNumberOrHashWithNumber(1), page.Cursor, &limit)
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != StateExportPrunedCode {
+		t.Fatalf("error mismatch: have %v, want code %d", err, StateExportPrunedCode)
+	}
+	// Exports of pruned states can't be started
+	if _, err := api.ExportState(context.Background(), rpc.BlockNumberOrHashWithNumber(2), nil, &limit); err == nil {
+		t.Fatal("exported pruned state")
+	}
+}
@@ -0,0 +1,133 @@
+package eth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/txpool"
+)
+
+// maxTxPoolHistory is the maximum number of events returned by a history query.
+const maxTxPoolHistory = 10000
+
+var errNoTxPoolHistory = errors.New("transaction pool history is not recorded, enable it with --txpool.history")
+
+// TxPoolHistoryAPI serves the history of the transaction pool.
+type TxPoolHistoryAPI struct {
+	eth *Ethereum
+}
+
+// NewTxPoolHistoryAPI creates a new history API for the transaction pool.
+func NewTxPoolHistoryAPI(eth *Ethereum) *TxPoolHistoryAPI {
+	return &TxPoolHistoryAPI{eth: eth}
+}
+
+// TxPoolEvent is an event of the transaction pool's history.
+type TxPoolEvent struct {
+	Time        time.Time       `json:"time"`
+	Event       string          `json:"event"`
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Reason      string          `json:"reason,omitempty"`
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+}
+
+func newTxPoolEvents(events []*txpool.Event) []*TxPoolEvent {
+	res := make([]*TxPoolEvent, 0, len(events))
+	for _, ev := range events {
+		event := &TxPoolEvent{
+			Time:   time.Unix(0, int64(ev.Time)).UTC(),
+			Event:  ev.Kind.String(),
+			Hash:   ev.Hash,
+			From:   ev.Sender,
+			Nonce:  hexutil.Uint64(ev.Nonce),
+			Reason: ev.Reason,
+		}
+		switch ev.Kind {
+		case txpool.EventReplaced:
+			replacement := ev.Replacement
+			event.ReplacedBy = &replacement
+		case txpool.EventIncluded:
+			number := hexutil.Uint64(ev.Block)
+			event.BlockNumber = &number
+		}
+		res = append(res, event)
+	}
+	return res
+}
+
+func (api *TxPoolHistoryAPI) recorder() (*txpool.Recorder, error) {
+	recorder := api.eth.TxPool().Recorder()
+	if recorder == nil {
+		return nil, errNoTxPoolHistory
+	}
+	return recorder, nil
+}
+
+// historyWindow turns the unix times in seconds of a query into nanoseconds,
+// the end defaulting to now and the start to an hour before the end.
+func historyWindow(from, to *hexutil.Uint64) (uint64, uint64, error) {
+	end := uint64(time.Now().UnixNano()) + 1
+	if to != nil {
+		end = uint64(*to) * uint64(time.Second)
+	}
+	var start uint64
+	if end > uint64(time.Hour) {
+		start = end - uint64(time.Hour)
+	}
+	if from != nil {
+		start = uint64(*from) * uint64(time.Second)
+	}
+	if start > end {
+		return 0, 0, errors.New("history window ends before it starts")
+	}
+	return start, end, nil
+}
+
+func historyLimit(limit *int) int {
+	if limit == nil || *limit <= 0 || *limit > maxTxPoolHistory {
+		return maxTxPoolHistory
+	}
+	return *limit
+}
+
+// History returns the recorded events of a transaction, oldest first.
+func (api *TxPoolHistoryAPI) History(hash common.Hash) ([]*TxPoolEvent, error) {
+	recorder, err := api.recorder()
+	if err != nil {
+		return nil, err
+	}
+	return newTxPoolEvents(recorder.ByHash(hash, maxTxPoolHistory)), nil
+}
+
+// HistoryBySender returns the recorded events of the transactions of a sender
+// between two unix times in seconds, the end excluded, oldest first.
+func (api *TxPoolHistoryAPI) HistoryBySender(sender common.Address, from, to *hexutil.Uint64, limit *int) ([]*TxPoolEvent, error) {
+	recorder, err := api.recorder()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := historyWindow(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return newTxPoolEvents(recorder.BySender(sender, start, end, historyLimit(limit))), nil
+}
+
+// HistoryRange returns the events recorded between two unix times in seconds,
+// the end excluded, oldest first.
+func (api *TxPoolHistoryAPI) HistoryRange(from, to *hexutil.Uint64, limit *int) ([]*TxPoolEvent, error) {
+	recorder, err := api.recorder()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := historyWindow(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return newTxPoolEvents(recorder.Range(start, end, historyLimit(limit))), nil
+}
@@ -203,6 +203,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
 			StateScheme:         scheme,
+			AddressIndex:        config.AddressIndex,
+			AddressIndexLimit:   config.AddressIndexLimit,
+			SenderNonceIndex:    config.SenderNonceIndex,
+			LogIndex:            config.LogIndex,
+			HistoryEra1Dir:      config.HistoryEra1Dir,
+			LiveTraceRetention:  config.LiveTraceRetention,
 		}
 	)
 	// Override the chain config with provided settings.
@@ -233,6 +239,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	// begin PluGeth code injection
+	if config.TxPoolHistory {
+		log.Info("Recording transaction pool history", "retention", common.PrettyDuration(config.TxPoolHistoryRetention))
+		eth.txPool.SetRecorder(txpool.NewRecorder(chainDb, config.TxPoolHistoryRetention))
+	}
+	// end PluGeth code injection
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
 	if eth.handler, err = newHandler(&handlerConfig{
@@ -329,6 +341,12 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "debug",
 			Service:   NewDebugAPI(s),
+		}, {
+			Namespace: "txpool",
+			Service:   NewTxPoolHistoryAPI(s),
+		}, {
+			Namespace: "eth",
+			Service:   NewAddressIndexAPI(s),
 		}, {
 			Namespace: "net",
 			Service:   s.netRPCService,
@@ -538,6 +556,7 @@ func (s *Ethereum) Stop() error {
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
 	s.txPool.Close()
+	s.txPool.Recorder().Close() // PluGeth injection
 	s.miner.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
@@ -49,25 +49,27 @@ var FullNodeGPO = gasprice.Config{
 
 // Defaults contains default settings for use on the Ethereum main net.
 var Defaults = Config{
-	SyncMode:           downloader.SnapSync,
-	NetworkId:          0, // enable auto configuration of networkID == chainID
-	TxLookupLimit:      2350000,
-	TransactionHistory: 2350000,
-	StateHistory:       params.FullImmutabilityThreshold,
-	LightPeers:         100,
-	DatabaseCache:      512,
-	TrieCleanCache:     154,
-	TrieDirtyCache:     256,
-	TrieTimeout:        60 * time.Minute,
-	SnapshotCache:      102,
-	FilterLogCacheSize: 32,
-	Miner:              miner.DefaultConfig,
-	TxPool:             legacypool.DefaultConfig,
-	BlobPool:           blobpool.DefaultConfig,
-	RPCGasCap:          50000000,
-	RPCEVMTimeout:      5 * time.Second,
-	GPO:                FullNodeGPO,
-	RPCTxFeeCap:        1, // 1 ether
+	SyncMode:               downloader.SnapSync,
+	NetworkId:              0, // enable auto configuration of networkID == chainID
+	TxLookupLimit:          2350000,
+	TransactionHistory:     2350000,
+	StateHistory:           params.FullImmutabilityThreshold,
+	LiveTraceRetention:     params.FullImmutabilityThreshold,
+	LightPeers:             100,
+	DatabaseCache:          512,
+	TrieCleanCache:         154,
+	TrieDirtyCache:         256,
+	TrieTimeout:            60 * time.Minute,
+	SnapshotCache:          102,
+	FilterLogCacheSize:     32,
+	Miner:                  miner.DefaultConfig,
+	TxPool:                 legacypool.DefaultConfig,
+	BlobPool:               blobpool.DefaultConfig,
+	TxPoolHistoryRetention: 7 * 24 * time.Hour,
+	RPCGasCap:              50000000,
+	RPCEVMTimeout:          5 * time.Second,
+	GPO:                    FullNodeGPO,
+	RPCTxFeeCap:            1, // 1 ether
 }
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
@@ -96,6 +98,27 @@ type Config struct {
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
 
+	// Whether to index the transactions involving each address, and the
+	// number of blocks from head to index (0 = entire chain)
+	AddressIndex      bool   `toml:",omitempty"`
+	AddressIndexLimit uint64 `toml:",omitempty"`
+
+	// Whether to index the transactions by sender and nonce, over the
+	// TransactionHistory range
+	SenderNonceIndex bool `toml:",omitempty"`
+
+	// Whether to index the blocks holding each log address and topic, for
+	// the log filters over wide ranges
+	LogIndex bool `toml:",omitempty"`
+
+	// Directory of Era1 files to serve the pre-merge block bodies and
+	// receipts from, pruning them from the ancient store
+	HistoryEra1Dir string `toml:",omitempty"`
+
+	// Number of blocks from head whose stored live tracer results are kept
+	// (0 = entire chain)
+	LiveTraceRetention uint64 `toml:",omitempty"`
+
 	// State scheme represents the scheme used to store ethereum states and trie
 	// nodes on top. It can be 'hash', 'path', or none which means use the scheme
 	// consistent with persistent state.
@@ -136,6 +159,11 @@ type Config struct {
 	TxPool   legacypool.Config
 	BlobPool blobpool.Config
 
+	// Whether to record the history of the pool's transactions, and for how
+	// long to keep it (forever if zero)
+	TxPoolHistory          bool
+	TxPoolHistoryRetention time.Duration
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -27,6 +27,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxLookupLimit           uint64                 `toml:",omitempty"`
 		TransactionHistory      uint64                 `toml:",omitempty"`
 		StateHistory            uint64                 `toml:",omitempty"`
+		AddressIndex            bool                   `toml:",omitempty"`
+		AddressIndexLimit       uint64                 `toml:",omitempty"`
+		SenderNonceIndex        bool                   `toml:",omitempty"`
+		LogIndex                bool                   `toml:",omitempty"`
+		HistoryEra1Dir          string                 `toml:",omitempty"`
+		LiveTraceRetention      uint64                 `toml:",omitempty"`
 		StateScheme             string                 `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
@@ -48,6 +54,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Miner                   miner.Config
 		TxPool                  legacypool.Config
 		BlobPool                blobpool.Config
+		TxPoolHistory           bool
+		TxPoolHistoryRetention  time.Duration
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
@@ -68,6 +76,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.TransactionHistory = c.TransactionHistory
 	enc.StateHistory = c.StateHistory
+	enc.AddressIndex = c.AddressIndex
+	enc.AddressIndexLimit = c.AddressIndexLimit
+	enc.SenderNonceIndex = c.SenderNonceIndex
+	enc.LogIndex = c.LogIndex
+	enc.HistoryEra1Dir = c.HistoryEra1Dir
+	enc.LiveTraceRetention = c.LiveTraceRetention
 	enc.StateScheme = c.StateScheme
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
@@ -89,6 +103,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Miner = c.Miner
 	enc.TxPool = c.TxPool
 	enc.BlobPool = c.BlobPool
+	enc.TxPoolHistory = c.TxPoolHistory
+	enc.TxPoolHistoryRetention = c.TxPoolHistoryRetention
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
@@ -113,6 +129,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxLookupLimit           *uint64                `toml:",omitempty"`
 		TransactionHistory      *uint64                `toml:",omitempty"`
 		StateHistory            *uint64                `toml:",omitempty"`
+		AddressIndex            *bool                  `toml:",omitempty"`
+		AddressIndexLimit       *uint64                `toml:",omitempty"`
+		SenderNonceIndex        *bool                  `toml:",omitempty"`
+		LogIndex                *bool                  `toml:",omitempty"`
+		HistoryEra1Dir          *string                `toml:",omitempty"`
+		LiveTraceRetention      *uint64                `toml:",omitempty"`
 		StateScheme             *string                `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
@@ -134,6 +156,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Miner                   *miner.Config
 		TxPool                  *legacypool.Config
 		BlobPool                *blobpool.Config
+		TxPoolHistory           *bool
+		TxPoolHistoryRetention  *time.Duration
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
@@ -177,6 +201,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StateHistory != nil {
 		c.StateHistory = *dec.StateHistory
 	}
+	if dec.AddressIndex != nil {
+		c.AddressIndex = *dec.AddressIndex
+	}
+	if dec.AddressIndexLimit != nil {
+		c.AddressIndexLimit = *dec.AddressIndexLimit
+	}
+	if dec.SenderNonceIndex != nil {
+		c.SenderNonceIndex = *dec.SenderNonceIndex
+	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
+	if dec.HistoryEra1Dir != nil {
+		c.HistoryEra1Dir = *dec.HistoryEra1Dir
+	}
+	if dec.LiveTraceRetention != nil {
+		c.LiveTraceRetention = *dec.LiveTraceRetention
+	}
 	if dec.StateScheme != nil {
 		c.StateScheme = *dec.StateScheme
 	}
@@ -240,6 +282,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.BlobPool != nil {
 		c.BlobPool = *dec.BlobPool
 	}
+	if dec.TxPoolHistory != nil {
+		c.TxPoolHistory = *dec.TxPoolHistory
+	}
+	if dec.TxPoolHistoryRetention != nil {
+		c.TxPoolHistoryRetention = *dec.TxPoolHistoryRetention
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
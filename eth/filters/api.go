@@ -266,6 +266,16 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 	if err != nil {
 		return nil, err
 	}
+	// begin PluGeth code injection
+	// Subscriptions from a block number first get the logs of the blocks up to
+	// the current head, the live logs being installed beforehand.
+	if head := api.sys.backend.CurrentHeader(); head != nil {
+		if begin, end, ok := backfillRange(crit, head.Number.Uint64()); ok {
+			go api.backfillLogs(notifier, rpcSub, crit, begin, end, logsSub, matchedLogs)
+			return rpcSub, nil
+		}
+	}
+	// end PluGeth code injection
 
 	go func() {
 		defer logsSub.Unsubscribe()
@@ -0,0 +1,136 @@
+package filters
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// backfillChunkSize is the number of blocks whose logs are retrieved at once
+// when backfilling a subscription.
+const backfillChunkSize = 2000
+
+// backfillError is the last notification of a subscription whose backfill
+// failed, e.g. as the history of the range was pruned. Nothing is delivered
+// after it, clients decoding it as a log fail their subscription.
+type backfillError struct {
+	Error string `json:"error"`
+}
+
+// backfillRange returns the range of blocks whose logs a subscription should
+// backfill, ok being false if it only wants new logs. Subscriptions starting
+// at a block number are backfilled up to the given head, or their end block
+// if lower.
+func backfillRange(crit FilterCriteria, head uint64) (begin, end int64, ok bool) {
+	if crit.FromBlock == nil || crit.FromBlock.Sign() < 0 {
+		return 0, 0, false
+	}
+	begin, end = crit.FromBlock.Int64(), int64(head)
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Int64() < end {
+		end = crit.ToBlock.Int64()
+	}
+	return begin, end, true
+}
+
+// backfillLogs streams the logs matching a subscription from the given range
+// of blocks, then hands over to the live logs. The live logs received during
+// the backfill are held back and, at the handover, the ones of blocks already
+// backfilled are dropped, as are the removals of blocks which were not. If the
+// backfill fails, the subscription ends with a backfillError notification
+// rather than silently missing logs.
+func (api *FilterAPI) backfillLogs(notifier *rpc.Notifier, rpcSub *rpc.Subscription, crit FilterCriteria, begin, end int64, logsSub *Subscription, live chan []*types.Log) {
+	defer logsSub.Unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		historic  = make(chan []*types.Log)
+		errc      = make(chan error, 1)
+		held      [][]*types.Log
+		delivered = make(map[common.Hash]struct{}) // Blocks whose logs were backfilled
+	)
+	go func() {
+		for from := begin; from <= end; from += backfillChunkSize {
+			to := from + backfillChunkSize - 1
+			if to > end {
+				to = end
+			}
+			logs, err := api.sys.NewRangeFilter(from, to, crit.Addresses, crit.Topics).Logs(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case historic <- logs:
+			case <-ctx.Done():
+				return
+			}
+		}
+		close(historic)
+	}()
+	notify := func(logs []*types.Log) {
+		for _, l := range logs {
+			l := l
+			notifier.Notify(rpcSub.ID, &l)
+		}
+	}
+	for {
+		select {
+		case logs, ok := <-historic:
+			if !ok {
+				notify(handoverLogs(held, delivered))
+				historic, held, delivered = nil, nil, nil
+				continue
+			}
+			for _, l := range logs {
+				delivered[l.BlockHash] = struct{}{}
+			}
+			notify(logs)
+		case logs := <-live:
+			if historic != nil {
+				held = append(held, logs)
+				continue
+			}
+			notify(logs)
+		case err := <-errc:
+			log.Warn("Failed to backfill logs subscription", "id", rpcSub.ID, "err", err)
+			notifier.Notify(rpcSub.ID, &backfillError{Error: err.Error()})
+			return
+		case <-rpcSub.Err(): // client send an unsubscribe request
+			return
+		case <-notifier.Closed(): // connection dropped
+			return
+		}
+	}
+}
+
+// handoverLogs returns the live logs held back during a backfill which were
+// not delivered by it, given the blocks it delivered logs of: new logs of
+// blocks not backfilled and removed logs of blocks which were.
+func handoverLogs(held [][]*types.Log, delivered map[common.Hash]struct{}) []*types.Log {
+	var logs []*types.Log
+	for _, batch := range held {
+		// A batch holds either new or removed logs, the blocks they belong
+		// to only change state once the whole batch is processed.
+		var changed []common.Hash
+		for _, l := range batch {
+			_, seen := delivered[l.BlockHash]
+			if seen == l.Removed {
+				logs = append(logs, l)
+				changed = append(changed, l.BlockHash)
+			}
+		}
+		for _, hash := range changed {
+			if batch[0].Removed {
+				delete(delivered, hash)
+			} else {
+				delivered[hash] = struct{}{}
+			}
+		}
+	}
+	return logs
+}
@@ -0,0 +1,165 @@
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+func TestHandoverLogs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		a = &types.Log{BlockHash: common.Hash{0xa}, BlockNumber: 5}
+		b = &types.Log{BlockHash: common.Hash{0xb}, BlockNumber: 5}
+		c = &types.Log{BlockHash: common.Hash{0xc}, BlockNumber: 6}
+
+		removed = func(l *types.Log) *types.Log {
+			cpy := *l
+			cpy.Removed = true
+			return &cpy
+		}
+	)
+	// Block a was imported during the backfill, which delivered its logs,
+	// then reorged out for b, which was reorged out for a again. Block c
+	// was imported after the backfill, then reorged out.
+	held := [][]*types.Log{{a, a}, {removed(a), removed(a)}, {b}, {removed(b)}, {a}, {c}, {removed(c)}}
+	have := handoverLogs(held, map[common.Hash]struct{}{a.BlockHash: {}})
+	want := []*types.Log{removed(a), removed(a), b, removed(b), a, c, removed(c)}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("handover logs mismatch\nhave: %v\nwant: %v", have, want)
+	}
+	// The removal of a block the backfill never saw is not delivered, nor
+	// are the new logs of a block it did.
+	held = [][]*types.Log{{removed(b)}, {a}}
+	if have := handoverLogs(held, map[common.Hash]struct{}{a.BlockHash: {}}); len(have) != 0 {
+		t.Errorf("have handover logs %v, want none", have)
+	}
+}
+
+// newBackfillTest creates a chain of 4 blocks, each with a log of addr, and a
+// client of the filter API over it.
+func newBackfillTest(t *testing.T, addr common.Address) (*testBackend, []types.Receipts, *rpc.Client) {
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		topic        = common.HexToHash("0x01")
+		gspec        = &core.Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	)
+	_, chain, receipts := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 4, func(i int, gen *core.BlockGen) {
+		receipt := makeReceipt(addr)
+		receipt.Logs[0].Topics = []common.Hash{topic}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(999, common.HexToAddress("0x999"), big.NewInt(999), 999, gen.BaseFee(), nil))
+	})
+	gspec.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	server := rpc.NewServer()
+	t.Cleanup(server.Stop)
+	if err := server.RegisterName("eth", NewFilterAPI(sys, false)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	t.Cleanup(client.Close)
+	return backend, receipts, client
+}
+
+// TestLogsSubscriptionBackfill tests that a subscription from a block number
+// delivers the past logs before the new ones.
+func TestLogsSubscriptionBackfill(t *testing.T) {
+	t.Parallel()
+
+	var (
+		addr                      = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		topic                     = common.HexToHash("0x01")
+		backend, receipts, client = newBackfillTest(t, addr)
+	)
+	logs := make(chan types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{"fromBlock": "0x2", "address": addr})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	expect := func(number uint64, removed bool) {
+		t.Helper()
+		select {
+		case l := <-logs:
+			if l.BlockNumber != number || l.Removed != removed {
+				t.Fatalf("have log of block %d (removed %v), want block %d (removed %v)", l.BlockNumber, l.Removed, number, removed)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for log of block %d", number)
+		}
+	}
+	backend.logsFeed.Send([]*types.Log{{Address: addr, Topics: []common.Hash{topic}, BlockNumber: 5, BlockHash: common.Hash{5}}})
+	expect(2, false)
+	expect(3, false)
+	expect(4, false)
+	expect(5, false)
+
+	removed := *receipts[3][0].Logs[0]
+	removed.Removed = true
+	backend.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: []*types.Log{&removed}})
+	expect(4, true)
+}
+
+// TestLogsSubscriptionBackfillFailure tests that a subscription whose backfill
+// fails is ended with the error instead of going silent.
+func TestLogsSubscriptionBackfillFailure(t *testing.T) {
+	t.Parallel()
+
+	var (
+		addr               = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		backend, _, client = newBackfillTest(t, addr)
+		hash               = rawdb.ReadCanonicalHash(backend.db, 3)
+	)
+	// The history of block 3 was pruned
+	rawdb.DeleteBody(backend.db, hash, 3)
+
+	notifications := make(chan json.RawMessage)
+	sub, err := client.EthSubscribe(context.Background(), notifications, "logs", map[string]interface{}{"fromBlock": "0x2", "address": addr})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case msg := <-notifications:
+		var failure backfillError
+		if err := json.Unmarshal(msg, &failure); err != nil || failure.Error == "" {
+			t.Fatalf("have notification %s, want backfill error", msg)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for backfill error")
+	}
+	// Nothing is delivered past the failure
+	backend.logsFeed.Send([]*types.Log{{Address: addr, BlockNumber: 5, BlockHash: common.Hash{5}}})
+	select {
+	case msg := <-notifications:
+		t.Fatalf("notification after backfill failure: %s", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -192,6 +192,14 @@ func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan erro
 			close(logChan)
 		}()
 
+		// begin PluGeth code injection
+		// Gather the logs of the blocks covered by the log index first
+		if err := f.logIndexLogs(ctx, uint64(f.end), logChan); err != nil {
+			errChan <- err
+			return
+		}
+		// end PluGeth code injection
+
 		// Gather all indexed logs, and finish with non indexed ones
 		var (
 			end            = uint64(f.end)
@@ -0,0 +1,123 @@
+package filters
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/exp/slices"
+)
+
+// logIndexLogs retrieves the logs matching the filter criteria from the blocks
+// covered by the log index, if it is maintained, moving the start of the
+// filter past them. Filters without address or topic criteria are left to
+// the bloombits, all blocks being candidates.
+func (f *Filter) logIndexLogs(ctx context.Context, end uint64, logChan chan *types.Log) error {
+	groups := f.logIndexGroups()
+	if len(groups) == 0 {
+		return nil
+	}
+	db := f.sys.backend.ChainDb()
+	head, hash, ok := rawdb.ReadLogIndexHead(db)
+	if !ok || rawdb.ReadCanonicalHash(db, head) != hash {
+		// Not built yet or lagging behind a reorg
+		return nil
+	}
+	if head < end {
+		end = head
+	}
+	for f.begin <= int64(end) {
+		section := uint64(f.begin) / rawdb.LogIndexSectionSize
+		last := (section+1)*rawdb.LogIndexSectionSize - 1
+		if last > end {
+			last = end
+		}
+		for _, number := range logIndexCandidates(db, groups, section) {
+			if number < uint64(f.begin) || number > last {
+				continue
+			}
+			header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return err
+			}
+			for _, log := range found {
+				select {
+				case logChan <- log:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		f.begin = int64(last) + 1
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logIndexGroups returns the terms of the log index a block must be listed
+// under to hold a matching log: one of each group.
+func (f *Filter) logIndexGroups() [][]string {
+	var groups [][]string
+	if len(f.addresses) > 0 {
+		group := make([]string, len(f.addresses))
+		for i, addr := range f.addresses {
+			group[i] = rawdb.LogIndexAddressTerm(addr)
+		}
+		groups = append(groups, group)
+	}
+	for _, topics := range f.topics {
+		if len(topics) == 0 {
+			continue
+		}
+		group := make([]string, len(topics))
+		for i, topic := range topics {
+			group[i] = rawdb.LogIndexTopicTerm(topic)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// logIndexCandidates returns the ascending numbers of the blocks of a section
+// listed under a term of each group.
+func logIndexCandidates(db ethdb.KeyValueReader, groups [][]string, section uint64) []uint64 {
+	var candidates []uint64
+	for i, group := range groups {
+		var union []uint64
+		for _, term := range group {
+			union = append(union, rawdb.ReadLogIndexSection(db, term, section)...)
+		}
+		slices.Sort(union)
+		union = slices.Compact(union)
+
+		if i == 0 {
+			candidates = union
+			continue
+		}
+		var (
+			merged []uint64
+			j      int
+		)
+		for _, number := range candidates {
+			for j < len(union) && union[j] < number {
+				j++
+			}
+			if j < len(union) && union[j] == number {
+				merged = append(merged, number)
+			}
+		}
+		candidates = merged
+		if len(candidates) == 0 {
+			break
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,134 @@
+package filters
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+func TestLogIndexCandidates(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		addr1  = rawdb.LogIndexAddressTerm(common.HexToAddress("0x1"))
+		addr2  = rawdb.LogIndexAddressTerm(common.HexToAddress("0x2"))
+		topic  = rawdb.LogIndexTopicTerm(common.HexToHash("0x3"))
+		offset = uint64(rawdb.LogIndexSectionSize)
+	)
+	rawdb.WriteLogIndexSection(db, addr1, 1, []uint64{offset + 1, offset + 5, offset + 9})
+	rawdb.WriteLogIndexSection(db, addr2, 1, []uint64{offset + 2, offset + 5})
+	rawdb.WriteLogIndexSection(db, topic, 1, []uint64{offset + 2, offset + 5, offset + 9})
+
+	tests := []struct {
+		groups [][]string
+		want   []uint64
+	}{
+		{[][]string{{addr1}}, []uint64{offset + 1, offset + 5, offset + 9}},
+		{[][]string{{addr1, addr2}}, []uint64{offset + 1, offset + 2, offset + 5, offset + 9}},
+		{[][]string{{addr1}, {topic}}, []uint64{offset + 5, offset + 9}},
+		{[][]string{{addr1, addr2}, {topic}}, []uint64{offset + 2, offset + 5, offset + 9}},
+		{[][]string{{addr2}, {topic}, {addr1}}, []uint64{offset + 5}},
+	}
+	for i, test := range tests {
+		if have := logIndexCandidates(db, test.groups, 1); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: have candidates %v, want %v", i, have, test.want)
+		}
+		if have := logIndexCandidates(db, test.groups, 0); len(have) != 0 {
+			t.Errorf("test %d: have candidates %v in empty section", i, have)
+		}
+	}
+}
+
+// TestLogIndexFilter tests that range filters use the log index over the
+// blocks it covers, and the other paths over the following ones.
+func TestLogIndexFilter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		_, sys = newTestFilterSystem(t, db, Config{})
+		addr1  = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		addr2  = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		gspec  = &core.Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	)
+	_, chain, receipts := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 6, func(i int, gen *core.BlockGen) {
+		var addr common.Address
+		switch i {
+		case 0, 4:
+			addr = addr1
+		case 2:
+			addr = addr2
+		default:
+			return
+		}
+		gen.AddUncheckedReceipt(makeReceipt(addr))
+		gen.AddUncheckedTx(types.NewTransaction(999, common.HexToAddress("0x999"), big.NewInt(999), 999, gen.BaseFee(), nil))
+	})
+	gspec.MustCommit(db, triedb.NewDatabase(db, triedb.HashDefaults))
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// The index covers the first four blocks, listing the second one as a
+	// false positive.
+	rawdb.WriteLogIndexSection(db, rawdb.LogIndexAddressTerm(addr1), 0, []uint64{1, 2})
+	rawdb.WriteLogIndexSection(db, rawdb.LogIndexAddressTerm(addr2), 0, []uint64{3})
+	rawdb.WriteLogIndexHead(db, 4, chain[3].Hash())
+
+	blocks := func(logs []*types.Log) []uint64 {
+		numbers := []uint64{}
+		for _, log := range logs {
+			numbers = append(numbers, log.BlockNumber)
+		}
+		return numbers
+	}
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		want       []uint64
+	}{
+		{0, 6, []common.Address{addr1}, []uint64{1, 5}},
+		{2, 6, []common.Address{addr1}, []uint64{5}},
+		{0, 2, []common.Address{addr1, addr2}, []uint64{1}},
+		{0, -1, []common.Address{addr1, addr2}, []uint64{1, 3, 5}},
+		{0, -1, nil, []uint64{1, 3, 5}},
+	}
+	for i, test := range tests {
+		logs, err := sys.NewRangeFilter(test.begin, test.end, test.addresses, nil).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		if have := blocks(logs); !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: have logs of blocks %v, want %v", i, have, test.want)
+		}
+	}
+	// Blocks covered by the index are only searched if listed in it, unless
+	// it lags behind a reorg.
+	rawdb.WriteLogIndexSection(db, rawdb.LogIndexAddressTerm(addr2), 0, nil)
+	for _, head := range []common.Hash{chain[3].Hash(), {1}} {
+		rawdb.WriteLogIndexHead(db, 4, head)
+		logs, err := sys.NewRangeFilter(0, -1, []common.Address{addr2}, nil).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("failed to filter logs: %v", err)
+		}
+		want := []uint64{}
+		if head != chain[3].Hash() {
+			want = []uint64{3}
+		}
+		if have := blocks(logs); !reflect.DeepEqual(have, want) {
+			t.Errorf("index head %x: have logs of blocks %v, want %v", head, have, want)
+		}
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -92,11 +93,14 @@ type Backend interface {
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
 	backend Backend
+	jobs    *traceJobManager
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Ethereum service.
 func NewAPI(backend Backend) *API {
-	return &API{backend: backend}
+	api := &API{backend: backend}
+	api.jobs = newTraceJobManager(api)
+	return api
 }
 
 // chainContext constructs the context reader which is used by the evm for reading
@@ -147,6 +151,18 @@ func (api *API) blockByNumberAndHash(ctx context.Context, number rpc.BlockNumber
 	return api.blockByHash(ctx, hash)
 }
 
+// blockByNumberOrHash is a wrapper of blockByHash and blockByNumber, resolving
+// whichever of the two the argument holds.
+func (api *API) blockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.blockByHash(ctx, hash)
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		return api.blockByNumber(ctx, number)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
 // TraceConfig holds extra parameters to trace functions.
 type TraceConfig struct {
 	*logger.Config
@@ -950,7 +966,7 @@ func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Conte
 		// Get the tracer from the plugin loader
 		//begin PluGeth code injection
 		if tr, ok := getPluginTracer(*config.Tracer); ok {
-			tracer = tr(statedb, vmctx)
+			tracer = tr(statedb, vmctx, pluginTxContext(txctx, message, txContext))
 		} else {
 			tracer, err = DefaultDirectory.New(*config.Tracer, txctx, config.TracerConfig)
 			if err != nil {
@@ -988,11 +1004,29 @@ func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Conte
 
 // APIs return the collection of RPC services the tracer package offers.
 func APIs(backend Backend) []rpc.API {
+	return apis(NewAPI(backend))
+}
+
+// Register adds the tracer APIs to the node, along with the lifecycle of their
+// tracing jobs, which are resumed when the node starts and stopped before it
+// shuts down.
+func Register(stack *node.Node, backend Backend) {
+	api := NewAPI(backend)
+	stack.RegisterAPIs(apis(api))
+	stack.RegisterLifecycle(api.jobs)
+}
+
+func apis(api *API) []rpc.API {
 	// Append all the local APIs and return
 	return []rpc.API{
 		{
 			Namespace: "debug",
-			Service:   NewAPI(backend),
+			Service:   api,
+		},
+		// PluGeth injection
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(api),
 		},
 	}
 }
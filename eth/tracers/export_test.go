@@ -0,0 +1,14 @@
+package tracers
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// NewTestBackend exposes the test backend to the external tests, which can
+// load the native tracers. The returned function releases the backend.
+func NewTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) (Backend, func()) {
+	backend := newTestBackend(t, n, gspec, generator)
+	return backend, backend.teardown
+}
@@ -0,0 +1,195 @@
+package tracetest
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// vmTrace is the result of a parityVmTracer run.
+type vmTrace struct {
+	Code hexutil.Bytes `json:"code"`
+	Ops  []struct {
+		Cost uint64 `json:"cost"`
+		Ex   *struct {
+			Mem *struct {
+				Off  uint64        `json:"off"`
+				Data hexutil.Bytes `json:"data"`
+			} `json:"mem"`
+			Push  []*hexutil.Big `json:"push"`
+			Store *struct {
+				Key *hexutil.Big `json:"key"`
+				Val *hexutil.Big `json:"val"`
+			} `json:"store"`
+			Used uint64 `json:"used"`
+		} `json:"ex"`
+		Pc  uint64   `json:"pc"`
+		Sub *vmTrace `json:"sub"`
+	} `json:"ops"`
+}
+
+// Stores 0x2a in memory and 7 in slot 1, then calls a callee computing 5 - 3.
+var (
+	vmTraceCode = []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x7, byte(vm.PUSH1), 0x1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x0, byte(vm.PUSH1), 0x0, byte(vm.PUSH1), 0x0, byte(vm.PUSH1), 0x0, // in and outs zero
+		byte(vm.PUSH1), 0x0, byte(vm.PUSH1), 0xff, byte(vm.GAS), // value=0,address=0xff, gas=GAS
+		byte(vm.CALL),
+		byte(vm.STOP),
+	}
+	vmTraceCalleeCode = []byte{byte(vm.PUSH1), 0x3, byte(vm.PUSH1), 0x5, byte(vm.SUB), byte(vm.STOP)}
+)
+
+// runParityVmTracer executes the test code with the vm tracer, stopping the
+// tracer before execution if stop is set.
+func runParityVmTracer(t *testing.T, stop error) (json.RawMessage, error) {
+	var (
+		to     = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		callee = common.HexToAddress("0x00000000000000000000000000000000000000ff")
+		origin = common.HexToAddress("0x00000000000000000000000000000000feed")
+
+		txContext = vm.TxContext{
+			Origin:   origin,
+			GasPrice: big.NewInt(1),
+		}
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			Coinbase:    common.Address{},
+			BlockNumber: new(big.Int).SetUint64(8000000),
+			Time:        5,
+			Difficulty:  big.NewInt(0x30000),
+			GasLimit:    uint64(6000000),
+		}
+	)
+	state := tests.MakePreState(rawdb.NewMemoryDatabase(),
+		types.GenesisAlloc{
+			to:     types.Account{Code: vmTraceCode},
+			callee: types.Account{Code: vmTraceCalleeCode},
+			origin: types.Account{Balance: big.NewInt(500000000000000)},
+		}, false, rawdb.HashScheme)
+	defer state.Close()
+
+	tracer, err := tracers.DefaultDirectory.New("parityVmTracer", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create vm tracer: %v", err)
+	}
+	if stop != nil {
+		tracer.Stop(stop)
+	}
+	evm := vm.NewEVM(context, txContext, state.StateDB, params.MainnetChainConfig, vm.Config{Tracer: tracer})
+	msg := &core.Message{
+		To:        &to,
+		From:      origin,
+		Value:     big.NewInt(0),
+		GasLimit:  80000,
+		GasPrice:  big.NewInt(0),
+		GasFeeCap: big.NewInt(0),
+		GasTipCap: big.NewInt(0),
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(msg.GasLimit))
+	if _, err := st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	return tracer.GetResult()
+}
+
+func TestParityVmTracer(t *testing.T) {
+	code, calleeCode := vmTraceCode, vmTraceCalleeCode
+	res, err := runParityVmTracer(t, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var trace vmTrace
+	if err := json.Unmarshal(res, &trace); err != nil {
+		t.Fatalf("failed to decode trace result: %v", err)
+	}
+	if common.Bytes2Hex(trace.Code) != common.Bytes2Hex(code) {
+		t.Fatalf("code mismatch: have %x, want %x", trace.Code, code)
+	}
+	if len(trace.Ops) != 15 {
+		t.Fatalf("op count mismatch: have %d, want %d", len(trace.Ops), 15)
+	}
+	for i, op := range trace.Ops {
+		if op.Ex == nil {
+			t.Fatalf("op %d: missing effects", i)
+		}
+		if i > 0 && op.Pc <= trace.Ops[i-1].Pc {
+			t.Fatalf("op %d: pc %d does not follow %d", i, op.Pc, trace.Ops[i-1].Pc)
+		}
+		if i > 0 && trace.Ops[i-1].Ex.Used < op.Ex.Used {
+			t.Fatalf("op %d: gas left %d above %d", i, op.Ex.Used, trace.Ops[i-1].Ex.Used)
+		}
+	}
+	// PUSH1 0x2a
+	if push := trace.Ops[0].Ex.Push; len(push) != 1 || push[0].ToInt().Uint64() != 0x2a {
+		t.Errorf("push mismatch: have %v, want [0x2a]", push)
+	}
+	// MSTORE
+	mstore := trace.Ops[2].Ex
+	if len(mstore.Push) != 0 {
+		t.Errorf("mstore pushed %v", mstore.Push)
+	}
+	if mem := mstore.Mem; mem == nil || mem.Off != 0 || common.BytesToHash(mem.Data) != common.BigToHash(big.NewInt(0x2a)) || len(mem.Data) != 32 {
+		t.Errorf("mstore memory mismatch: have %+v", mem)
+	}
+	// SSTORE
+	if store := trace.Ops[5].Ex.Store; store == nil || store.Key.ToInt().Uint64() != 1 || store.Val.ToInt().Uint64() != 7 {
+		t.Errorf("sstore mismatch: have %+v", store)
+	}
+	// CALL
+	call := trace.Ops[13]
+	if call.Pc != 23 {
+		t.Fatalf("call pc mismatch: have %d, want %d", call.Pc, 23)
+	}
+	if push := call.Ex.Push; len(push) != 1 || push[0].ToInt().Uint64() != 1 {
+		t.Errorf("call result mismatch: have %v, want [0x1]", push)
+	}
+	sub := call.Sub
+	if sub == nil {
+		t.Fatal("call has no sub trace")
+	}
+	if common.Bytes2Hex(sub.Code) != common.Bytes2Hex(calleeCode) {
+		t.Errorf("sub trace code mismatch: have %x, want %x", sub.Code, calleeCode)
+	}
+	if len(sub.Ops) != 4 {
+		t.Fatalf("sub trace op count mismatch: have %d, want %d", len(sub.Ops), 4)
+	}
+	if push := sub.Ops[2].Ex.Push; len(push) != 1 || push[0].ToInt().Uint64() != 2 {
+		t.Errorf("sub result mismatch: have %v, want [0x2]", push)
+	}
+	for i, op := range trace.Ops {
+		if i != 13 && op.Sub != nil {
+			t.Errorf("op %d: unexpected sub trace", i)
+		}
+	}
+}
+
+// Calls made while the tracer is stopped, before the EVM is cancelled, must
+// not unbalance its frames.
+func TestParityVmTracerStopped(t *testing.T) {
+	stop := errors.New("execution timeout")
+	res, err := runParityVmTracer(t, stop)
+	if err != stop {
+		t.Fatalf("have error %v, want %v", err, stop)
+	}
+	var trace vmTrace
+	if err := json.Unmarshal(res, &trace); err != nil {
+		t.Fatalf("failed to decode trace result: %v", err)
+	}
+	if len(trace.Ops) != 0 {
+		t.Errorf("stopped tracer traced %d ops", len(trace.Ops))
+	}
+}
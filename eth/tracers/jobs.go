@@ -0,0 +1,382 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// traceJobRetries is the number of times a block is retried before a
+	// tracing job is marked as failed.
+	traceJobRetries = 3
+
+	// traceJobRetryDelay is the delay between two attempts at the same block.
+	traceJobRetryDelay = 5 * time.Second
+)
+
+// traceJobPrefix is the database key prefix of persisted tracing jobs.
+var traceJobPrefix = []byte("plugeth-trace-job-")
+
+// Tracing job states.
+const (
+	TraceJobRunning   = "running"
+	TraceJobPaused    = "paused"
+	TraceJobCancelled = "cancelled"
+	TraceJobFailed    = "failed"
+	TraceJobDone      = "done"
+)
+
+var (
+	errTraceJobNotFound = errors.New("tracing job not found")
+	errTraceJobOutput   = errors.New("exactly one of outputDir and sink must be set")
+)
+
+// TraceJobConfig describes a server-side tracing job over a block range. The
+// embedded TraceConfig selects the tracer, which may be any built-in tracer or
+// one provided by a plugin through the Tracers map.
+type TraceJobConfig struct {
+	TraceConfig
+	From      hexutil.Uint64 `json:"from"`      // First block to trace
+	To        hexutil.Uint64 `json:"to"`        // Last block to trace, inclusive
+	OutputDir string         `json:"outputDir"` // Directory receiving one JSON file per block
+	Sink      string         `json:"sink"`      // Name of a plugin provided TraceSinks entry
+}
+
+// TraceJob is the persisted state of a tracing job. Next is the checkpoint,
+// every block below it has been traced and handed to the output.
+type TraceJob struct {
+	ID      string         `json:"id"`
+	Config  TraceJobConfig `json:"config"`
+	Status  string         `json:"status"`
+	Next    hexutil.Uint64 `json:"next"`
+	Error   string         `json:"error,omitempty"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+}
+
+func traceJobKey(id string) []byte {
+	return append(append([]byte{}, traceJobPrefix...), id...)
+}
+
+// traceJobManager runs tracing jobs in the background and persists their
+// progress, so they survive both dropped connections and node restarts.
+type traceJobManager struct {
+	api *API
+
+	mu      sync.Mutex
+	jobs    map[string]*TraceJob
+	cancels map[string]context.CancelFunc
+	closed  bool // Whether workers can no longer be started
+	wg      sync.WaitGroup
+}
+
+func newTraceJobManager(api *API) *traceJobManager {
+	return &traceJobManager{
+		api:     api,
+		jobs:    make(map[string]*TraceJob),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// db returns the database the jobs are persisted in.
+func (m *traceJobManager) db() ethdb.KeyValueStore {
+	return m.api.backend.ChainDb()
+}
+
+// Start implements node.Lifecycle, picking up the tracing jobs that were
+// running when the node stopped.
+func (m *traceJobManager) Start() error {
+	m.restore()
+	return nil
+}
+
+// Stop implements node.Lifecycle, stopping the workers before the database
+// is closed.
+func (m *traceJobManager) Stop() error {
+	m.close()
+	return nil
+}
+
+// restore loads the persisted jobs and restarts the ones that were running.
+func (m *traceJobManager) restore() {
+	it := m.db().NewIterator(traceJobPrefix, nil)
+	defer it.Release()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = false
+	for it.Next() {
+		job := new(TraceJob)
+		if err := json.Unmarshal(it.Value(), job); err != nil {
+			log.Warn("Failed to decode tracing job", "key", string(it.Key()), "err", err)
+			continue
+		}
+		if _, ok := m.jobs[job.ID]; ok {
+			continue // Started over RPC before the node finished starting
+		}
+		m.jobs[job.ID] = job
+		if job.Status == TraceJobRunning {
+			log.Info("Resuming tracing job", "id", job.ID, "next", uint64(job.Next), "to", uint64(job.Config.To))
+			m.startLocked(job)
+		}
+	}
+}
+
+// persistLocked writes the job to the database, the caller must hold m.mu.
+func (m *traceJobManager) persistLocked(job *TraceJob) {
+	job.Updated = time.Now()
+	blob, err := json.Marshal(job)
+	if err != nil {
+		log.Error("Failed to encode tracing job", "id", job.ID, "err", err)
+		return
+	}
+	if err := m.db().Put(traceJobKey(job.ID), blob); err != nil {
+		log.Error("Failed to persist tracing job", "id", job.ID, "err", err)
+	}
+}
+
+func (m *traceJobManager) create(config TraceJobConfig) (*TraceJob, error) {
+	if (config.OutputDir == "") == (config.Sink == "") {
+		return nil, errTraceJobOutput
+	}
+	if config.From == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if config.From > config.To {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", config.To, config.From)
+	}
+	if config.Sink != "" {
+		if _, ok := getPluginTraceSink(config.Sink); !ok {
+			return nil, fmt.Errorf("unknown trace sink %q", config.Sink)
+		}
+	}
+	if config.OutputDir != "" {
+		if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	job := &TraceJob{
+		ID:      string(rpc.NewID()),
+		Config:  config,
+		Status:  TraceJobRunning,
+		Next:    config.From,
+		Created: time.Now(),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = job
+	m.persistLocked(job)
+	m.startLocked(job)
+	return job.copy(), nil
+}
+
+func (m *traceJobManager) startLocked(job *TraceJob) {
+	if m.closed {
+		return // Persisted as running, resumed by the next restore
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[job.ID] = cancel
+	m.wg.Add(1)
+	go m.run(ctx, job.ID)
+}
+
+// stopLocked cancels the worker of a job and sets its new status.
+func (m *traceJobManager) stopLocked(job *TraceJob, status string) {
+	if cancel, ok := m.cancels[job.ID]; ok {
+		cancel()
+		delete(m.cancels, job.ID)
+	}
+	job.Status = status
+	m.persistLocked(job)
+}
+
+// transition moves a job from one of the states in from to the state to.
+func (m *traceJobManager) transition(id string, to string, from ...string) (*TraceJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, errTraceJobNotFound
+	}
+	allowed := false
+	for _, status := range from {
+		allowed = allowed || job.Status == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("tracing job is %s", job.Status)
+	}
+	if to == TraceJobRunning {
+		job.Status, job.Error = TraceJobRunning, ""
+		m.persistLocked(job)
+		m.startLocked(job)
+	} else {
+		m.stopLocked(job, to)
+	}
+	return job.copy(), nil
+}
+
+func (m *traceJobManager) get(id string) (*TraceJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, errTraceJobNotFound
+	}
+	return job.copy(), nil
+}
+
+func (m *traceJobManager) list() []*TraceJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*TraceJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job.copy())
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+	return jobs
+}
+
+// close stops all workers without changing the persisted state of the jobs,
+// so running jobs resume when the manager is restored.
+func (m *traceJobManager) close() {
+	m.mu.Lock()
+	m.closed = true
+	for id, cancel := range m.cancels {
+		cancel()
+		delete(m.cancels, id)
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// run traces the remaining blocks of a job, checkpointing after each block.
+func (m *traceJobManager) run(ctx context.Context, id string) {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	job := m.jobs[id].copy()
+	m.mu.Unlock()
+
+	for n := uint64(job.Next); n <= uint64(job.Config.To); n++ {
+		var err error
+		for attempt := 0; attempt < traceJobRetries; attempt++ {
+			if err = m.traceBlock(ctx, job, n); err == nil || ctx.Err() != nil {
+				break
+			}
+			log.Warn("Tracing job failed to trace block", "id", id, "block", n, "attempt", attempt+1, "err", err)
+			select {
+			case <-time.After(traceJobRetryDelay):
+			case <-ctx.Done():
+			}
+		}
+		m.mu.Lock()
+		if ctx.Err() != nil {
+			// Paused, cancelled or shutting down, the state was updated by the caller.
+			m.mu.Unlock()
+			return
+		}
+		job := m.jobs[id]
+		if err != nil {
+			job.Error = err.Error()
+			m.stopLocked(job, TraceJobFailed)
+			m.mu.Unlock()
+			return
+		}
+		job.Next = hexutil.Uint64(n + 1)
+		if n == uint64(job.Config.To) {
+			m.stopLocked(job, TraceJobDone)
+			log.Info("Tracing job finished", "id", id, "from", uint64(job.Config.From), "to", n)
+		} else {
+			m.persistLocked(job)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// traceBlock traces a single block of a job and hands the result to the
+// configured output.
+func (m *traceJobManager) traceBlock(ctx context.Context, job *TraceJob, number uint64) error {
+	block, err := m.api.blockByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return err
+	}
+	traces, err := m.api.traceBlock(ctx, block, &job.Config.TraceConfig)
+	if err != nil {
+		return err
+	}
+	result, err := json.Marshal(&blockTraceResult{
+		Block:  hexutil.Uint64(number),
+		Hash:   block.Hash(),
+		Traces: traces,
+	})
+	if err != nil {
+		return err
+	}
+	if job.Config.Sink != "" {
+		sink, ok := getPluginTraceSink(job.Config.Sink)
+		if !ok {
+			return fmt.Errorf("unknown trace sink %q", job.Config.Sink)
+		}
+		return sink(job.ID, number, block.Hash(), result)
+	}
+	// Write to a temporary file first, so a crash never leaves a partial
+	// result behind the checkpoint.
+	path := filepath.Join(job.Config.OutputDir, fmt.Sprintf("%d.json", number))
+	if err := os.WriteFile(path+".tmp", result, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (job *TraceJob) copy() *TraceJob {
+	cpy := *job
+	return &cpy
+}
+
+// StartTraceJob starts a server-side tracing job over the given block range,
+// returning the job id. Unlike TraceChain the job keeps running when the
+// client disconnects, and resumes from its last checkpoint after a restart.
+func (api *API) StartTraceJob(ctx context.Context, config TraceJobConfig) (string, error) {
+	job, err := api.jobs.create(config)
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// TraceJob returns the state of a tracing job.
+func (api *API) TraceJob(ctx context.Context, id string) (*TraceJob, error) {
+	return api.jobs.get(id)
+}
+
+// TraceJobs returns the state of all known tracing jobs.
+func (api *API) TraceJobs(ctx context.Context) []*TraceJob {
+	return api.jobs.list()
+}
+
+// PauseTraceJob stops a running tracing job at its last checkpoint.
+func (api *API) PauseTraceJob(ctx context.Context, id string) (*TraceJob, error) {
+	return api.jobs.transition(id, TraceJobPaused, TraceJobRunning)
+}
+
+// ResumeTraceJob restarts a paused or failed tracing job from its last checkpoint.
+func (api *API) ResumeTraceJob(ctx context.Context, id string) (*TraceJob, error) {
+	return api.jobs.transition(id, TraceJobRunning, TraceJobPaused, TraceJobFailed)
+}
+
+// CancelTraceJob stops a tracing job for good. Its results so far are kept.
+func (api *API) CancelTraceJob(ctx context.Context, id string) (*TraceJob, error) {
+	return api.jobs.transition(id, TraceJobCancelled, TraceJobRunning, TraceJobPaused, TraceJobFailed)
+}
@@ -0,0 +1,179 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newTraceJobBackend(t *testing.T, blocks int) *testBackend {
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	return newTestBackend(t, blocks, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+}
+
+func waitTraceJob(t *testing.T, api *API, id, status string) *TraceJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := api.TraceJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("tracing job did not reach status %s", status)
+	return nil
+}
+
+func checkTraceJobOutput(t *testing.T, dir string, from, to uint64) {
+	t.Helper()
+	for n := from; n <= to; n++ {
+		blob, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.json", n)))
+		if err != nil {
+			t.Fatalf("block %d: %v", n, err)
+		}
+		var result blockTraceResult
+		if err := json.Unmarshal(blob, &result); err != nil {
+			t.Fatalf("block %d: %v", n, err)
+		}
+		if uint64(result.Block) != n || len(result.Traces) != 1 {
+			t.Fatalf("block %d: unexpected result %s", n, blob)
+		}
+	}
+}
+
+func TestTraceJob(t *testing.T) {
+	backend := newTraceJobBackend(t, 10)
+	defer backend.teardown()
+	api := NewAPI(backend)
+	defer api.jobs.close()
+
+	dir := t.TempDir()
+	id, err := api.StartTraceJob(context.Background(), TraceJobConfig{From: 1, To: 10, OutputDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := waitTraceJob(t, api, id, TraceJobDone)
+	if job.Next != 11 {
+		t.Fatalf("unexpected checkpoint %d", job.Next)
+	}
+	checkTraceJobOutput(t, dir, 1, 10)
+
+	if _, err := api.PauseTraceJob(context.Background(), id); err == nil {
+		t.Fatal("paused a finished job")
+	}
+	if _, err := api.StartTraceJob(context.Background(), TraceJobConfig{From: 1, To: 2}); err != errTraceJobOutput {
+		t.Fatalf("have %v, want %v", err, errTraceJobOutput)
+	}
+	if _, err := api.StartTraceJob(context.Background(), TraceJobConfig{From: 5, To: 2, OutputDir: dir}); err == nil {
+		t.Fatal("accepted an inverted block range")
+	}
+}
+
+func TestTraceJobRestore(t *testing.T) {
+	backend := newTraceJobBackend(t, 10)
+	defer backend.teardown()
+
+	// Persist a job that was interrupted after block 5, as if the node
+	// stopped in the middle of it.
+	dir := t.TempDir()
+	api := NewAPI(backend)
+	job := &TraceJob{
+		ID:      "restored",
+		Config:  TraceJobConfig{From: 1, To: 10, OutputDir: dir},
+		Status:  TraceJobRunning,
+		Next:    hexutil.Uint64(6),
+		Created: time.Now(),
+	}
+	api.jobs.persistLocked(job)
+
+	api = NewAPI(backend)
+	api.jobs.restore()
+	defer api.jobs.close()
+
+	waitTraceJob(t, api, job.ID, TraceJobDone)
+	checkTraceJobOutput(t, dir, 6, 10)
+	if _, err := os.Stat(filepath.Join(dir, "5.json")); !os.IsNotExist(err) {
+		t.Fatalf("block before the checkpoint was traced again: %v", err)
+	}
+
+	// Cancelled jobs are restored for inspection but not restarted.
+	if _, err := api.CancelTraceJob(context.Background(), job.ID); err == nil {
+		t.Fatal("cancelled a finished job")
+	}
+	jobs := api.TraceJobs(context.Background())
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Fatalf("unexpected job list %v", jobs)
+	}
+}
+
+func TestTraceJobLifecycle(t *testing.T) {
+	backend := newTraceJobBackend(t, 10)
+	defer backend.teardown()
+
+	dir := t.TempDir()
+	job := &TraceJob{
+		ID:      "lifecycle",
+		Config:  TraceJobConfig{From: 1, To: 10, OutputDir: dir},
+		Status:  TraceJobRunning,
+		Next:    hexutil.Uint64(1),
+		Created: time.Now(),
+	}
+	NewAPI(backend).jobs.persistLocked(job)
+
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register(stack, backend)
+
+	// Building the APIs must not resume the jobs, starting the node does.
+	APIs(backend)
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(dir, "1.json")); !os.IsNotExist(err) {
+		t.Fatalf("job resumed before the node started: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		blob, _ := backend.ChainDb().Get(traceJobKey(job.ID))
+		var stored TraceJob
+		if json.Unmarshal(blob, &stored) == nil && stored.Status == TraceJobDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tracing job did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkTraceJobOutput(t, dir, 1, 10)
+	if err := stack.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
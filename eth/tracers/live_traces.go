@@ -0,0 +1,91 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxLiveTraceRange is the maximum number of blocks LiveTraceBlockRange
+// returns in one call.
+const maxLiveTraceRange = 1024
+
+// LiveTraceResult is the stored live tracer result of a block.
+type LiveTraceResult struct {
+	Block  hexutil.Uint64  `json:"block"`
+	Hash   common.Hash     `json:"hash"`
+	Result json.RawMessage `json:"result"`
+}
+
+// canonicalNumber returns the number of the block with the given hash, and
+// whether it is part of the canonical chain. Stored live traces of blocks that
+// were reorged out are never served.
+func (api *API) canonicalNumber(hash common.Hash) (uint64, bool) {
+	db := api.backend.ChainDb()
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		return 0, false
+	}
+	return *number, rawdb.ReadCanonicalHash(db, *number) == hash
+}
+
+// LiveTraceBlockByHash returns the result a live tracer stored for the block
+// with the given hash, under the given namespace.
+func (api *API) LiveTraceBlockByHash(ctx context.Context, namespace string, hash common.Hash) (json.RawMessage, error) {
+	if _, ok := api.canonicalNumber(hash); !ok {
+		return nil, fmt.Errorf("block %#x not found in the canonical chain", hash)
+	}
+	result := rawdb.ReadLiveTraceBlock(api.backend.ChainDb(), namespace, hash)
+	if result == nil {
+		return nil, fmt.Errorf("no %s trace stored for block %#x", namespace, hash)
+	}
+	return result, nil
+}
+
+// LiveTraceBlockRange returns the results a live tracer stored for the
+// canonical blocks in the given range, under the given namespace. Blocks
+// without a stored result are omitted.
+func (api *API) LiveTraceBlockRange(ctx context.Context, namespace string, start, end rpc.BlockNumber) ([]*LiveTraceResult, error) {
+	from, err := api.backend.HeaderByNumber(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.backend.HeaderByNumber(ctx, end)
+	if err != nil {
+		return nil, err
+	}
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("block range %d-%d not found", start, end)
+	}
+	if from.Number.Cmp(to.Number) > 0 {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", to.Number, from.Number)
+	}
+	if to.Number.Uint64()-from.Number.Uint64() >= maxLiveTraceRange {
+		return nil, fmt.Errorf("block range exceeds the limit of %d blocks", maxLiveTraceRange)
+	}
+	db := api.backend.ChainDb()
+	results := []*LiveTraceResult{}
+	for n := from.Number.Uint64(); n <= to.Number.Uint64(); n++ {
+		hash := rawdb.ReadCanonicalHash(db, n)
+		if result := rawdb.ReadLiveTraceBlock(db, namespace, hash); result != nil {
+			results = append(results, &LiveTraceResult{Block: hexutil.Uint64(n), Hash: hash, Result: result})
+		}
+	}
+	return results, nil
+}
+
+// LiveTraceTransaction returns the result a live tracer stored for the
+// transaction with the given hash, under the given namespace.
+func (api *API) LiveTraceTransaction(ctx context.Context, namespace string, hash common.Hash) (json.RawMessage, error) {
+	for block, result := range rawdb.ReadLiveTraceTransactions(api.backend.ChainDb(), namespace, hash) {
+		if _, ok := api.canonicalNumber(block); ok {
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s trace stored for transaction %#x", namespace, hash)
+}
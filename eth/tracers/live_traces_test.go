@@ -0,0 +1,155 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	gcore "github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/openrelayxyz/plugeth-utils/core"
+)
+
+// storingTracer is a live tracer counting the transactions of each block.
+type storingTracer struct {
+	txs int
+}
+
+func (t *storingTracer) CaptureStart(from core.Address, to core.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+func (t *storingTracer) CaptureState(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *storingTracer) CaptureFault(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, depth int, err error) {
+}
+func (t *storingTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+func (t *storingTracer) CaptureEnter(typ core.OpCode, from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (t *storingTracer) CaptureExit(output []byte, gasUsed uint64, err error)          {}
+func (t *storingTracer) Result() (interface{}, error)                                  { return nil, nil }
+func (t *storingTracer) PreProcessBlock(hash core.Hash, number uint64, encoded []byte) { t.txs = 0 }
+func (t *storingTracer) PreProcessTransaction(tx core.Hash, block core.Hash, i int)    {}
+func (t *storingTracer) BlockProcessingError(tx core.Hash, block core.Hash, err error) {}
+func (t *storingTracer) PostProcessTransaction(tx core.Hash, block core.Hash, i int, receipt []byte) {
+	t.txs++
+}
+func (t *storingTracer) PostProcessBlock(block core.Hash) {}
+
+func (t *storingTracer) TraceNamespace() string { return "counter" }
+func (t *storingTracer) StoredTransactionResult(tx core.Hash, i int) ([]byte, error) {
+	return []byte(fmt.Sprintf("%d", i)), nil
+}
+func (t *storingTracer) StoredBlockResult(block core.Hash) ([]byte, error) {
+	return json.Marshal(t.txs)
+}
+
+func TestLiveTraceStore(t *testing.T) {
+	done := plugins.HookTester("GetLiveTracer", func(core.Hash, core.StateDB) core.BlockTracer { return new(storingTracer) })
+	backend := newTraceJobBackend(t, 5)
+	done()
+	defer backend.teardown()
+	api := NewAPI(backend)
+
+	block := backend.chain.GetBlockByNumber(3)
+	result, err := api.LiveTraceBlockByHash(context.Background(), "counter", block.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "1" {
+		t.Errorf("have block result %s, want 1", result)
+	}
+	if _, err := api.LiveTraceBlockByHash(context.Background(), "other", block.Hash()); err == nil {
+		t.Error("served a result from an unknown namespace")
+	}
+	results, err := api.LiveTraceBlockRange(context.Background(), "counter", 2, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || results[0].Block != 2 || results[3].Hash != backend.chain.CurrentBlock().Hash() {
+		t.Errorf("unexpected range result %v", results)
+	}
+	tx := block.Transactions()[0]
+	if result, err := api.LiveTraceTransaction(context.Background(), "counter", tx.Hash()); err != nil || string(result) != "0" {
+		t.Errorf("have transaction result %s (%v), want 0", result, err)
+	}
+
+	// Results of blocks which are no longer canonical are not served.
+	rawdb.WriteCanonicalHash(backend.chaindb, common.Hash{}, 3)
+	if _, err := api.LiveTraceBlockByHash(context.Background(), "counter", block.Hash()); err == nil {
+		t.Error("served the result of a reorged block")
+	}
+	if _, err := api.LiveTraceTransaction(context.Background(), "counter", tx.Hash()); err == nil {
+		t.Error("served the result of a reorged transaction")
+	}
+}
+
+func TestLiveTraceStorePruning(t *testing.T) {
+	done := plugins.HookTester("GetLiveTracer", func(core.Hash, core.StateDB) core.BlockTracer { return new(storingTracer) })
+	defer done()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		engine  = ethash.NewFaker()
+		signer  = types.HomesteadSigner{}
+		genesis = &gcore.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		transfer = func(i int, b *gcore.BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), common.Address{1}, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		}
+	)
+	gendb, blocks, _ := gcore.GenerateChainWithGenesis(genesis, engine, 8, transfer)
+	db := rawdb.NewMemoryDatabase()
+	chain, err := gcore.NewBlockChain(db, &gcore.CacheConfig{TrieDirtyDisabled: true, LiveTraceRetention: 3}, genesis, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	stored := func(block *types.Block) bool {
+		have := rawdb.ReadLiveTraceBlock(db, "counter", block.Hash()) != nil
+		if _, ok := rawdb.ReadLiveTraceTransactions(db, "counter", block.Transactions()[0].Hash())[block.Hash()]; ok != have {
+			t.Fatalf("block %d: block and transaction results out of sync", block.NumberU64())
+		}
+		return have
+	}
+	// Only the results of the last 3 blocks are retained.
+	for _, block := range blocks {
+		if have, want := stored(block), block.NumberU64() > 5; have != want {
+			t.Errorf("block %d: have stored result %t, want %t", block.NumberU64(), have, want)
+		}
+	}
+	// The results of reorged blocks are deleted.
+	fork, _ := gcore.GenerateChain(genesis.Config, blocks[5], engine, gendb, 3, func(i int, b *gcore.BlockGen) {
+		b.SetCoinbase(common.Address{2})
+		transfer(i, b)
+	})
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks[6:] {
+		if stored(block) {
+			t.Errorf("block %d: result of reorged block not deleted", block.NumberU64())
+		}
+	}
+	for _, block := range fork {
+		if !stored(block) {
+			t.Errorf("block %d: missing result of new canonical block", block.NumberU64())
+		}
+	}
+}
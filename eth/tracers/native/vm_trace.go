@@ -0,0 +1,243 @@
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/holiman/uint256"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("parityVmTracer", newParityVmTracer, false)
+}
+
+// vmTrace is the Parity style trace of the execution of a piece of code.
+type vmTrace struct {
+	Code hexutil.Bytes `json:"code"`
+	Ops  []*vmTraceOp  `json:"ops"`
+}
+
+// vmTraceOp is a step of a vmTrace, with its effects and the trace of the
+// code it called into.
+type vmTraceOp struct {
+	Cost uint64     `json:"cost"`
+	Ex   *vmTraceEx `json:"ex"`
+	Pc   uint64     `json:"pc"`
+	Sub  *vmTrace   `json:"sub"`
+}
+
+type vmTraceEx struct {
+	Mem   *vmTraceMem    `json:"mem"`
+	Push  []*hexutil.Big `json:"push"`
+	Store *vmTraceStore  `json:"store"`
+	Used  uint64         `json:"used"`
+}
+
+type vmTraceMem struct {
+	Off  uint64        `json:"off"`
+	Data hexutil.Bytes `json:"data"`
+}
+
+type vmTraceStore struct {
+	Key *hexutil.Big `json:"key"`
+	Val *hexutil.Big `json:"val"`
+}
+
+// vmTraceFrame is the execution state of a call frame being traced. The
+// effects of an op are only known once the next op of the frame starts, so
+// they are recorded then.
+type vmTraceFrame struct {
+	trace   *vmTrace
+	pending *vmTraceOp
+	gas     uint64 // Gas available before the pending op
+	pushes  int    // Number of stack items the pending op pushes
+	memOff  int64  // Memory written by the pending op, if memLen > 0
+	memLen  int64
+	store   *vmTraceStore
+}
+
+// parityVmTracer produces the vmTrace of Parity's trace_replay* and
+// trace_call methods.
+type parityVmTracer struct {
+	noopTracer
+	env       *vm.EVM
+	root      *vmTrace
+	frames    []*vmTraceFrame
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+func newParityVmTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	return &parityVmTracer{}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *parityVmTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.root = t.enter(to, create, input)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *parityVmTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.exit()
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+// The frame is entered even once interrupted, to keep it paired with its exit.
+func (t *parityVmTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if len(t.frames) == 0 {
+		return
+	}
+	create := typ == vm.CREATE || typ == vm.CREATE2
+	sub := t.enter(to, create, input)
+
+	// The sub trace belongs to the op of the parent frame making the call
+	if parent := t.frames[len(t.frames)-2]; parent.pending != nil && typ != vm.SELFDESTRUCT {
+		parent.pending.Sub = sub
+	}
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *parityVmTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	t.exit()
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *parityVmTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil || t.interrupt.Load() || len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	frame.finish(gas, scope)
+
+	frame.pending = &vmTraceOp{Cost: cost, Pc: pc}
+	frame.gas, frame.pushes, frame.memLen, frame.store = gas, pushedItems(op), 0, nil
+	frame.trace.Ops = append(frame.trace.Ops, frame.pending)
+
+	var (
+		stack = scope.Stack.Data()
+		back  = func(n int) *uint256.Int { return &stack[len(stack)-1-n] }
+	)
+	setMem := func(off, size int) {
+		if len(stack) > off && len(stack) > size {
+			frame.memOff, frame.memLen = int64(back(off).Uint64()), int64(back(size).Uint64())
+		}
+	}
+	switch op {
+	case vm.MSTORE:
+		if len(stack) > 0 {
+			frame.memOff, frame.memLen = int64(back(0).Uint64()), 32
+		}
+	case vm.MSTORE8:
+		if len(stack) > 0 {
+			frame.memOff, frame.memLen = int64(back(0).Uint64()), 1
+		}
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY, vm.MCOPY:
+		setMem(0, 2)
+	case vm.EXTCODECOPY:
+		setMem(1, 3)
+	case vm.CALL, vm.CALLCODE:
+		setMem(5, 6)
+	case vm.DELEGATECALL, vm.STATICCALL:
+		setMem(4, 5)
+	case vm.SSTORE:
+		if len(stack) > 1 {
+			frame.store = &vmTraceStore{Key: (*hexutil.Big)(back(0).ToBig()), Val: (*hexutil.Big)(back(1).ToBig())}
+		}
+	}
+}
+
+// enter starts tracing a new call frame.
+func (t *parityVmTracer) enter(to common.Address, create bool, input []byte) *vmTrace {
+	code := input
+	if !create {
+		code = t.env.StateDB.GetCode(to)
+	}
+	trace := &vmTrace{Code: common.CopyBytes(code), Ops: []*vmTraceOp{}}
+	t.frames = append(t.frames, &vmTraceFrame{trace: trace})
+	return trace
+}
+
+// exit finishes tracing the current call frame. The effects of its last op
+// are not known past the gas it cost.
+func (t *parityVmTracer) exit() {
+	if len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	if frame.pending != nil {
+		var used uint64
+		if frame.gas > frame.pending.Cost {
+			used = frame.gas - frame.pending.Cost
+		}
+		frame.pending.Ex = &vmTraceEx{Push: []*hexutil.Big{}, Store: frame.store, Used: used}
+		frame.pending = nil
+	}
+	t.frames = t.frames[:len(t.frames)-1]
+}
+
+// finish records the effects of the pending op of the frame, gas being the
+// gas available once it was executed.
+func (f *vmTraceFrame) finish(gas uint64, scope *vm.ScopeContext) {
+	if f.pending == nil {
+		return
+	}
+	ex := &vmTraceEx{Push: []*hexutil.Big{}, Store: f.store, Used: gas}
+	stack := scope.Stack.Data()
+	start := len(stack) - f.pushes
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(stack); i++ {
+		ex.Push = append(ex.Push, (*hexutil.Big)(stack[i].ToBig()))
+	}
+	if f.memLen > 0 {
+		if data, err := tracers.GetMemoryCopyPadded(scope.Memory, f.memOff, f.memLen); err == nil {
+			ex.Mem = &vmTraceMem{Off: uint64(f.memOff), Data: data}
+		}
+	}
+	f.pending.Ex = ex
+	f.pending = nil
+}
+
+// pushedItems returns the number of stack items reported as pushed by an op,
+// for DUP and SWAP the whole range of items they touch.
+func pushedItems(op vm.OpCode) int {
+	switch {
+	case op >= vm.PUSH0 && op <= vm.PUSH32:
+		return 1
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		return int(op-vm.DUP1) + 2
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	}
+	switch op {
+	case vm.STOP, vm.POP, vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.TSTORE, vm.JUMP, vm.JUMPI, vm.JUMPDEST,
+		vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY, vm.MCOPY,
+		vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4, vm.RETURN, vm.REVERT, vm.SELFDESTRUCT, vm.INVALID:
+		return 0
+	}
+	return 1
+}
+
+// GetResult returns the json-encoded vmTrace, and any error arising from the
+// encoding or forceful termination (via `Stop`).
+func (t *parityVmTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.root)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *parityVmTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
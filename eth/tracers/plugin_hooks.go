@@ -4,6 +4,9 @@ import (
 	"reflect"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gcore "github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/log"
@@ -13,7 +16,7 @@ import (
 	"github.com/openrelayxyz/plugeth-utils/core"
 )
 
-func GetPluginTracer(pl *plugins.PluginLoader, name string) (func(*state.StateDB, vm.BlockContext) interfaces.TracerResult, bool) {
+func GetPluginTracer(pl *plugins.PluginLoader, name string) (func(*state.StateDB, vm.BlockContext, *wrappers.TracerTxContext) interfaces.TracerResult, bool) {
 	tracers := pl.Lookup("Tracers", func(item interface{}) bool {
 		_, ok := item.(*map[string]func(core.StateDB) core.TracerResult)
 		_, ok2 := item.(*map[string]func(core.StateDB, core.BlockContext) core.TracerResult)
@@ -26,15 +29,15 @@ func GetPluginTracer(pl *plugins.PluginLoader, name string) (func(*state.StateDB
 	for _, tmap := range tracers {
 		if tracerMap, ok := tmap.(*map[string]func(core.StateDB) core.TracerResult); ok {
 			if tracer, ok := (*tracerMap)[name]; ok {
-				return func(sdb *state.StateDB, vmctx vm.BlockContext) interfaces.TracerResult {
-					return wrappers.NewWrappedTracer(tracer(wrappers.NewWrappedStateDB(sdb)))
+				return func(sdb *state.StateDB, vmctx vm.BlockContext, txctx *wrappers.TracerTxContext) interfaces.TracerResult {
+					return wrappers.NewWrappedTracerWithContext(tracer(wrappers.NewWrappedStateDB(sdb)), vmctx, txctx)
 				}, true
 			}
 		}
 		if tracerMap, ok := tmap.(*map[string]func(core.StateDB, core.BlockContext) core.TracerResult); ok {
 			if tracer, ok := (*tracerMap)[name]; ok {
-				return func(sdb *state.StateDB, vmctx vm.BlockContext) interfaces.TracerResult {
-					return wrappers.NewWrappedTracer(tracer(wrappers.NewWrappedStateDB(sdb), core.BlockContext{
+				return func(sdb *state.StateDB, vmctx vm.BlockContext, txctx *wrappers.TracerTxContext) interfaces.TracerResult {
+					return wrappers.NewWrappedTracerWithContext(tracer(wrappers.NewWrappedStateDB(sdb), core.BlockContext{
 						Coinbase:    core.Address(vmctx.Coinbase),
 						GasLimit:    vmctx.GasLimit,
 						BlockNumber: vmctx.BlockNumber,
@@ -42,7 +45,7 @@ func GetPluginTracer(pl *plugins.PluginLoader, name string) (func(*state.StateDB
 						Time:        new(big.Int).SetInt64(int64(vmctx.Time)),
 						Difficulty:  vmctx.Difficulty,
 						BaseFee:     vmctx.BaseFee,
-					}))
+					}), vmctx, txctx)
 				}, true
 			}
 		}
@@ -51,10 +54,64 @@ func GetPluginTracer(pl *plugins.PluginLoader, name string) (func(*state.StateDB
 	return nil, false
 }
 
-func getPluginTracer(name string) (func(*state.StateDB, vm.BlockContext) interfaces.TracerResult, bool) {
+// pluginTxContext collects the context handed to plugin tracers for a single
+// transaction or call.
+func pluginTxContext(txctx *Context, msg *gcore.Message, txContext vm.TxContext) *wrappers.TracerTxContext {
+	return &wrappers.TracerTxContext{
+		BlockHash:     txctx.BlockHash,
+		BlockNumber:   (*hexutil.Big)(txctx.BlockNumber),
+		TxHash:        txctx.TxHash,
+		TxIndex:       hexutil.Uint(txctx.TxIndex),
+		From:          msg.From,
+		To:            msg.To,
+		Nonce:         hexutil.Uint64(msg.Nonce),
+		Value:         (*hexutil.Big)(msg.Value),
+		Gas:           hexutil.Uint64(msg.GasLimit),
+		GasPrice:      (*hexutil.Big)(txContext.GasPrice),
+		GasFeeCap:     (*hexutil.Big)(msg.GasFeeCap),
+		GasTipCap:     (*hexutil.Big)(msg.GasTipCap),
+		BlobGasFeeCap: (*hexutil.Big)(msg.BlobGasFeeCap),
+		BlobHashes:    msg.BlobHashes,
+	}
+}
+
+func getPluginTracer(name string) (func(*state.StateDB, vm.BlockContext, *wrappers.TracerTxContext) interfaces.TracerResult, bool) {
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting GetPluginTracer, but default PluginLoader has not been initialized")
 		return nil, false
 	}
 	return GetPluginTracer(plugins.DefaultPluginLoader, name)
 }
+
+func GetPluginTraceSink(pl *plugins.PluginLoader, name string) (func(string, uint64, core.Hash, []byte) error, bool) {
+	sinks := pl.Lookup("TraceSinks", func(item interface{}) bool {
+		_, ok := item.(*map[string]func(string, uint64, core.Hash, []byte) error)
+		if !ok {
+			log.Warn("Found trace sink that did not match type", "sink", reflect.TypeOf(item))
+		}
+		return ok
+	})
+	for _, smap := range sinks {
+		if sinkMap, ok := smap.(*map[string]func(string, uint64, core.Hash, []byte) error); ok {
+			if sink, ok := (*sinkMap)[name]; ok {
+				return sink, true
+			}
+		}
+	}
+	log.Info("Trace sink not found", "name", name, "sinks", len(sinks))
+	return nil, false
+}
+
+func getPluginTraceSink(name string) (func(string, uint64, common.Hash, []byte) error, bool) {
+	if plugins.DefaultPluginLoader == nil {
+		log.Warn("Attempting GetPluginTraceSink, but default PluginLoader has not been initialized")
+		return nil, false
+	}
+	sink, ok := GetPluginTraceSink(plugins.DefaultPluginLoader, name)
+	if !ok {
+		return nil, false
+	}
+	return func(job string, number uint64, hash common.Hash, result []byte) error {
+		return sink(job, number, core.Hash(hash), result)
+	}, true
+}
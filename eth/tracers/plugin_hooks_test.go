@@ -0,0 +1,108 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gcore "github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/plugins"
+	"github.com/ethereum/go-ethereum/plugins/wrappers"
+	"github.com/openrelayxyz/plugeth-utils/core"
+)
+
+type contextTracer struct {
+	block    wrappers.TracerBlockContext
+	tx       wrappers.TracerTxContext
+	gasLimit uint64
+	endHash  core.Hash
+	gasUsed  uint64
+}
+
+func (t *contextTracer) CaptureStart(from core.Address, to core.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+func (t *contextTracer) CaptureState(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, rData []byte, depth int, err error) {
+}
+func (t *contextTracer) CaptureFault(pc uint64, op core.OpCode, gas, cost uint64, scope core.ScopeContext, depth int, err error) {
+}
+func (t *contextTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {}
+func (t *contextTracer) CaptureEnter(typ core.OpCode, from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (t *contextTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (t *contextTracer) Result() (interface{}, error)                         { return t.tx.TxHash, nil }
+
+func (t *contextTracer) CaptureBlockContext(blockContext []byte) {
+	json.Unmarshal(blockContext, &t.block)
+}
+func (t *contextTracer) CaptureTxStart(gasLimit uint64) { t.gasLimit = gasLimit }
+func (t *contextTracer) CaptureTxEnd(restGas uint64)    {}
+func (t *contextTracer) CaptureTxContextStart(txContext []byte) {
+	json.Unmarshal(txContext, &t.tx)
+}
+func (t *contextTracer) CaptureTxContextEnd(txHash core.Hash, gasUsed uint64) {
+	t.endHash, t.gasUsed = txHash, gasUsed
+}
+
+func TestPluginTracerContext(t *testing.T) {
+	accounts := newAccounts(2)
+	genesis := &gcore.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	var target *types.Transaction
+	backend := newTestBackend(t, 1, genesis, func(i int, b *gcore.BlockGen) {
+		tx, _ := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+			ChainID:   params.TestChainConfig.ChainID,
+			Nonce:     uint64(i),
+			To:        &accounts[1].addr,
+			Value:     big.NewInt(1000),
+			Gas:       params.TxGas,
+			GasFeeCap: b.BaseFee(),
+		}), types.LatestSigner(params.TestChainConfig), accounts[0].key)
+		b.AddTx(tx)
+		target = tx
+	})
+	defer backend.teardown()
+
+	tracer := new(contextTracer)
+	tracerMap := map[string]func(core.StateDB, core.BlockContext) core.TracerResult{
+		"contextTracer": func(core.StateDB, core.BlockContext) core.TracerResult { return tracer },
+	}
+	done := plugins.HookTester("Tracers", &tracerMap)
+	defer done()
+
+	name := "contextTracer"
+	api := NewAPI(backend)
+	if _, err := api.TraceTransaction(context.Background(), target.Hash(), &TraceConfig{Tracer: &name}); err != nil {
+		t.Fatal(err)
+	}
+	block := backend.chain.GetBlockByNumber(1)
+	if tracer.block.BlockNumber.ToInt().Uint64() != 1 || tracer.block.Coinbase != block.Coinbase() {
+		t.Errorf("unexpected block context %+v", tracer.block)
+	}
+	if tracer.block.BaseFee == nil || tracer.block.BaseFee.ToInt().Cmp(block.BaseFee()) != 0 {
+		t.Errorf("unexpected base fee %v, want %v", tracer.block.BaseFee, block.BaseFee())
+	}
+	if tracer.tx.TxHash != target.Hash() || tracer.tx.BlockHash != block.Hash() || tracer.tx.TxIndex != 0 {
+		t.Errorf("unexpected transaction context %+v", tracer.tx)
+	}
+	if tracer.tx.From != accounts[0].addr || *tracer.tx.To != accounts[1].addr {
+		t.Errorf("unexpected sender or recipient %+v", tracer.tx)
+	}
+	if tracer.tx.GasFeeCap == nil || tracer.tx.GasPrice == nil {
+		t.Errorf("missing fee fields %+v", tracer.tx)
+	}
+	if tracer.gasLimit != params.TxGas {
+		t.Errorf("have gas limit %d, want %d", tracer.gasLimit, params.TxGas)
+	}
+	if common.Hash(tracer.endHash) != target.Hash() || tracer.gasUsed != params.TxGas {
+		t.Errorf("unexpected transaction end %x %d", tracer.endHash, tracer.gasUsed)
+	}
+}
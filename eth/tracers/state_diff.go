@@ -0,0 +1,382 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+)
+
+// beaconRootsBufferLength is the size of the ring buffers of the EIP-4788
+// beacon roots contract.
+const beaconRootsBufferLength = 8191
+
+// stateHistoryBackend is implemented by backends able to reconstruct the
+// change made by a block from the path-based state histories.
+type stateHistoryBackend interface {
+	StateHistoryDiff(root common.Hash) (*pathdb.HistoryDiff, error)
+}
+
+// StateDiff is the change made to the state by a block or a transaction.
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff is the change made to an account, only holding the fields that
+// changed. Storage slots whose key is not known, as the state histories only
+// record their hashes and no preimage was stored, are keyed by hash.
+type AccountDiff struct {
+	Balance       *BalanceDiff                 `json:"balance,omitempty"`
+	Nonce         *NonceDiff                   `json:"nonce,omitempty"`
+	Code          *CodeDiff                    `json:"code,omitempty"`
+	Storage       map[common.Hash]*StorageDiff `json:"storage,omitempty"`
+	HashedStorage map[common.Hash]*StorageDiff `json:"hashedStorage,omitempty"`
+}
+
+type BalanceDiff struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+type NonceDiff struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+type CodeDiff struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+type StorageDiff struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// TxStateDiff is the change made to the state by a transaction of a block.
+type TxStateDiff struct {
+	TxHash common.Hash `json:"txHash"`
+	Diff   StateDiff   `json:"diff"`
+}
+
+// accountValues are the fields of an account, all zero if it doesn't exist.
+type accountValues struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+}
+
+// setAccount records the changed fields of an account.
+func (d StateDiff) setAccount(addr common.Address, from, to accountValues) {
+	if from.balance == nil {
+		from.balance = new(big.Int)
+	}
+	if to.balance == nil {
+		to.balance = new(big.Int)
+	}
+	if from.balance.Cmp(to.balance) != 0 {
+		d.account(addr).Balance = &BalanceDiff{From: (*hexutil.Big)(from.balance), To: (*hexutil.Big)(to.balance)}
+	}
+	if from.nonce != to.nonce {
+		d.account(addr).Nonce = &NonceDiff{From: hexutil.Uint64(from.nonce), To: hexutil.Uint64(to.nonce)}
+	}
+	if string(from.code) != string(to.code) {
+		d.account(addr).Code = &CodeDiff{From: codeOrEmpty(from.code), To: codeOrEmpty(to.code)}
+	}
+}
+
+// setSlot records a storage slot if it changed.
+func (d StateDiff) setSlot(addr common.Address, key, from, to common.Hash) {
+	if from == to {
+		return
+	}
+	acc := d.account(addr)
+	if acc.Storage == nil {
+		acc.Storage = make(map[common.Hash]*StorageDiff)
+	}
+	acc.Storage[key] = &StorageDiff{From: from, To: to}
+}
+
+func (d StateDiff) account(addr common.Address) *AccountDiff {
+	if d[addr] == nil {
+		d[addr] = new(AccountDiff)
+	}
+	return d[addr]
+}
+
+// GetStateDiff returns the change made to the state by a block, with the
+// values before and after it. The change is taken from the state histories
+// if the node keeps them for the block, otherwise it is computed by executing
+// the block, which needs its parent state to be available.
+func (api *API) GetStateDiff(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (StateDiff, error) {
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if backend, ok := api.backend.(stateHistoryBackend); ok {
+		if diff, err := backend.StateHistoryDiff(block.Root()); err == nil {
+			return newHistoryStateDiff(api.backend.ChainDb(), diff)
+		}
+	}
+	return api.executedStateDiff(ctx, block)
+}
+
+// GetTransactionStateDiff returns the change made to the state by a
+// transaction, with the values before and after it.
+func (api *API) GetTransactionStateDiff(ctx context.Context, hash common.Hash) (StateDiff, error) {
+	result, err := api.TraceTransaction(ctx, hash, prestateDiffConfig())
+	if err != nil {
+		return nil, err
+	}
+	return newTxStateDiff(result.(json.RawMessage))
+}
+
+// GetBlockTransactionStateDiffs returns the changes made to the state by each
+// transaction of a block, with the values before and after them.
+func (api *API) GetBlockTransactionStateDiffs(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*TxStateDiff, error) {
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.traceBlock(ctx, block, prestateDiffConfig())
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]*TxStateDiff, len(results))
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("transaction %#x: %s", result.TxHash, result.Error)
+		}
+		diff, err := newTxStateDiff(result.Result.(json.RawMessage))
+		if err != nil {
+			return nil, err
+		}
+		diffs[i] = &TxStateDiff{TxHash: result.TxHash, Diff: diff}
+	}
+	return diffs, nil
+}
+
+// executedStateDiff computes the change made by a block by executing it. The
+// values are read from the states before and after the block, for all the
+// accounts and slots its transactions changed along with the ones changed by
+// the block rewards, withdrawals and beacon root.
+func (api *API) executedStateDiff(ctx context.Context, block *types.Block) (StateDiff, error) {
+	results, err := api.traceBlock(ctx, block, prestateDiffConfig())
+	if err != nil {
+		return nil, err
+	}
+	touched := make(map[common.Address]map[common.Hash]struct{})
+	touch := func(addr common.Address, slots ...common.Hash) {
+		if touched[addr] == nil {
+			touched[addr] = make(map[common.Hash]struct{})
+		}
+		for _, slot := range slots {
+			touched[addr][slot] = struct{}{}
+		}
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("transaction %#x: %s", result.TxHash, result.Error)
+		}
+		var diff struct {
+			Pre  map[common.Address]*prestateAccount `json:"pre"`
+			Post map[common.Address]*prestateAccount `json:"post"`
+		}
+		if err := json.Unmarshal(result.Result.(json.RawMessage), &diff); err != nil {
+			return nil, err
+		}
+		for _, accounts := range []map[common.Address]*prestateAccount{diff.Pre, diff.Post} {
+			for addr, acc := range accounts {
+				touch(addr)
+				for slot := range acc.Storage {
+					touch(addr, slot)
+				}
+			}
+		}
+	}
+	touch(block.Coinbase())
+	for _, uncle := range block.Uncles() {
+		touch(uncle.Coinbase)
+	}
+	for _, w := range block.Withdrawals() {
+		touch(w.Address)
+	}
+	if block.BeaconRoot() != nil {
+		index := block.Time() % beaconRootsBufferLength
+		touch(params.BeaconRootsStorageAddress, common.BigToHash(new(big.Int).SetUint64(index)), common.BigToHash(new(big.Int).SetUint64(index+beaconRootsBufferLength)))
+	}
+	parent, err := api.blockByHash(ctx, block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	prev, release, err := api.backend.StateAtBlock(ctx, parent, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	post, releasePost, err := api.backend.StateAtBlock(ctx, block, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer releasePost()
+
+	diff := make(StateDiff)
+	for addr, slots := range touched {
+		diff.setAccount(addr, stateAccountValues(prev, addr), stateAccountValues(post, addr))
+		for slot := range slots {
+			diff.setSlot(addr, slot, prev.GetState(addr, slot), post.GetState(addr, slot))
+		}
+	}
+	return diff, nil
+}
+
+func stateAccountValues(db *state.StateDB, addr common.Address) accountValues {
+	return accountValues{balance: db.GetBalance(addr).ToBig(), nonce: db.GetNonce(addr), code: db.GetCode(addr)}
+}
+
+// newTxStateDiff converts the result of the prestate tracer in diff mode to a
+// state diff. The tracer reports the accounts modified by the transaction
+// before it, and their modified fields after it. Created accounts are missing
+// before it, deleted ones after it.
+func newTxStateDiff(raw json.RawMessage) (StateDiff, error) {
+	var diff struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(raw, &diff); err != nil {
+		return nil, err
+	}
+	res := make(StateDiff)
+	for addr, pre := range diff.Pre {
+		from := accountValues{balance: (*big.Int)(pre.Balance), nonce: pre.Nonce, code: pre.Code}
+		post, ok := diff.Post[addr]
+		if !ok {
+			res.setAccount(addr, from, accountValues{})
+			for key, val := range pre.Storage {
+				res.setSlot(addr, key, val, common.Hash{})
+			}
+			continue
+		}
+		to := from
+		if post.Balance != nil {
+			to.balance = (*big.Int)(post.Balance)
+		}
+		if post.Nonce != 0 {
+			to.nonce = post.Nonce
+		}
+		if post.Code != nil {
+			to.code = post.Code
+		}
+		res.setAccount(addr, from, to)
+
+		// Slots cleared by the transaction are missing after it, the ones
+		// set from zero are missing before it.
+		for key, val := range pre.Storage {
+			res.setSlot(addr, key, val, post.Storage[key])
+		}
+		for key, val := range post.Storage {
+			if _, ok := pre.Storage[key]; !ok {
+				res.setSlot(addr, key, common.Hash{}, val)
+			}
+		}
+	}
+	for addr, post := range diff.Post {
+		if _, ok := diff.Pre[addr]; ok {
+			continue
+		}
+		res.setAccount(addr, accountValues{}, accountValues{balance: (*big.Int)(post.Balance), nonce: post.Nonce, code: post.Code})
+		for key, val := range post.Storage {
+			res.setSlot(addr, key, common.Hash{}, val)
+		}
+	}
+	return res, nil
+}
+
+// newHistoryStateDiff converts the change of a block reconstructed from the
+// state histories to a state diff.
+func newHistoryStateDiff(db ethdb.KeyValueReader, diff *pathdb.HistoryDiff) (StateDiff, error) {
+	res := make(StateDiff)
+	for addr, value := range diff.Accounts {
+		from, err := historyAccountValues(db, value.Prev)
+		if err != nil {
+			return nil, err
+		}
+		to, err := historyAccountValues(db, value.Post)
+		if err != nil {
+			return nil, err
+		}
+		res.setAccount(addr, from, to)
+	}
+	for addr, slots := range diff.Storages {
+		for hash, value := range slots {
+			from, err := historySlotValue(value.Prev)
+			if err != nil {
+				return nil, err
+			}
+			to, err := historySlotValue(value.Post)
+			if err != nil {
+				return nil, err
+			}
+			if from == to {
+				continue
+			}
+			if key := rawdb.ReadPreimage(db, hash); len(key) == common.HashLength {
+				res.setSlot(addr, common.BytesToHash(key), from, to)
+				continue
+			}
+			acc := res.account(addr)
+			if acc.HashedStorage == nil {
+				acc.HashedStorage = make(map[common.Hash]*StorageDiff)
+			}
+			acc.HashedStorage[hash] = &StorageDiff{From: from, To: to}
+		}
+	}
+	return res, nil
+}
+
+// historyAccountValues decodes an account of the state histories, in the slim
+// RLP encoding.
+func historyAccountValues(db ethdb.KeyValueReader, blob []byte) (accountValues, error) {
+	if len(blob) == 0 {
+		return accountValues{}, nil
+	}
+	account, err := types.FullAccount(blob)
+	if err != nil {
+		return accountValues{}, err
+	}
+	values := accountValues{balance: account.Balance.ToBig(), nonce: account.Nonce}
+	if codeHash := common.BytesToHash(account.CodeHash); codeHash != types.EmptyCodeHash {
+		values.code = rawdb.ReadCode(db, codeHash)
+	}
+	return values, nil
+}
+
+// historySlotValue decodes a storage slot of the state histories, in the
+// prefix-zero trimmed RLP encoding.
+func historySlotValue(blob []byte) (common.Hash, error) {
+	if len(blob) == 0 {
+		return common.Hash{}, nil
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(content), nil
+}
+
+func prestateDiffConfig() *TraceConfig {
+	return traceConfig(prestateTracerName, json.RawMessage(`{"diffMode":true}`))
+}
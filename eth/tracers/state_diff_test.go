@@ -0,0 +1,126 @@
+package tracers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb/pathdb"
+	"github.com/holiman/uint256"
+)
+
+// checkStateDiff compares the JSON encoding of a state diff with the expected
+// one.
+func checkStateDiff(t *testing.T, diff StateDiff, want string) {
+	t.Helper()
+	have, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("failed to encode state diff: %v", err)
+	}
+	var haveVal, wantVal interface{}
+	if err := json.Unmarshal(have, &haveVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(haveVal, wantVal) {
+		t.Errorf("state diff mismatch\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestNewTxStateDiff(t *testing.T) {
+	t.Parallel()
+
+	// A sender paying a contract, which clears one slot and sets another,
+	// creates an account and self-destructs another.
+	prestate := `{
+		"pre": {
+			"0x0000000000000000000000000000000000000001": {"balance": "0x10", "nonce": 1},
+			"0x0000000000000000000000000000000000000002": {"balance": "0x0", "code": "0x6000", "nonce": 1,
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000005"}},
+			"0x0000000000000000000000000000000000000004": {"balance": "0x3", "code": "0xff", "nonce": 1,
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000001"}}
+		},
+		"post": {
+			"0x0000000000000000000000000000000000000001": {"balance": "0x8", "nonce": 2},
+			"0x0000000000000000000000000000000000000002": {"balance": "0x5",
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000002": "0x0000000000000000000000000000000000000000000000000000000000000007"}},
+			"0x0000000000000000000000000000000000000003": {"balance": "0x3", "nonce": 1}
+		}
+	}`
+	diff, err := newTxStateDiff(json.RawMessage(prestate))
+	if err != nil {
+		t.Fatalf("failed to convert state diff: %v", err)
+	}
+	checkStateDiff(t, diff, `{
+		"0x0000000000000000000000000000000000000001": {"balance": {"from": "0x10", "to": "0x8"}, "nonce": {"from": "0x1", "to": "0x2"}},
+		"0x0000000000000000000000000000000000000002": {"balance": {"from": "0x0", "to": "0x5"}, "storage": {
+			"0x0000000000000000000000000000000000000000000000000000000000000001": {"from": "0x0000000000000000000000000000000000000000000000000000000000000005", "to": "0x0000000000000000000000000000000000000000000000000000000000000000"},
+			"0x0000000000000000000000000000000000000000000000000000000000000002": {"from": "0x0000000000000000000000000000000000000000000000000000000000000000", "to": "0x0000000000000000000000000000000000000000000000000000000000000007"}
+		}},
+		"0x0000000000000000000000000000000000000003": {"balance": {"from": "0x0", "to": "0x3"}, "nonce": {"from": "0x0", "to": "0x1"}},
+		"0x0000000000000000000000000000000000000004": {"balance": {"from": "0x3", "to": "0x0"}, "nonce": {"from": "0x1", "to": "0x0"}, "code": {"from": "0xff", "to": "0x"}, "storage": {
+			"0x0000000000000000000000000000000000000000000000000000000000000001": {"from": "0x0000000000000000000000000000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000000000000000000000000000000"}
+		}}
+	}`)
+}
+
+func TestNewHistoryStateDiff(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		code     = []byte{0x60, 0x00}
+		contract = common.HexToAddress("0x02")
+		created  = common.HexToAddress("0x03")
+		known    = common.HexToHash("0x01")
+		unknown  = crypto.Keccak256Hash(common.HexToHash("0x02").Bytes())
+	)
+	rawdb.WriteCode(db, crypto.Keccak256Hash(code), code)
+	rawdb.WritePreimages(db, map[common.Hash][]byte{crypto.Keccak256Hash(known.Bytes()): known.Bytes()})
+
+	account := func(balance uint64, nonce uint64, code []byte) []byte {
+		acc := types.NewEmptyStateAccount()
+		acc.Balance, acc.Nonce = uint256.NewInt(balance), nonce
+		if code != nil {
+			acc.CodeHash = crypto.Keccak256(code)
+		}
+		return types.SlimAccountRLP(*acc)
+	}
+	slot := func(value uint64) []byte {
+		blob, _ := rlp.EncodeToBytes(value)
+		return blob
+	}
+	diff, err := newHistoryStateDiff(db, &pathdb.HistoryDiff{
+		Accounts: map[common.Address]*pathdb.HistoryValue{
+			contract: {Prev: account(1, 1, code), Post: account(2, 1, code)},
+			created:  {Post: account(3, 0, nil)},
+		},
+		Storages: map[common.Address]map[common.Hash]*pathdb.HistoryValue{
+			contract: {
+				crypto.Keccak256Hash(known.Bytes()): {Prev: slot(5)},
+				unknown:                             {Prev: slot(1), Post: slot(2)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to convert state diff: %v", err)
+	}
+	checkStateDiff(t, diff, `{
+		"0x0000000000000000000000000000000000000002": {"balance": {"from": "0x1", "to": "0x2"},
+			"storage": {
+				"0x0000000000000000000000000000000000000000000000000000000000000001": {"from": "0x0000000000000000000000000000000000000000000000000000000000000005", "to": "0x0000000000000000000000000000000000000000000000000000000000000000"}
+			},
+			"hashedStorage": {
+				"`+unknown.Hex()+`": {"from": "0x0000000000000000000000000000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000000000000000000000000000002"}
+			}
+		},
+		"0x0000000000000000000000000000000000000003": {"balance": {"from": "0x0", "to": "0x3"}}
+	}`)
+}
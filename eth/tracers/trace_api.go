@@ -0,0 +1,467 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxTraceFilterRange is the maximum number of blocks trace_filter
+	// traces in one call.
+	maxTraceFilterRange = 1000
+
+	flatCallTracerName = "flatCallTracer"
+	prestateTracerName = "prestateTracer"
+	vmTracerName       = "parityVmTracer"
+)
+
+// TraceAPI serves the Parity style trace namespace. Call traces are produced
+// by the flat call tracer, state diffs by the prestate tracer in diff mode.
+// Block and uncle rewards are not reported.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates the trace namespace on top of the tracing API.
+func NewTraceAPI(api *API) *TraceAPI {
+	return &TraceAPI{api: api}
+}
+
+// TraceFilterArgs selects the call traces returned by trace_filter. A trace
+// matches if its sender is one of FromAddress and its recipient one of
+// ToAddress, an empty list matching any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *hexutil.Uint64  `json:"after"`
+	Count       *hexutil.Uint64  `json:"count"`
+}
+
+// TraceResults is the outcome of replaying a transaction, with the kinds of
+// traces that were asked for.
+type TraceResults struct {
+	Output          hexutil.Bytes                        `json:"output"`
+	StateDiff       map[common.Address]*StateDiffAccount `json:"stateDiff"`
+	Trace           []json.RawMessage                    `json:"trace"`
+	VmTrace         json.RawMessage                      `json:"vmTrace"`
+	TransactionHash *common.Hash                         `json:"transactionHash,omitempty"`
+}
+
+// StateDiffAccount is the change of an account made by a transaction. Each
+// field is "=" if unchanged, {"+": new} if the account was created, {"-": old}
+// if it was deleted and {"*": {"from": old, "to": new}} otherwise.
+type StateDiffAccount struct {
+	Balance interface{}                 `json:"balance"`
+	Code    interface{}                 `json:"code"`
+	Nonce   interface{}                 `json:"nonce"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// Block returns the call traces of all transactions of a block.
+func (t *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]json.RawMessage, error) {
+	block, err := t.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return t.blockTraces(ctx, block)
+}
+
+// Transaction returns the call traces of a transaction.
+func (t *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]json.RawMessage, error) {
+	res, err := t.api.TraceTransaction(ctx, hash, traceConfig(flatCallTracerName, nil))
+	if err != nil {
+		return nil, err
+	}
+	var traces []json.RawMessage
+	if err := json.Unmarshal(res.(json.RawMessage), &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// Filter returns the call traces of a block range matching the filter, at most
+// maxTraceFilterRange blocks being traced.
+func (t *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]json.RawMessage, error) {
+	from, to := rpc.EarliestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	start, err := t.api.backend.HeaderByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := t.api.backend.HeaderByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if start == nil || end == nil {
+		return nil, errors.New("block range not found")
+	}
+	first, last := start.Number.Uint64(), end.Number.Uint64()
+	if first > last {
+		return nil, fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if first == 0 {
+		first = 1 // genesis is not traceable
+	}
+	if last >= first && last-first >= maxTraceFilterRange {
+		return nil, fmt.Errorf("block range too large, at most %d blocks are allowed", maxTraceFilterRange)
+	}
+	var (
+		fromAddrs = addressSet(args.FromAddress)
+		toAddrs   = addressSet(args.ToAddress)
+		skip      uint64
+		res       = []json.RawMessage{}
+	)
+	if args.After != nil {
+		skip = uint64(*args.After)
+	}
+	for n := first; n <= last; n++ {
+		block, err := t.api.blockByNumber(ctx, rpc.BlockNumber(n))
+		if err != nil {
+			return nil, err
+		}
+		traces, err := t.blockTraces(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range traces {
+			from, to, err := traceAddresses(trace)
+			if err != nil {
+				return nil, err
+			}
+			if !matchAddress(fromAddrs, from) || !matchAddress(toAddrs, to) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			res = append(res, trace)
+			if args.Count != nil && uint64(len(res)) >= uint64(*args.Count) {
+				return res, nil
+			}
+		}
+	}
+	return res, nil
+}
+
+// ReplayBlockTransactions replays all transactions of a block, returning the
+// requested kinds of traces: "trace", "stateDiff" and "vmTrace".
+func (t *TraceAPI) ReplayBlockTransactions(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, traceTypes []string) ([]*TraceResults, error) {
+	block, err := t.api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	config, err := replayConfig(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	results, err := t.api.traceBlock(ctx, block, config)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*TraceResults, len(results))
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("transaction %#x: %s", result.TxHash, result.Error)
+		}
+		if res[i], err = newTraceResults(result.Result, traceTypes); err != nil {
+			return nil, err
+		}
+		hash := result.TxHash
+		res[i].TransactionHash = &hash
+	}
+	return res, nil
+}
+
+// ReplayTransaction replays a transaction, returning the requested kinds of
+// traces: "trace", "stateDiff" and "vmTrace".
+func (t *TraceAPI) ReplayTransaction(ctx context.Context, hash common.Hash, traceTypes []string) (*TraceResults, error) {
+	config, err := replayConfig(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.api.TraceTransaction(ctx, hash, config)
+	if err != nil {
+		return nil, err
+	}
+	return newTraceResults(result, traceTypes)
+}
+
+// Call executes a call on top of a block, returning the requested kinds of
+// traces: "trace", "stateDiff" and "vmTrace".
+func (t *TraceAPI) Call(ctx context.Context, args ethapi.TransactionArgs, traceTypes []string, blockNrOrHash *rpc.BlockNumberOrHash) (*TraceResults, error) {
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	config, err := replayConfig(traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.api.TraceCall(ctx, args, *blockNrOrHash, &TraceCallConfig{TraceConfig: *config})
+	if err != nil {
+		return nil, err
+	}
+	return newTraceResults(result, traceTypes)
+}
+
+// blockTraces returns the flat call traces of all transactions of a block.
+func (t *TraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]json.RawMessage, error) {
+	traces := []json.RawMessage{}
+	if block.NumberU64() == 0 {
+		return traces, nil
+	}
+	results, err := t.api.traceBlock(ctx, block, traceConfig(flatCallTracerName, nil))
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("transaction %#x: %s", result.TxHash, result.Error)
+		}
+		var txTraces []json.RawMessage
+		if err := json.Unmarshal(result.Result.(json.RawMessage), &txTraces); err != nil {
+			return nil, err
+		}
+		traces = append(traces, txTraces...)
+	}
+	return traces, nil
+}
+
+func traceConfig(tracer string, config json.RawMessage) *TraceConfig {
+	return &TraceConfig{Tracer: &tracer, TracerConfig: config}
+}
+
+// replayConfig returns the config of a mux tracer producing the requested
+// kinds of traces. The call traces are always produced, for the output.
+func replayConfig(traceTypes []string) (*TraceConfig, error) {
+	tracers := map[string]json.RawMessage{flatCallTracerName: json.RawMessage(`{}`)}
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+		case "stateDiff":
+			tracers[prestateTracerName] = json.RawMessage(`{"diffMode":true}`)
+		case "vmTrace":
+			tracers[vmTracerName] = json.RawMessage(`{}`)
+		default:
+			return nil, fmt.Errorf("unknown trace type %q", typ)
+		}
+	}
+	config, err := json.Marshal(tracers)
+	if err != nil {
+		return nil, err
+	}
+	return traceConfig("muxTracer", config), nil
+}
+
+// newTraceResults assembles the requested traces out of the results of the
+// mux tracer.
+func newTraceResults(result interface{}, traceTypes []string) (*TraceResults, error) {
+	var results map[string]json.RawMessage
+	if err := json.Unmarshal(result.(json.RawMessage), &results); err != nil {
+		return nil, err
+	}
+	var traces []json.RawMessage
+	if err := json.Unmarshal(results[flatCallTracerName], &traces); err != nil {
+		return nil, err
+	}
+	res := &TraceResults{Output: hexutil.Bytes{}}
+	if len(traces) > 0 {
+		var top struct {
+			Result *struct {
+				Output hexutil.Bytes `json:"output"`
+				Code   hexutil.Bytes `json:"code"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(traces[0], &top); err != nil {
+			return nil, err
+		}
+		if top.Result != nil {
+			res.Output = top.Result.Output
+			if top.Result.Code != nil {
+				res.Output = top.Result.Code
+			}
+		}
+	}
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+			res.Trace = traces
+		case "stateDiff":
+			diff, err := newStateDiff(results[prestateTracerName])
+			if err != nil {
+				return nil, err
+			}
+			res.StateDiff = diff
+		case "vmTrace":
+			res.VmTrace = results[vmTracerName]
+		}
+	}
+	return res, nil
+}
+
+// prestateAccount is an account as reported by the prestate tracer.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Code    hexutil.Bytes               `json:"code"`
+	Nonce   uint64                      `json:"nonce"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// empty reports whether the account has no nonce, balance or code.
+func (a *prestateAccount) empty() bool {
+	return a.Nonce == 0 && (a.Balance == nil || a.Balance.ToInt().Sign() == 0) && len(a.Code) == 0
+}
+
+// newStateDiff converts the result of the prestate tracer in diff mode to a
+// Parity state diff. The tracer only reports the modified fields of accounts
+// after the transaction, and the accounts it deleted only before it.
+func newStateDiff(raw json.RawMessage) (map[common.Address]*StateDiffAccount, error) {
+	var diff struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(raw, &diff); err != nil {
+		return nil, err
+	}
+	res := make(map[common.Address]*StateDiffAccount)
+	for addr, post := range diff.Post {
+		// The tracer reports accounts that did not exist before the
+		// transaction, like the recipient of a transfer, as empty ones.
+		pre, ok := diff.Pre[addr]
+		if !ok || pre.empty() {
+			acc := &StateDiffAccount{
+				Balance: map[string]interface{}{"+": balanceOrZero(post.Balance)},
+				Code:    map[string]interface{}{"+": codeOrEmpty(post.Code)},
+				Nonce:   map[string]interface{}{"+": hexutil.Uint64(post.Nonce)},
+				Storage: make(map[common.Hash]interface{}),
+			}
+			for key, val := range post.Storage {
+				acc.Storage[key] = map[string]interface{}{"+": val}
+			}
+			res[addr] = acc
+			continue
+		}
+		acc := &StateDiffAccount{Balance: "=", Code: "=", Nonce: "=", Storage: make(map[common.Hash]interface{})}
+		if post.Balance != nil {
+			acc.Balance = changed(balanceOrZero(pre.Balance), post.Balance)
+		}
+		if post.Code != nil {
+			acc.Code = changed(codeOrEmpty(pre.Code), post.Code)
+		}
+		if post.Nonce != 0 && post.Nonce != pre.Nonce {
+			acc.Nonce = changed(hexutil.Uint64(pre.Nonce), hexutil.Uint64(post.Nonce))
+		}
+		// Slots cleared by the transaction are missing after it, the ones
+		// set from zero are missing before it.
+		for key, val := range pre.Storage {
+			acc.Storage[key] = changed(val, post.Storage[key])
+		}
+		for key, val := range post.Storage {
+			if _, ok := pre.Storage[key]; !ok {
+				acc.Storage[key] = changed(common.Hash{}, val)
+			}
+		}
+		res[addr] = acc
+	}
+	for addr, pre := range diff.Pre {
+		if _, ok := diff.Post[addr]; ok {
+			continue
+		}
+		acc := &StateDiffAccount{
+			Balance: map[string]interface{}{"-": balanceOrZero(pre.Balance)},
+			Code:    map[string]interface{}{"-": codeOrEmpty(pre.Code)},
+			Nonce:   map[string]interface{}{"-": hexutil.Uint64(pre.Nonce)},
+			Storage: make(map[common.Hash]interface{}),
+		}
+		for key, val := range pre.Storage {
+			acc.Storage[key] = map[string]interface{}{"-": val}
+		}
+		res[addr] = acc
+	}
+	return res, nil
+}
+
+func changed(from, to interface{}) interface{} {
+	return map[string]interface{}{"*": map[string]interface{}{"from": from, "to": to}}
+}
+
+func balanceOrZero(balance *hexutil.Big) *hexutil.Big {
+	if balance == nil {
+		return new(hexutil.Big)
+	}
+	return balance
+}
+
+func codeOrEmpty(code hexutil.Bytes) hexutil.Bytes {
+	if code == nil {
+		return hexutil.Bytes{}
+	}
+	return code
+}
+
+// traceAddresses returns the sender and recipient of a flat call trace: the
+// created contract for creations, the beneficiary for self-destructs.
+func traceAddresses(trace json.RawMessage) (from, to *common.Address, err error) {
+	var frame struct {
+		Action struct {
+			From          *common.Address `json:"from"`
+			To            *common.Address `json:"to"`
+			Address       *common.Address `json:"address"`
+			RefundAddress *common.Address `json:"refundAddress"`
+		} `json:"action"`
+		Result *struct {
+			Address *common.Address `json:"address"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(trace, &frame); err != nil {
+		return nil, nil, err
+	}
+	from, to = frame.Action.From, frame.Action.To
+	if frame.Action.Address != nil {
+		from, to = frame.Action.Address, frame.Action.RefundAddress
+	}
+	if to == nil && frame.Result != nil {
+		to = frame.Result.Address
+	}
+	return from, to, nil
+}
+
+func addressSet(addrs []common.Address) map[common.Address]struct{} {
+	if len(addrs) == 0 {
+		return nil
+	}
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// matchAddress reports whether the address is in the set, any address
+// matching an empty set.
+func matchAddress(set map[common.Address]struct{}, addr *common.Address) bool {
+	if set == nil {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	_, ok := set[*addr]
+	return ok
+}
@@ -0,0 +1,188 @@
+package tracers_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	// Force-load the native tracers, to trigger registration
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+// flatCallTrace is the part of a trace of the flat call tracer checked by the
+// tests.
+type flatCallTrace struct {
+	Action struct {
+		From common.Address `json:"from"`
+		To   common.Address `json:"to"`
+	} `json:"action"`
+	BlockNumber     uint64      `json:"blockNumber"`
+	TransactionHash common.Hash `json:"transactionHash"`
+	Type            string      `json:"type"`
+}
+
+func decodeFlatCallTraces(t *testing.T, raw []json.RawMessage) []flatCallTrace {
+	traces := make([]flatCallTrace, len(raw))
+	for i, trace := range raw {
+		if err := json.Unmarshal(trace, &traces[i]); err != nil {
+			t.Fatalf("failed to decode trace %d: %v", i, err)
+		}
+	}
+	return traces
+}
+
+func TestTraceAPI(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		fresh    = common.HexToAddress("0xdead")
+		contract = common.HexToAddress("0xc0de")
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				// Stores 7 in slot 1
+				contract: {Code: []byte{byte(vm.PUSH1), 0x7, byte(vm.PUSH1), 0x1, byte(vm.SSTORE), byte(vm.STOP)}},
+			},
+		}
+		signer = types.HomesteadSigner{}
+		hashes [][]common.Hash
+	)
+	backend, teardown := tracers.NewTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		// Pay a new account, then call the contract
+		var txs []common.Hash
+		for j, to := range []common.Address{fresh, contract} {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+				Nonce:    uint64(2*i + j),
+				To:       &to,
+				Value:    big.NewInt(1000),
+				Gas:      100000,
+				GasPrice: b.BaseFee(),
+			}), signer, key)
+			b.AddTx(tx)
+			txs = append(txs, tx.Hash())
+		}
+		hashes = append(hashes, txs)
+	})
+	defer teardown()
+	api := tracers.NewTraceAPI(tracers.NewAPI(backend))
+	ctx := context.Background()
+
+	// trace_block
+	raw, err := api.Block(ctx, rpc.BlockNumber(1))
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	traces := decodeFlatCallTraces(t, raw)
+	if len(traces) != 2 {
+		t.Fatalf("block trace count mismatch: have %d, want %d", len(traces), 2)
+	}
+	for i, to := range []common.Address{fresh, contract} {
+		trace := traces[i]
+		if trace.Type != "call" || trace.Action.From != sender || trace.Action.To != to {
+			t.Errorf("trace %d: have %s from %x to %x, want call from %x to %x", i, trace.Type, trace.Action.From, trace.Action.To, sender, to)
+		}
+		if trace.BlockNumber != 1 || trace.TransactionHash != hashes[0][i] {
+			t.Errorf("trace %d: have block %d tx %x, want block 1 tx %x", i, trace.BlockNumber, trace.TransactionHash, hashes[0][i])
+		}
+	}
+
+	// trace_filter
+	var (
+		from, to = rpc.BlockNumber(1), rpc.BlockNumber(2)
+		one      = hexutil.Uint64(1)
+	)
+	for _, tt := range []struct {
+		args tracers.TraceFilterArgs
+		want []common.Hash
+	}{
+		{tracers.TraceFilterArgs{FromBlock: &from, ToBlock: &to}, []common.Hash{hashes[0][0], hashes[0][1], hashes[1][0], hashes[1][1]}},
+		{tracers.TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{contract}}, []common.Hash{hashes[0][1], hashes[1][1]}},
+		{tracers.TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{contract}, After: &one, Count: &one}, []common.Hash{hashes[1][1]}},
+		{tracers.TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{fresh}}, nil},
+	} {
+		raw, err := api.Filter(ctx, tt.args)
+		if err != nil {
+			t.Fatalf("failed to filter traces: %v", err)
+		}
+		if raw == nil {
+			t.Errorf("filter %+v: nil result instead of an empty list", tt.args)
+		}
+		traces := decodeFlatCallTraces(t, raw)
+		if len(traces) != len(tt.want) {
+			t.Errorf("filter %+v: have %d traces, want %d", tt.args, len(traces), len(tt.want))
+			continue
+		}
+		for i, trace := range traces {
+			if trace.TransactionHash != tt.want[i] {
+				t.Errorf("filter %+v: trace %d: have tx %x, want %x", tt.args, i, trace.TransactionHash, tt.want[i])
+			}
+		}
+	}
+
+	// trace_replayBlockTransactions
+	results, err := api.ReplayBlockTransactions(ctx, rpc.BlockNumberOrHashWithNumber(1), []string{"trace", "stateDiff", "vmTrace"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("replay result count mismatch: have %d, want %d", len(results), 2)
+	}
+	for i, res := range results {
+		if res.TransactionHash == nil || *res.TransactionHash != hashes[0][i] {
+			t.Errorf("result %d: have tx %v, want %x", i, res.TransactionHash, hashes[0][i])
+		}
+		if len(res.Trace) != 1 {
+			t.Errorf("result %d: have %d call traces, want 1", i, len(res.Trace))
+		}
+	}
+	// The paid account did not exist before
+	encoded, _ := json.Marshal(results[0].StateDiff[fresh])
+	if want := `{"balance":{"+":"0x3e8"},"code":{"+":"0x"},"nonce":{"+":"0x0"},"storage":{}}`; string(encoded) != want {
+		t.Errorf("new account diff mismatch\nhave: %s\nwant: %s", encoded, want)
+	}
+	encoded, _ = json.Marshal(results[1].StateDiff[contract].Storage)
+	if want := `{"0x0000000000000000000000000000000000000000000000000000000000000001":{"*":{"from":"0x0000000000000000000000000000000000000000000000000000000000000000","to":"0x0000000000000000000000000000000000000000000000000000000000000007"}}}`; string(encoded) != want {
+		t.Errorf("storage diff mismatch\nhave: %s\nwant: %s", encoded, want)
+	}
+	var vmTrace struct {
+		Code hexutil.Bytes     `json:"code"`
+		Ops  []json.RawMessage `json:"ops"`
+	}
+	if err := json.Unmarshal(results[0].VmTrace, &vmTrace); err != nil {
+		t.Fatalf("failed to decode vm trace: %v", err)
+	}
+	if len(vmTrace.Code) != 0 || len(vmTrace.Ops) != 0 {
+		t.Errorf("transfer vm trace has code %x and %d ops", vmTrace.Code, len(vmTrace.Ops))
+	}
+	if err := json.Unmarshal(results[1].VmTrace, &vmTrace); err != nil {
+		t.Fatalf("failed to decode vm trace: %v", err)
+	}
+	if len(vmTrace.Ops) != 4 {
+		t.Errorf("contract vm trace op count mismatch: have %d, want %d", len(vmTrace.Ops), 4)
+	}
+
+	// Only the requested kinds of traces are returned
+	results, err = api.ReplayBlockTransactions(ctx, rpc.BlockNumberOrHashWithNumber(2), []string{"trace"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	for i, res := range results {
+		if res.StateDiff != nil || res.VmTrace != nil || len(res.Trace) != 1 {
+			t.Errorf("result %d: unexpected traces %+v", i, res)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package tracers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewStateDiff(t *testing.T) {
+	t.Parallel()
+
+	// A sender paying a contract, which clears one slot and sets another,
+	// creates an account and self-destructs another. The tracer reports
+	// the empty account paid by the sender as existing before.
+	prestate := `{
+		"pre": {
+			"0x0000000000000000000000000000000000000001": {"balance": "0x10", "nonce": 1},
+			"0x0000000000000000000000000000000000000002": {"balance": "0x0", "code": "0x6000", "nonce": 1,
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000005"}},
+			"0x0000000000000000000000000000000000000004": {"balance": "0x3", "code": "0xff", "nonce": 1},
+			"0x0000000000000000000000000000000000000005": {"balance": "0x0"}
+		},
+		"post": {
+			"0x0000000000000000000000000000000000000001": {"balance": "0x8", "nonce": 2},
+			"0x0000000000000000000000000000000000000002": {"balance": "0x5",
+				"storage": {"0x0000000000000000000000000000000000000000000000000000000000000002": "0x0000000000000000000000000000000000000000000000000000000000000007"}},
+			"0x0000000000000000000000000000000000000003": {"balance": "0x3", "nonce": 1},
+			"0x0000000000000000000000000000000000000005": {"balance": "0x2"}
+		}
+	}`
+	diff, err := newStateDiff(json.RawMessage(prestate))
+	if err != nil {
+		t.Fatalf("failed to convert state diff: %v", err)
+	}
+	have, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("failed to encode state diff: %v", err)
+	}
+	want := `{
+		"0x0000000000000000000000000000000000000001": {"balance": {"*": {"from": "0x10", "to": "0x8"}}, "code": "=", "nonce": {"*": {"from": "0x1", "to": "0x2"}}, "storage": {}},
+		"0x0000000000000000000000000000000000000002": {"balance": {"*": {"from": "0x0", "to": "0x5"}}, "code": "=", "nonce": "=", "storage": {
+			"0x0000000000000000000000000000000000000000000000000000000000000001": {"*": {"from": "0x0000000000000000000000000000000000000000000000000000000000000005", "to": "0x0000000000000000000000000000000000000000000000000000000000000000"}},
+			"0x0000000000000000000000000000000000000000000000000000000000000002": {"*": {"from": "0x0000000000000000000000000000000000000000000000000000000000000000", "to": "0x0000000000000000000000000000000000000000000000000000000000000007"}}
+		}},
+		"0x0000000000000000000000000000000000000003": {"balance": {"+": "0x3"}, "code": {"+": "0x"}, "nonce": {"+": "0x1"}, "storage": {}},
+		"0x0000000000000000000000000000000000000004": {"balance": {"-": "0x3"}, "code": {"-": "0xff"}, "nonce": {"-": "0x1"}, "storage": {}},
+		"0x0000000000000000000000000000000000000005": {"balance": {"+": "0x2"}, "code": {"+": "0x"}, "nonce": {"+": "0x0"}, "storage": {}}
+	}`
+	var haveVal, wantVal interface{}
+	if err := json.Unmarshal(have, &haveVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(haveVal, wantVal) {
+		t.Errorf("state diff mismatch\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestTraceAddresses(t *testing.T) {
+	t.Parallel()
+
+	var (
+		a = common.HexToAddress("0x01")
+		b = common.HexToAddress("0x02")
+	)
+	tests := []struct {
+		trace    string
+		from, to common.Address
+	}{
+		{`{"action": {"from": "0x0000000000000000000000000000000000000001", "to": "0x0000000000000000000000000000000000000002"}, "type": "call"}`, a, b},
+		{`{"action": {"from": "0x0000000000000000000000000000000000000001"}, "result": {"address": "0x0000000000000000000000000000000000000002"}, "type": "create"}`, a, b},
+		{`{"action": {"address": "0x0000000000000000000000000000000000000002", "refundAddress": "0x0000000000000000000000000000000000000001"}, "type": "suicide"}`, b, a},
+	}
+	for i, tt := range tests {
+		from, to, err := traceAddresses(json.RawMessage(tt.trace))
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if from == nil || to == nil || *from != tt.from || *to != tt.to {
+			t.Errorf("test %d: have %v -> %v, want %v -> %v", i, from, to, tt.from, tt.to)
+		}
+	}
+	set := addressSet([]common.Address{a})
+	if !matchAddress(nil, nil) || !matchAddress(set, &a) || matchAddress(set, &b) || matchAddress(set, nil) {
+		t.Error("address matching failed")
+	}
+}
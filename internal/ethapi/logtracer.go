@@ -0,0 +1,115 @@
+package ethapi
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+var (
+	// transferTopic is the topic of the ERC20 Transfer event, which is also
+	// used for the logs of ETH transfers.
+	transferTopic = common.HexToHash("ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+	// transferAddress is the pseudo address emitting the logs of ETH
+	// transfers, as per ERC-7528.
+	transferAddress = common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+)
+
+// logTracer collects the logs of a call together with logs of the ETH
+// transfers it makes, in execution order. The logs of reverted call frames
+// are discarded, like the EVM does for regular logs.
+type logTracer struct {
+	frames [][]*types.Log // Logs of the call frames being executed
+	logs   []*types.Log   // Logs of the call once it is finished
+}
+
+func newLogTracer() *logTracer {
+	return &logTracer{}
+}
+
+func (t *logTracer) CaptureTxStart(gasLimit uint64) {
+	t.frames, t.logs = nil, nil
+}
+
+func (t *logTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *logTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.enter(from, to, value)
+}
+
+func (t *logTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+func (t *logTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if typ == vm.DELEGATECALL || typ == vm.CALLCODE {
+		// No value leaves the executing account
+		value = nil
+	}
+	t.enter(from, to, value)
+}
+
+func (t *logTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+func (t *logTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil || op < vm.LOG0 || op > vm.LOG4 {
+		return
+	}
+	var (
+		stack  = scope.Stack
+		offset = stack.Back(0)
+		size   = stack.Back(1)
+		topics = make([]common.Hash, int(op-vm.LOG0))
+	)
+	for i := range topics {
+		topics[i] = stack.Back(2 + i).Bytes32()
+	}
+	t.add(&types.Log{
+		Address: scope.Contract.Address(),
+		Topics:  topics,
+		Data:    scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64())),
+	})
+}
+
+func (t *logTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *logTracer) enter(from, to common.Address, value *big.Int) {
+	t.frames = append(t.frames, nil)
+	if value != nil && value.Sign() > 0 {
+		t.add(&types.Log{
+			Address: transferAddress,
+			Topics:  []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.BigToHash(value).Bytes(),
+		})
+	}
+}
+
+func (t *logTracer) exit(err error) {
+	last := len(t.frames) - 1
+	logs := t.frames[last]
+	t.frames = t.frames[:last]
+	if err != nil {
+		return
+	}
+	if last == 0 {
+		t.logs = append(t.logs, logs...)
+	} else {
+		t.frames[last-1] = append(t.frames[last-1], logs...)
+	}
+}
+
+func (t *logTracer) add(log *types.Log) {
+	last := len(t.frames) - 1
+	t.frames[last] = append(t.frames[last], log)
+}
+
+// Logs returns the logs of the last call.
+func (t *logTracer) Logs() []*types.Log {
+	return t.logs
+}
@@ -0,0 +1,61 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// senderNonceBackend is implemented by backends able to look transactions up
+// by sender and nonce.
+type senderNonceBackend interface {
+	// SenderNonceLookup returns the hash of the canonical transaction sent by
+	// an address with the given nonce, the zero hash if it is not indexed.
+	SenderNonceLookup(sender common.Address, nonce uint64) (common.Hash, error)
+
+	// SenderNonceIndexDone reports whether the index covers all the blocks it
+	// should.
+	SenderNonceIndexDone() bool
+}
+
+// GetTransactionBySenderAndNonce returns the transaction sent by an address
+// with the given nonce: the canonical one if it was included in a block,
+// otherwise the one waiting in the pool, if any. An error is returned if
+// neither is found while the index is still being built.
+func (s *TransactionAPI) GetTransactionBySenderAndNonce(ctx context.Context, sender common.Address, nonce hexutil.Uint64) (*RPCTransaction, error) {
+	backend, ok := s.b.(senderNonceBackend)
+	if !ok {
+		return nil, errors.New("transaction lookup by sender and nonce is not supported")
+	}
+	hash, err := backend.SenderNonceLookup(sender, uint64(nonce))
+	if err != nil {
+		return nil, err
+	}
+	if hash != (common.Hash{}) {
+		found, tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
+		if err != nil {
+			return nil, NewTxIndexingError()
+		}
+		// The entry of a transaction reorged out may linger until its
+		// replacement is included, fall back to the pool then.
+		if found {
+			header, err := s.b.HeaderByHash(ctx, blockHash)
+			if err != nil {
+				return nil, err
+			}
+			return newRPCTransaction(tx, blockHash, blockNumber, header.Time, index, header.BaseFee, s.b.ChainConfig()), nil
+		}
+	}
+	pending, queued := s.b.TxPoolContentFrom(sender)
+	for _, tx := range append(pending, queued...) {
+		if tx.Nonce() == uint64(nonce) {
+			return NewRPCPendingTransaction(tx, s.b.CurrentHeader(), s.b.ChainConfig()), nil
+		}
+	}
+	if !backend.SenderNonceIndexDone() {
+		return nil, NewTxIndexingError()
+	}
+	return nil, nil
+}
@@ -0,0 +1,97 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// senderNonceTestBackend serves a sender nonce index and a pool over the test
+// backend.
+type senderNonceTestBackend struct {
+	*testBackend
+	lookups map[uint64]common.Hash
+	done    bool
+	pool    []*types.Transaction
+}
+
+func (b *senderNonceTestBackend) SenderNonceLookup(sender common.Address, nonce uint64) (common.Hash, error) {
+	return b.lookups[nonce], nil
+}
+
+func (b *senderNonceTestBackend) SenderNonceIndexDone() bool { return b.done }
+
+func (b *senderNonceTestBackend) TxPoolContentFrom(addr common.Address) ([]*types.Transaction, []*types.Transaction) {
+	return b.pool, nil
+}
+
+func TestGetTransactionBySenderAndNonce(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		to      = common.HexToAddress("0xdeadbeef")
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		mined  *types.Transaction
+	)
+	b := newTestBackend(t, 1, genesis, ethash.NewFaker(), func(i int, b *core.BlockGen) {
+		mined = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &to, Gas: params.TxGas, GasPrice: b.BaseFee()})
+		b.AddTx(mined)
+	})
+	pending := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, To: &to, Gas: params.TxGas, GasPrice: big.NewInt(params.GWei)})
+	backend := &senderNonceTestBackend{
+		testBackend: b,
+		lookups:     map[uint64]common.Hash{0: mined.Hash()},
+		pool:        []*types.Transaction{pending},
+	}
+	api := NewTransactionAPI(backend, nil)
+
+	for _, tt := range []struct {
+		nonce uint64
+		done  bool
+		want  common.Hash
+		err   bool
+	}{
+		{nonce: 0, want: mined.Hash()},
+		{nonce: 1, want: pending.Hash()},
+		{nonce: 2, err: true}, // Maybe in a block not indexed yet
+		{nonce: 2, done: true},
+	} {
+		backend.done = tt.done
+		tx, err := api.GetTransactionBySenderAndNonce(context.Background(), sender, hexutil.Uint64(tt.nonce))
+		if tt.err {
+			var indexing *TxIndexingError
+			if !errors.As(err, &indexing) {
+				t.Errorf("nonce %d: have error %v, want indexing error", tt.nonce, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("nonce %d: lookup failed: %v", tt.nonce, err)
+		}
+		var have common.Hash
+		if tx != nil {
+			have = tx.Hash
+		}
+		if have != tt.want {
+			t.Errorf("nonce %d: have transaction %x, want %x", tt.nonce, have, tt.want)
+		}
+	}
+	if tx, _ := api.GetTransactionBySenderAndNonce(context.Background(), sender, 0); tx == nil || tx.BlockNumber == nil || tx.BlockNumber.ToInt().Uint64() != 1 {
+		t.Errorf("mined transaction not returned with its block: %+v", tx)
+	}
+}
@@ -0,0 +1,499 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks a simulation may
+	// span, including the empty ones filling number gaps.
+	maxSimulateBlocks = 256
+
+	// simulateTimestampIncrement is the default time between two simulated
+	// blocks.
+	simulateTimestampIncrement = 12
+)
+
+// simBlock is a block to simulate: its header overrides, the state overrides
+// applied before it and the calls it contains.
+type simBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// simOpts are the inputs of eth_simulateV1.
+type simOpts struct {
+	BlockStateCalls        []simBlock `json:"blockStateCalls"`
+	TraceTransfers         bool       `json:"traceTransfers"`
+	Validation             bool       `json:"validation"`
+	ReturnFullTransactions bool       `json:"returnFullTransactions"`
+}
+
+// simCallResult is the outcome of a simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       *callError     `json:"error,omitempty"`
+}
+
+// callError is the error of a failed simulated call.
+type callError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+const (
+	errCodeReverted = 3      // Same as for eth_call reverts
+	errCodeVMError  = -32015 // Execution failed for another reason than a revert
+)
+
+// simChainContext serves the headers of the simulated blocks on top of the
+// chain, so BLOCKHASH can reach them.
+type simChainContext struct {
+	*ChainContext
+	headers map[common.Hash]*types.Header
+}
+
+func (c *simChainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := c.headers[hash]; ok {
+		return header
+	}
+	return c.ChainContext.GetHeader(hash, number)
+}
+
+// simulator runs a sequence of simulated blocks on top of a base block, the
+// state of each block carrying forward to the next one.
+type simulator struct {
+	b              Backend
+	state          *state.StateDB
+	base           *types.Header
+	chain          *simChainContext
+	budget         uint64 // Gas left to the whole simulation
+	traceTransfers bool
+	validate       bool
+	fullTx         bool
+}
+
+// SimulateV1 executes series of calls in simulated blocks on top of the given
+// block. The state changes of a block are visible to the following ones, but
+// nothing is persisted.
+//
+// Unless validation is requested, nonces, balances and fees are not checked,
+// the base fee of the blocks defaulting to zero. With traceTransfers, the logs
+// of the calls include ETH transfers as ERC20-like Transfer events emitted by
+// 0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts simOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks, at most %d are allowed", maxSimulateBlocks)
+	}
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	defer func(start time.Time) { log.Debug("Executing EVM simulation finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, base, err := s.b.StateAndHeaderByNumberOrHash(ctx, *blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	var cancel context.CancelFunc
+	if timeout := s.b.RPCEVMTimeout(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	budget := s.b.RPCGasCap()
+	if budget == 0 {
+		budget = math.MaxUint64
+	}
+	sim := &simulator{
+		b:     s.b,
+		state: state,
+		base:  base,
+		chain: &simChainContext{
+			ChainContext: NewChainContext(ctx, s.b),
+			headers:      make(map[common.Hash]*types.Header),
+		},
+		budget:         budget,
+		traceTransfers: opts.TraceTransfers,
+		validate:       opts.Validation,
+		fullTx:         opts.ReturnFullTransactions,
+	}
+	return sim.execute(ctx, opts.BlockStateCalls)
+}
+
+// execute runs the blocks, after filling the gaps between their numbers.
+func (sim *simulator) execute(ctx context.Context, blocks []simBlock) ([]map[string]interface{}, error) {
+	blocks, err := sim.sanitizeChain(blocks)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		results = make([]map[string]interface{}, len(blocks))
+		parent  = sim.base
+	)
+	for i, block := range blocks {
+		result, header, err := sim.processBlock(ctx, &block, parent)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results[i] = result
+		parent = header
+	}
+	return results, nil
+}
+
+// sanitizeChain checks that block numbers and times are increasing, filling
+// them in when not given, and inserts empty blocks where numbers are skipped.
+func (sim *simulator) sanitizeChain(blocks []simBlock) ([]simBlock, error) {
+	var (
+		res      = make([]simBlock, 0, len(blocks))
+		prevNum  = sim.base.Number.Uint64()
+		prevTime = sim.base.Time
+	)
+	for _, block := range blocks {
+		if block.BlockOverrides == nil {
+			block.BlockOverrides = new(BlockOverrides)
+		}
+		if block.BlockOverrides.Number == nil {
+			block.BlockOverrides.Number = (*hexutil.Big)(new(big.Int).SetUint64(prevNum + 1))
+		}
+		number := block.BlockOverrides.Number.ToInt()
+		if !number.IsUint64() || number.Uint64() <= prevNum {
+			return nil, fmt.Errorf("block numbers must be increasing: %v after %d", number, prevNum)
+		}
+		if number.Uint64()-sim.base.Number.Uint64() > maxSimulateBlocks {
+			return nil, fmt.Errorf("too many blocks, at most %d are allowed", maxSimulateBlocks)
+		}
+		// Fill the gap with empty blocks
+		for n := prevNum + 1; n < number.Uint64(); n++ {
+			prevTime += simulateTimestampIncrement
+			t := prevTime
+			res = append(res, simBlock{BlockOverrides: &BlockOverrides{
+				Number: (*hexutil.Big)(new(big.Int).SetUint64(n)),
+				Time:   (*hexutil.Uint64)(&t),
+			}})
+		}
+		prevNum = number.Uint64()
+
+		if block.BlockOverrides.Time == nil {
+			t := prevTime + simulateTimestampIncrement
+			block.BlockOverrides.Time = (*hexutil.Uint64)(&t)
+		}
+		if t := uint64(*block.BlockOverrides.Time); t <= prevTime {
+			return nil, fmt.Errorf("block timestamps must be increasing: %d after %d", t, prevTime)
+		}
+		prevTime = uint64(*block.BlockOverrides.Time)
+		res = append(res, block)
+	}
+	return res, nil
+}
+
+// makeHeader assembles the header of a simulated block on top of its parent.
+// The fields depending on the block's execution are filled in later.
+func (sim *simulator) makeHeader(overrides *BlockOverrides, parent *types.Header) *types.Header {
+	var (
+		config = sim.b.ChainConfig()
+		header = &types.Header{
+			ParentHash: parent.Hash(),
+			UncleHash:  types.EmptyUncleHash,
+			Coinbase:   parent.Coinbase,
+			Difficulty: parent.Difficulty,
+			Number:     overrides.Number.ToInt(),
+			GasLimit:   parent.GasLimit,
+			Time:       uint64(*overrides.Time),
+			MixDigest:  parent.MixDigest,
+		}
+	)
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty = overrides.Difficulty.ToInt()
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.Random != nil {
+		header.MixDigest = *overrides.Random
+	}
+	if config.IsLondon(header.Number) {
+		switch {
+		case overrides.BaseFee != nil:
+			header.BaseFee = overrides.BaseFee.ToInt()
+		case sim.validate:
+			header.BaseFee = eip1559.CalcBaseFee(config, parent)
+		default:
+			header.BaseFee = new(big.Int)
+		}
+	}
+	if config.IsShanghai(header.Number, header.Time) {
+		header.WithdrawalsHash = &types.EmptyWithdrawalsHash
+	}
+	if config.IsCancun(header.Number, header.Time) {
+		var excessBlobGas uint64
+		if parent.ExcessBlobGas != nil && parent.BlobGasUsed != nil {
+			excessBlobGas = eip4844.CalcExcessBlobGas(*parent.ExcessBlobGas, *parent.BlobGasUsed)
+		}
+		header.ExcessBlobGas = &excessBlobGas
+		header.BlobGasUsed = new(uint64)
+		header.ParentBeaconRoot = new(common.Hash)
+	}
+	return header
+}
+
+// processBlock runs the calls of a block and returns its RPC representation
+// along with its header.
+func (sim *simulator) processBlock(ctx context.Context, block *simBlock, parent *types.Header) (map[string]interface{}, *types.Header, error) {
+	var (
+		config   = sim.b.ChainConfig()
+		header   = sim.makeHeader(block.BlockOverrides, parent)
+		blockCtx = core.NewEVMBlockContext(header, sim.chain, &header.Coinbase)
+	)
+	if block.BlockOverrides.BlobBaseFee != nil {
+		blockCtx.BlobBaseFee = block.BlockOverrides.BlobBaseFee.ToInt()
+	}
+	if err := block.StateOverrides.Apply(sim.state); err != nil {
+		return nil, nil, err
+	}
+	if header.ParentBeaconRoot != nil {
+		evm := vm.NewEVM(blockCtx, vm.TxContext{}, sim.state, config, vm.Config{})
+		core.ProcessBeaconBlockRoot(*header.ParentBeaconRoot, evm, sim.state)
+	}
+	var (
+		gp       = new(core.GasPool).AddGas(header.GasLimit)
+		txs      = make([]*types.Transaction, len(block.Calls))
+		senders  = make([]common.Address, len(block.Calls))
+		receipts = make([]*types.Receipt, len(block.Calls))
+		calls    = make([]simCallResult, len(block.Calls))
+		allLogs  []*types.Log // Logs of the calls, to set the block hash of
+		gasUsed  uint64
+		blobGas  uint64
+		tracer   *logTracer
+		vmConfig = vm.Config{NoBaseFee: !sim.validate}
+
+		logIndex, receiptLogIndex uint
+	)
+	if sim.traceTransfers {
+		tracer = newLogTracer()
+		vmConfig.Tracer = tracer
+	}
+	for i := range block.Calls {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, fmt.Errorf("execution aborted (timeout = %v)", sim.b.RPCEVMTimeout())
+		}
+		args := &block.Calls[i]
+		if err := sim.sanitizeCall(args, header, gp.Gas()); err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		msg, err := args.ToMessage(0, header.BaseFee)
+		if err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		msg.Nonce = uint64(*args.Nonce)
+		msg.SkipAccountChecks = !sim.validate
+
+		tx := args.toTransaction()
+		txs[i], senders[i] = tx, msg.From
+		sim.state.SetTxContext(tx.Hash(), i)
+
+		evm := sim.b.GetEVM(ctx, msg, sim.state, header, &vmConfig, &blockCtx)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		result, err := core.ApplyMessage(evm, msg, gp)
+		close(done)
+		if err := sim.state.Error(); err != nil {
+			return nil, nil, err
+		}
+		if evm.Cancelled() {
+			return nil, nil, fmt.Errorf("execution aborted (timeout = %v)", sim.b.RPCEVMTimeout())
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		gasUsed += result.UsedGas
+		blobGas += uint64(len(msg.BlobHashes)) * params.BlobTxBlobGasPerBlob
+		sim.budget -= result.UsedGas
+		var root []byte
+		if config.IsByzantium(header.Number) {
+			sim.state.Finalise(true)
+		} else {
+			root = sim.state.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+		}
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			PostState:         root,
+			CumulativeGasUsed: gasUsed,
+			TxHash:            tx.Hash(),
+			GasUsed:           result.UsedGas,
+			Logs:              sim.state.GetLogs(tx.Hash(), header.Number.Uint64(), common.Hash{}),
+			BlockNumber:       header.Number,
+			TransactionIndex:  uint(i),
+		}
+		if result.Failed() {
+			receipt.Status = types.ReceiptStatusFailed
+		} else {
+			receipt.Status = types.ReceiptStatusSuccessful
+		}
+		if msg.To == nil {
+			receipt.ContractAddress = crypto.CreateAddress(msg.From, msg.Nonce)
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		receipts[i] = receipt
+
+		// The call results hold the logs of the tracer if transfers are
+		// traced, the ones of the receipt otherwise.
+		for _, l := range receipt.Logs {
+			l.Index = receiptLogIndex
+			receiptLogIndex++
+		}
+		allLogs = append(allLogs, receipt.Logs...)
+
+		logs := receipt.Logs
+		if tracer != nil {
+			logs = tracer.Logs()
+			for _, l := range logs {
+				l.TxHash, l.TxIndex, l.BlockNumber = tx.Hash(), uint(i), header.Number.Uint64()
+				l.Index = logIndex
+				logIndex++
+			}
+			allLogs = append(allLogs, logs...)
+		}
+		if logs == nil {
+			logs = []*types.Log{}
+		}
+
+		calls[i] = simCallResult{
+			ReturnValue: result.Return(),
+			Logs:        logs,
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+			Status:      hexutil.Uint64(receipt.Status),
+		}
+		if result.Failed() {
+			if revert := result.Revert(); len(revert) > 0 {
+				err := newRevertError(revert)
+				calls[i].Error = &callError{Message: err.Error(), Code: errCodeReverted, Data: err.reason}
+			} else {
+				calls[i].Error = &callError{Message: result.Err.Error(), Code: errCodeVMError}
+			}
+		}
+	}
+	header.GasUsed = gasUsed
+	if header.BlobGasUsed != nil {
+		header.BlobGasUsed = &blobGas
+	}
+	header.Root = sim.state.IntermediateRoot(config.IsEIP158(header.Number))
+
+	var withdrawals []*types.Withdrawal
+	if header.WithdrawalsHash != nil {
+		withdrawals = []*types.Withdrawal{}
+	}
+	b := types.NewBlockWithWithdrawals(header, txs, nil, receipts, withdrawals, trie.NewStackTrie(nil))
+	hash := b.Hash()
+	for _, l := range allLogs {
+		l.BlockHash = hash
+	}
+	sim.chain.headers[hash] = b.Header()
+
+	fields := RPCMarshalBlock(b, true, sim.fullTx, config)
+	if sim.fullTx {
+		// The transactions are not signed, fill in their senders.
+		for i, tx := range fields["transactions"].([]interface{}) {
+			tx.(*RPCTransaction).From = senders[i]
+		}
+	}
+	fields["calls"] = calls
+	return fields, b.Header(), nil
+}
+
+// sanitizeCall fills in the defaults of a call, checking it fits the gas
+// left in the block and in the simulation.
+func (sim *simulator) sanitizeCall(args *TransactionArgs, header *types.Header, blockGas uint64) error {
+	if args.Nonce == nil {
+		nonce := sim.state.GetNonce(args.from())
+		args.Nonce = (*hexutil.Uint64)(&nonce)
+	}
+	if args.Gas == nil {
+		gas := blockGas
+		if sim.budget < gas {
+			gas = sim.budget
+		}
+		args.Gas = (*hexutil.Uint64)(&gas)
+	} else {
+		if uint64(*args.Gas) > blockGas {
+			return fmt.Errorf("block gas limit reached: %d > %d", *args.Gas, blockGas)
+		}
+		if uint64(*args.Gas) > sim.budget {
+			return fmt.Errorf("simulation gas cap reached: %d > %d", *args.Gas, sim.budget)
+		}
+	}
+	if args.ChainID == nil {
+		args.ChainID = (*hexutil.Big)(sim.b.ChainConfig().ChainID)
+	}
+	if args.Value == nil {
+		args.Value = new(hexutil.Big)
+	}
+	// Fill in the fee fields the transaction type needs.
+	switch {
+	case args.GasPrice != nil:
+		if args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil {
+			return errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+		}
+	case header.BaseFee == nil:
+		args.GasPrice = new(hexutil.Big)
+	default:
+		if args.MaxFeePerGas == nil {
+			args.MaxFeePerGas = new(hexutil.Big)
+		}
+		if args.MaxPriorityFeePerGas == nil {
+			args.MaxPriorityFeePerGas = new(hexutil.Big)
+		}
+	}
+	if args.BlobHashes != nil {
+		if args.To == nil {
+			return errors.New("blob transactions can't create contracts")
+		}
+		if args.BlobFeeCap == nil {
+			args.BlobFeeCap = new(hexutil.Big)
+		}
+		if args.MaxFeePerGas == nil {
+			args.MaxFeePerGas, args.MaxPriorityFeePerGas = args.GasPrice, args.GasPrice
+			args.GasPrice = nil
+		}
+	}
+	return nil
+}
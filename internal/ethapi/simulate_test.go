@@ -0,0 +1,105 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestSimulateV1(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(3)
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		genBlocks = 4
+		api       = NewBlockChainAPI(newTestBackend(t, genBlocks, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+			b.SetPoS()
+		}))
+		value = func(v int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(v)) }
+
+		// Code reverting with the data 0xff.
+		revertCode = hexutil.Bytes{0x60, 0xff, 0x60, 0x00, 0x53, 0x60, 0x01, 0x60, 0x00, 0xfd}
+		reverter   = common.Address{0xaa}
+	)
+	// Funds are moved in a first block and forwarded in a gapped second one,
+	// along with a call reverting.
+	res, err := api.SimulateV1(context.Background(), simOpts{
+		BlockStateCalls: []simBlock{
+			{Calls: []TransactionArgs{{From: &accounts[0].addr, To: &accounts[1].addr, Value: value(1000)}}},
+			{
+				BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(int64(genBlocks + 4)))},
+				StateOverrides: &StateOverride{reverter: OverrideAccount{Code: &revertCode}},
+				Calls: []TransactionArgs{
+					{From: &accounts[1].addr, To: &accounts[2].addr, Value: value(600)},
+					{From: &accounts[0].addr, To: &reverter},
+				},
+			},
+		},
+		TraceTransfers: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("have %d blocks, want 4", len(res))
+	}
+	var parent common.Hash
+	for i, block := range res {
+		if have, want := uint64(block["number"].(*hexutil.Big).ToInt().Uint64()), uint64(genBlocks+1+i); have != want {
+			t.Errorf("block %d: have number %d, want %d", i, have, want)
+		}
+		if i > 0 && block["parentHash"].(common.Hash) != parent {
+			t.Errorf("block %d: parent hash mismatch", i)
+		}
+		parent = block["hash"].(common.Hash)
+	}
+	if calls := res[1]["calls"].([]simCallResult); len(calls) != 0 {
+		t.Errorf("gap block has %d calls", len(calls))
+	}
+	first := res[0]["calls"].([]simCallResult)
+	if len(first) != 1 || first[0].Status != 1 || len(first[0].Logs) != 1 {
+		t.Fatalf("unexpected transfer result: %+v", first)
+	}
+	if log := first[0].Logs[0]; log.Address != transferAddress || log.Topics[2] != common.BytesToHash(accounts[1].addr.Bytes()) || log.BlockHash != res[0]["hash"].(common.Hash) {
+		t.Errorf("unexpected transfer log: %+v", log)
+	}
+	last := res[3]["calls"].([]simCallResult)
+	if len(last) != 2 {
+		t.Fatalf("have %d calls, want 2", len(last))
+	}
+	if last[0].Status != 1 || len(last[0].Logs) != 1 {
+		t.Errorf("forwarding the transferred funds failed: %+v", last[0])
+	}
+	if last[1].Status != 0 || last[1].Error == nil || last[1].Error.Code != errCodeReverted || last[1].Error.Data != "0xff" {
+		t.Errorf("unexpected revert result: %+v", last[1])
+	}
+	// Validation rejects a wrong nonce, which is fine otherwise.
+	wrongNonce := hexutil.Uint64(5)
+	opts := simOpts{BlockStateCalls: []simBlock{{Calls: []TransactionArgs{{From: &accounts[0].addr, To: &accounts[1].addr, Nonce: &wrongNonce}}}}}
+	if _, err := api.SimulateV1(context.Background(), opts, nil); err != nil {
+		t.Errorf("simulation without validation failed: %v", err)
+	}
+	opts.Validation = true
+	if _, err := api.SimulateV1(context.Background(), opts, nil); err == nil {
+		t.Error("simulation with validation accepted a wrong nonce")
+	}
+	// Block numbers must increase.
+	opts = simOpts{BlockStateCalls: []simBlock{{BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(int64(genBlocks)))}}}}
+	if _, err := api.SimulateV1(context.Background(), opts, nil); err == nil {
+		t.Error("simulation accepted a block number below the base")
+	}
+}
@@ -211,6 +211,10 @@ type Config struct {
 	EnablePersonal bool `toml:"-"`
 
 	DBEngine string `toml:",omitempty"`
+
+	// DBAncientEngine is the plugin provided engine storing the ancient chain
+	// segments, the flat file freezer is used if empty.
+	DBAncientEngine string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
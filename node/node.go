@@ -761,6 +761,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 			Type:              n.config.DBEngine,
 			Directory:         n.ResolvePath(name),
 			AncientsDirectory: n.ResolveAncient(name, ancient),
+			AncientEngine:     n.config.DBAncientEngine, // PluGeth injection
 			Namespace:         namespace,
 			Cache:             cache,
 			Handles:           handles,
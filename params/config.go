@@ -480,41 +480,81 @@ func (c *ChainConfig) Description() string {
 
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("homestead", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.HomesteadBlock, num)
 }
 
 // IsDAOFork returns whether num is either equal to the DAO fork block or greater.
 func (c *ChainConfig) IsDAOFork(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("daoFork", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.DAOForkBlock, num)
 }
 
 // IsEIP150 returns whether num is either equal to the EIP150 fork block or greater.
 func (c *ChainConfig) IsEIP150(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("eip150", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.EIP150Block, num)
 }
 
 // IsEIP155 returns whether num is either equal to the EIP155 fork block or greater.
 func (c *ChainConfig) IsEIP155(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("eip155", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.EIP155Block, num)
 }
 
 // IsEIP158 returns whether num is either equal to the EIP158 fork block or greater.
 func (c *ChainConfig) IsEIP158(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("eip158", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.EIP158Block, num)
 }
 
 // IsByzantium returns whether num is either equal to the Byzantium fork block or greater.
 func (c *ChainConfig) IsByzantium(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("byzantium", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.ByzantiumBlock, num)
 }
 
 // IsConstantinople returns whether num is either equal to the Constantinople fork block or greater.
 func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("constantinople", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.ConstantinopleBlock, num)
 }
 
 // IsMuirGlacier returns whether num is either equal to the Muir Glacier (EIP-2384) fork block or greater.
 func (c *ChainConfig) IsMuirGlacier(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("muirGlacier", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.MuirGlacierBlock, num)
 }
 
@@ -522,31 +562,61 @@ func (c *ChainConfig) IsMuirGlacier(num *big.Int) bool {
 // - equal to or greater than the PetersburgBlock fork block,
 // - OR is nil, and Constantinople is active
 func (c *ChainConfig) IsPetersburg(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("petersburg", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.PetersburgBlock, num) || c.PetersburgBlock == nil && isBlockForked(c.ConstantinopleBlock, num)
 }
 
 // IsIstanbul returns whether num is either equal to the Istanbul fork block or greater.
 func (c *ChainConfig) IsIstanbul(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("istanbul", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.IstanbulBlock, num)
 }
 
 // IsBerlin returns whether num is either equal to the Berlin fork block or greater.
 func (c *ChainConfig) IsBerlin(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("berlin", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.BerlinBlock, num)
 }
 
 // IsLondon returns whether num is either equal to the London fork block or greater.
 func (c *ChainConfig) IsLondon(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("london", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.LondonBlock, num)
 }
 
 // IsArrowGlacier returns whether num is either equal to the Arrow Glacier (EIP-4345) fork block or greater.
 func (c *ChainConfig) IsArrowGlacier(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("arrowGlacier", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.ArrowGlacierBlock, num)
 }
 
 // IsGrayGlacier returns whether num is either equal to the Gray Glacier (EIP-5133) fork block or greater.
 func (c *ChainConfig) IsGrayGlacier(num *big.Int) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("grayGlacier", num, 0); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return isBlockForked(c.GrayGlacierBlock, num)
 }
 
@@ -569,16 +639,31 @@ func (c *ChainConfig) IsTerminalPoWBlock(parentTotalDiff *big.Int, totalDiff *bi
 
 // IsCancun returns whether num is either equal to the Cancun fork time or greater.
 func (c *ChainConfig) IsCancun(num *big.Int, time uint64) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("cancun", num, time); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return c.IsLondon(num) && isTimestampForked(c.CancunTime, time)
 }
 
 // IsPrague returns whether num is either equal to the Prague fork time or greater.
 func (c *ChainConfig) IsPrague(num *big.Int, time uint64) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("prague", num, time); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return c.IsLondon(num) && isTimestampForked(c.PragueTime, time)
 }
 
 // IsVerkle returns whether num is either equal to the Verkle fork time or greater.
 func (c *ChainConfig) IsVerkle(num *big.Int, time uint64) bool {
+	// begin PluGeth code injection
+	if active, ok := pluginForkRule("verkle", num, time); ok {
+		return active
+	}
+	// end PluGeth code injection
 	return c.IsLondon(num) && isTimestampForked(c.VerkleTime, time)
 }
 
@@ -918,9 +1003,14 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
+	// begin PluGeth injection
+	if active, ok := pluginForkRule("merge", num, timestamp); ok {
+		isMerge = active
+	}
+	// end PluGeth injection
 	// disallow setting Merge out of order
 	isMerge = isMerge && c.IsLondon(num)
-	return Rules{
+	rules := Rules{
 		ChainID:          new(big.Int).Set(chainID),
 		IsHomestead:      c.IsHomestead(num),
 		IsEIP150:         c.IsEIP150(num),
@@ -947,4 +1037,17 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 		// End plugeth injection
 
 	}
+	// begin PluGeth injection
+	// Rules overridden by a plugin apply whether or not the merge happened
+	if _, ok := pluginForkRule("cancun", num, timestamp); ok {
+		rules.IsCancun = c.IsCancun(num, timestamp)
+	}
+	if _, ok := pluginForkRule("prague", num, timestamp); ok {
+		rules.IsPrague = c.IsPrague(num, timestamp)
+	}
+	if _, ok := pluginForkRule("verkle", num, timestamp); ok {
+		rules.IsVerkle = c.IsVerkle(num, timestamp)
+	}
+	// end PluGeth injection
+	return rules
 }
@@ -1,7 +1,14 @@
 package params
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/plugins"
@@ -9,6 +16,9 @@ import (
 
 // Is1559 returns whether num is either equal to the London fork block or greater, if the chain supports EIP1559
 func (c *ChainConfig) Is1559(num *big.Int) bool {
+	if active, ok := pluginForkRule("eip1559", num, 0); ok {
+		return active
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting is1559, but default PluginLoader has not been initialized")
 		return c.IsLondon(num)
@@ -30,6 +40,9 @@ func PluginEIPCheck(pl *plugins.PluginLoader, eipHookName string, num *big.Int)
 // IsEIP160 returns whether num is either equal to the EIP160 block or greater. 
 // This defaults to same as 158, but some chains do it at a different block
 func (c *ChainConfig) IsEIP160(num *big.Int) bool {
+	if active, ok := pluginForkRule("eip160", num, 0); ok {
+		return active
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting is160, but default PluginLoader has not been initialized")
 		return c.IsEIP158(num)
@@ -43,6 +56,9 @@ func (c *ChainConfig) IsEIP160(num *big.Int) bool {
 // IsShanghai is modified here to return whether num is either equal to the Shanghai fork block or greater, if the chain supports Shanghai
 // the foundation implementation has been commented out
 func (c *ChainConfig) IsShanghai(num *big.Int, time uint64) bool {
+	if active, ok := pluginForkRule("shanghai", num, time); ok {
+		return active
+	}
 	if plugins.DefaultPluginLoader == nil {
 		log.Warn("Attempting isPluginShanghai, but default PluginLoader has not been initialized")
 		return c.IsLondon(num) && isTimestampForked(c.ShanghaiTime, time)
@@ -51,4 +67,358 @@ func (c *ChainConfig) IsShanghai(num *big.Int, time uint64) bool {
 		return active
 	}
 	return c.IsLondon(num) && isTimestampForked(c.ShanghaiTime, time)
-}
\ No newline at end of file
+}
+// ForkActivation schedules a fork rule at a block number or a timestamp. A
+// rule with neither is never active.
+type ForkActivation struct {
+	Block *uint64 `json:"block,omitempty"`
+	Time  *uint64 `json:"time,omitempty"`
+}
+
+// active reports whether the rule is active at the given block and time.
+func (f ForkActivation) active(num *big.Int, time uint64) bool {
+	switch {
+	case f.Block != nil:
+		return num != nil && num.IsUint64() && num.Uint64() >= *f.Block
+	case f.Time != nil:
+		return time >= *f.Time
+	}
+	return false
+}
+
+// ChainSpec is the complete definition of a chain, provided by a plugin as
+// JSON through the ChainSpec hook. It takes precedence over the single purpose
+// GenesisBlock, SetNetworkId, SetBootstrapNodes, SetETHDiscoveryURLs,
+// SetSnapDiscoveryURLs, Is1559, Is160 and IsShanghai hooks. Fields left empty
+// fall back to those hooks and to the defaults.
+//
+// Forks maps fork rule names, as listed in ForkRules, to their activation. A
+// scheduled rule overrides the corresponding ChainConfig.Is* method, whatever
+// the chain config says.
+type ChainSpec struct {
+	Genesis           json.RawMessage           `json:"genesis,omitempty"`
+	NetworkID         *uint64                   `json:"networkId,omitempty"`
+	Bootnodes         []string                  `json:"bootnodes,omitempty"`
+	EthDiscoveryURLs  []string                  `json:"ethDiscoveryURLs,omitempty"`
+	SnapDiscoveryURLs []string                  `json:"snapDiscoveryURLs,omitempty"`
+	Forks             map[string]ForkActivation `json:"forks,omitempty"`
+}
+
+// ForkRules lists the fork rules plugins can override, through the ChainSpec
+// or the ForkRule hook, in the order they must activate, with the ChainConfig
+// field holding each of them. Rules without a field are PluGeth specific. The
+// ForkRule hook can additionally override "merge", which is not scheduled by
+// block or time.
+var ForkRules = []struct {
+	Name  string
+	Field string
+}{
+	{"homestead", "HomesteadBlock"},
+	{"daoFork", "DAOForkBlock"},
+	{"eip150", "EIP150Block"},
+	{"eip155", "EIP155Block"},
+	{"eip158", "EIP158Block"},
+	{"eip160", ""},
+	{"byzantium", "ByzantiumBlock"},
+	{"constantinople", "ConstantinopleBlock"},
+	{"petersburg", "PetersburgBlock"},
+	{"istanbul", "IstanbulBlock"},
+	{"muirGlacier", "MuirGlacierBlock"},
+	{"berlin", "BerlinBlock"},
+	{"london", "LondonBlock"},
+	{"eip1559", ""},
+	{"arrowGlacier", "ArrowGlacierBlock"},
+	{"grayGlacier", "GrayGlacierBlock"},
+	{"shanghai", "ShanghaiTime"},
+	{"cancun", "CancunTime"},
+	{"prague", "PragueTime"},
+	{"verkle", "VerkleTime"},
+}
+
+// Validate checks that the fork schedule only names known rules, that block
+// based rules are scheduled by block, and that the rules activate in order.
+// The dao fork and the PluGeth specific rules may activate at any point.
+func (spec *ChainSpec) Validate() error {
+	known := make(map[string]bool)
+	for _, rule := range ForkRules {
+		known[rule.Name] = true
+	}
+	for name, fork := range spec.Forks {
+		if !known[name] {
+			return fmt.Errorf("unknown fork rule %q", name)
+		}
+		if fork.Block != nil && fork.Time != nil {
+			return fmt.Errorf("fork rule %q is scheduled by both block and time", name)
+		}
+	}
+	for _, rule := range ForkRules {
+		if fork, ok := spec.Forks[rule.Name]; ok && fork.Time != nil && !strings.HasSuffix(rule.Field, "Time") {
+			return fmt.Errorf("fork rule %q can only be scheduled by block", rule.Name)
+		}
+	}
+	var (
+		lastBlock, lastTime *uint64
+		lastName            string
+	)
+	for _, rule := range ForkRules {
+		fork, ok := spec.Forks[rule.Name]
+		if !ok || rule.Field == "" || rule.Name == "daoFork" {
+			continue
+		}
+		switch {
+		case fork.Block != nil:
+			if lastTime != nil {
+				return fmt.Errorf("fork rule %q scheduled by block after time based %q", rule.Name, lastName)
+			}
+			if lastBlock != nil && *fork.Block < *lastBlock {
+				return fmt.Errorf("fork rule %q at block %d before %q at block %d", rule.Name, *fork.Block, lastName, *lastBlock)
+			}
+			lastBlock, lastName = fork.Block, rule.Name
+		case fork.Time != nil:
+			if lastTime != nil && *fork.Time < *lastTime {
+				return fmt.Errorf("fork rule %q at time %d before %q at time %d", rule.Name, *fork.Time, lastName, *lastTime)
+			}
+			lastTime, lastName = fork.Time, rule.Name
+		}
+	}
+	if len(spec.Genesis) > 0 {
+		var genesis struct {
+			Config *ChainConfig `json:"config"`
+		}
+		if err := json.Unmarshal(spec.Genesis, &genesis); err != nil {
+			return fmt.Errorf("invalid genesis: %w", err)
+		}
+		if genesis.Config != nil {
+			if err := spec.Apply(genesis.Config).CheckConfigForkOrder(); err != nil {
+				return fmt.Errorf("fork schedule conflicts with the genesis config: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of config with the scheduled forks written into their
+// ChainConfig fields, so code reading the fields directly, like the fork ID
+// calculation, agrees with the overridden Is* methods.
+func (spec *ChainSpec) Apply(config *ChainConfig) *ChainConfig {
+	cpy := *config
+	v := reflect.ValueOf(&cpy).Elem()
+	for _, rule := range ForkRules {
+		fork, ok := spec.Forks[rule.Name]
+		if !ok || rule.Field == "" {
+			continue
+		}
+		field := v.FieldByName(rule.Field)
+		switch {
+		case strings.HasSuffix(rule.Field, "Block"):
+			var block *big.Int
+			if fork.Block != nil {
+				block = new(big.Int).SetUint64(*fork.Block)
+			}
+			field.Set(reflect.ValueOf(block))
+		default:
+			// Time based rules scheduled by block are reported by ExtraForks
+			field.Set(reflect.ValueOf(fork.Time))
+		}
+	}
+	return &cpy
+}
+
+// ExtraForks returns the block activations that have no ChainConfig field to
+// be written into by Apply, namely the PluGeth specific rules and time based
+// rules scheduled by block.
+func (spec *ChainSpec) ExtraForks() []uint64 {
+	var byBlock []uint64
+	for _, rule := range ForkRules {
+		fork, ok := spec.Forks[rule.Name]
+		if !ok || fork.Block == nil || strings.HasSuffix(rule.Field, "Block") {
+			continue
+		}
+		byBlock = append(byBlock, *fork.Block)
+	}
+	return byBlock
+}
+
+var chainSpecCache struct {
+	sync.Mutex
+	pl       *plugins.PluginLoader
+	spec     *ChainSpec
+	err      error
+	reported bool
+}
+
+// PluginChainSpec returns the chain spec provided by the ChainSpec hook, or
+// nil if no plugin provides one. The spec is decoded and validated once per
+// plugin loader.
+func PluginChainSpec(pl *plugins.PluginLoader) (*ChainSpec, error) {
+	chainSpecCache.Lock()
+	defer chainSpecCache.Unlock()
+	if chainSpecCache.pl == pl {
+		return chainSpecCache.spec, chainSpecCache.err
+	}
+	chainSpecCache.pl, chainSpecCache.spec, chainSpecCache.err, chainSpecCache.reported = pl, nil, nil, false
+	fn, ok := plugins.LookupOne[func() []byte](pl, "ChainSpec")
+	if !ok {
+		return nil, nil
+	}
+	spec := new(ChainSpec)
+	if err := json.Unmarshal(fn(), spec); err != nil {
+		chainSpecCache.err = fmt.Errorf("invalid chain spec: %w", err)
+	} else if err := spec.Validate(); err != nil {
+		chainSpecCache.err = fmt.Errorf("invalid chain spec: %w", err)
+	} else {
+		chainSpecCache.spec = spec
+	}
+	return chainSpecCache.spec, chainSpecCache.err
+}
+
+// pluginChainSpec returns the chain spec of the default plugin loader. An
+// invalid spec is reported and ignored here, the node refuses to start with
+// it when the configuration is assembled.
+func pluginChainSpec() *ChainSpec {
+	if plugins.DefaultPluginLoader == nil {
+		// The fork rules are checked all the time, including in tools
+		// which never load plugins, so don't warn here.
+		return nil
+	}
+	spec, err := PluginChainSpec(plugins.DefaultPluginLoader)
+	if err != nil {
+		chainSpecCache.Lock()
+		if !chainSpecCache.reported {
+			log.Error("Ignoring plugin chain spec", "err", err)
+			chainSpecCache.reported = true
+		}
+		chainSpecCache.Unlock()
+	}
+	return spec
+}
+
+// PluginForkRule consults the ForkRule hook, which overrides any fork rule
+// listed in ForkRules at a given block and time. The hook returns false as its
+// second value for the rules it leaves alone. Overrides must be monotonic, a
+// rule which is active at a block and time stays active after them.
+func PluginForkRule(pl *plugins.PluginLoader, name string, num *big.Int, time uint64) (bool, bool) {
+	fn, ok := plugins.LookupOne[func(string, *big.Int, uint64) (bool, bool)](pl, "ForkRule")
+	if !ok {
+		return false, false
+	}
+	return fn(name, num, time)
+}
+
+// forkRuleSource holds what the fork rules of a plugin loader are resolved
+// from, looked up once as the rules are checked all the time.
+type forkRuleSource struct {
+	pl   *plugins.PluginLoader
+	hook func(string, *big.Int, uint64) (bool, bool)
+	spec *ChainSpec
+}
+
+var forkRuleSourceCache atomic.Pointer[forkRuleSource]
+
+// pluginForkRuleSource returns the fork rule source of the default plugin
+// loader, or nil if no loader is initialized.
+func pluginForkRuleSource() *forkRuleSource {
+	pl := plugins.DefaultPluginLoader
+	if pl == nil {
+		return nil
+	}
+	if src := forkRuleSourceCache.Load(); src != nil && src.pl == pl {
+		return src
+	}
+	src := &forkRuleSource{pl: pl, spec: pluginChainSpec()}
+	src.hook, _ = plugins.LookupOne[func(string, *big.Int, uint64) (bool, bool)](pl, "ForkRule")
+	forkRuleSourceCache.Store(src)
+	return src
+}
+
+// pluginForkRule returns whether the named fork rule is active at the given
+// block and time, and whether a plugin overrides it at all. The ForkRule hook
+// takes precedence over the schedule of the chain spec.
+func pluginForkRule(name string, num *big.Int, time uint64) (bool, bool) {
+	src := pluginForkRuleSource()
+	if src == nil {
+		return false, false
+	}
+	if src.hook != nil {
+		if active, ok := src.hook(name, num, time); ok {
+			return active, true
+		}
+	}
+	if src.spec == nil {
+		return false, false
+	}
+	fork, ok := src.spec.Forks[name]
+	if !ok {
+		return false, false
+	}
+	return fork.active(num, time), true
+}
+
+// probeForkRule finds the activation of a rule overridden by the ForkRule
+// hook, by bisecting over block numbers, or timestamps if the rule doesn't
+// activate by block alone.
+func probeForkRule(pl *plugins.PluginLoader, name string) (ForkActivation, bool) {
+	far := new(big.Int).SetUint64(math.MaxInt64)
+	if _, ok := PluginForkRule(pl, name, far, math.MaxInt64); !ok {
+		return ForkActivation{}, false
+	}
+	search := func(active func(uint64) bool) *uint64 {
+		lo, hi := uint64(0), uint64(math.MaxInt64)
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			if active(mid) {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+		return &lo
+	}
+	if active, _ := PluginForkRule(pl, name, far, 0); active {
+		return ForkActivation{Block: search(func(n uint64) bool {
+			active, _ := PluginForkRule(pl, name, new(big.Int).SetUint64(n), 0)
+			return active
+		})}, true
+	}
+	if active, _ := PluginForkRule(pl, name, far, math.MaxInt64); active {
+		return ForkActivation{Time: search(func(t uint64) bool {
+			active, _ := PluginForkRule(pl, name, far, t)
+			return active
+		})}, true
+	}
+	return ForkActivation{}, true
+}
+
+var forkScheduleCache struct {
+	sync.Mutex
+	pl   *plugins.PluginLoader
+	spec *ChainSpec
+}
+
+// PluginForkSchedule returns the fork schedule resulting from the chain spec
+// and the ForkRule hook, or nil if no plugin overrides any fork rule. The
+// activations of the rules overridden by the hook are found by probing it, so
+// the fork ID can be derived from them.
+func PluginForkSchedule(pl *plugins.PluginLoader) *ChainSpec {
+	forkScheduleCache.Lock()
+	defer forkScheduleCache.Unlock()
+	if forkScheduleCache.pl == pl {
+		return forkScheduleCache.spec
+	}
+	schedule := &ChainSpec{Forks: make(map[string]ForkActivation)}
+	if spec, err := PluginChainSpec(pl); spec != nil && err == nil {
+		for name, fork := range spec.Forks {
+			schedule.Forks[name] = fork
+		}
+	}
+	for _, rule := range ForkRules {
+		if fork, ok := probeForkRule(pl, rule.Name); ok {
+			schedule.Forks[rule.Name] = fork
+		}
+	}
+	if len(schedule.Forks) == 0 {
+		schedule = nil
+	}
+	forkScheduleCache.pl, forkScheduleCache.spec = pl, schedule
+	return schedule
+}
@@ -0,0 +1,131 @@
+package params
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/plugins"
+)
+
+func u64(n uint64) *uint64 { return &n }
+
+func TestChainSpecForkRules(t *testing.T) {
+	spec := &ChainSpec{
+		Forks: map[string]ForkActivation{
+			"london":   {Block: u64(100)},
+			"eip1559":  {Block: u64(200)},
+			"shanghai": {Block: u64(300)},
+			"cancun":   {},
+		},
+	}
+	blob, _ := json.Marshal(spec)
+	done := plugins.HookTester("ChainSpec", func() []byte { return blob })
+	defer done()
+
+	config := *AllEthashProtocolChanges
+	config.CancunTime = u64(0)
+	for _, test := range []struct {
+		name string
+		have bool
+		want bool
+	}{
+		{"london before", config.IsLondon(big.NewInt(99)), false},
+		{"london at", config.IsLondon(big.NewInt(100)), true},
+		{"eip1559 before", config.Is1559(big.NewInt(150)), false},
+		{"eip1559 at", config.Is1559(big.NewInt(200)), true},
+		{"shanghai by block", config.IsShanghai(big.NewInt(300), 0), true},
+		{"cancun disabled", config.IsCancun(big.NewInt(1000), 1000), false},
+		{"berlin unscheduled", config.IsBerlin(big.NewInt(0)), true},
+		{"rules", config.Rules(big.NewInt(150), false, 0).IsLondon, true},
+	} {
+		if test.have != test.want {
+			t.Errorf("%s: have %v, want %v", test.name, test.have, test.want)
+		}
+	}
+	applied := spec.Apply(&config)
+	if applied.LondonBlock.Uint64() != 100 || applied.CancunTime != nil || applied.ShanghaiTime != nil {
+		t.Errorf("schedule not applied to config fields: %v", applied)
+	}
+	if extra := spec.ExtraForks(); len(extra) != 2 || extra[0] != 200 || extra[1] != 300 {
+		t.Errorf("unexpected extra forks %v", extra)
+	}
+}
+
+func TestChainSpecValidation(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		spec  ChainSpec
+		valid bool
+	}{
+		{"empty", ChainSpec{}, true},
+		{"ordered", ChainSpec{Forks: map[string]ForkActivation{"berlin": {Block: u64(1)}, "london": {Block: u64(2)}, "shanghai": {Time: u64(10)}, "cancun": {Time: u64(20)}}}, true},
+		{"unknown", ChainSpec{Forks: map[string]ForkActivation{"atlantis": {Block: u64(1)}}}, false},
+		{"both", ChainSpec{Forks: map[string]ForkActivation{"shanghai": {Block: u64(1), Time: u64(1)}}}, false},
+		{"block rule by time", ChainSpec{Forks: map[string]ForkActivation{"london": {Time: u64(1)}}}, false},
+		{"plugeth rule by time", ChainSpec{Forks: map[string]ForkActivation{"eip160": {Time: u64(1)}}}, false},
+		{"block order", ChainSpec{Forks: map[string]ForkActivation{"berlin": {Block: u64(2)}, "london": {Block: u64(1)}}}, false},
+		{"time order", ChainSpec{Forks: map[string]ForkActivation{"shanghai": {Time: u64(2)}, "cancun": {Time: u64(1)}}}, false},
+		{"block after time", ChainSpec{Forks: map[string]ForkActivation{"shanghai": {Time: u64(2)}, "cancun": {Block: u64(1)}}}, false},
+		{"plugeth rules unordered", ChainSpec{Forks: map[string]ForkActivation{"london": {Block: u64(5)}, "eip1559": {Block: u64(1)}, "eip160": {Block: u64(9)}}}, true},
+		{"genesis conflict", ChainSpec{
+			Genesis: json.RawMessage(`{"config":{"chainId":1,"homesteadBlock":0,"eip150Block":0,"eip155Block":0,"eip158Block":0,"byzantiumBlock":10}}`),
+			Forks:   map[string]ForkActivation{"eip158": {Block: u64(20)}},
+		}, false},
+	} {
+		if err := test.spec.Validate(); (err == nil) != test.valid {
+			t.Errorf("%s: have error %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+
+	done := plugins.HookTester("ChainSpec", func() []byte { return []byte(`{"forks":{"atlantis":{"block":1}}}`) })
+	defer done()
+	if _, err := PluginChainSpec(plugins.DefaultPluginLoader); err == nil {
+		t.Error("invalid plugin chain spec accepted")
+	}
+	// Invalid specs don't override any rule.
+	if !AllEthashProtocolChanges.IsLondon(big.NewInt(0)) {
+		t.Error("invalid chain spec applied")
+	}
+}
+
+func TestForkRuleHook(t *testing.T) {
+	done := plugins.HookTester("ForkRule", func(name string, num *big.Int, time uint64) (bool, bool) {
+		switch name {
+		case "berlin":
+			return num.Uint64() >= 50, true
+		case "cancun":
+			return time >= 1000, true
+		case "merge":
+			return true, true
+		}
+		return false, false
+	})
+	defer done()
+
+	config := *AllEthashProtocolChanges
+	if config.IsBerlin(big.NewInt(49)) || !config.IsBerlin(big.NewInt(50)) {
+		t.Error("berlin not overridden")
+	}
+	if !config.IsLondon(big.NewInt(0)) {
+		t.Error("london overridden")
+	}
+	rules := config.Rules(big.NewInt(100), false, 1000)
+	if !rules.IsMerge || !rules.IsCancun || !rules.IsBerlin {
+		t.Errorf("overrides missing from rules %+v", rules)
+	}
+	if rules := config.Rules(big.NewInt(10), false, 999); rules.IsCancun || rules.IsBerlin {
+		t.Errorf("rules active too early %+v", rules)
+	}
+
+	schedule := PluginForkSchedule(plugins.DefaultPluginLoader)
+	if berlin := schedule.Forks["berlin"]; berlin.Block == nil || *berlin.Block != 50 {
+		t.Errorf("have berlin activation %+v, want block 50", berlin)
+	}
+	if cancun := schedule.Forks["cancun"]; cancun.Time == nil || *cancun.Time != 1000 {
+		t.Errorf("have cancun activation %+v, want time 1000", cancun)
+	}
+	if _, ok := schedule.Forks["london"]; ok {
+		t.Error("london not overridden but scheduled")
+	}
+}
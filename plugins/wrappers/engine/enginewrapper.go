@@ -11,7 +11,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/plugins/wrappers"
 	"github.com/openrelayxyz/plugeth-utils/core"
 	ptypes "github.com/openrelayxyz/plugeth-utils/restricted/types"
 	pconsensus "github.com/openrelayxyz/plugeth-utils/restricted/consensus"
@@ -39,6 +38,9 @@ func gethToUtilsHeader(header *types.Header) *ptypes.Header {
 		Nonce: ptypes.BlockNonce(header.Nonce),
 		BaseFee: header.BaseFee,
 		WithdrawalsHash: (*core.Hash)(header.WithdrawalsHash),
+		BlobGasUsed: header.BlobGasUsed,
+		ExcessBlobGas: header.ExcessBlobGas,
+		ParentBeaconRoot: (*core.Hash)(header.ParentBeaconRoot),
 	}
 }
 func utilsToGethHeader(header *ptypes.Header) *types.Header {
@@ -61,6 +63,9 @@ func utilsToGethHeader(header *ptypes.Header) *types.Header {
 		Nonce: types.BlockNonce(header.Nonce),
 		BaseFee: header.BaseFee,
 		WithdrawalsHash: (*common.Hash)(header.WithdrawalsHash),
+		BlobGasUsed: header.BlobGasUsed,
+		ExcessBlobGas: header.ExcessBlobGas,
+		ParentBeaconRoot: (*common.Hash)(header.ParentBeaconRoot),
 	}
 }
 
@@ -71,7 +76,7 @@ func gethToUtilsTransactions(transactions []*types.Transaction) []*ptypes.Transa
 		bin, err := tx.MarshalBinary()
 		if err != nil { panic (err) }
 		txs[i] = &ptypes.Transaction{}
-		txs[i].UnmarshalBinary(bin)
+		if err := txs[i].UnmarshalBinary(bin); err != nil { panic (err) }
 	}
 	return txs
 }
@@ -85,6 +90,10 @@ func gethToUtilsHeaders(headers []*types.Header) []*ptypes.Header {
 	return pheaders
 }
 
+// gethToUtilsReceipts converts receipts for plugins. BlobGasUsed and
+// BlobGasPrice have no counterpart in the plugeth-utils receipt, they follow
+// from the blob hashes of the transaction and the excess blob gas of the
+// header. Receipts are only handed to plugins, never converted back.
 func gethToUtilsReceipts(receipts []*types.Receipt) []*ptypes.Receipt {
 	if receipts == nil { return nil }
 	preceipts := make([]*ptypes.Receipt, len(receipts))
@@ -99,6 +108,7 @@ func gethToUtilsReceipts(receipts []*types.Receipt) []*ptypes.Receipt {
 			TxHash: core.Hash(receipt.TxHash),
 			ContractAddress: core.Address(receipt.ContractAddress),
 			GasUsed: receipt.GasUsed,
+			EffectiveGasPrice: receipt.EffectiveGasPrice,
 			BlockHash: core.Hash(receipt.BlockHash),
 			BlockNumber: receipt.BlockNumber,
 			TransactionIndex: receipt.TransactionIndex,
@@ -147,7 +157,7 @@ func utilsToGethTransactions(transactions []*ptypes.Transaction) []*types.Transa
 		bin, err := tx.MarshalBinary()
 		if err != nil { panic (err) }
 		txs[i] = &types.Transaction{}
-		txs[i].UnmarshalBinary(bin)
+		if err := txs[i].UnmarshalBinary(bin); err != nil { panic (err) }
 	}
 	return txs
 }
@@ -396,10 +406,10 @@ func (ew *engineWrapper) Prepare(chain consensus.ChainHeaderReader, header *type
 	return nil
 }
 func (ew *engineWrapper) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, withdrawals []*types.Withdrawal) {
-	ew.engine.Finalize(&WrappedHeaderReader{chain, nil}, gethToUtilsHeader(header), wrappers.NewWrappedStateDB(state), gethToUtilsTransactions(txs), gethToUtilsHeaders(uncles), gethToUtilsWithdrawals(withdrawals))
+	ew.engine.Finalize(&WrappedHeaderReader{chain, nil}, gethToUtilsHeader(header), newEngineStateDB(chain, ew, header, state, len(txs)), gethToUtilsTransactions(txs), gethToUtilsHeaders(uncles), gethToUtilsWithdrawals(withdrawals))
 }
 func (ew *engineWrapper) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt, withdrawals []*types.Withdrawal) (*types.Block, error) {
-	block, err := ew.engine.FinalizeAndAssemble(&WrappedHeaderReader{chain, nil}, gethToUtilsHeader(header), wrappers.NewWrappedStateDB(state), gethToUtilsTransactions(txs), gethToUtilsHeaders(uncles), gethToUtilsReceipts(receipts), gethToUtilsWithdrawals(withdrawals))
+	block, err := ew.engine.FinalizeAndAssemble(&WrappedHeaderReader{chain, nil}, gethToUtilsHeader(header), newEngineStateDB(chain, ew, header, state, len(txs)), gethToUtilsTransactions(txs), gethToUtilsHeaders(uncles), gethToUtilsReceipts(receipts), gethToUtilsWithdrawals(withdrawals))
 	return utilsToGethBlock(block), err
 }
 func (ew *engineWrapper) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
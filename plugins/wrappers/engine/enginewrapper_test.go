@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	ptypes "github.com/openrelayxyz/plugeth-utils/restricted/types"
+)
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+// fill sets every exported field reachable from v to a non-zero value, so a
+// round trip through the conversion functions only succeeds if each field is
+// carried. Fields added upstream are picked up automatically.
+func fill(v reflect.Value, seed *byte) {
+	*seed++
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type().Elem() == bigIntType {
+			v.Set(reflect.ValueOf(big.NewInt(int64(*seed))))
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), seed)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), seed)
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), seed)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), seed)
+		}
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*seed))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*seed))
+	case reflect.String:
+		v.SetString(string(rune('a' + *seed%26)))
+	default:
+		panic("unsupported field kind " + v.Kind().String())
+	}
+}
+
+func filled[T any]() *T {
+	var seed byte
+	v := new(T)
+	fill(reflect.ValueOf(v).Elem(), &seed)
+	return v
+}
+
+// fieldNames returns the exported field names of a struct type.
+func fieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			names[t.Field(i).Name] = true
+		}
+	}
+	return names
+}
+
+func TestHeaderConversion(t *testing.T) {
+	header := filled[types.Header]()
+	pheader := gethToUtilsHeader(header)
+	if have, want := common.Hash(pheader.Hash()), header.Hash(); have != want {
+		t.Errorf("have plugin header hash %x, want %x", have, want)
+	}
+	if have := utilsToGethHeader(pheader); !reflect.DeepEqual(have, header) {
+		t.Errorf("header changed in round trip\nhave %+v\nwant %+v", have, header)
+	}
+}
+
+func TestReceiptConversion(t *testing.T) {
+	// The plugeth-utils receipt has no blob gas fields. If it gets them, they
+	// must be carried.
+	pfields := fieldNames(reflect.TypeOf(ptypes.Receipt{}))
+	for _, name := range []string{"BlobGasUsed", "BlobGasPrice"} {
+		if pfields[name] {
+			t.Errorf("plugeth-utils receipt field %s is not converted", name)
+		}
+	}
+	preceipt := reflect.ValueOf(gethToUtilsReceipts([]*types.Receipt{filled[types.Receipt]()})[0]).Elem()
+	for name := range pfields {
+		if preceipt.FieldByName(name).IsZero() {
+			t.Errorf("plugeth-utils receipt field %s is not set", name)
+		}
+	}
+}
+
+func TestLogConversion(t *testing.T) {
+	log := filled[types.Log]()
+	if have := utilsToGethLog(gethToUtilsLog(log)); !reflect.DeepEqual(have, log) {
+		t.Errorf("log changed in round trip\nhave %+v\nwant %+v", have, log)
+	}
+}
+
+func TestWithdrawalConversion(t *testing.T) {
+	withdrawal := filled[types.Withdrawal]()
+	have := utilsToGethWithdrawals(gethToUtilsWithdrawals([]*types.Withdrawal{withdrawal}))
+	if !reflect.DeepEqual(have[0], withdrawal) {
+		t.Errorf("withdrawal changed in round trip\nhave %+v\nwant %+v", have[0], withdrawal)
+	}
+}
+
+func TestBlockConversion(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.NewCancunSigner(params.TestChainConfig.ChainID)
+	var (
+		blob        kzg4844.Blob
+		commitment  = must(kzg4844.BlobToCommitment(blob))
+		proof       = must(kzg4844.ComputeBlobProof(blob, commitment))
+		to          = common.HexToAddress("0x01")
+		chainID     = uint256.MustFromBig(params.TestChainConfig.ChainID)
+		unsignedTxs = []types.TxData{
+			&types.LegacyTx{Nonce: 0, To: &to, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1)},
+			&types.AccessListTx{ChainID: params.TestChainConfig.ChainID, Nonce: 1, Gas: 30000, GasPrice: big.NewInt(1), AccessList: types.AccessList{{Address: to, StorageKeys: []common.Hash{{1}}}}},
+			&types.DynamicFeeTx{ChainID: params.TestChainConfig.ChainID, Nonce: 2, To: &to, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Data: []byte{1, 2}},
+			&types.BlobTx{ChainID: chainID, Nonce: 3, To: to, Gas: 21000, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), BlobFeeCap: uint256.NewInt(3),
+				BlobHashes: []common.Hash{kzg4844.CalcBlobHashV1(sha256.New(), &commitment)},
+				Sidecar:    &types.BlobTxSidecar{Blobs: []kzg4844.Blob{blob}, Commitments: []kzg4844.Commitment{commitment}, Proofs: []kzg4844.Proof{proof}},
+			},
+		}
+	)
+	txs := make([]*types.Transaction, len(unsignedTxs))
+	for i, inner := range unsignedTxs {
+		txs[i] = types.MustSignNewTx(key, signer, inner)
+	}
+	header := filled[types.Header]()
+	uncle := filled[types.Header]()
+	block := types.NewBlockWithHeader(header).WithBody(txs, []*types.Header{uncle}).WithWithdrawals([]*types.Withdrawal{filled[types.Withdrawal]()})
+
+	pblock := gethToUtilsBlock(block)
+	if have, want := common.Hash(pblock.Hash()), block.Hash(); have != want {
+		t.Errorf("have plugin block hash %x, want %x", have, want)
+	}
+	have := utilsToGethBlock(pblock)
+	if have.Hash() != block.Hash() {
+		t.Errorf("have block hash %x, want %x", have.Hash(), block.Hash())
+	}
+	if !reflect.DeepEqual(have.Header(), block.Header()) || !reflect.DeepEqual(have.Uncles(), block.Uncles()) {
+		t.Error("block headers changed in round trip")
+	}
+	if !reflect.DeepEqual(have.Withdrawals(), block.Withdrawals()) {
+		t.Error("withdrawals changed in round trip")
+	}
+	for i, tx := range have.Transactions() {
+		want, _ := txs[i].MarshalBinary()
+		enc, _ := tx.MarshalBinary()
+		if tx.Hash() != txs[i].Hash() || string(enc) != string(want) {
+			t.Errorf("transaction %d changed in round trip", i)
+		}
+	}
+	if have.Transactions()[3].BlobTxSidecar() == nil {
+		t.Error("blob sidecar lost in round trip")
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	gcore "github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/plugins/wrappers"
+	"github.com/holiman/uint256"
+	"github.com/openrelayxyz/plugeth-utils/core"
+	ptypes "github.com/openrelayxyz/plugeth-utils/restricted/types"
+)
+
+// SystemStateDB is the state handed to the Finalize and FinalizeAndAssemble
+// methods of plugin consensus engines. It extends core.RWStateDB with the
+// operations engines need to apply rewards, mint and burn, and execute
+// consensus mandated system calls. Plugins can't import this package, they
+// declare the subset of methods they use and type assert the state to it.
+//
+// Every mutation is journaled, so it can be undone with RevertToSnapshot, and
+// is part of the state root of the block.
+type SystemStateDB interface {
+	core.RWStateDB
+
+	SubBalance(addr core.Address, amount *big.Int)
+	SetNonce(addr core.Address, nonce uint64)
+	SetCode(addr core.Address, code []byte)
+	SetState(addr core.Address, key core.Hash, value core.Hash)
+
+	Snapshot() int
+	RevertToSnapshot(id int)
+
+	// SystemCall executes a call from the given address without charging it
+	// for gas, like the EIP-4788 beacon root update, and returns its receipt
+	// and output. The receipt carries the status, gas used and logs of the
+	// call, identified by a pseudo transaction hash and indexed after the
+	// transactions of the block. State changes of a failed call are reverted,
+	// the error being returned alongside the receipt.
+	//
+	// Like the system calls of geth itself, the receipt is not part of the
+	// receipts or receipt root of the block, it is up to the engine to keep it.
+	SystemCall(from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) (*ptypes.Receipt, []byte, error)
+}
+
+// systemCallHash returns the pseudo transaction hash identifying the receipt
+// and logs of the i-th system call of a block, keeping them apart from those
+// of the transactions.
+func systemCallHash(number uint64, i int) common.Hash {
+	var enc [16]byte
+	binary.BigEndian.PutUint64(enc[:8], number)
+	binary.BigEndian.PutUint64(enc[8:], uint64(i))
+	return crypto.Keccak256Hash([]byte("plugeth-system-call"), enc[:])
+}
+
+// chainContext provides the EVM with the chain a plugin engine runs on.
+type chainContext struct {
+	consensus.ChainHeaderReader
+	engine consensus.Engine
+}
+
+func (c *chainContext) Engine() consensus.Engine {
+	return c.engine
+}
+
+var _ SystemStateDB = (*engineStateDB)(nil)
+
+type engineStateDB struct {
+	*wrappers.WrappedStateDB
+	s      *state.StateDB
+	chain  *chainContext
+	header *types.Header
+
+	txs     int    // number of transactions in the block, system calls are indexed after them
+	calls   int    // number of system calls executed so far
+	gasUsed uint64 // cumulative gas used by the block and the system calls so far
+}
+
+func newEngineStateDB(chain consensus.ChainHeaderReader, engine consensus.Engine, header *types.Header, s *state.StateDB, txs int) *engineStateDB {
+	return &engineStateDB{
+		WrappedStateDB: wrappers.NewWrappedStateDB(s),
+		s:              s,
+		chain:          &chainContext{chain, engine},
+		header:         header,
+		txs:            txs,
+		gasUsed:        header.GasUsed,
+	}
+}
+
+func (e *engineStateDB) SubBalance(addr core.Address, amount *big.Int) {
+	e.s.SubBalance(common.Address(addr), uint256.MustFromBig(amount))
+}
+
+func (e *engineStateDB) SetNonce(addr core.Address, nonce uint64) {
+	e.s.SetNonce(common.Address(addr), nonce)
+}
+
+func (e *engineStateDB) SetCode(addr core.Address, code []byte) {
+	e.s.SetCode(common.Address(addr), code)
+}
+
+func (e *engineStateDB) SetState(addr core.Address, key core.Hash, value core.Hash) {
+	e.s.SetState(common.Address(addr), common.Hash(key), common.Hash(value))
+}
+
+func (e *engineStateDB) Snapshot() int {
+	return e.s.Snapshot()
+}
+
+func (e *engineStateDB) RevertToSnapshot(id int) {
+	e.s.RevertToSnapshot(id)
+}
+
+func (e *engineStateDB) SystemCall(from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) (*ptypes.Receipt, []byte, error) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	var (
+		hash   = systemCallHash(e.header.Number.Uint64(), e.calls)
+		index  = e.txs + e.calls
+		caller = common.Address(from)
+		callee = common.Address(to)
+		msg    = &gcore.Message{
+			From:      caller,
+			To:        &callee,
+			Value:     value,
+			GasLimit:  gas,
+			GasPrice:  common.Big0,
+			GasFeeCap: common.Big0,
+			GasTipCap: common.Big0,
+			Data:      input,
+		}
+		blockContext = gcore.NewEVMBlockContext(e.header, e.chain, &e.header.Coinbase)
+		vmenv        = vm.NewEVM(blockContext, gcore.NewEVMTxContext(msg), e.s, e.chain.Config(), vm.Config{NoBaseFee: true})
+	)
+	e.s.SetTxContext(hash, index)
+	e.calls++
+	e.s.AddAddressToAccessList(callee)
+	ret, leftOver, err := vmenv.Call(vm.AccountRef(caller), callee, input, gas, uint256.MustFromBig(value))
+	e.s.Finalise(true)
+
+	e.gasUsed += gas - leftOver
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: e.gasUsed,
+		TxHash:            hash,
+		GasUsed:           gas - leftOver,
+		EffectiveGasPrice: new(big.Int),
+		BlockNumber:       new(big.Int).Set(e.header.Number),
+		TransactionIndex:  uint(index),
+	}
+	if err != nil {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	receipt.Logs = e.s.GetLogs(hash, e.header.Number.Uint64(), common.Hash{})
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return gethToUtilsReceipts([]*types.Receipt{receipt})[0], ret, err
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/openrelayxyz/plugeth-utils/core"
+	pconsensus "github.com/openrelayxyz/plugeth-utils/restricted/consensus"
+	ptypes "github.com/openrelayxyz/plugeth-utils/restricted/types"
+)
+
+type testHeaderReader struct {
+	consensus.ChainHeaderReader
+}
+
+func (r *testHeaderReader) Config() *params.ChainConfig { return params.TestChainConfig }
+
+// finalizeEngine is a plugin engine minting a reward and executing a system
+// call in Finalize. Only the methods used by the test are implemented.
+type finalizeEngine struct {
+	pconsensus.Engine
+	receipt *ptypes.Receipt
+	err     error
+}
+
+func (e *finalizeEngine) Finalize(chain pconsensus.ChainHeaderReader, header *ptypes.Header, state core.RWStateDB, txs []*ptypes.Transaction, uncles []*ptypes.Header, withdrawals []*ptypes.Withdrawal) {
+	sdb, ok := state.(interface {
+		SystemCall(from core.Address, to core.Address, input []byte, gas uint64, value *big.Int) (*ptypes.Receipt, []byte, error)
+	})
+	if !ok {
+		e.err = errNoSystemState
+		return
+	}
+	state.AddBalance(header.Coinbase, big.NewInt(params.Ether))
+	e.receipt, _, e.err = sdb.SystemCall(core.Address(params.SystemAddress), core.Address(storer), nil, 100000, nil)
+}
+
+var errNoSystemState = errors.New("state does not support system calls")
+
+var (
+	// storer stores 1 in slot 0 and emits an empty log, reverter reverts.
+	storer   = common.HexToAddress("0x1000")
+	reverter = common.HexToAddress("0x2000")
+)
+
+func newEngineTestState(t *testing.T) *state.StateDB {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.SetCode(storer, []byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}) // PUSH1 1 PUSH1 0 SSTORE PUSH1 0 PUSH1 0 LOG0 STOP
+	statedb.SetCode(reverter, []byte{0x60, 0x00, 0x60, 0x00, 0xfd})                                   // PUSH1 0 PUSH1 0 REVERT
+	return statedb
+}
+
+func TestEngineStateDB(t *testing.T) {
+	statedb := newEngineTestState(t)
+	header := &types.Header{Number: big.NewInt(1), GasUsed: 1000, GasLimit: 30_000_000, Difficulty: big.NewInt(1), BaseFee: big.NewInt(params.InitialBaseFee)}
+	sdb := newEngineStateDB(&testHeaderReader{}, nil, header, statedb, 2)
+
+	// Journaled mutations can be reverted.
+	addr := core.Address{1}
+	sdb.AddBalance(addr, big.NewInt(100))
+	id := sdb.Snapshot()
+	sdb.SubBalance(addr, big.NewInt(40))
+	sdb.SetNonce(addr, 5)
+	sdb.SetState(addr, core.Hash{1}, core.Hash{2})
+	if sdb.GetBalance(addr).Int64() != 60 || sdb.GetNonce(addr) != 5 {
+		t.Fatalf("mutations not applied")
+	}
+	sdb.RevertToSnapshot(id)
+	if sdb.GetBalance(addr).Int64() != 100 || sdb.GetNonce(addr) != 0 || sdb.GetState(addr, core.Hash{1}) != (core.Hash{}) {
+		t.Fatalf("mutations not reverted")
+	}
+
+	receipt, _, err := sdb.SystemCall(core.Address(params.SystemAddress), core.Address(storer), nil, 100000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful || len(receipt.Logs) != 1 || receipt.Logs[0].Address != core.Address(storer) {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+	hash := core.Hash(systemCallHash(1, 0))
+	if receipt.TxHash != hash || receipt.Logs[0].TxHash != hash || receipt.Logs[0].TxIndex != 2 || receipt.TransactionIndex != 2 {
+		t.Errorf("system call not identified by its pseudo hash and index: %+v", receipt)
+	}
+	if receipt.GasUsed == 0 || receipt.CumulativeGasUsed != header.GasUsed+receipt.GasUsed {
+		t.Errorf("unexpected gas accounting %d/%d", receipt.GasUsed, receipt.CumulativeGasUsed)
+	}
+	if sdb.GetState(core.Address(storer), core.Hash{}) != core.BytesToHash([]byte{1}) {
+		t.Errorf("system call not executed")
+	}
+	if sdb.GetBalance(core.Address(params.SystemAddress)).Sign() != 0 {
+		t.Errorf("system call was charged for gas")
+	}
+	// The state keeps the logs of system calls apart from those of the transactions.
+	if logs := statedb.GetLogs(systemCallHash(1, 0), 1, common.Hash{}); len(logs) != 1 || logs[0].TxIndex != 2 {
+		t.Errorf("system call logs not recorded under their pseudo hash: %v", logs)
+	}
+
+	receipt, _, err = sdb.SystemCall(core.Address(params.SystemAddress), core.Address(reverter), nil, 100000, nil)
+	if err == nil || receipt.Status != types.ReceiptStatusFailed || len(receipt.Logs) != 0 || receipt.TransactionIndex != 3 {
+		t.Fatalf("unexpected result of reverted call %+v: %v", receipt, err)
+	}
+}
+
+func TestFinalizeSystemState(t *testing.T) {
+	statedb := newEngineTestState(t)
+	header := &types.Header{Number: big.NewInt(1), GasLimit: 30_000_000, Difficulty: big.NewInt(1), Coinbase: common.Address{2}}
+	plugin := &finalizeEngine{}
+	NewWrappedEngine(plugin).Finalize(&testHeaderReader{}, header, statedb, nil, nil, nil)
+	if plugin.err != nil {
+		t.Fatal(plugin.err)
+	}
+	if statedb.GetState(storer, common.Hash{}) != common.BytesToHash([]byte{1}) {
+		t.Errorf("system call not executed")
+	}
+	if r := plugin.receipt; r == nil || len(r.Logs) != 1 || r.Logs[0].Address != core.Address(storer) || r.Logs[0].TxHash != core.Hash(systemCallHash(1, 0)) {
+		t.Errorf("system call receipt does not carry its logs: %+v", r)
+	}
+	if statedb.GetBalance(header.Coinbase).ToBig().Cmp(big.NewInt(params.Ether)) != 0 {
+		t.Errorf("reward not applied")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/holiman/uint256"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/node"
@@ -77,12 +78,93 @@ func (w *WrappedContract) UseGas(gas uint64) (ok bool) {
 	return false
 }
 
+// TracerTxContext describes the transaction a plugin tracer is running
+// against. It is passed JSON encoded to tracers implementing TxContextTracer.
+// Block and transaction fields are zero when tracing a call.
+type TracerTxContext struct {
+	BlockHash     common.Hash     `json:"blockHash"`
+	BlockNumber   *hexutil.Big    `json:"blockNumber"`
+	TxHash        common.Hash     `json:"hash"`
+	TxIndex       hexutil.Uint    `json:"transactionIndex"`
+	From          common.Address  `json:"from"`
+	To            *common.Address `json:"to"`
+	Nonce         hexutil.Uint64  `json:"nonce"`
+	Value         *hexutil.Big    `json:"value"`
+	Gas           hexutil.Uint64  `json:"gas"`
+	GasPrice      *hexutil.Big    `json:"gasPrice"`
+	GasFeeCap     *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	GasTipCap     *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	BlobGasFeeCap *hexutil.Big    `json:"maxFeePerBlobGas,omitempty"`
+	BlobHashes    []common.Hash   `json:"blobVersionedHashes,omitempty"`
+}
+
+// TracerBlockContext is the complete EVM block context, passed JSON encoded
+// to tracers implementing BlockContextTracer.
+type TracerBlockContext struct {
+	Coinbase    common.Address `json:"miner"`
+	GasLimit    hexutil.Uint64 `json:"gasLimit"`
+	BlockNumber *hexutil.Big   `json:"number"`
+	Time        hexutil.Uint64 `json:"timestamp"`
+	Difficulty  *hexutil.Big   `json:"difficulty"`
+	BaseFee     *hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	BlobBaseFee *hexutil.Big   `json:"blobBaseFee,omitempty"`
+	Random      *common.Hash   `json:"prevRandao,omitempty"`
+}
+
+func NewTracerBlockContext(c vm.BlockContext) *TracerBlockContext {
+	return &TracerBlockContext{
+		Coinbase:    c.Coinbase,
+		GasLimit:    hexutil.Uint64(c.GasLimit),
+		BlockNumber: (*hexutil.Big)(c.BlockNumber),
+		Time:        hexutil.Uint64(c.Time),
+		Difficulty:  (*hexutil.Big)(c.Difficulty),
+		BaseFee:     (*hexutil.Big)(c.BaseFee),
+		BlobBaseFee: (*hexutil.Big)(c.BlobBaseFee),
+		Random:      c.Random,
+	}
+}
+
+// TxStartTracer may be implemented by plugin tracers to receive the gas
+// accounting callbacks of the transaction being traced.
+type TxStartTracer interface {
+	CaptureTxStart(gasLimit uint64)
+	CaptureTxEnd(restGas uint64)
+}
+
+// TxContextTracer may be implemented by plugin tracers that want the full
+// context of the transaction being traced. txContext is a JSON encoded
+// TracerTxContext.
+type TxContextTracer interface {
+	CaptureTxContextStart(txContext []byte)
+	CaptureTxContextEnd(txHash core.Hash, gasUsed uint64)
+}
+
+// BlockContextTracer may be implemented by plugin tracers that want the
+// complete block context, it is called once before execution starts.
+// blockContext is a JSON encoded TracerBlockContext.
+type BlockContextTracer interface {
+	CaptureBlockContext(blockContext []byte)
+}
+
 type WrappedTracer struct {
-	r core.TracerResult
+	r        core.TracerResult
+	tx       *TracerTxContext
+	gasLimit uint64
 }
 
 func NewWrappedTracer(r core.TracerResult) *WrappedTracer {
-	return &WrappedTracer{r}
+	return &WrappedTracer{r: r}
+}
+
+// NewWrappedTracerWithContext wraps a plugin tracer, handing the block and
+// transaction context to it if it implements the optional context interfaces.
+func NewWrappedTracerWithContext(r core.TracerResult, block vm.BlockContext, tx *TracerTxContext) *WrappedTracer {
+	if v, ok := r.(BlockContextTracer); ok {
+		if encoded, err := json.Marshal(NewTracerBlockContext(block)); err == nil {
+			v.CaptureBlockContext(encoded)
+		}
+	}
+	return &WrappedTracer{r: r, tx: tx}
 }
 func (w WrappedTracer) CapturePreStart(from common.Address, to *common.Address, input []byte, gas uint64, value *big.Int) {
 	if v, ok := w.r.(core.PreTracer); ok {
@@ -113,9 +195,26 @@ func (w WrappedTracer) GetResult() (json.RawMessage, error) {
 	result, err := json.Marshal(data)
 	return json.RawMessage(result), err
 }
-func (w WrappedTracer) CaptureTxStart (gasLimit uint64) {}
-
-func (w WrappedTracer) CaptureTxEnd (restGas uint64) {}
+func (w *WrappedTracer) CaptureTxStart(gasLimit uint64) {
+	w.gasLimit = gasLimit
+	if v, ok := w.r.(TxStartTracer); ok {
+		v.CaptureTxStart(gasLimit)
+	}
+	if v, ok := w.r.(TxContextTracer); ok && w.tx != nil {
+		if encoded, err := json.Marshal(w.tx); err == nil {
+			v.CaptureTxContextStart(encoded)
+		}
+	}
+}
+
+func (w *WrappedTracer) CaptureTxEnd(restGas uint64) {
+	if v, ok := w.r.(TxStartTracer); ok {
+		v.CaptureTxEnd(restGas)
+	}
+	if v, ok := w.r.(TxContextTracer); ok && w.tx != nil {
+		v.CaptureTxContextEnd(core.Hash(w.tx.TxHash), w.gasLimit-restGas)
+	}
+}
 
 func (w WrappedTracer) Stop(err error) {}
 
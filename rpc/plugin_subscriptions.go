@@ -2,15 +2,81 @@ package rpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/plugins"
 )
 
+const (
+	// defaultPluginSubscriptionBuffer is the number of undelivered events held
+	// for a plugin subscription when the client does not request a size.
+	defaultPluginSubscriptionBuffer = 1024
+
+	// maxPluginSubscriptionBuffer caps the buffer size a client can request.
+	maxPluginSubscriptionBuffer = 65536
+
+	// pluginBackfillSuffix names the optional sibling method used to backfill
+	// a plugin subscription from a historic block.
+	pluginBackfillSuffix = "Backfill"
+)
+
+// pluginSubscriptionRetention is how long a resumable plugin subscription is
+// kept alive, and buffering, after its connection drops.
+var pluginSubscriptionRetention = time.Minute
+
+var (
+	errPluginSubscriptionOverflow = errors.New("subscription buffer overflow")
+	errNoBackfill                 = errors.New("subscription does not support fromBlock")
+	errUnknownResumeToken         = errors.New("unknown or expired resume token")
+	errResumeTokenTooOld          = errors.New("resume token is older than the retained history")
+	errStreamAttached             = errors.New("subscription is already attached to a connection")
+)
+
+// PluginSubscriptionOptions may be passed by clients as an optional trailing
+// argument to any subscription provided by a plugin through a channel returning
+// method. Supplying options switches notifications to the
+// PluginSubscriptionResult envelope, which carries the resume token.
+type PluginSubscriptionOptions struct {
+	// FromBlock asks the plugin to backfill events starting at this block
+	// before switching to live events. The plugin service must provide a
+	// <Method>Backfill method for this to be accepted.
+	FromBlock *hexutil.Uint64 `json:"fromBlock"`
+	// ResumeToken reattaches to a subscription whose connection dropped, and
+	// replays every event after the one the token was delivered with.
+	ResumeToken string `json:"resumeToken"`
+	// BufferSize is the number of undelivered events held for a slow client.
+	BufferSize int `json:"bufferSize"`
+	// Policy decides what happens when the buffer is full: "drop" discards
+	// the oldest undelivered event, "close" ends the subscription.
+	Policy string `json:"policy"`
+}
+
+// PluginSubscriptionResult is the notification payload for plugin
+// subscriptions created with PluginSubscriptionOptions.
+type PluginSubscriptionResult struct {
+	ResumeToken string      `json:"resumeToken"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+var (
+	pluginSubscriptionOptionsType = reflect.TypeOf((*PluginSubscriptionOptions)(nil))
+	// Subscriptions are returned by pointer so they marshal as their ID.
+	pluginSubscriptionType = reflect.TypeOf((*Subscription)(nil))
+)
+
 // Is t context.Context or *context.Context?
 func isContextType(t reflect.Type) bool {
 	for t.Kind() == reflect.Ptr {
-			t = t.Elem()
+		t = t.Elem()
 	}
 	return t == contextType
 }
@@ -37,7 +103,25 @@ func isChanPubsub(methodType reflect.Type) bool {
 		isErrorType(methodType.Out(1))
 }
 
-func callbackifyChanPubSub(receiver, fn reflect.Value) *callback {
+// isBackfillFor reports whether backfill has the signature of a backfill
+// method for the subscription method live, which is the signature of live with
+// a uint64 starting block inserted after the context.
+func isBackfillFor(backfill, live reflect.Type) bool {
+	if !isChanPubsub(backfill) || backfill.NumIn() != live.NumIn()+1 {
+		return false
+	}
+	if backfill.In(2).Kind() != reflect.Uint64 {
+		return false
+	}
+	for i := 2; i < live.NumIn(); i++ {
+		if backfill.In(i+1) != live.In(i) {
+			return false
+		}
+	}
+	return backfill.Out(0) == live.Out(0)
+}
+
+func callbackifyChanPubSub(receiver, fn, backfill reflect.Value) *callback {
 	c := &callback{rcvr: receiver, errPos: 1, isSubscribe: true}
 	fntype := fn.Type()
 	// Skip receiver and context.Context parameter (if present).
@@ -49,84 +133,443 @@ func callbackifyChanPubSub(receiver, fn reflect.Value) *callback {
 		c.hasCtx = true
 		firstArg++
 	}
-	// Add all remaining parameters.
-	c.argTypes = make([]reflect.Type, fntype.NumIn()-firstArg)
+	// Add all remaining parameters, followed by the optional subscription
+	// options which are consumed here rather than by the plugin.
+	c.argTypes = make([]reflect.Type, fntype.NumIn()-firstArg, fntype.NumIn()-firstArg+1)
 	for i := firstArg; i < fntype.NumIn(); i++ {
 		c.argTypes[i-firstArg] = fntype.In(i)
 	}
+	c.argTypes = append(c.argTypes, pluginSubscriptionOptionsType)
+
+	retFnType := reflect.FuncOf(append([]reflect.Type{receiver.Type(), contextType}, c.argTypes...), []reflect.Type{pluginSubscriptionType, errorType}, false)
+
+	fail := func(err error) []reflect.Value {
+		return []reflect.Value{reflect.Zero(pluginSubscriptionType), reflect.ValueOf(&err).Elem()}
+	}
 
-	retFnType := reflect.FuncOf(append([]reflect.Type{receiver.Type(), contextType}, c.argTypes...), []reflect.Type{subscriptionType, errorType}, false)
-
-// // What follows uses reflection to construct a dynamically typed function equivalent to:
-// func(receiver <T>, cctx context.Context, args ...<T>) (rpc.Subscription, error) {
-// 	notifier, supported := NotifierFromContext(cctx)
-// 	if !supported { return Subscription{}, ErrNotificationsUnsupported}
-// 	ctx, cancel := context.WithCancel(context.Background())
-// 	ch, err := fn()
-// 	if err != nil { return Subscription{}, err }
-// 	rpcSub := notifier.CreateSubscription()
-// 	go func() {
-// 		select {
-// 		case v, ok := <- ch:
-// 			if !ok { return }
-// 			notifier.Notify(rpcSub.ID, v)
-// 		case <-rpcSub.Err():
-// 			cancel()
-// 			return
-// 		case <-notifier.Closed():
-// 			cancel()
-// 			return
-// 		}
-// 	}()
-// 	return rpcSub, nil
-// }
-//
-
-	c.fn = reflect.MakeFunc(retFnType, func(args []reflect.Value) ([]reflect.Value) {
+	// What follows uses reflection to construct a dynamically typed function
+	// which calls the plugin method, then hands its channel to a pluginStream
+	// that buffers events and delivers them to the subscriber.
+	c.fn = reflect.MakeFunc(retFnType, func(args []reflect.Value) []reflect.Value {
 		notifier, supported := NotifierFromContext(args[1].Interface().(context.Context))
 		if !supported {
-			return []reflect.Value{reflect.Zero(subscriptionType), reflect.ValueOf(ErrNotificationsUnsupported)}
+			return []reflect.Value{reflect.Zero(pluginSubscriptionType), reflect.ValueOf(ErrNotificationsUnsupported)}
+		}
+		opts, _ := args[len(args)-1].Interface().(*PluginSubscriptionOptions)
+		args = args[:len(args)-1]
+
+		if opts != nil && opts.ResumeToken != "" {
+			rpcSub, err := resumePluginStream(opts.ResumeToken, notifier)
+			if err != nil {
+				return fail(err)
+			}
+			return []reflect.Value{reflect.ValueOf(rpcSub), reflect.Zero(errorType)}
 		}
-		ctx, cancel := context.WithCancel(context.Background())
-		args[1] = reflect.ValueOf(ctx)
+		if opts != nil && opts.FromBlock != nil && !backfill.IsValid() {
+			return fail(errNoBackfill)
+		}
+		stream, err := newPluginStream(opts)
+		if err != nil {
+			return fail(err)
+		}
+		// Geth's provided context is done once we've returned the subscription id.
+		// This new context will cancel when the subscription terminates.
+		args[1] = reflect.ValueOf(stream.ctx)
 		out := fn.Call(args)
 		if !out[1].IsNil() {
 			// This amounts to: if err != nil { return nil, err }
-			return []reflect.Value{reflect.Zero(subscriptionType), out[1]}
+			stream.terminate()
+			return []reflect.Value{reflect.Zero(pluginSubscriptionType), out[1]}
+		}
+		var history reflect.Value
+		if opts != nil && opts.FromBlock != nil {
+			backfillArgs := append([]reflect.Value{args[0], args[1], reflect.ValueOf(uint64(*opts.FromBlock))}, args[2:]...)
+			bout := backfill.Call(backfillArgs)
+			if !bout[1].IsNil() {
+				stream.terminate()
+				return []reflect.Value{reflect.Zero(pluginSubscriptionType), bout[1]}
+			}
+			history = bout[0]
 		}
-		// Geth's provided context is done once we've returned the subscription id.
-		// This new context will cancel when the notifier closes.
-
 		rpcSub := notifier.CreateSubscription()
+		stream.attach(notifier, rpcSub)
+		stream.start(history, out[0])
+		return []reflect.Value{reflect.ValueOf(rpcSub), reflect.Zero(errorType)}
+	})
+	return c
+}
+
+// pluginEvent is a plugin subscription item with its position in the stream.
+type pluginEvent struct {
+	seq   uint64
+	value interface{}
+}
+
+// pluginStreams tracks resumable plugin subscriptions by stream id.
+var pluginStreams = struct {
+	sync.Mutex
+	m map[string]*pluginStream
+}{m: make(map[string]*pluginStream)}
+
+// pluginStream sits between a plugin's subscription channel and the RPC
+// subscriber. Events are read from the plugin as fast as it produces them and
+// held in a bounded buffer, so a slow client never blocks the plugin. A stream
+// created with options outlives its connection for pluginSubscriptionRetention,
+// and can be reattached with a resume token.
+type pluginStream struct {
+	id        string
+	size      int
+	overflow  bool // close rather than drop when the buffer is full
+	resumable bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	seq         uint64        // sequence number of the last queued event
+	trimmed     uint64        // sequence number of the last event evicted from sent
+	sent        []pluginEvent // recently delivered events, retained for replay
+	pending     []pluginEvent // events waiting for delivery
+	held        []interface{} // live events waiting for the backfill to finish
+	backfilling bool
+	sourceDone  bool
+	failed      error
+	dropped     int
+	notifier    *Notifier
+	sub         *Subscription
+	detached    time.Time
+
+	wake  chan struct{} // signalled when there is work for the writer
+	space chan struct{} // signalled when the writer frees buffer space
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newPluginStream(opts *PluginSubscriptionOptions) (*pluginStream, error) {
+	s := &pluginStream{
+		id:    string(NewID()),
+		size:  defaultPluginSubscriptionBuffer,
+		wake:  make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	if opts != nil {
+		s.resumable = true
+		if opts.BufferSize < 0 || opts.BufferSize > maxPluginSubscriptionBuffer {
+			return nil, fmt.Errorf("bufferSize must be between 1 and %d", maxPluginSubscriptionBuffer)
+		}
+		if opts.BufferSize > 0 {
+			s.size = opts.BufferSize
+		}
+		switch opts.Policy {
+		case "", "drop":
+		case "close":
+			s.overflow = true
+		default:
+			return nil, fmt.Errorf("unknown subscription policy %q", opts.Policy)
+		}
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if s.resumable {
+		pluginStreams.Lock()
+		pluginStreams.m[s.id] = s
+		pluginStreams.Unlock()
+	}
+	return s, nil
+}
+
+// resumePluginStream reattaches the stream named by token to a new
+// subscription on notifier.
+func resumePluginStream(token string, notifier *Notifier) (*Subscription, error) {
+	id, seqHex, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, errUnknownResumeToken
+	}
+	seq, err := strconv.ParseUint(seqHex, 16, 64)
+	if err != nil {
+		return nil, errUnknownResumeToken
+	}
+	pluginStreams.Lock()
+	s, ok := pluginStreams.m[id]
+	pluginStreams.Unlock()
+	if !ok {
+		return nil, errUnknownResumeToken
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notifier != nil {
+		return nil, errStreamAttached
+	}
+	if seq < s.trimmed {
+		return nil, errResumeTokenTooOld
+	}
+	sub := notifier.CreateSubscription()
+	// Everything delivered after the token goes back in front of the queue.
+	var replay []pluginEvent
+	for _, ev := range s.sent {
+		if ev.seq > seq {
+			replay = append(replay, ev)
+		}
+	}
+	s.pending = append(replay, s.pending...)
+	s.sent = s.sent[:len(s.sent)-len(replay)]
+	s.notifier, s.sub = notifier, sub
+	s.signal(s.wake)
+	return sub, nil
+}
+
+func (s *pluginStream) token(seq uint64) string {
+	return fmt.Sprintf("%s:%x", s.id, seq)
+}
+
+func (s *pluginStream) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (s *pluginStream) attach(notifier *Notifier, sub *Subscription) {
+	s.mu.Lock()
+	s.notifier, s.sub = notifier, sub
+	s.mu.Unlock()
+}
+
+// detach forgets the connection of notifier. Streams without a resume token
+// have no use once their connection is gone and are terminated instead.
+func (s *pluginStream) detach(notifier *Notifier) {
+	s.mu.Lock()
+	if s.notifier == notifier {
+		s.notifier, s.sub = nil, nil
+		s.detached = time.Now()
+	}
+	s.mu.Unlock()
+	if !s.resumable {
+		s.terminate()
+	}
+}
+
+// terminate stops the plugin side of the stream and forgets it.
+func (s *pluginStream) terminate() {
+	s.once.Do(func() {
+		s.cancel()
+		close(s.done)
+		pluginStreams.Lock()
+		delete(pluginStreams.m, s.id)
+		pluginStreams.Unlock()
+		s.mu.Lock()
+		if s.dropped > 0 {
+			log.Warn("Plugin subscription dropped events for a slow subscriber", "id", s.id, "dropped", s.dropped)
+		}
+		s.mu.Unlock()
+	})
+}
+
+// start consumes the plugin channels, the optional history channel first.
+func (s *pluginStream) start(history, live reflect.Value) {
+	var wg sync.WaitGroup
+	if history.IsValid() {
+		s.backfilling = true
+		wg.Add(1)
 		go func() {
-			defer log.Info("Plugin subscription goroutine closed")
-			selectCases := []reflect.SelectCase{
-				{Dir: reflect.SelectRecv, Chan: out[0]},
-				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(rpcSub.Err())},
-				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(notifier.Closed())},
-			}
-			for {
-				chosen, val, recvOK := reflect.Select(selectCases)
-				switch chosen {
-				case 0: // val, ok := <-ch
-					if !recvOK {
-						return
-					}
-					if err := notifier.Notify(rpcSub.ID, val.Interface()); err != nil {
-						log.Warn("Subscription notification failed", "id", rpcSub.ID, "err", err)
-					}
-				case 1:
-					cancel()
-					return
-				case 2:
-					cancel()
-					return
-				}
+			defer wg.Done()
+			s.consume(history, s.pushHistory)
+			s.mu.Lock()
+			for _, v := range s.held {
+				s.queue(v)
 			}
+			s.held, s.backfilling = nil, false
+			s.mu.Unlock()
 		}()
-		return []reflect.Value{reflect.ValueOf(*rpcSub), reflect.Zero(errorType)}
-	})
-	return c
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.consume(live, s.pushLive)
+	}()
+	go func() {
+		wg.Wait()
+		s.mu.Lock()
+		s.sourceDone = true
+		s.mu.Unlock()
+		s.signal(s.wake)
+	}()
+	go s.deliver()
+}
+
+func (s *pluginStream) consume(ch reflect.Value, push func(interface{}) bool) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.ctx.Done())},
+	}
+	for {
+		chosen, val, recvOK := reflect.Select(cases)
+		if chosen == 1 || !recvOK {
+			return
+		}
+		if !push(val.Interface()) {
+			return
+		}
+	}
+}
+
+// pushHistory queues a backfilled event, waiting for buffer space rather than
+// dropping history the client explicitly asked for.
+func (s *pluginStream) pushHistory(v interface{}) bool {
+	for {
+		s.mu.Lock()
+		if len(s.pending) < s.size {
+			s.queue(v)
+			s.mu.Unlock()
+			return true
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.space:
+		case <-s.done:
+			return false
+		}
+	}
+}
+
+// pushLive queues a live event, applying the overflow policy if the client
+// has fallen too far behind.
+func (s *pluginStream) pushLive(v interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backfilling {
+		if len(s.held) >= s.size && !s.overflowLocked() {
+			return false
+		}
+		if len(s.held) >= s.size {
+			s.held = s.held[1:]
+		}
+		s.held = append(s.held, v)
+		return true
+	}
+	if len(s.pending) >= s.size {
+		if !s.overflowLocked() {
+			return false
+		}
+		s.pending = s.pending[1:]
+	}
+	s.queue(v)
+	return true
+}
+
+// overflowLocked applies the overflow policy to a full buffer, reporting
+// whether the oldest event may be dropped to make room.
+func (s *pluginStream) overflowLocked() bool {
+	if s.overflow {
+		s.failed = errPluginSubscriptionOverflow
+		s.signal(s.wake)
+		return false
+	}
+	s.dropped++
+	log.Debug("Plugin subscription buffer full, dropping event", "id", s.id)
+	return true
+}
+
+func (s *pluginStream) queue(v interface{}) {
+	s.seq++
+	s.pending = append(s.pending, pluginEvent{seq: s.seq, value: v})
+	s.signal(s.wake)
+}
+
+func (s *pluginStream) payload(ev pluginEvent) interface{} {
+	if !s.resumable {
+		return ev.value
+	}
+	return &PluginSubscriptionResult{ResumeToken: s.token(ev.seq), Result: ev.value}
+}
+
+// next pops the next deliverable event, moving it to the replay history.
+func (s *pluginStream) next() (*Notifier, *Subscription, *pluginEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notifier == nil || len(s.pending) == 0 {
+		return s.notifier, s.sub, nil
+	}
+	ev := s.pending[0]
+	s.pending = s.pending[1:]
+	if s.resumable {
+		s.sent = append(s.sent, ev)
+		if len(s.sent) > s.size {
+			s.trimmed = s.sent[0].seq
+			s.sent = s.sent[1:]
+		}
+	}
+	s.signal(s.space)
+	return s.notifier, s.sub, &ev
+}
+
+// deliver writes queued events to whichever connection the stream is
+// attached to, until the stream terminates.
+func (s *pluginStream) deliver() {
+	defer log.Debug("Plugin subscription goroutine closed", "id", s.id)
+	defer s.terminate()
+	for {
+		notifier, sub, ev := s.next()
+		if ev != nil {
+			if err := notifier.Notify(sub.ID, s.payload(*ev)); err != nil {
+				log.Warn("Subscription notification failed", "id", sub.ID, "err", err)
+				s.detach(notifier)
+			}
+			continue
+		}
+		s.mu.Lock()
+		failed, finished, detached := s.failed, s.sourceDone && len(s.pending) == 0, s.detached
+		s.mu.Unlock()
+		if failed != nil {
+			if notifier != nil && s.resumable {
+				notifier.Notify(sub.ID, &PluginSubscriptionResult{ResumeToken: s.token(s.lastSent()), Error: failed.Error()})
+			}
+			return
+		}
+		if finished && notifier != nil {
+			return
+		}
+		var (
+			unsub  <-chan error
+			closed <-chan interface{}
+			timer  *time.Timer
+			expire <-chan time.Time
+		)
+		if notifier != nil {
+			unsub, closed = sub.Err(), notifier.Closed()
+		} else {
+			timer = time.NewTimer(time.Until(detached.Add(pluginSubscriptionRetention)))
+			expire = timer.C
+		}
+		select {
+		case <-s.wake:
+		case err := <-unsub:
+			// A nil error means the client unsubscribed, anything else
+			// is the connection going away.
+			if err == nil {
+				return
+			}
+			s.detach(notifier)
+		case <-closed:
+			s.detach(notifier)
+		case <-expire:
+			return
+		case <-s.done:
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (s *pluginStream) lastSent() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) == 0 {
+		return s.trimmed
+	}
+	return s.sent[len(s.sent)-1].seq
 }
 
 func RPCSubscription(pl *plugins.PluginLoader) {
@@ -154,7 +597,11 @@ func pluginExtendedCallbacks(callbacks map[string]*callback, receiver reflect.Va
 			continue // method not exported
 		}
 		if isChanPubsub(method.Type) {
-			cb := callbackifyChanPubSub(receiver, method.Func)
+			var backfill reflect.Value
+			if bm, ok := typ.MethodByName(method.Name + pluginBackfillSuffix); ok && isBackfillFor(bm.Type, method.Type) {
+				backfill = bm.Func
+			}
+			cb := callbackifyChanPubSub(receiver, method.Func, backfill)
 			name := formatName(method.Name)
 			callbacks[name] = cb
 		}
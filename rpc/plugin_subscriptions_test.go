@@ -0,0 +1,193 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/plugins"
+)
+
+type pluginSubService struct {
+	feed chan int
+}
+
+func (s *pluginSubService) Count(ctx context.Context, n int) (<-chan int, error) {
+	return countChan(ctx, 0, n), nil
+}
+
+func (s *pluginSubService) CountBackfill(ctx context.Context, from uint64, n int) (<-chan int, error) {
+	return countChan(ctx, 100+int(from), 103), nil
+}
+
+func (s *pluginSubService) Feed(ctx context.Context) (<-chan int, error) {
+	return s.feed, nil
+}
+
+func countChan(ctx context.Context, from, to int) <-chan int {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := from; i < to; i++ {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+type pluginSubEnvelope struct {
+	ResumeToken string `json:"resumeToken"`
+	Result      int    `json:"result"`
+	Error       string `json:"error"`
+}
+
+func newPluginSubServer(t *testing.T, svc *pluginSubService) *Server {
+	done := plugins.HookTester("RPCSubscriptionTest", func() {})
+	defer done()
+	server := NewServer()
+	if err := server.RegisterName("test", svc); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func receiveN[T any](t *testing.T, ch chan T, sub *ClientSubscription, n int) []T {
+	t.Helper()
+	var out []T
+	for len(out) < n {
+		select {
+		case v := <-ch:
+			out = append(out, v)
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed after %d items: %v", len(out), err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %d items", len(out))
+		}
+	}
+	return out
+}
+
+func TestPluginSubscription(t *testing.T) {
+	server := newPluginSubServer(t, &pluginSubService{})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "test", ch, "count", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	for i, v := range receiveN(t, ch, sub, 5) {
+		if v != i {
+			t.Errorf("item %d: have %d, want %d", i, v, i)
+		}
+	}
+}
+
+func TestPluginSubscriptionBackfill(t *testing.T) {
+	server := newPluginSubServer(t, &pluginSubService{})
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	from := hexutil.Uint64(1)
+	ch := make(chan pluginSubEnvelope)
+	sub, err := client.Subscribe(context.Background(), "test", ch, "count", 3, &PluginSubscriptionOptions{FromBlock: &from})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	want := []int{101, 102, 0, 1, 2}
+	for i, v := range receiveN(t, ch, sub, len(want)) {
+		if v.Result != want[i] {
+			t.Errorf("item %d: have %d, want %d", i, v.Result, want[i])
+		}
+		if v.ResumeToken == "" {
+			t.Errorf("item %d: missing resume token", i)
+		}
+	}
+	// Subscriptions without a backfill method must refuse a starting block.
+	if _, err := client.Subscribe(context.Background(), "test", ch, "feed", &PluginSubscriptionOptions{FromBlock: &from}); err == nil {
+		t.Fatal("expected error for fromBlock without backfill support")
+	}
+}
+
+func TestPluginSubscriptionResume(t *testing.T) {
+	svc := &pluginSubService{feed: make(chan int)}
+	server := newPluginSubServer(t, svc)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	ch := make(chan pluginSubEnvelope)
+	sub, err := client.Subscribe(context.Background(), "test", ch, "feed", &PluginSubscriptionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		svc.feed <- i
+	}
+	got := receiveN(t, ch, sub, 3)
+	client.Close()
+
+	// Events produced while the client is away must be buffered.
+	for i := 4; i <= 5; i++ {
+		svc.feed <- i
+	}
+	client = DialInProc(server)
+	defer client.Close()
+	ch = make(chan pluginSubEnvelope)
+	sub, err = client.Subscribe(context.Background(), "test", ch, "feed", &PluginSubscriptionOptions{ResumeToken: got[1].ResumeToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	want := []int{3, 4, 5}
+	for i, v := range receiveN(t, ch, sub, len(want)) {
+		if v.Result != want[i] {
+			t.Errorf("item %d: have %d, want %d", i, v.Result, want[i])
+		}
+	}
+	if _, err := client.Subscribe(context.Background(), "test", ch, "feed", &PluginSubscriptionOptions{ResumeToken: "bogus:1"}); err == nil {
+		t.Fatal("expected error for unknown resume token")
+	}
+}
+
+func TestPluginStreamOverflow(t *testing.T) {
+	s, err := newPluginStream(&PluginSubscriptionOptions{BufferSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.terminate()
+	for i := 0; i < 3; i++ {
+		if !s.pushLive(i) {
+			t.Fatalf("push %d refused with drop policy", i)
+		}
+	}
+	if len(s.pending) != 2 || s.pending[0].value != 1 || s.dropped != 1 {
+		t.Fatalf("unexpected buffer after drop: %v, dropped %d", s.pending, s.dropped)
+	}
+
+	s, err = newPluginStream(&PluginSubscriptionOptions{BufferSize: 2, Policy: "close"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.terminate()
+	s.pushLive(0)
+	s.pushLive(1)
+	if s.pushLive(2) {
+		t.Fatal("push accepted on a full buffer with close policy")
+	}
+	if s.failed != errPluginSubscriptionOverflow {
+		t.Fatalf("have failure %v, want %v", s.failed, errPluginSubscriptionOverflow)
+	}
+	if _, err := newPluginStream(&PluginSubscriptionOptions{Policy: "block"}); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
@@ -286,6 +286,18 @@ func (db *Database) Recoverable(root common.Hash) (bool, error) {
 	return pdb.Recoverable(root), nil
 }
 
+// StateHistoryDiff reconstructs the change made by the block transitioning
+// to the given state root from the state histories. It's only supported by
+// path-based database and will return an error for others.
+// PluGeth injection
+func (db *Database) StateHistoryDiff(root common.Hash) (*pathdb.HistoryDiff, error) {
+	pdb, ok := db.backend.(*pathdb.Database)
+	if !ok {
+		return nil, errors.New("not supported")
+	}
+	return pdb.HistoryDiff(root, trie.NewMerkleLoader(db))
+}
+
 // Disable deactivates the database and invalidates all available state layers
 // as stale to prevent access to the persistent state, which is in the syncing
 // stage.
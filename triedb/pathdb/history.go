@@ -599,6 +599,9 @@ func truncateFromHead(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, nhead
 	if err := batch.Write(); err != nil {
 		return 0, err
 	}
+	// begin PluGeth injection
+	defer rawdb.SetTruncateReason(rawdb.StateFreezerName, rawdb.TruncateReasonStateRollback)()
+	// end PluGeth injection
 	ohead, err = freezer.TruncateHead(nhead)
 	if err != nil {
 		return 0, err
@@ -641,6 +644,9 @@ func truncateFromTail(db ethdb.Batcher, freezer *rawdb.ResettableFreezer, ntail
 	if err := batch.Write(); err != nil {
 		return 0, err
 	}
+	// begin PluGeth injection
+	defer rawdb.SetTruncateReason(rawdb.StateFreezerName, rawdb.TruncateReasonStatePruning)()
+	// end PluGeth injection
 	otail, err = freezer.TruncateTail(ntail)
 	if err != nil {
 		return 0, err
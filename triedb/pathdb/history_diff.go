@@ -0,0 +1,159 @@
+package pathdb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/triestate"
+)
+
+// HistoryValue is a state value before and after a block. Accounts are in
+// the slim RLP encoding, storage slots in the prefix-zero trimmed RLP one, an
+// empty value meaning not present.
+type HistoryValue struct {
+	Prev []byte
+	Post []byte
+}
+
+// HistoryDiff is the change a block made to the state, reconstructed from
+// the state histories. Storage slots are keyed by the hash of the slot.
+type HistoryDiff struct {
+	Block    uint64
+	Accounts map[common.Address]*HistoryValue
+	Storages map[common.Address]map[common.Hash]*HistoryValue
+}
+
+// HistoryDiff reconstructs the change made by the block transitioning to the
+// given state root. The previous values are recorded by the state history of
+// the block, the values after it by the first later history changing them,
+// or the persistent state if none did. The states of the recent blocks, not
+// yet written to histories, are not supported.
+func (db *Database) HistoryDiff(root common.Hash, loader triestate.TrieLoader) (*HistoryDiff, error) {
+	if db.freezer == nil {
+		return nil, errors.New("state histories are not available")
+	}
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil {
+		return nil, fmt.Errorf("state %#x is not known", root)
+	}
+	h, err := readHistory(db.freezer, *id)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.meta.incomplete) > 0 {
+		return nil, fmt.Errorf("state history %d misses the storage changes of %d destructed accounts", *id, len(h.meta.incomplete))
+	}
+	diff := &HistoryDiff{
+		Block:    h.meta.block,
+		Accounts: make(map[common.Address]*HistoryValue),
+		Storages: make(map[common.Address]map[common.Hash]*HistoryValue),
+	}
+	var (
+		accounts = make(map[common.Address]bool) // Accounts whose value after the block is unknown
+		slots    = make(map[common.Address]map[common.Hash]bool)
+	)
+	for addr, prev := range h.accounts {
+		diff.Accounts[addr] = &HistoryValue{Prev: prev}
+		accounts[addr] = true
+	}
+	for addr, storage := range h.storages {
+		diff.Storages[addr] = make(map[common.Hash]*HistoryValue)
+		slots[addr] = make(map[common.Hash]bool)
+		for slot, prev := range storage {
+			diff.Storages[addr][slot] = &HistoryValue{Prev: prev}
+			slots[addr][slot] = true
+		}
+	}
+	// Take the values from the later histories, up to the persistent state,
+	// which moves ahead meanwhile.
+	for scanned := *id; ; {
+		dl := db.tree.bottom()
+		for next := scanned + 1; next <= dl.stateID() && (len(accounts) > 0 || len(slots) > 0); next++ {
+			later, err := readHistory(db.freezer, next)
+			if err != nil {
+				return nil, err
+			}
+			for addr, value := range later.accounts {
+				if accounts[addr] {
+					diff.Accounts[addr].Post = value
+					delete(accounts, addr)
+				}
+			}
+			for addr, storage := range later.storages {
+				for slot, value := range storage {
+					if slots[addr][slot] {
+						diff.Storages[addr][slot].Post = value
+						delete(slots[addr], slot)
+					}
+				}
+				if len(slots[addr]) == 0 {
+					delete(slots, addr)
+				}
+			}
+		}
+		scanned = dl.stateID()
+
+		err := readPersistentValues(dl.rootHash(), loader, diff, accounts, slots)
+		if err == nil {
+			return diff, nil
+		}
+		if !errors.Is(err, errSnapshotStale) {
+			return nil, err
+		}
+	}
+}
+
+// readPersistentValues fills in the values after the block which were not
+// changed since, from the persistent state.
+func readPersistentValues(root common.Hash, loader triestate.TrieLoader, diff *HistoryDiff, accounts map[common.Address]bool, slots map[common.Address]map[common.Hash]bool) error {
+	tr, err := loader.OpenTrie(root)
+	if err != nil {
+		return err
+	}
+	read := func(addr common.Address) (*types.StateAccount, error) {
+		blob, err := tr.Get(crypto.Keccak256(addr.Bytes()))
+		if err != nil || len(blob) == 0 {
+			return nil, err
+		}
+		account := new(types.StateAccount)
+		if err := rlp.DecodeBytes(blob, account); err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+	for addr := range accounts {
+		account, err := read(addr)
+		if err != nil {
+			return err
+		}
+		if account != nil {
+			diff.Accounts[addr].Post = types.SlimAccountRLP(*account)
+		}
+	}
+	for addr, storage := range slots {
+		account, err := read(addr)
+		if err != nil {
+			return err
+		}
+		if account == nil {
+			continue
+		}
+		st, err := loader.OpenStorageTrie(root, crypto.Keccak256Hash(addr.Bytes()), account.Root)
+		if err != nil {
+			return err
+		}
+		for slot := range storage {
+			value, err := st.Get(slot.Bytes())
+			if err != nil {
+				return err
+			}
+			diff.Storages[addr][slot].Post = value
+		}
+	}
+	return nil
+}
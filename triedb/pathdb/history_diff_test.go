@@ -0,0 +1,59 @@
+package pathdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestHistoryDiff(t *testing.T) {
+	tester := newTester(t, 0)
+	defer tester.release()
+
+	var (
+		bottom = tester.bottomIndex()
+		disk   = tester.roots[bottom]
+		loader = newHashLoader(tester.snapAccounts[disk], tester.snapStorages[disk])
+	)
+	if _, err := tester.db.HistoryDiff(tester.roots[bottom+1], loader); err == nil {
+		t.Fatal("expected error for state without history")
+	}
+	for i := 0; i <= bottom; i++ {
+		root, parent := tester.roots[i], types.EmptyRootHash
+		if i > 0 {
+			parent = tester.roots[i-1]
+		}
+		diff, err := tester.db.HistoryDiff(root, loader)
+		if err != nil {
+			t.Fatalf("state %d: failed to reconstruct diff: %v", i, err)
+		}
+		if diff.Block != uint64(i) {
+			t.Errorf("state %d: have block %d", i, diff.Block)
+		}
+		if len(diff.Accounts) == 0 {
+			t.Errorf("state %d: no account changes", i)
+		}
+		for addr, value := range diff.Accounts {
+			addrHash := crypto.Keccak256Hash(addr.Bytes())
+			if !bytes.Equal(value.Prev, tester.snapAccounts[parent][addrHash]) {
+				t.Errorf("state %d: account %x: previous value mismatch", i, addr)
+			}
+			if !bytes.Equal(value.Post, tester.snapAccounts[root][addrHash]) {
+				t.Errorf("state %d: account %x: value mismatch", i, addr)
+			}
+		}
+		for addr, slots := range diff.Storages {
+			addrHash := crypto.Keccak256Hash(addr.Bytes())
+			for slot, value := range slots {
+				if !bytes.Equal(value.Prev, tester.snapStorages[parent][addrHash][slot]) {
+					t.Errorf("state %d: slot %x of %x: previous value mismatch", i, slot, addr)
+				}
+				if !bytes.Equal(value.Post, tester.snapStorages[root][addrHash][slot]) {
+					t.Errorf("state %d: slot %x of %x: value mismatch", i, slot, addr)
+				}
+			}
+		}
+	}
+}